@@ -0,0 +1,77 @@
+package main
+
+import (
+	"compress/gzip"
+	"context"
+	"flag"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+	"github.com/yourusername/diabetes-assistant/internal/config"
+	"github.com/yourusername/diabetes-assistant/internal/services/products"
+	"github.com/yourusername/diabetes-assistant/internal/storage"
+)
+
+// import-products loads an Open Food Facts CSV export into the local product database, e.g.
+//
+//	go run ./cmd/import-products -file en.openfoodfacts.org.products.csv.gz
+func main() {
+	file := flag.String("file", "", "Open Food Facts CSV export (.csv or .csv.gz)")
+	batchSize := flag.Int("batch", 1000, "Products saved per database write")
+	flag.Parse()
+
+	if *file == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	// Load environment variables from .env file
+	if err := godotenv.Load(); err != nil {
+		log.Println("No .env file found or error loading it")
+	}
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+
+	dbStorage, err := storage.NewMongoDBStorage(cfg.MongoURI)
+	if err != nil {
+		log.Fatalf("Failed to connect to MongoDB: %v", err)
+	}
+	defer dbStorage.Close()
+
+	f, err := os.Open(*file)
+	if err != nil {
+		log.Fatalf("Failed to open dump: %v", err)
+	}
+	defer f.Close()
+
+	var reader io.Reader = f
+	if strings.HasSuffix(*file, ".gz") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			log.Fatalf("Failed to open gzip dump: %v", err)
+		}
+		defer gz.Close()
+		reader = gz
+	}
+
+	started := time.Now()
+	log.Printf("Importing products from %s", *file)
+
+	stats, err := products.ImportOpenFoodFacts(context.Background(), reader, *batchSize, dbStorage.SaveProducts)
+	if err != nil {
+		if stats != nil {
+			log.Printf("Imported %d products before the error", stats.Imported)
+		}
+		log.Fatalf("Import failed: %v", err)
+	}
+
+	log.Printf("Done in %s: %d rows, %d products imported, %d skipped",
+		time.Since(started).Round(time.Second), stats.Rows, stats.Imported, stats.Skipped)
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"time"
+
+	"github.com/joho/godotenv"
+	"github.com/yourusername/diabetes-assistant/internal/config"
+	"github.com/yourusername/diabetes-assistant/internal/services/imports"
+	"github.com/yourusername/diabetes-assistant/internal/storage"
+)
+
+// import-readings loads a LibreView or Dexcom Clarity CSV export into a user's readings, e.g.
+//
+//	go run ./cmd/import-readings -user 42 -tz Europe/Moscow -file GlucoseData.csv
+func main() {
+	userID := flag.String("user", "", "User the readings belong to")
+	file := flag.String("file", "", "LibreView or Dexcom Clarity CSV export")
+	tz := flag.String("tz", "UTC", "IANA time zone the device clock was set to")
+	flag.Parse()
+
+	if *userID == "" || *file == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	loc, err := time.LoadLocation(*tz)
+	if err != nil {
+		log.Fatalf("Invalid time zone: %v", err)
+	}
+
+	// Load environment variables from .env file
+	if err := godotenv.Load(); err != nil {
+		log.Println("No .env file found or error loading it")
+	}
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+
+	dbStorage, err := storage.NewMongoDBStorage(cfg.MongoURI)
+	if err != nil {
+		log.Fatalf("Failed to connect to MongoDB: %v", err)
+	}
+	defer dbStorage.Close()
+
+	f, err := os.Open(*file)
+	if err != nil {
+		log.Fatalf("Failed to open export: %v", err)
+	}
+	defer f.Close()
+
+	started := time.Now()
+	log.Printf("Importing readings from %s", *file)
+
+	stats, err := imports.NewService(dbStorage).Import(context.Background(), *userID, f, loc)
+	if err != nil {
+		if stats != nil {
+			log.Printf("Imported %d readings before the error", stats.Imported)
+		}
+		log.Fatalf("Import failed: %v", err)
+	}
+
+	for _, rowErr := range stats.Errors {
+		log.Printf("Line %d: %s", rowErr.Line, rowErr.Message)
+	}
+	log.Printf("Done in %s: %s export, %d rows, %d readings imported, %d skipped (%d duplicates), %d errors",
+		time.Since(started).Round(time.Second), stats.Format, stats.Rows, stats.Imported, stats.Skipped, stats.Duplicates, stats.Errored)
+}
@@ -7,17 +7,32 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
-	"path/filepath"
 	"strings"
 	"syscall"
 	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/joho/godotenv"
+	"github.com/yourusername/diabetes-assistant/internal/blobs"
 	"github.com/yourusername/diabetes-assistant/internal/config"
 	"github.com/yourusername/diabetes-assistant/internal/handlers"
+	"github.com/yourusername/diabetes-assistant/internal/models"
 	"github.com/yourusername/diabetes-assistant/internal/services/ai"
+	"github.com/yourusername/diabetes-assistant/internal/services/alerts"
+	"github.com/yourusername/diabetes-assistant/internal/services/analysis"
+	"github.com/yourusername/diabetes-assistant/internal/services/chat"
+	"github.com/yourusername/diabetes-assistant/internal/services/export"
+	"github.com/yourusername/diabetes-assistant/internal/services/hypo"
+	"github.com/yourusername/diabetes-assistant/internal/services/imports"
+	"github.com/yourusername/diabetes-assistant/internal/services/jobs"
 	"github.com/yourusername/diabetes-assistant/internal/services/libre"
+	"github.com/yourusername/diabetes-assistant/internal/services/nightscout"
+	"github.com/yourusername/diabetes-assistant/internal/services/notify"
+	"github.com/yourusername/diabetes-assistant/internal/services/prediction"
+	"github.com/yourusername/diabetes-assistant/internal/services/reminders"
+	"github.com/yourusername/diabetes-assistant/internal/services/reports"
+	"github.com/yourusername/diabetes-assistant/internal/services/uploads"
+	"github.com/yourusername/diabetes-assistant/internal/services/usage"
 	"github.com/yourusername/diabetes-assistant/internal/storage"
 )
 
@@ -33,16 +48,25 @@ func main() {
 		log.Fatalf("Failed to load config: %v", err)
 	}
 
-	// Ensure uploads directory exists
-	uploadsDir := "./uploads"
-	if err := os.MkdirAll(uploadsDir, 0755); err != nil {
-		log.Fatalf("Failed to create uploads directory: %v", err)
+	// Initialize photo storage
+	blobStore, err := blobs.NewStore(cfg.BlobStore, cfg.UploadsDir, blobs.S3Config{
+		Endpoint:  cfg.S3Endpoint,
+		Bucket:    cfg.S3Bucket,
+		Region:    cfg.S3Region,
+		AccessKey: cfg.S3AccessKey,
+		SecretKey: cfg.S3SecretKey,
+	})
+	if err != nil {
+		log.Fatalf("Failed to initialize photo storage: %v", err)
 	}
+	log.Printf("Storing photos in %s blob store", cfg.BlobStore)
 
-	// Convert to absolute path
-	uploadsDir, err = filepath.Abs(uploadsDir)
+	if cfg.PhotoURLSecret == "" {
+		log.Println("PHOTO_URL_SECRET is not set: photo links will stop working after a restart")
+	}
+	uploadStore, err := uploads.NewStore(blobStore, cfg.UploadMaxDimension, cfg.PhotoURLSecret)
 	if err != nil {
-		log.Fatalf("Failed to get absolute path: %v", err)
+		log.Fatalf("Failed to initialize uploads: %v", err)
 	}
 
 	// Initialize AI service
@@ -106,8 +130,84 @@ func main() {
 		defer mongoDBStorage.Close()
 	}
 
+	// Reuse analysis results for repeated uploads of the same photo
+	aiService.SetCache(dbStorage, cfg.AnalysisCacheTTL)
+
+	// Record every AI call for cost reporting and daily quotas
+	aiService.SetUsageRecorder(dbStorage)
+	usageService := usage.NewService(dbStorage, cfg.AIDailyQuota)
+
+	// Start the analysis workers, resuming jobs left over from the last run
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
+	defer stopJobs()
+	analysisService := analysis.NewService(dbStorage, aiService, uploadStore, usageService)
+	jobQueue := jobs.NewQueue(dbStorage, analysisService, cfg.AnalysisWorkers)
+	if err := jobQueue.Start(jobsCtx); err != nil {
+		log.Fatalf("Failed to start analysis jobs: %v", err)
+	}
+
+	// Deliver alerts and reminders to the users' webhooks, email and Telegram chats
+	notifyService := notify.NewService(dbStorage, cfg.NotifyMaxAttempts)
+	notifyService.Register(models.ChannelWebhook, notify.NewWebhookNotifier())
+	if cfg.SMTPHost != "" {
+		notifyService.Register(models.ChannelEmail, notify.NewEmailNotifier(notify.SMTPConfig{
+			Host:     cfg.SMTPHost,
+			Port:     cfg.SMTPPort,
+			Username: cfg.SMTPUsername,
+			Password: cfg.SMTPPassword,
+			From:     cfg.SMTPFrom,
+		}))
+	}
+	if cfg.TelegramBotToken != "" {
+		notifyService.Register(models.ChannelTelegram, notify.NewTelegramNotifier(cfg.TelegramAPIURL, cfg.TelegramBotToken))
+	}
+	notifyCtx, stopNotify := context.WithCancel(context.Background())
+	defer stopNotify()
+	go notifyService.Run(notifyCtx, time.Minute)
+
+	// Deliver reminders when they are due, picking up the ones left pending by the last run.
+	// Logged meals schedule a glucose check.
+	reminderService := reminders.NewService(dbStorage)
+	reminderService.SetDeliverFunc(notifyService.Reminder)
+	analysisService.SetMealFunc(reminderService.MealLogged)
+	remindersCtx, stopReminders := context.WithCancel(context.Background())
+	defer stopReminders()
+	go reminderService.Run(remindersCtx, time.Minute)
+
 	// Create API handler
-	apiHandler := handlers.NewAPIHandler(dbStorage, aiService, libreService, uploadsDir)
+	// Suggest treatments for lows and remind users to recheck
+	hypoService := hypo.NewService(dbStorage)
+	hypoService.SetRecheckFunc(reminderService.Recheck)
+
+	// Raise alerts on new readings and when sensor readings stop arriving
+	alertService := alerts.NewService(dbStorage)
+	alertService.SetNotifyFunc(notifyService.Alert)
+	alertsCtx, stopAlerts := context.WithCancel(context.Background())
+	defer stopAlerts()
+	go alertService.Run(alertsCtx, time.Minute)
+
+	apiHandler := handlers.NewAPIHandler(dbStorage, jobQueue, libreService, uploadStore, usageService, hypoService, alertService, reminderService, aiService.Locales())
+	jobHandler := handlers.NewJobHandler(jobQueue, uploadStore)
+	chatHandler := handlers.NewChatHandler(chat.NewService(dbStorage, aiService))
+	adminHandler := handlers.NewAdminHandler(usageService, cfg.AdminToken)
+	predictionHandler := handlers.NewPredictionHandler(prediction.NewService(dbStorage))
+	hypoHandler := handlers.NewHypoHandler(hypoService, dbStorage)
+	alertHandler := handlers.NewAlertHandler(alertService, dbStorage)
+	notificationHandler := handlers.NewNotificationHandler(notifyService, dbStorage)
+	reminderHandler := handlers.NewReminderHandler(reminderService, dbStorage)
+	exportHandler := handlers.NewExportHandler(export.NewService(dbStorage))
+	importHandler := handlers.NewImportHandler(imports.NewService(dbStorage))
+	reportHandler := handlers.NewReportHandler(reports.NewService(dbStorage))
+	telegramHandler := handlers.NewTelegramHandler(dbStorage, cfg.TelegramBotName)
+	nightscoutHandler := handlers.NewNightscoutHandler(nightscout.NewService(dbStorage, hypoService, alertService, reminderService))
+
+	// Delete photos that never made it into a meal record
+	janitorCtx, stopJanitor := context.WithCancel(context.Background())
+	defer stopJanitor()
+	if cfg.UploadRetentionDays > 0 {
+		janitor := uploads.NewJanitor(uploadStore, dbStorage, time.Duration(cfg.UploadRetentionDays)*24*time.Hour)
+		go janitor.Run(janitorCtx, time.Hour)
+	}
 
 	// Create router
 	router := mux.NewRouter()
@@ -125,7 +225,7 @@ func main() {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			w.Header().Set("Access-Control-Allow-Origin", "*")
 			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, api-secret")
 
 			if r.Method == "OPTIONS" {
 				w.WriteHeader(http.StatusOK)
@@ -164,7 +264,51 @@ func main() {
 	api.HandleFunc("/bloodsugar", apiHandler.SaveBloodSugar).Methods("POST")
 	api.HandleFunc("/bloodsugar", apiHandler.DeleteBloodSugar).Methods("DELETE")
 	api.HandleFunc("/analyze-food", apiHandler.AnalyzeFood).Methods("POST")
+	api.HandleFunc("/jobs/{id}", jobHandler.GetJob).Methods("GET")
 	api.HandleFunc("/sync-libre", apiHandler.SyncLibre).Methods("POST")
+	api.HandleFunc("/products/{barcode}", apiHandler.GetProduct).Methods("GET")
+	api.HandleFunc("/doses", apiHandler.SaveInsulinDose).Methods("POST")
+	api.HandleFunc("/doses/{userId}", apiHandler.GetInsulinDoses).Methods("GET")
+	api.HandleFunc("/meals/{userId}", apiHandler.GetMealRecords).Methods("GET")
+	api.HandleFunc("/meals/{userId}/{mealId}/eaten", apiHandler.ConfirmMeal).Methods("POST")
+	api.HandleFunc("/dashboard/{userId}", apiHandler.GetDashboard).Methods("GET")
+	api.HandleFunc("/predict/{userId}", predictionHandler.GetForecast).Methods("GET")
+	api.HandleFunc("/hypo/{userId}", hypoHandler.GetTreatments).Methods("GET")
+	api.HandleFunc("/hypo/{userId}/{id}/confirm", hypoHandler.ConfirmTreatment).Methods("POST")
+	api.HandleFunc("/alerts/{userId}", alertHandler.GetAlerts).Methods("GET")
+	api.HandleFunc("/alerts/{userId}/{id}/acknowledge", alertHandler.AcknowledgeAlert).Methods("POST")
+	api.HandleFunc("/alerts/{userId}/{id}/snooze", alertHandler.SnoozeAlert).Methods("POST")
+	api.HandleFunc("/notifications/{userId}", notificationHandler.GetNotifications).Methods("GET")
+	api.HandleFunc("/notifications/{userId}/test", notificationHandler.SendTest).Methods("POST")
+	api.HandleFunc("/notifications/{userId}/{id}/retry", notificationHandler.RetryNotification).Methods("POST")
+	api.HandleFunc("/reminders/{userId}", reminderHandler.GetReminders).Methods("GET")
+	api.HandleFunc("/reminders/{userId}", reminderHandler.CreateReminder).Methods("POST")
+	api.HandleFunc("/reminders/{userId}/{id}", reminderHandler.UpdateReminder).Methods("PUT")
+	api.HandleFunc("/reminders/{userId}/{id}", reminderHandler.DeleteReminder).Methods("DELETE")
+	api.HandleFunc("/export/{userId}", exportHandler.Export).Methods("GET")
+	api.HandleFunc("/fhir/{userId}/Bundle", exportHandler.ExportFHIR).Methods("GET")
+	api.HandleFunc("/import/{userId}", importHandler.ImportReadings).Methods("POST")
+	api.HandleFunc("/reports/{userId}/pdf", reportHandler.GetPDF).Methods("GET")
+	api.HandleFunc("/telegram/{userId}/link-code", telegramHandler.CreateLinkCode).Methods("POST")
+	api.HandleFunc("/nightscout/{userId}/secret", nightscoutHandler.CreateSecret).Methods("POST")
+	api.HandleFunc("/photos/{id}", apiHandler.GetPhoto).Methods("GET")
+	api.HandleFunc("/chat/{userId}", chatHandler.SendMessage).Methods("POST")
+	api.HandleFunc("/chat/{userId}", chatHandler.GetHistory).Methods("GET")
+	api.HandleFunc("/chat/{userId}", chatHandler.ClearHistory).Methods("DELETE")
+
+	// Nightscout-compatible upload API, authenticated with the api-secret header
+	api.HandleFunc("/v1/status", nightscoutHandler.GetStatus).Methods("GET")
+	api.HandleFunc("/v1/status.json", nightscoutHandler.GetStatus).Methods("GET")
+	api.HandleFunc("/v1/entries", nightscoutHandler.UploadEntries).Methods("POST")
+	api.HandleFunc("/v1/treatments", nightscoutHandler.UploadTreatments).Methods("POST")
+	api.HandleFunc("/v1/devicestatus", nightscoutHandler.UploadDeviceStatus).Methods("POST")
+
+	// Admin routes require ADMIN_TOKEN
+	admin := api.PathPrefix("/admin").Subrouter()
+	admin.Use(adminHandler.Authorize)
+	admin.HandleFunc("/usage", adminHandler.GetUsage).Methods("GET")
+	admin.HandleFunc("/quotas/{userId}", adminHandler.GetQuota).Methods("GET")
+	admin.HandleFunc("/quotas/{userId}", adminHandler.SetQuota).Methods("PUT")
 
 	// Serve static files
 	router.PathPrefix("/").Handler(http.FileServer(http.Dir("./web")))
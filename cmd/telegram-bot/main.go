@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/joho/godotenv"
+	"github.com/yourusername/diabetes-assistant/internal/blobs"
+	"github.com/yourusername/diabetes-assistant/internal/config"
+	"github.com/yourusername/diabetes-assistant/internal/models"
+	"github.com/yourusername/diabetes-assistant/internal/services/ai"
+	"github.com/yourusername/diabetes-assistant/internal/services/alerts"
+	"github.com/yourusername/diabetes-assistant/internal/services/analysis"
+	"github.com/yourusername/diabetes-assistant/internal/services/hypo"
+	"github.com/yourusername/diabetes-assistant/internal/services/notify"
+	"github.com/yourusername/diabetes-assistant/internal/services/reminders"
+	"github.com/yourusername/diabetes-assistant/internal/services/uploads"
+	"github.com/yourusername/diabetes-assistant/internal/services/usage"
+	"github.com/yourusername/diabetes-assistant/internal/storage"
+	"github.com/yourusername/diabetes-assistant/internal/telegram"
+)
+
+// telegram-bot runs the Telegram front-end next to the server, sharing its MongoDB database
+// and photo storage, e.g.
+//
+//	TELEGRAM_BOT_TOKEN=123:abc go run ./cmd/telegram-bot
+//
+// Background jobs such as retrying notifications and delivering reminders are left to the server;
+// the bot only schedules reminders, which the server picks up from the database.
+func main() {
+	// Load environment variables from .env file
+	if err := godotenv.Load(); err != nil {
+		log.Println("No .env file found or error loading it")
+	}
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+	if cfg.TelegramBotToken == "" {
+		log.Fatalf("TELEGRAM_BOT_TOKEN is required")
+	}
+
+	// Chats are linked through the server's API, so the bot needs the same database
+	dbStorage, err := storage.NewMongoDBStorage(cfg.MongoURI)
+	if err != nil {
+		log.Fatalf("Failed to connect to MongoDB: %v", err)
+	}
+	defer dbStorage.Close()
+
+	blobStore, err := blobs.NewStore(cfg.BlobStore, cfg.UploadsDir, blobs.S3Config{
+		Endpoint:  cfg.S3Endpoint,
+		Bucket:    cfg.S3Bucket,
+		Region:    cfg.S3Region,
+		AccessKey: cfg.S3AccessKey,
+		SecretKey: cfg.S3SecretKey,
+	})
+	if err != nil {
+		log.Fatalf("Failed to initialize photo storage: %v", err)
+	}
+	uploadStore, err := uploads.NewStore(blobStore, cfg.UploadMaxDimension, cfg.PhotoURLSecret)
+	if err != nil {
+		log.Fatalf("Failed to initialize uploads: %v", err)
+	}
+
+	aiService, err := ai.NewService(cfg)
+	if err != nil {
+		log.Fatalf("Failed to initialize AI service: %v", err)
+	}
+	aiService.SetCache(dbStorage, cfg.AnalysisCacheTTL)
+	aiService.SetUsageRecorder(dbStorage)
+	usageService := usage.NewService(dbStorage, cfg.AIDailyQuota)
+
+	// Alerts raised by readings logged in the chat are delivered like the server's
+	notifyService := notify.NewService(dbStorage, cfg.NotifyMaxAttempts)
+	notifyService.Register(models.ChannelWebhook, notify.NewWebhookNotifier())
+	notifyService.Register(models.ChannelTelegram, notify.NewTelegramNotifier(cfg.TelegramAPIURL, cfg.TelegramBotToken))
+	if cfg.SMTPHost != "" {
+		notifyService.Register(models.ChannelEmail, notify.NewEmailNotifier(notify.SMTPConfig{
+			Host:     cfg.SMTPHost,
+			Port:     cfg.SMTPPort,
+			Username: cfg.SMTPUsername,
+			Password: cfg.SMTPPassword,
+			From:     cfg.SMTPFrom,
+		}))
+	}
+	alertService := alerts.NewService(dbStorage)
+	alertService.SetNotifyFunc(notifyService.Alert)
+
+	// Meals and lows logged in the chat schedule glucose checks
+	reminderService := reminders.NewService(dbStorage)
+	analysisService := analysis.NewService(dbStorage, aiService, uploadStore, usageService)
+	analysisService.SetMealFunc(reminderService.MealLogged)
+	hypoService := hypo.NewService(dbStorage)
+	hypoService.SetRecheckFunc(reminderService.Recheck)
+
+	bot := telegram.NewBot(
+		telegram.NewClient(cfg.TelegramAPIURL, cfg.TelegramBotToken),
+		dbStorage,
+		analysisService,
+		uploadStore,
+		hypoService,
+		alertService,
+		reminderService,
+	)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	log.Printf("Telegram bot running with AI provider %s", aiService.GetCurrentProvider())
+	bot.Run(ctx)
+	log.Println("Telegram bot stopped")
+}
@@ -0,0 +1,64 @@
+package blobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// ErrNotFound is returned when a blob does not exist
+var ErrNotFound = errors.New("blob not found")
+
+// ErrInvalidKey is returned for keys that could escape the store, such as "../x"
+var ErrInvalidKey = errors.New("invalid blob key")
+
+// ObjectInfo describes a stored blob
+type ObjectInfo struct {
+	Key         string
+	Size        int64
+	ContentType string
+	ModTime     time.Time
+}
+
+// Store defines the interface for storing binary objects such as food photos
+type Store interface {
+	// Put stores data under key, replacing any existing blob
+	Put(ctx context.Context, key string, data []byte, contentType string) error
+
+	// Get opens a blob for reading. The caller must close the reader.
+	Get(ctx context.Context, key string) (io.ReadCloser, *ObjectInfo, error)
+
+	// Delete removes a blob. Deleting a missing blob is not an error.
+	Delete(ctx context.Context, key string) error
+
+	// List returns all blobs whose key starts with prefix
+	List(ctx context.Context, prefix string) ([]ObjectInfo, error)
+}
+
+// NewStore creates the blob store of the given kind, "local" storing in dir or "s3"
+func NewStore(kind, dir string, s3 S3Config) (Store, error) {
+	switch kind {
+	case "local":
+		return NewLocalStore(dir)
+	case "s3":
+		return NewS3Store(s3)
+	}
+	return nil, fmt.Errorf("unknown blob store %q (expected local or s3)", kind)
+}
+
+// ValidateKey checks that a key is a relative slash-separated path without "." or ".." elements
+func ValidateKey(key string) error {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, "\\") {
+		return ErrInvalidKey
+	}
+
+	for _, part := range strings.Split(key, "/") {
+		if part == "" || part == "." || part == ".." {
+			return ErrInvalidKey
+		}
+	}
+	return nil
+}
@@ -1,7 +1,11 @@
 package config
 
 import (
+	"errors"
+	"fmt"
 	"os"
+	"strconv"
+	"time"
 )
 
 // Config holds the application configuration
@@ -12,6 +16,43 @@ type Config struct {
 	OpenAIToken  string
 	GrokToken    string
 	DefaultModel string
+
+	// Prompt templates
+	PromptsDir    string
+	PromptVersion string // Pins a template version, empty means latest
+
+	// Photo uploads
+	BlobStore           string // "local" or "s3"
+	UploadsDir          string // Directory of the local blob store
+	UploadMaxDimension  int    // Longest side in pixels photos are downscaled to
+	UploadRetentionDays int    // Photos not attached to a meal are deleted after this many days
+	PhotoURLSecret      string
+
+	// How long AI analysis results are reused for identical requests, 0 disables the cache
+	AnalysisCacheTTL time.Duration
+	AnalysisWorkers  int // Concurrent food analyses
+
+	// AI food analyses a user may run per day, 0 means unlimited. Admins can override it per user.
+	AIDailyQuota int
+	AdminToken   string // Bearer token for /api/admin, admin endpoints are disabled when empty
+
+	// Notification delivery. Email and Telegram channels only work when their server or bot is set.
+	SMTPHost          string
+	SMTPPort          int
+	SMTPUsername      string
+	SMTPPassword      string
+	SMTPFrom          string
+	TelegramBotToken  string
+	TelegramAPIURL    string // Telegram Bot API, may point at a stand-in server
+	TelegramBotName   string // Username of the bot for t.me link URLs, without "@"
+	NotifyMaxAttempts int    // Deliveries are retried this often before they go to the dead-letter log
+
+	// S3-compatible blob store
+	S3Endpoint  string
+	S3Bucket    string
+	S3Region    string
+	S3AccessKey string
+	S3SecretKey string
 }
 
 // LoadConfig loads the application configuration from environment variables
@@ -23,6 +64,58 @@ func LoadConfig() (*Config, error) {
 		OpenAIToken:  os.Getenv("OPENAI_API_KEY"),
 		GrokToken:    os.Getenv("GROK_API_KEY"),
 		DefaultModel: getEnvWithDefault("DEFAULT_MODEL", "gpt-3.5-turbo"),
+
+		PromptsDir:    getEnvWithDefault("PROMPTS_DIR", "./prompts"),
+		PromptVersion: os.Getenv("PROMPT_VERSION"),
+
+		BlobStore:      getEnvWithDefault("BLOB_STORE", "local"),
+		UploadsDir:     getEnvWithDefault("UPLOADS_DIR", "./uploads"),
+		PhotoURLSecret: os.Getenv("PHOTO_URL_SECRET"),
+
+		AdminToken: os.Getenv("ADMIN_TOKEN"),
+
+		SMTPHost:         os.Getenv("SMTP_HOST"),
+		SMTPUsername:     os.Getenv("SMTP_USERNAME"),
+		SMTPPassword:     os.Getenv("SMTP_PASSWORD"),
+		SMTPFrom:         os.Getenv("SMTP_FROM"),
+		TelegramBotToken: os.Getenv("TELEGRAM_BOT_TOKEN"),
+		TelegramAPIURL:   getEnvWithDefault("TELEGRAM_API_URL", "https://api.telegram.org"),
+		TelegramBotName:  os.Getenv("TELEGRAM_BOT_USERNAME"),
+
+		S3Endpoint:  os.Getenv("S3_ENDPOINT"),
+		S3Bucket:    os.Getenv("S3_BUCKET"),
+		S3Region:    os.Getenv("S3_REGION"),
+		S3AccessKey: os.Getenv("S3_ACCESS_KEY"),
+		S3SecretKey: os.Getenv("S3_SECRET_KEY"),
+	}
+
+	var err error
+	if config.UploadMaxDimension, err = getEnvInt("UPLOAD_MAX_DIMENSION", 1568); err != nil {
+		return nil, err
+	}
+	if config.UploadRetentionDays, err = getEnvInt("UPLOAD_RETENTION_DAYS", 7); err != nil {
+		return nil, err
+	}
+	if config.AnalysisCacheTTL, err = getEnvDuration("ANALYSIS_CACHE_TTL", 24*time.Hour); err != nil {
+		return nil, err
+	}
+	if config.AnalysisWorkers, err = getEnvInt("ANALYSIS_WORKERS", 4); err != nil {
+		return nil, err
+	}
+	if config.AIDailyQuota, err = getEnvInt("AI_DAILY_QUOTA", 50); err != nil {
+		return nil, err
+	}
+	if config.SMTPPort, err = getEnvInt("SMTP_PORT", 587); err != nil {
+		return nil, err
+	}
+	if config.NotifyMaxAttempts, err = getEnvInt("NOTIFY_MAX_ATTEMPTS", 5); err != nil {
+		return nil, err
+	}
+
+	// A shared blob store means several replicas serve the same photos, a random
+	// per-process secret would break every link signed by another replica
+	if config.BlobStore == "s3" && config.PhotoURLSecret == "" {
+		return nil, errors.New("PHOTO_URL_SECRET is required with BLOB_STORE=s3")
 	}
 
 	return config, nil
@@ -36,3 +129,31 @@ func getEnvWithDefault(key, defaultValue string) string {
 	}
 	return value
 }
+
+// getEnvInt returns the integer value of an environment variable or a default value
+func getEnvInt(key string, defaultValue int) (int, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue, nil
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", key, err)
+	}
+	return n, nil
+}
+
+// getEnvDuration returns the duration value (e.g. "24h") of an environment variable or a default value
+func getEnvDuration(key string, defaultValue time.Duration) (time.Duration, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue, nil
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", key, err)
+	}
+	return d, nil
+}
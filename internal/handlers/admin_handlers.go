@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/yourusername/diabetes-assistant/internal/services/usage"
+)
+
+// defaultUsageDays is how many days GET /api/admin/usage covers without a from date
+const defaultUsageDays = 7
+
+// AdminHandler handles the admin API for AI usage and quotas
+type AdminHandler struct {
+	usage *usage.Service
+	token string
+}
+
+// NewAdminHandler creates a new admin handler. An empty token disables the admin API.
+func NewAdminHandler(usageService *usage.Service, token string) *AdminHandler {
+	return &AdminHandler{
+		usage: usageService,
+		token: token,
+	}
+}
+
+// Authorize is middleware that requires the admin token as a bearer token
+func (h *AdminHandler) Authorize(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if h.token == "" {
+			respondError(w, http.StatusForbidden, "Admin API is disabled, set ADMIN_TOKEN to enable it")
+			return
+		}
+
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(h.token)) != 1 {
+			respondError(w, http.StatusUnauthorized, "Invalid admin token")
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// GetUsage handles GET /api/admin/usage?userId=&from=YYYY-MM-DD&to=YYYY-MM-DD.
+// Returns AI calls aggregated by user and day; to is inclusive and both default to the last week.
+func (h *AdminHandler) GetUsage(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+	from := today.AddDate(0, 0, -(defaultUsageDays - 1))
+	to := today
+
+	var err error
+	if value := query.Get("from"); value != "" {
+		if from, err = time.ParseInLocation("2006-01-02", value, time.Local); err != nil {
+			respondError(w, http.StatusBadRequest, "Invalid from date, expected YYYY-MM-DD")
+			return
+		}
+	}
+	if value := query.Get("to"); value != "" {
+		if to, err = time.ParseInLocation("2006-01-02", value, time.Local); err != nil {
+			respondError(w, http.StatusBadRequest, "Invalid to date, expected YYYY-MM-DD")
+			return
+		}
+	}
+	if to.Before(from) {
+		respondError(w, http.StatusBadRequest, "to must not be before from")
+		return
+	}
+
+	summaries, err := h.usage.Summarize(r.Context(), query.Get("userId"), from, to.AddDate(0, 0, 1))
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Error fetching usage: %v", err))
+		return
+	}
+
+	// Totals over the whole range for a quick overview
+	var calls, inputTokens, outputTokens int
+	var cost float64
+	for _, summary := range summaries {
+		calls += summary.Calls
+		inputTokens += summary.InputTokens
+		outputTokens += summary.OutputTokens
+		cost += summary.CostUSD
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"from": from.Format("2006-01-02"),
+		"to":   to.Format("2006-01-02"),
+		"days": summaries,
+		"total": map[string]interface{}{
+			"calls":        calls,
+			"inputTokens":  inputTokens,
+			"outputTokens": outputTokens,
+			"costUsd":      cost,
+		},
+	})
+}
+
+// GetQuota handles GET /api/admin/quotas/{userId}
+func (h *AdminHandler) GetQuota(w http.ResponseWriter, r *http.Request) {
+	status, err := h.usage.Quota(r.Context(), mux.Vars(r)["userId"])
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Error fetching quota: %v", err))
+		return
+	}
+
+	respondJSON(w, http.StatusOK, status)
+}
+
+// SetQuota handles PUT /api/admin/quotas/{userId} with {"dailyLimit": n}.
+// A limit of 0 means unlimited, null restores the default.
+func (h *AdminHandler) SetQuota(w http.ResponseWriter, r *http.Request) {
+	userID := mux.Vars(r)["userId"]
+
+	var req struct {
+		DailyLimit *int `json:"dailyLimit"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, fmt.Sprintf("Invalid request body: %v", err))
+		return
+	}
+	if req.DailyLimit != nil && *req.DailyLimit < 0 {
+		respondError(w, http.StatusBadRequest, "dailyLimit must not be negative")
+		return
+	}
+
+	if err := h.usage.SetQuota(r.Context(), userID, req.DailyLimit); err != nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Error saving quota: %v", err))
+		return
+	}
+
+	status, err := h.usage.Quota(r.Context(), userID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Error fetching quota: %v", err))
+		return
+	}
+
+	respondJSON(w, http.StatusOK, status)
+}
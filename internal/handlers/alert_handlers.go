@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/yourusername/diabetes-assistant/internal/models"
+	"github.com/yourusername/diabetes-assistant/internal/services/alerts"
+	"github.com/yourusername/diabetes-assistant/internal/storage"
+)
+
+// AlertHandler handles the alerts API
+type AlertHandler struct {
+	alerts  *alerts.Service
+	storage storage.Storage
+}
+
+// NewAlertHandler creates a new alert handler
+func NewAlertHandler(alertService *alerts.Service, storage storage.Storage) *AlertHandler {
+	return &AlertHandler{
+		alerts:  alertService,
+		storage: storage,
+	}
+}
+
+// GetAlerts handles GET /api/alerts/{userId}. With ?active=true only unacknowledged alerts are returned.
+func (h *AlertHandler) GetAlerts(w http.ResponseWriter, r *http.Request) {
+	userID := mux.Vars(r)["userId"]
+
+	// Default to 1 day ago if no start date provided
+	startDate := time.Now().AddDate(0, 0, -1)
+	if startDateStr := r.URL.Query().Get("startDate"); startDateStr != "" {
+		var err error
+		startDate, err = time.Parse(time.RFC3339, startDateStr)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "Invalid startDate parameter")
+			return
+		}
+	}
+
+	userAlerts, err := h.storage.GetAlerts(r.Context(), userID, startDate)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Error fetching alerts: %v", err))
+		return
+	}
+
+	if r.URL.Query().Get("active") == "true" {
+		active := []models.Alert{}
+		for _, alert := range userAlerts {
+			if alert.AcknowledgedAt == nil {
+				active = append(active, alert)
+			}
+		}
+		userAlerts = active
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{"alerts": userAlerts})
+}
+
+// AcknowledgeAlert handles POST /api/alerts/{userId}/{id}/acknowledge
+func (h *AlertHandler) AcknowledgeAlert(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	alert, err := h.alerts.Acknowledge(r.Context(), vars["userId"], vars["id"])
+	if err != nil {
+		respondAlertError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{"success": true, "alert": alert})
+}
+
+// SnoozeAlert handles POST /api/alerts/{userId}/{id}/snooze with the body {"minutes": 60}
+func (h *AlertHandler) SnoozeAlert(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	var req struct {
+		Minutes int `json:"minutes"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		respondError(w, http.StatusBadRequest, fmt.Sprintf("Invalid request body: %v", err))
+		return
+	}
+
+	snooze := time.Duration(req.Minutes) * time.Minute
+	if snooze <= 0 || snooze > alerts.MaxSnooze {
+		respondError(w, http.StatusBadRequest, "minutes must be between 1 and 1440")
+		return
+	}
+
+	alert, err := h.alerts.Snooze(r.Context(), vars["userId"], vars["id"], snooze)
+	if err != nil {
+		respondAlertError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{"success": true, "alert": alert})
+}
+
+func respondAlertError(w http.ResponseWriter, err error) {
+	if errors.Is(err, alerts.ErrNotFound) {
+		respondError(w, http.StatusNotFound, "Alert not found")
+		return
+	}
+	respondError(w, http.StatusInternalServerError, fmt.Sprintf("Error updating alert: %v", err))
+}
@@ -4,40 +4,65 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
+	"math"
 	"net/http"
-	"os"
-	"path/filepath"
+	"net/mail"
+	"net/url"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
+	"github.com/yourusername/diabetes-assistant/internal/blobs"
 	"github.com/yourusername/diabetes-assistant/internal/models"
-	"github.com/yourusername/diabetes-assistant/internal/services/ai"
+	"github.com/yourusername/diabetes-assistant/internal/services/alerts"
+	"github.com/yourusername/diabetes-assistant/internal/services/cob"
+	"github.com/yourusername/diabetes-assistant/internal/services/glucose"
+	"github.com/yourusername/diabetes-assistant/internal/services/hypo"
 	"github.com/yourusername/diabetes-assistant/internal/services/insulin"
+	"github.com/yourusername/diabetes-assistant/internal/services/jobs"
 	"github.com/yourusername/diabetes-assistant/internal/services/libre"
+	"github.com/yourusername/diabetes-assistant/internal/services/prediction"
+	"github.com/yourusername/diabetes-assistant/internal/services/products"
+	"github.com/yourusername/diabetes-assistant/internal/services/reminders"
+	"github.com/yourusername/diabetes-assistant/internal/services/uploads"
+	"github.com/yourusername/diabetes-assistant/internal/services/usage"
 	"github.com/yourusername/diabetes-assistant/internal/storage"
 )
 
+// maxDescriptionLen is the longest accepted meal description in bytes
+const maxDescriptionLen = 1000
+
 // APIHandler handles API requests
 type APIHandler struct {
-	storage    storage.Storage
-	ai         *ai.Service
-	libre      *libre.LibreService
-	uploadsDir string
+	storage   storage.Storage
+	jobs      *jobs.Queue
+	libre     *libre.LibreService
+	uploads   *uploads.Store
+	usage     *usage.Service
+	hypo      *hypo.Service
+	alerts    *alerts.Service
+	reminders *reminders.Service
+	locales   []string // Locales users can choose, the ones with prompt templates
 }
 
 // NewAPIHandler creates a new API handler
-func NewAPIHandler(storage storage.Storage, aiService *ai.Service, libreService *libre.LibreService, uploadsDir string) *APIHandler {
+func NewAPIHandler(storage storage.Storage, jobQueue *jobs.Queue, libreService *libre.LibreService, uploadStore *uploads.Store, usageService *usage.Service, hypoService *hypo.Service, alertService *alerts.Service, reminderService *reminders.Service, locales []string) *APIHandler {
 	return &APIHandler{
-		storage:    storage,
-		ai:         aiService,
-		libre:      libreService,
-		uploadsDir: uploadsDir,
+		storage:   storage,
+		jobs:      jobQueue,
+		libre:     libreService,
+		uploads:   uploadStore,
+		usage:     usageService,
+		hypo:      hypoService,
+		alerts:    alertService,
+		reminders: reminderService,
+		locales:   locales,
 	}
 }
 
@@ -104,6 +129,60 @@ func (h *APIHandler) SaveUserSettings(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if settings.FPUFactor < 0 || settings.FPUFactor > 20 {
+		http.Error(w, "FPU factor must be between 0 and 20 grams of carbohydrate", http.StatusBadRequest)
+		return
+	}
+
+	// 0 keeps the default absorption time for a profile
+	for _, hours := range []float64{settings.AbsorptionTimes.Fast, settings.AbsorptionTimes.Medium, settings.AbsorptionTimes.Slow} {
+		if hours < 0 || hours > 8 {
+			http.Error(w, "Carb absorption times must be between 0 and 8 hours", http.StatusBadRequest)
+			return
+		}
+	}
+
+	if settings.AbsorptionCurve != "" && settings.AbsorptionCurve != models.AbsorptionCurveLinear && settings.AbsorptionCurve != models.AbsorptionCurvePiecewise {
+		http.Error(w, "Carb absorption curve must be linear or piecewise", http.StatusBadRequest)
+		return
+	}
+
+	if settings.Alerts != nil {
+		if msg := validateAlertSettings(settings.Alerts); msg != "" {
+			http.Error(w, msg, http.StatusBadRequest)
+			return
+		}
+	}
+
+	if settings.Reminders != nil && (settings.Reminders.MealCheckMinutes < 0 || settings.Reminders.MealCheckMinutes > 360) {
+		http.Error(w, "Meal check minutes must be between 0 and 360", http.StatusBadRequest)
+		return
+	}
+
+	if msg := validateNotificationChannels(settings.NotificationChannels); msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	// The whole document is replaced, clients that don't send the locale keep the stored one
+	if settings.Locale == "" {
+		settings.Locale = models.DefaultLocale
+		if current, err := h.storage.GetUserSettings(r.Context(), userID); err == nil && current != nil && current.Locale != "" {
+			settings.Locale = current.Locale
+		}
+	}
+	if !h.supportsLocale(settings.Locale) {
+		http.Error(w, fmt.Sprintf("Locale must be one of: %s", strings.Join(h.locales, ", ")), http.StatusBadRequest)
+		return
+	}
+
+	// Deliveries refer to their channel by ID
+	for i := range settings.NotificationChannels {
+		if settings.NotificationChannels[i].ID == "" {
+			settings.NotificationChannels[i].ID = uuid.New().String()
+		}
+	}
+
 	// Validate periods
 	if len(settings.InsulinPeriods) == 0 || len(settings.SensitivityPeriods) == 0 || len(settings.CarbRatioPeriods) == 0 {
 		http.Error(w, "At least one period is required for each type", http.StatusBadRequest)
@@ -127,11 +206,25 @@ func (h *APIHandler) SaveUserSettings(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// supportsLocale reports whether there are prompt templates for the locale
+func (h *APIHandler) supportsLocale(locale string) bool {
+	for _, supported := range h.locales {
+		if supported == locale {
+			return true
+		}
+	}
+	return false
+}
+
 // SaveBloodSugar handles POST /api/bloodsugar
 func (h *APIHandler) SaveBloodSugar(w http.ResponseWriter, r *http.Request) {
 	var req struct {
 		UserID string  `json:"userId"`
 		Value  float64 `json:"value"`
+		Source string  `json:"source,omitempty"`
+		// Trend arrow and rate of change in mmol/L per minute, when the CGM provides them
+		Trend        string   `json:"trend,omitempty"`
+		RateOfChange *float64 `json:"rateOfChange,omitempty"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -149,6 +242,11 @@ func (h *APIHandler) SaveBloodSugar(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if req.Trend != "" && !glucose.IsArrow(req.Trend) {
+		respondError(w, http.StatusBadRequest, "Invalid trend, expected DoubleUp, SingleUp, FortyFiveUp, Flat, FortyFiveDown, SingleDown, DoubleDown or NotComputable")
+		return
+	}
+
 	user, err := h.storage.GetUser(req.UserID)
 	if err != nil {
 		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Error fetching user: %v", err))
@@ -171,8 +269,11 @@ func (h *APIHandler) SaveBloodSugar(w http.ResponseWriter, r *http.Request) {
 
 	// Create reading
 	reading := models.BloodSugarReading{
-		Value:     req.Value,
-		Timestamp: time.Now(),
+		Value:        req.Value,
+		Timestamp:    time.Now(),
+		Source:       req.Source,
+		Trend:        req.Trend,
+		RateOfChange: req.RateOfChange,
 	}
 
 	// Save reading
@@ -181,14 +282,22 @@ func (h *APIHandler) SaveBloodSugar(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// A new reading is the recheck after treating a low
+	if err := h.hypo.ReadingReceived(r.Context(), req.UserID, reading.Timestamp); err != nil {
+		log.Printf("Error updating hypo rechecks: %v", err)
+	}
+	if err := h.reminders.ReadingReceived(r.Context(), req.UserID, reading.Timestamp); err != nil {
+		log.Printf("Error updating reminders: %v", err)
+	}
+
 	// Determine status
-	status := "Normal range"
-	if req.Value < 3.9 {
-		status = "Low blood sugar (hypoglycemia)"
+	rangeStatus := "Normal range"
+	if req.Value < hypo.Threshold {
+		rangeStatus = "Low blood sugar (hypoglycemia)"
 	} else if req.Value > 10.0 {
-		status = "High blood sugar (hyperglycemia)"
+		rangeStatus = "High blood sugar (hyperglycemia)"
 	} else if req.Value > 7.0 {
-		status = "Slightly elevated"
+		rangeStatus = "Slightly elevated"
 	}
 
 	// Analyze readings and potentially adjust coefficients
@@ -202,6 +311,13 @@ func (h *APIHandler) SaveBloodSugar(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Say where glucose is heading when there are enough recent readings
+	glucose.AnnotateReading(&reading, recentReadings)
+	status := rangeStatus
+	if reading.Trend != glucose.ArrowNotComputable {
+		status = glucose.Describe(req.Value, reading.Trend, userLocale(user))
+	}
+
 	if len(recentReadings) >= 5 && user.Settings.TargetMin > 0 {
 		// Analyze readings for potential adjustments
 		// First convert the new-style insulin periods to the legacy format for the calculator
@@ -229,16 +345,118 @@ func (h *APIHandler) SaveBloodSugar(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	respondJSON(w, http.StatusOK, map[string]interface{}{
+	response := map[string]interface{}{
 		"success": true,
 		"reading": map[string]interface{}{
-			"value":     req.Value,
-			"status":    status,
-			"timestamp": reading.Timestamp,
+			"value":        req.Value,
+			"status":       status,
+			"range":        rangeStatus,
+			"trend":        reading.Trend,
+			"trendArrow":   glucose.Symbol(reading.Trend),
+			"rateOfChange": reading.RateOfChange,
+			"timestamp":    reading.Timestamp,
 		},
 		"coefficientsAdjusted": coefficientsAdjusted,
 		"targetLevel":          user.Settings.TargetMin,
-	})
+	}
+
+	// Suggest how to treat a low, with a reminder to check again
+	if req.Value < hypo.Threshold {
+		treatment, err := h.hypo.Advise(r.Context(), &user.Settings, req.UserID, reading)
+		if err != nil {
+			log.Printf("Error advising hypo treatment: %v", err)
+		} else {
+			response["treatment"] = treatment
+		}
+	}
+
+	raised, err := h.alerts.Evaluate(r.Context(), req.UserID, &user.Settings, reading.Timestamp)
+	if err != nil {
+		log.Printf("Error evaluating alerts: %v", err)
+	} else if len(raised) > 0 {
+		response["alerts"] = raised
+	}
+
+	respondJSON(w, http.StatusOK, response)
+}
+
+// validateAlertSettings returns what is wrong with alert settings, or "" if they are valid
+func validateAlertSettings(config *models.AlertSettings) string {
+	glucoseRules := []models.AlertRule{config.UrgentLow, config.Low, config.High, config.PredictedLow, config.PersistentHigh}
+	for _, rule := range glucoseRules {
+		if rule.Enabled && (rule.Threshold < 2 || rule.Threshold > 25) {
+			return "Alert glucose thresholds must be between 2 and 25 mmol/L"
+		}
+	}
+	for _, rule := range []models.AlertRule{config.RapidRise, config.RapidFall} {
+		if rule.Enabled && (rule.Threshold <= 0 || rule.Threshold > 1) {
+			return "Rapid change thresholds must be between 0 and 1 mmol/L per minute"
+		}
+	}
+
+	if config.PredictedLow.Enabled && (config.PredictedLow.Minutes < 5 || config.PredictedLow.Minutes > 30) {
+		return "Predicted low look-ahead must be between 5 and 30 minutes"
+	}
+	if config.StaleData.Enabled && (config.StaleData.Minutes < 10 || config.StaleData.Minutes > 720) {
+		return "Missed readings time must be between 10 and 720 minutes"
+	}
+	if config.PersistentHigh.Enabled && (config.PersistentHigh.Minutes < 30 || config.PersistentHigh.Minutes > 720) {
+		return "Persistent high time must be between 30 and 720 minutes"
+	}
+
+	for _, rule := range append(glucoseRules, config.RapidRise, config.RapidFall, config.StaleData) {
+		if rule.SnoozeMinutes < 0 || rule.SnoozeMinutes > 1440 {
+			return "Alert snooze must be between 0 and 1440 minutes"
+		}
+	}
+
+	if config.QuietHours.Enabled && (!models.IsClock(config.QuietHours.Start) || !models.IsClock(config.QuietHours.End)) {
+		return "Quiet hours must be given as HH:MM"
+	}
+	return ""
+}
+
+// maxNotificationChannels is the most channels a user can set up, caregivers included
+const maxNotificationChannels = 10
+
+// validateNotificationChannels returns what is wrong with notification channels, or "" if they are valid
+func validateNotificationChannels(channels []models.NotificationChannel) string {
+	if len(channels) > maxNotificationChannels {
+		return fmt.Sprintf("At most %d notification channels are allowed", maxNotificationChannels)
+	}
+
+	ids := make(map[string]bool)
+	for _, channel := range channels {
+		if channel.ID != "" {
+			if ids[channel.ID] {
+				return "Notification channel IDs must be unique"
+			}
+			ids[channel.ID] = true
+		}
+		if len(channel.Name) > 100 {
+			return "Notification channel names must be at most 100 characters"
+		}
+
+		switch channel.Type {
+		case models.ChannelWebhook:
+			target, err := url.Parse(channel.Target)
+			if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+				return "Webhook channels need an http or https URL"
+			}
+		case models.ChannelEmail:
+			if _, err := mail.ParseAddress(channel.Target); err != nil {
+				return "Email channels need a valid email address"
+			}
+		case models.ChannelTelegram:
+			// A numeric chat ID, or @username of a public channel
+			if _, err := strconv.ParseInt(channel.Target, 10, 64); err != nil && (!strings.HasPrefix(channel.Target, "@") || len(channel.Target) < 2) {
+				return "Telegram channels need a numeric chat ID or @channelname"
+			}
+		default:
+			return "Notification channel type must be webhook, email or telegram"
+		}
+	}
+	return ""
 }
 
 // Helper to compare insulin periods
@@ -297,13 +515,98 @@ func (h *APIHandler) GetBloodSugarReadings(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	respondJSON(w, http.StatusOK, map[string]interface{}{"readings": readings})
+	// Readings the CGM didn't report a trend for get one computed from the readings before them
+	glucose.Annotate(readings)
+
+	response := map[string]interface{}{"readings": readings}
+	if latest, ok := glucose.Latest(readings, time.Now()); ok {
+		user, err := h.storage.GetUser(userId)
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, fmt.Sprintf("Error fetching user: %v", err))
+			return
+		}
+		response["current"] = currentGlucose(latest, userLocale(user))
+	}
+
+	respondJSON(w, http.StatusOK, response)
+}
+
+// currentGlucose describes the latest reading with its trend in the user's language
+func currentGlucose(latest models.BloodSugarReading, locale string) map[string]interface{} {
+	return map[string]interface{}{
+		"value":        latest.Value,
+		"timestamp":    latest.Timestamp,
+		"trend":        latest.Trend,
+		"trendArrow":   glucose.Symbol(latest.Trend),
+		"rateOfChange": latest.RateOfChange,
+		"status":       glucose.Describe(latest.Value, latest.Trend, locale),
+	}
+}
+
+// userLocale returns the user's language, the default one for unknown users
+func userLocale(user *models.User) string {
+	if user == nil || user.Settings.Locale == "" {
+		return models.DefaultLocale
+	}
+	return user.Settings.Locale
+}
+
+// GetDashboard handles GET /api/dashboard/{userId}: the current glucose with its trend,
+// insulin on board and carbs on board with the meals still being absorbed
+func (h *APIHandler) GetDashboard(w http.ResponseWriter, r *http.Request) {
+	userId := mux.Vars(r)["userId"]
+
+	user, err := h.storage.GetUser(userId)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Error fetching user: %v", err))
+		return
+	}
+
+	if user == nil {
+		respondError(w, http.StatusNotFound, "User not found")
+		return
+	}
+
+	now := time.Now()
+	readings, err := h.storage.GetRecentBloodSugarReadings(userId, 0, now.Add(-time.Hour))
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Error fetching readings: %v", err))
+		return
+	}
+
+	doses, err := h.storage.GetInsulinDoses(r.Context(), userId, now.Add(-prediction.HistoryWindow))
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Error fetching doses: %v", err))
+		return
+	}
+
+	meals, err := h.storage.GetMealRecords(r.Context(), userId, now.Add(-prediction.HistoryWindow))
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Error fetching meals: %v", err))
+		return
+	}
+
+	// Analyses the user didn't eat don't count
+	meals = cob.Eaten(meals, doses)
+	response := map[string]interface{}{
+		"iob":         insulin.OnBoard(doses, now, user.Settings.IOBDuration),
+		"cob":         math.Round(cob.OnBoard(meals, &user.Settings, now)*10) / 10,
+		"activeMeals": cob.Active(meals, &user.Settings, now),
+		"updatedAt":   now,
+	}
+
+	glucose.Annotate(readings)
+	if latest, ok := glucose.Latest(readings, now); ok {
+		response["current"] = currentGlucose(latest, userLocale(user))
+	}
+
+	respondJSON(w, http.StatusOK, response)
 }
 
 // AnalyzeFood handles POST /api/analyze-food
 func (h *APIHandler) AnalyzeFood(w http.ResponseWriter, r *http.Request) {
 	// Log request
-	fmt.Printf("AnalyzeFood: Received %s request with content type: %s\n", r.Method, r.Header.Get("Content-Type"))
+	log.Printf("AnalyzeFood: Received %s request with content type: %s", r.Method, r.Header.Get("Content-Type"))
 
 	// Handle preflight OPTIONS request
 	if r.Method == "OPTIONS" {
@@ -317,39 +620,52 @@ func (h *APIHandler) AnalyzeFood(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Accept, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization")
 
 	var userId string
-	var photoProvided bool
-	var foodPhotoPath string
+	var photoID string     // Blob key of the stored photo
 	var foodWeight float64 // Weight in grams
+	var userNotes string   // Optional notes about hidden ingredients or preparation
+	var mealContext string // Optional meal context, e.g. "breakfast"
+	var labelMode bool     // Read a nutrition facts label instead of estimating a dish
+	var barcode string     // Optional barcode for a lookup in the product database
+	var description string // Optional text description of the meal, used with or without a photo
 
 	// Parse the multipart form first (max 10MB)
 	err := r.ParseMultipartForm(10 << 20)
 	if err != nil {
-		fmt.Printf("AnalyzeFood: Not a multipart form: %v\n", err)
+		log.Printf("AnalyzeFood: Not a multipart form: %v", err)
 		// Not a multipart form - try to parse as JSON
 		var req struct {
-			UserID     string  `json:"userId"`
-			FoodWeight float64 `json:"foodWeight,omitempty"` // Optional weight in grams
+			UserID      string  `json:"userId"`
+			FoodWeight  float64 `json:"foodWeight,omitempty"` // Optional weight in grams
+			Barcode     string  `json:"barcode,omitempty"`    // Optional barcode of a packaged product
+			Food        string  `json:"food,omitempty"`       // Text description of the meal
+			Notes       string  `json:"notes,omitempty"`
+			MealContext string  `json:"mealContext,omitempty"`
 		}
 
 		bodyBytes, _ := io.ReadAll(r.Body)
 		if len(bodyBytes) > 0 {
-			fmt.Printf("AnalyzeFood: Raw request body: %s\n", string(bodyBytes))
 			r.Body = io.NopCloser(bytes.NewBuffer(bodyBytes))
 
 			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-				fmt.Printf("AnalyzeFood: Error decoding JSON: %v\n", err)
+				log.Printf("AnalyzeFood: Error decoding JSON: %v", err)
 				respondError(w, http.StatusBadRequest, fmt.Sprintf("Invalid JSON: %v", err))
 				return
 			}
 
 			userId = req.UserID
 			foodWeight = req.FoodWeight
-
-			// Photo is required
-			respondError(w, http.StatusBadRequest, "Food photo is required for analysis")
-			return
+			barcode = strings.TrimSpace(req.Barcode)
+			description = strings.TrimSpace(req.Food)
+			userNotes = strings.TrimSpace(req.Notes)
+			mealContext = strings.TrimSpace(req.MealContext)
+
+			// Without a photo the meal has to be described or scanned
+			if barcode == "" && description == "" {
+				respondError(w, http.StatusBadRequest, "Food photo, description or barcode is required for analysis")
+				return
+			}
 		} else {
-			fmt.Println("AnalyzeFood: Empty request body")
+			log.Println("AnalyzeFood: Empty request body")
 			respondError(w, http.StatusBadRequest, "Empty request body")
 			return
 		}
@@ -363,140 +679,98 @@ func (h *APIHandler) AnalyzeFood(w http.ResponseWriter, r *http.Request) {
 			var err error
 			foodWeight, err = strconv.ParseFloat(weightStr, 64)
 			if err != nil {
-				fmt.Printf("AnalyzeFood: Invalid food weight: %v\n", err)
+				log.Printf("AnalyzeFood: Invalid food weight: %v", err)
 				// Not a critical error, continue with weight=0
 				foodWeight = 0
 			}
 		}
 
-		fmt.Printf("AnalyzeFood: Multipart form values - userId: %s, foodWeight: %.1f\n",
+		userNotes = strings.TrimSpace(r.FormValue("notes"))
+		mealContext = strings.TrimSpace(r.FormValue("mealContext"))
+		barcode = strings.TrimSpace(r.FormValue("barcode"))
+		description = strings.TrimSpace(r.FormValue("description"))
+
+		// "label" reads a nutrition facts label instead of estimating a dish
+		mode := r.FormValue("mode")
+		if mode != "" && mode != "dish" && mode != "label" {
+			respondError(w, http.StatusBadRequest, "Invalid mode (expected dish or label)")
+			return
+		}
+		labelMode = mode == "label"
+
+		log.Printf("AnalyzeFood: Multipart form values - userId: %s, foodWeight: %.1f",
 			userId, foodWeight)
 
-		// Check for photo (required)
+		// Check for photo (required unless the meal is described or scanned)
 		foodPhoto, foodPhotoHeader, err := r.FormFile("foodPhoto")
 		if err == nil && foodPhoto != nil {
 			defer foodPhoto.Close()
-			photoProvided = true
-			fmt.Printf("AnalyzeFood: Photo provided - filename: %s, size: %d\n", foodPhotoHeader.Filename, foodPhotoHeader.Size)
+			log.Printf("AnalyzeFood: Photo provided - filename: %s, size: %d", foodPhotoHeader.Filename, foodPhotoHeader.Size)
 
-			// Save photo to disk
-			foodPhotoFileName := fmt.Sprintf("food_%s%s", uuid.New().String(), filepath.Ext(foodPhotoHeader.Filename))
-			foodPhotoPath = filepath.Join(h.uploadsDir, foodPhotoFileName)
-
-			foodPhotoFile, err := os.Create(foodPhotoPath)
-			if err != nil {
-				fmt.Printf("AnalyzeFood: Error creating file: %v\n", err)
-				respondError(w, http.StatusInternalServerError, fmt.Sprintf("Error creating file: %v", err))
+			// Validate, strip metadata and downscale before saving to disk
+			img, err := h.uploads.Save(r.Context(), foodPhoto)
+			if errors.Is(err, uploads.ErrUnsupportedImage) {
+				respondError(w, http.StatusBadRequest, fmt.Sprintf("Invalid food photo: %v", err))
 				return
 			}
-			defer foodPhotoFile.Close()
-
-			if _, err := io.Copy(foodPhotoFile, foodPhoto); err != nil {
-				fmt.Printf("AnalyzeFood: Error saving file: %v\n", err)
+			if err != nil {
+				log.Printf("AnalyzeFood: Error saving file: %v", err)
 				respondError(w, http.StatusInternalServerError, fmt.Sprintf("Error saving file: %v", err))
 				return
 			}
-			fmt.Printf("AnalyzeFood: Photo saved to %s\n", foodPhotoPath)
-		} else {
-			fmt.Printf("AnalyzeFood: No photo in request or error: %v\n", err)
-			respondError(w, http.StatusBadRequest, "Food photo is required for analysis")
+			photoID = img.ID
+			log.Printf("AnalyzeFood: Photo saved as %s (%s, %dx%d)", photoID, img.MIMEType, img.Width, img.Height)
+		} else if barcode == "" && (description == "" || labelMode) {
+			log.Printf("AnalyzeFood: No photo in request or error: %v", err)
+			respondError(w, http.StatusBadRequest, "Food photo, description or barcode is required for analysis")
 			return
 		}
 	}
 
-	// Get user settings for insulin calculations
-	user, err := h.storage.GetUser(userId)
-	if err != nil {
-		fmt.Printf("AnalyzeFood: Error fetching user: %v\n", err)
-		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Error fetching user: %v", err))
+	if len(description) > maxDescriptionLen {
+		respondError(w, http.StatusBadRequest, fmt.Sprintf("Description is too long (max %d characters)", maxDescriptionLen))
 		return
 	}
 
-	// Use default settings if user not found
-	var userSettings *models.Settings
-	if user != nil {
-		// Make a copy of the settings
-		settingsCopy := user.Settings
-		userSettings = &settingsCopy
-	} else {
-		// Create default settings
-		userSettings = models.CreateDefaultSettings(userId)
-	}
-
-	// Analyze food using AI service - we're passing empty string as the description parameter
-	var foodAnalysisResult *ai.FoodAnalysisResult
-	if photoProvided && foodPhotoPath != "" {
-		// Analyze with photo and optional weight
-		foodAnalysisResult, err = h.ai.AnalyzeFood(foodPhotoPath, "", foodWeight)
-		if err != nil {
-			fmt.Printf("AnalyzeFood: AI analysis error: %v\n", err)
-			respondError(w, http.StatusInternalServerError, fmt.Sprintf("Error analyzing food: %v", err))
+	// Reject over-quota AI analyses right away instead of failing the job.
+	// The worker checks again, a barcode that isn't found may still need the AI.
+	if barcode == "" {
+		if err := h.usage.CheckQuota(r.Context(), userId); errors.Is(err, usage.ErrQuotaExceeded) {
+			respondError(w, http.StatusTooManyRequests, err.Error())
+			return
+		} else if err != nil {
+			respondError(w, http.StatusInternalServerError, fmt.Sprintf("Error checking quota: %v", err))
 			return
-		}
-	} else {
-		// Photo is required
-		respondError(w, http.StatusBadRequest, "Food photo is required for analysis")
-		return
-	}
-
-	// Calculate insulin dose based on carbs and user settings
-	mealInsulin := foodAnalysisResult.Carbs / userSettings.CarbRatioPeriods[0].Ratio
-
-	// Get current time to determine time-based coefficient
-	hour := time.Now().Hour()
-	periodCoefficient := 1.0
-
-	// Apply time-based coefficient
-	if userSettings.InsulinPeriods != nil && len(userSettings.InsulinPeriods) > 0 {
-		// Use the new array format
-		for _, period := range userSettings.InsulinPeriods {
-			startHour, _ := strconv.Atoi(strings.Split(period.StartTime, ":")[0])
-			if hour >= startHour && hour < startHour+int(period.Hours) {
-				periodCoefficient = period.Coefficient
-				break
-			}
 		}
 	}
 
-	// Apply coefficient
-	mealInsulin *= periodCoefficient
-
-	// Add correction insulin if needed
-	lastReading, _ := h.storage.GetRecentBloodSugarReadings(userId, 1, time.Now().AddDate(0, 0, -7))
-
-	correctionInsulin := 0.0
-	if len(lastReading) > 0 && userSettings.TargetMin > 0 && userSettings.SensitivityPeriods[0].Sensitivity > 0 {
-		bloodSugarDiff := lastReading[0].Value - userSettings.TargetMin
-		if bloodSugarDiff > 0 {
-			correctionInsulin = bloodSugarDiff / userSettings.SensitivityPeriods[0].Sensitivity
-		}
+	// The analysis runs on a worker, vision calls can take longer than the server's write timeout
+	job, err := h.jobs.Submit(r.Context(), models.AnalysisRequest{
+		UserID:      userId,
+		PhotoID:     photoID,
+		Description: description,
+		UserNotes:   userNotes,
+		MealContext: mealContext,
+		Barcode:     barcode,
+		Label:       labelMode,
+		Weight:      foodWeight,
+	})
+	if errors.Is(err, jobs.ErrQueueFull) {
+		respondError(w, http.StatusServiceUnavailable, err.Error())
+		return
 	}
-
-	// Calculate total insulin
-	totalInsulin := mealInsulin + correctionInsulin
-
-	// Send the results
-	response := map[string]interface{}{
-		"success":       true,
-		"detectedFood":  foodAnalysisResult.Name,
-		"carbs":         foodAnalysisResult.Carbs,
-		"insulinDose":   totalInsulin,
-		"reasoning":     foodAnalysisResult.Reasoning,
-		"photoProvided": photoProvided,
-		"analysis": map[string]interface{}{
-			"dish":              foodAnalysisResult.Name,
-			"carbs":             foodAnalysisResult.Carbs,
-			"confidence":        foodAnalysisResult.Confidence,
-			"reasoning":         foodAnalysisResult.Reasoning,
-			"mealInsulin":       mealInsulin,
-			"correctionInsulin": correctionInsulin,
-			"totalInsulin":      totalInsulin,
-			"periodCoefficient": periodCoefficient,
-		},
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Error queueing analysis: %v", err))
+		return
 	}
 
-	fmt.Printf("AnalyzeFood: Sending response: %+v\n", response)
-	respondJSON(w, http.StatusOK, response)
+	log.Printf("AnalyzeFood: Queued analysis job %s", job.ID)
+	respondJSON(w, http.StatusAccepted, map[string]interface{}{
+		"success":   true,
+		"jobId":     job.ID,
+		"status":    job.Status,
+		"statusUrl": "/api/jobs/" + job.ID,
+	})
 }
 
 // SyncLibre handles POST /api/sync-libre
@@ -610,6 +884,223 @@ func (h *APIHandler) DeleteBloodSugar(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// SaveInsulinDose handles POST /api/doses
+func (h *APIHandler) SaveInsulinDose(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		UserID    string  `json:"userId"`
+		Units     float64 `json:"units"`
+		Type      string  `json:"type"`
+		MealID    string  `json:"mealId,omitempty"`
+		Note      string  `json:"note,omitempty"`
+		Timestamp string  `json:"timestamp,omitempty"` // RFC3339, defaults to now
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, fmt.Sprintf("Invalid request body: %v", err))
+		return
+	}
+
+	if req.UserID == "" {
+		respondError(w, http.StatusBadRequest, "Missing user ID")
+		return
+	}
+
+	if req.Units <= 0 || req.Units > 100 {
+		respondError(w, http.StatusBadRequest, "Invalid insulin units")
+		return
+	}
+
+	switch req.Type {
+	case "":
+		req.Type = models.DoseTypeBolus
+	case models.DoseTypeBolus, models.DoseTypeCorrection, models.DoseTypeBasal:
+	default:
+		respondError(w, http.StatusBadRequest, "Invalid dose type (expected bolus, correction or basal)")
+		return
+	}
+
+	timestamp := time.Now()
+	if req.Timestamp != "" {
+		var err error
+		timestamp, err = time.Parse(time.RFC3339, req.Timestamp)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "Invalid timestamp")
+			return
+		}
+	}
+
+	dose := &models.InsulinDose{
+		ID:        uuid.New().String(),
+		UserID:    req.UserID,
+		Timestamp: timestamp,
+		Units:     req.Units,
+		Type:      req.Type,
+		MealID:    req.MealID,
+		Note:      req.Note,
+	}
+
+	if err := h.storage.SaveInsulinDose(r.Context(), dose); err != nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Error saving dose: %v", err))
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"dose":    dose,
+	})
+}
+
+// GetInsulinDoses handles GET /api/doses/{userId}
+func (h *APIHandler) GetInsulinDoses(w http.ResponseWriter, r *http.Request) {
+	userId := mux.Vars(r)["userId"]
+
+	// Default to 1 week ago if no start date provided
+	startDate := time.Now().AddDate(0, 0, -7)
+	if startDateStr := r.URL.Query().Get("startDate"); startDateStr != "" {
+		var err error
+		startDate, err = time.Parse(time.RFC3339, startDateStr)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "Invalid startDate parameter")
+			return
+		}
+	}
+
+	doses, err := h.storage.GetInsulinDoses(r.Context(), userId, startDate)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Error fetching doses: %v", err))
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{"doses": doses})
+}
+
+// GetMealRecords handles GET /api/meals/{userId}
+func (h *APIHandler) GetMealRecords(w http.ResponseWriter, r *http.Request) {
+	userId := mux.Vars(r)["userId"]
+
+	// Default to 1 week ago if no start date provided
+	startDate := time.Now().AddDate(0, 0, -7)
+	if startDateStr := r.URL.Query().Get("startDate"); startDateStr != "" {
+		var err error
+		startDate, err = time.Parse(time.RFC3339, startDateStr)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "Invalid startDate parameter")
+			return
+		}
+	}
+
+	meals, err := h.storage.GetMealRecords(r.Context(), userId, startDate)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Error fetching meals: %v", err))
+		return
+	}
+
+	// Signed URLs let the browser load the photos directly, e.g. as <img> thumbnails
+	for i := range meals {
+		if meals[i].PhotoID != "" {
+			meals[i].PhotoURL = h.uploads.PhotoURL(meals[i].PhotoID)
+		}
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{"meals": meals})
+}
+
+// ConfirmMeal handles POST /api/meals/{userId}/{mealId}/eaten. An analyzed meal only counts
+// towards carbs on board once it is confirmed or a bolus is logged for it. eatenAt (RFC3339,
+// defaults to now) moves the meal to when it was eaten, which is when absorption starts.
+func (h *APIHandler) ConfirmMeal(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	var req struct {
+		EatenAt string `json:"eatenAt,omitempty"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		respondError(w, http.StatusBadRequest, fmt.Sprintf("Invalid request body: %v", err))
+		return
+	}
+
+	eatenAt := time.Now()
+	if req.EatenAt != "" {
+		var err error
+		eatenAt, err = time.Parse(time.RFC3339, req.EatenAt)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "Invalid eatenAt")
+			return
+		}
+	}
+
+	meal, err := h.storage.GetMealRecord(r.Context(), vars["userId"], vars["mealId"])
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Error fetching meal: %v", err))
+		return
+	}
+	if meal == nil {
+		respondError(w, http.StatusNotFound, "Meal not found")
+		return
+	}
+
+	meal.Timestamp = eatenAt
+	meal.EatenAt = &eatenAt
+	if err := h.storage.SaveMealRecord(r.Context(), meal); err != nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Error saving meal: %v", err))
+		return
+	}
+
+	if meal.PhotoID != "" {
+		meal.PhotoURL = h.uploads.PhotoURL(meal.PhotoID)
+	}
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"meal":    meal,
+	})
+}
+
+// GetPhoto handles GET /api/photos/{id}. The URL must carry a valid signature from
+// uploads.Store.PhotoURL; size=thumb returns a small version for list views.
+func (h *APIHandler) GetPhoto(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	query := r.URL.Query()
+
+	if !h.uploads.VerifyURL(id, query.Get("expires"), query.Get("sig")) {
+		respondError(w, http.StatusForbidden, "Invalid or expired photo link")
+		return
+	}
+
+	data, contentType, err := h.uploads.Open(r.Context(), id, query.Get("size") == "thumb")
+	if errors.Is(err, blobs.ErrNotFound) || errors.Is(err, blobs.ErrInvalidKey) {
+		respondError(w, http.StatusNotFound, "Photo not found")
+		return
+	}
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Error loading photo: %v", err))
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Cache-Control", "private, max-age=3600")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+}
+
+// GetProduct handles GET /api/products/{barcode}
+func (h *APIHandler) GetProduct(w http.ResponseWriter, r *http.Request) {
+	barcode := mux.Vars(r)["barcode"]
+
+	product, err := products.Lookup(r.Context(), h.storage, barcode)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, fmt.Sprintf("Error looking up barcode: %v", err))
+		return
+	}
+
+	if product == nil {
+		respondError(w, http.StatusNotFound, "Product not found")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{"product": product})
+}
+
 // Helper function to respond with JSON
 func respondJSON(w http.ResponseWriter, status int, payload interface{}) {
 	response, err := json.Marshal(payload)
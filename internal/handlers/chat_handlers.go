@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/yourusername/diabetes-assistant/internal/services/chat"
+)
+
+// ChatHandler handles the conversational assistant API
+type ChatHandler struct {
+	chat *chat.Service
+}
+
+// NewChatHandler creates a new chat handler
+func NewChatHandler(chatService *chat.Service) *ChatHandler {
+	return &ChatHandler{
+		chat: chatService,
+	}
+}
+
+// SendMessage handles POST /api/chat/{userId}.
+// The reply is streamed as Server-Sent Events when the client accepts text/event-stream,
+// otherwise it is returned as a single JSON response.
+func (h *ChatHandler) SendMessage(w http.ResponseWriter, r *http.Request) {
+	userID := mux.Vars(r)["userId"]
+
+	var req struct {
+		Message string `json:"message"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, fmt.Sprintf("Invalid request body: %v", err))
+		return
+	}
+
+	if strings.TrimSpace(req.Message) == "" {
+		respondError(w, http.StatusBadRequest, "Message is required")
+		return
+	}
+
+	if !strings.Contains(r.Header.Get("Accept"), "text/event-stream") {
+		reply, err := h.chat.SendMessage(r.Context(), userID, req.Message, func(string) error { return nil })
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, fmt.Sprintf("Error getting reply: %v", err))
+			return
+		}
+
+		respondJSON(w, http.StatusOK, map[string]interface{}{
+			"success": true,
+			"reply":   reply,
+		})
+		return
+	}
+
+	stream, err := newSSEStream(w)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	reply, err := h.chat.SendMessage(r.Context(), userID, req.Message, func(delta string) error {
+		return stream.Send("delta", map[string]string{"text": delta})
+	})
+	if err != nil {
+		log.Printf("Chat: error getting reply for user %s: %v", userID, err)
+		stream.Send("error", map[string]string{"error": fmt.Sprintf("Error getting reply: %v", err)})
+		return
+	}
+
+	stream.Send("done", map[string]interface{}{"reply": reply})
+}
+
+// GetHistory handles GET /api/chat/{userId}
+func (h *ChatHandler) GetHistory(w http.ResponseWriter, r *http.Request) {
+	userID := mux.Vars(r)["userId"]
+
+	history, err := h.chat.GetHistory(r.Context(), userID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Error fetching chat history: %v", err))
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{"messages": history})
+}
+
+// ClearHistory handles DELETE /api/chat/{userId}
+func (h *ChatHandler) ClearHistory(w http.ResponseWriter, r *http.Request) {
+	userID := mux.Vars(r)["userId"]
+
+	if err := h.chat.ClearHistory(r.Context(), userID); err != nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Error clearing chat history: %v", err))
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"message": "Chat history cleared",
+	})
+}
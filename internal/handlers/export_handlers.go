@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/yourusername/diabetes-assistant/internal/services/export"
+)
+
+// ExportHandler handles data exports
+type ExportHandler struct {
+	export *export.Service
+}
+
+// NewExportHandler creates a new export handler
+func NewExportHandler(exportService *export.Service) *ExportHandler {
+	return &ExportHandler{export: exportService}
+}
+
+// Export handles GET /api/export/{userId}?format=csv|json&from=&to=&tz=. from and to are RFC 3339
+// timestamps or dates, a date for to includes that whole day. Dates and the exported timestamps are
+// in the tz time zone, UTC by default. Everything up to now is exported without a range.
+func (h *ExportHandler) Export(w http.ResponseWriter, r *http.Request) {
+	userID := mux.Vars(r)["userId"]
+	query := r.URL.Query()
+
+	format := query.Get("format")
+	if format == "" {
+		format = export.FormatCSV
+	}
+	if format != export.FormatCSV && format != export.FormatJSON {
+		respondError(w, http.StatusBadRequest, "format must be csv or json")
+		return
+	}
+
+	opts, message := parseExportOptions(query)
+	if message != "" {
+		respondError(w, http.StatusBadRequest, message)
+		return
+	}
+
+	// Large ranges take longer than the server's write timeout. Headers can't change once
+	// streaming has started, so errors after this point are only logged.
+	http.NewResponseController(w).SetWriteDeadline(time.Time{})
+	filename := fmt.Sprintf("diabetes-export-%s", time.Now().In(opts.Location).Format("20060102"))
+	var err error
+	if format == export.FormatJSON {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename+".json"))
+		err = h.export.WriteJSON(r.Context(), w, userID, opts)
+	} else {
+		w.Header().Set("Content-Type", "application/zip")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename+".zip"))
+		err = h.export.WriteCSV(r.Context(), w, userID, opts)
+	}
+	if err != nil {
+		log.Printf("Export for user %s failed: %v", userID, err)
+	}
+}
+
+// ExportFHIR handles GET /api/fhir/{userId}/Bundle?from=&to=&tz=, a FHIR R4 Bundle of the user's
+// readings, meals and doses. The parameters are the same as for Export.
+func (h *ExportHandler) ExportFHIR(w http.ResponseWriter, r *http.Request) {
+	userID := mux.Vars(r)["userId"]
+
+	opts, message := parseExportOptions(r.URL.Query())
+	if message != "" {
+		respondError(w, http.StatusBadRequest, message)
+		return
+	}
+
+	http.NewResponseController(w).SetWriteDeadline(time.Time{})
+	w.Header().Set("Content-Type", "application/fhir+json")
+	if err := h.export.WriteFHIR(r.Context(), w, userID, opts); err != nil {
+		log.Printf("FHIR export for user %s failed: %v", userID, err)
+	}
+}
+
+// parseExportOptions reads the tz, from and to query parameters. It returns what is wrong with
+// them, or "" if they are valid.
+func parseExportOptions(query url.Values) (export.Options, string) {
+	loc := time.UTC
+	if tz := query.Get("tz"); tz != "" {
+		var err error
+		loc, err = time.LoadLocation(tz)
+		if err != nil {
+			return export.Options{}, "Invalid tz parameter, use an IANA time zone such as Europe/Moscow"
+		}
+	}
+
+	opts := export.Options{To: time.Now(), Location: loc}
+	if from := query.Get("from"); from != "" {
+		t, err := parseExportTime(from, loc, false)
+		if err != nil {
+			return export.Options{}, "Invalid from parameter"
+		}
+		opts.From = t
+	}
+	if to := query.Get("to"); to != "" {
+		t, err := parseExportTime(to, loc, true)
+		if err != nil {
+			return export.Options{}, "Invalid to parameter"
+		}
+		opts.To = t
+	}
+	if !opts.From.Before(opts.To) {
+		return export.Options{}, "from must be before to"
+	}
+	return opts, ""
+}
+
+// parseExportTime parses an RFC 3339 timestamp or a date in loc. A date is the start of the day,
+// or with endOfDay the start of the next one.
+func parseExportTime(s string, loc *time.Location, endOfDay bool) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	day, err := time.ParseInLocation("2006-01-02", s, loc)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if endOfDay {
+		return day.AddDate(0, 0, 1), nil
+	}
+	return day, nil
+}
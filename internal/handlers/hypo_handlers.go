@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/yourusername/diabetes-assistant/internal/services/hypo"
+	"github.com/yourusername/diabetes-assistant/internal/storage"
+)
+
+// HypoHandler handles the low treatment API
+type HypoHandler struct {
+	hypo    *hypo.Service
+	storage storage.Storage
+}
+
+// NewHypoHandler creates a new hypo treatment handler
+func NewHypoHandler(hypoService *hypo.Service, storage storage.Storage) *HypoHandler {
+	return &HypoHandler{
+		hypo:    hypoService,
+		storage: storage,
+	}
+}
+
+// GetTreatments handles GET /api/hypo/{userId}
+func (h *HypoHandler) GetTreatments(w http.ResponseWriter, r *http.Request) {
+	userID := mux.Vars(r)["userId"]
+
+	// Default to 1 week ago if no start date provided
+	startDate := time.Now().AddDate(0, 0, -7)
+	if startDateStr := r.URL.Query().Get("startDate"); startDateStr != "" {
+		var err error
+		startDate, err = time.Parse(time.RFC3339, startDateStr)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "Invalid startDate parameter")
+			return
+		}
+	}
+
+	treatments, err := h.storage.GetHypoTreatments(r.Context(), userID, startDate)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Error fetching treatments: %v", err))
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{"treatments": treatments})
+}
+
+// ConfirmTreatment handles POST /api/hypo/{userId}/{id}/confirm. The optional body
+// {"grams": 20, "name": "Juice"} records what was actually eaten.
+func (h *HypoHandler) ConfirmTreatment(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	var req struct {
+		Grams float64 `json:"grams"`
+		Name  string  `json:"name"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		respondError(w, http.StatusBadRequest, fmt.Sprintf("Invalid request body: %v", err))
+		return
+	}
+
+	if req.Grams < 0 || req.Grams > 200 {
+		respondError(w, http.StatusBadRequest, "grams must be between 0 and 200")
+		return
+	}
+
+	treatment, meal, err := h.hypo.Confirm(r.Context(), vars["userId"], vars["id"], req.Grams, req.Name)
+	switch {
+	case errors.Is(err, hypo.ErrNotFound):
+		respondError(w, http.StatusNotFound, "Treatment not found")
+		return
+	case errors.Is(err, hypo.ErrAlreadyConfirmed):
+		respondError(w, http.StatusConflict, "Treatment already confirmed")
+		return
+	case err != nil:
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Error confirming treatment: %v", err))
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"success":   true,
+		"treatment": treatment,
+		"meal":      meal,
+	})
+}
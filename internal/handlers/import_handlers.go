@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/yourusername/diabetes-assistant/internal/services/imports"
+)
+
+// maxImportSize is the largest accepted export file, years of sensor readings fit well below it
+const maxImportSize = 64 << 20
+
+// ImportHandler handles imports of CGM vendor exports
+type ImportHandler struct {
+	imports *imports.Service
+}
+
+// NewImportHandler creates a new import handler
+func NewImportHandler(importService *imports.Service) *ImportHandler {
+	return &ImportHandler{imports: importService}
+}
+
+// ImportReadings handles POST /api/import/{userId}, a multipart upload of a LibreView or Dexcom
+// Clarity CSV export in the "file" field. The optional "tz" field is the IANA time zone the
+// device clock was set to, UTC by default.
+func (h *ImportHandler) ImportReadings(w http.ResponseWriter, r *http.Request) {
+	userID := mux.Vars(r)["userId"]
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportSize)
+	if err := r.ParseMultipartForm(10 << 20); err != nil {
+		respondError(w, http.StatusBadRequest, fmt.Sprintf("Error parsing form: %v", err))
+		return
+	}
+
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "An export file is required in the file field")
+		return
+	}
+	defer file.Close()
+
+	loc := time.UTC
+	if tz := r.FormValue("tz"); tz != "" {
+		loc, err = time.LoadLocation(tz)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "Invalid tz field, use an IANA time zone such as Europe/Moscow")
+			return
+		}
+	}
+
+	// Years of readings take longer to save than the server's write timeout
+	http.NewResponseController(w).SetWriteDeadline(time.Time{})
+	stats, err := h.imports.Import(r.Context(), userID, file, loc)
+	if errors.Is(err, imports.ErrUnknownFormat) {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Error importing readings: %v", err))
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{"success": true, "import": stats})
+}
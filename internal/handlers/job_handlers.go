@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/yourusername/diabetes-assistant/internal/models"
+	"github.com/yourusername/diabetes-assistant/internal/services/jobs"
+	"github.com/yourusername/diabetes-assistant/internal/services/uploads"
+)
+
+// jobPollInterval is how often an event stream re-reads a job, which catches
+// updates made by workers on other replicas
+const jobPollInterval = 2 * time.Second
+
+// JobHandler handles the status of asynchronous analysis jobs
+type JobHandler struct {
+	jobs    *jobs.Queue
+	uploads *uploads.Store
+}
+
+// NewJobHandler creates a new job handler
+func NewJobHandler(jobQueue *jobs.Queue, uploadStore *uploads.Store) *JobHandler {
+	return &JobHandler{
+		jobs:    jobQueue,
+		uploads: uploadStore,
+	}
+}
+
+// GetJob handles GET /api/jobs/{id}.
+// With Accept: text/event-stream the job is streamed as "status" events until it is done or failed.
+func (h *JobHandler) GetJob(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	if !strings.Contains(r.Header.Get("Accept"), "text/event-stream") {
+		job, err := h.jobs.Get(r.Context(), id)
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, fmt.Sprintf("Error fetching job: %v", err))
+			return
+		}
+		if job == nil {
+			respondError(w, http.StatusNotFound, "Job not found")
+			return
+		}
+
+		respondJSON(w, http.StatusOK, h.present(job))
+		return
+	}
+
+	// Subscribe before reading the job so no update is missed in between
+	updates, unsubscribe := h.jobs.Subscribe(id)
+	defer unsubscribe()
+
+	job, err := h.jobs.Get(r.Context(), id)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Error fetching job: %v", err))
+		return
+	}
+	if job == nil {
+		respondError(w, http.StatusNotFound, "Job not found")
+		return
+	}
+
+	stream, err := newSSEStream(w)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	ticker := time.NewTicker(jobPollInterval)
+	defer ticker.Stop()
+
+	lastStatus := ""
+	for {
+		if job.Status != lastStatus {
+			if err := stream.Send("status", h.present(job)); err != nil {
+				return
+			}
+			lastStatus = job.Status
+		}
+		if job.Finished() {
+			return
+		}
+
+		select {
+		case <-r.Context().Done():
+			return
+		case job = <-updates:
+		case <-ticker.C:
+			latest, err := h.jobs.Get(r.Context(), id)
+			if err != nil || latest == nil {
+				continue
+			}
+			job = latest
+		}
+	}
+}
+
+// present adds a signed photo URL to a finished job
+func (h *JobHandler) present(job *models.AnalysisJob) *models.AnalysisJob {
+	if job.Result != nil && job.Request.PhotoID != "" {
+		result := *job.Result
+		result.PhotoURL = h.uploads.PhotoURL(job.Request.PhotoID)
+		job.Result = &result
+	}
+	return job
+}
@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/yourusername/diabetes-assistant/internal/services/nightscout"
+)
+
+// maxUploadSize is the largest accepted Nightscout upload, backfills after a long time offline
+// hold a few thousand entries
+const maxUploadSize = 10 << 20
+
+// NightscoutHandler serves the subset of the Nightscout API that uploaders such as xDrip+, Spike
+// and AndroidAPS use to push data. Uploads are authenticated with the api-secret header, which
+// maps them to a user.
+type NightscoutHandler struct {
+	nightscout *nightscout.Service
+}
+
+// NewNightscoutHandler creates a new Nightscout handler
+func NewNightscoutHandler(nightscoutService *nightscout.Service) *NightscoutHandler {
+	return &NightscoutHandler{nightscout: nightscoutService}
+}
+
+// CreateSecret handles POST /api/nightscout/{userId}/secret. It returns a new upload secret,
+// replacing the user's previous one, and the URL to enter in the uploader.
+func (h *NightscoutHandler) CreateSecret(w http.ResponseWriter, r *http.Request) {
+	userID := mux.Vars(r)["userId"]
+
+	secret, err := h.nightscout.CreateSecret(r.Context(), userID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Error creating secret: %v", err))
+		return
+	}
+
+	scheme := "http"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"secret": secret,
+		"url":    fmt.Sprintf("%s://%s@%s/api/v1/", scheme, secret, r.Host),
+	})
+}
+
+// GetStatus handles GET /api/v1/status, which uploaders call to check the server
+func (h *NightscoutHandler) GetStatus(w http.ResponseWriter, r *http.Request) {
+	now := time.Now()
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"status":            "ok",
+		"name":              "nightscout",
+		"version":           nightscout.Version,
+		"serverTime":        now.UTC().Format(time.RFC3339),
+		"serverTimeEpoch":   now.UnixMilli(),
+		"apiEnabled":        true,
+		"careportalEnabled": true,
+		"settings":          map[string]interface{}{"units": "mmol"},
+	})
+}
+
+// UploadEntries handles POST /api/v1/entries with one glucose entry or an array of them
+func (h *NightscoutHandler) UploadEntries(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.authorize(w, r)
+	if !ok {
+		return
+	}
+
+	var entries []nightscout.Entry
+	if err := decodeDocuments(w, r, &entries); err != nil {
+		respondError(w, http.StatusBadRequest, fmt.Sprintf("Invalid entries: %v", err))
+		return
+	}
+	if err := h.nightscout.SaveEntries(r.Context(), userID, entries); err != nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Error saving entries: %v", err))
+		return
+	}
+
+	respondJSON(w, http.StatusOK, entries)
+}
+
+// UploadTreatments handles POST /api/v1/treatments with one treatment or an array of them
+func (h *NightscoutHandler) UploadTreatments(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.authorize(w, r)
+	if !ok {
+		return
+	}
+
+	var treatments []nightscout.Treatment
+	if err := decodeDocuments(w, r, &treatments); err != nil {
+		respondError(w, http.StatusBadRequest, fmt.Sprintf("Invalid treatments: %v", err))
+		return
+	}
+	if err := h.nightscout.SaveTreatments(r.Context(), userID, treatments); err != nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Error saving treatments: %v", err))
+		return
+	}
+
+	respondJSON(w, http.StatusOK, treatments)
+}
+
+// UploadDeviceStatus handles POST /api/v1/devicestatus. Uploaders send the phone, pump and loop
+// status with every upload; it is accepted so they don't retry, but not stored.
+func (h *NightscoutHandler) UploadDeviceStatus(w http.ResponseWriter, r *http.Request) {
+	if _, ok := h.authorize(w, r); !ok {
+		return
+	}
+
+	var statuses []map[string]interface{}
+	if err := decodeDocuments(w, r, &statuses); err != nil {
+		respondError(w, http.StatusBadRequest, fmt.Sprintf("Invalid device status: %v", err))
+		return
+	}
+
+	respondJSON(w, http.StatusOK, statuses)
+}
+
+// authorize returns the user of the request's api-secret header. It responds with 401 and
+// returns false if the secret is missing or unknown.
+func (h *NightscoutHandler) authorize(w http.ResponseWriter, r *http.Request) (string, bool) {
+	userID, err := h.nightscout.Authenticate(r.Context(), r.Header.Get("api-secret"))
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Error checking API secret: %v", err))
+		return "", false
+	}
+	if userID == "" {
+		respondError(w, http.StatusUnauthorized, "Invalid API secret")
+		return "", false
+	}
+	return userID, true
+}
+
+// decodeDocuments reads a JSON array of documents, or a single document, into docs
+func decodeDocuments[T any](w http.ResponseWriter, r *http.Request, docs *[]T) error {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxUploadSize))
+	if err != nil {
+		return err
+	}
+
+	body = bytes.TrimSpace(body)
+	if len(body) > 0 && body[0] == '{' {
+		var doc T
+		if err := json.Unmarshal(body, &doc); err != nil {
+			return err
+		}
+		*docs = []T{doc}
+		return nil
+	}
+	return json.Unmarshal(body, docs)
+}
@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/yourusername/diabetes-assistant/internal/models"
+	"github.com/yourusername/diabetes-assistant/internal/services/notify"
+	"github.com/yourusername/diabetes-assistant/internal/storage"
+)
+
+// NotificationHandler handles the notification delivery API
+type NotificationHandler struct {
+	notify  *notify.Service
+	storage storage.Storage
+}
+
+// NewNotificationHandler creates a new notification handler
+func NewNotificationHandler(notifyService *notify.Service, storage storage.Storage) *NotificationHandler {
+	return &NotificationHandler{
+		notify:  notifyService,
+		storage: storage,
+	}
+}
+
+// GetNotifications handles GET /api/notifications/{userId}. With ?status=failed only the
+// dead-letter log is returned.
+func (h *NotificationHandler) GetNotifications(w http.ResponseWriter, r *http.Request) {
+	userID := mux.Vars(r)["userId"]
+
+	// Default to 1 week ago if no start date provided
+	startDate := time.Now().AddDate(0, 0, -7)
+	if startDateStr := r.URL.Query().Get("startDate"); startDateStr != "" {
+		var err error
+		startDate, err = time.Parse(time.RFC3339, startDateStr)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "Invalid startDate parameter")
+			return
+		}
+	}
+
+	notifications, err := h.storage.GetNotifications(r.Context(), userID, startDate)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Error fetching notifications: %v", err))
+		return
+	}
+
+	if status := r.URL.Query().Get("status"); status != "" {
+		filtered := []models.Notification{}
+		for _, notification := range notifications {
+			if notification.Status == status {
+				filtered = append(filtered, notification)
+			}
+		}
+		notifications = filtered
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{"notifications": notifications})
+}
+
+// SendTest handles POST /api/notifications/{userId}/test, it sends a test notification to every
+// enabled channel and returns the outcome of the first attempts
+func (h *NotificationHandler) SendTest(w http.ResponseWriter, r *http.Request) {
+	userID := mux.Vars(r)["userId"]
+
+	notifications, err := h.notify.Test(r.Context(), userID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Error sending test notification: %v", err))
+		return
+	}
+	if len(notifications) == 0 {
+		respondError(w, http.StatusBadRequest, "No notification channels are enabled")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{"notifications": notifications})
+}
+
+// RetryNotification handles POST /api/notifications/{userId}/{id}/retry, it attempts a failed
+// delivery again
+func (h *NotificationHandler) RetryNotification(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	notification, err := h.notify.Retry(r.Context(), vars["userId"], vars["id"])
+	switch {
+	case errors.Is(err, notify.ErrNotFound):
+		respondError(w, http.StatusNotFound, "Notification not found")
+		return
+	case errors.Is(err, notify.ErrNotFailed):
+		respondError(w, http.StatusConflict, "Only failed notifications can be retried")
+		return
+	case err != nil:
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Error retrying notification: %v", err))
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{"success": true, "notification": notification})
+}
@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/yourusername/diabetes-assistant/internal/services/prediction"
+)
+
+// PredictionHandler handles the glucose forecast API
+type PredictionHandler struct {
+	prediction *prediction.Service
+}
+
+// NewPredictionHandler creates a new prediction handler
+func NewPredictionHandler(predictionService *prediction.Service) *PredictionHandler {
+	return &PredictionHandler{
+		prediction: predictionService,
+	}
+}
+
+// GetForecast handles GET /api/predict/{userId}. The optional minutes parameter sets the
+// horizon, 30 to 180 minutes (default 180).
+func (h *PredictionHandler) GetForecast(w http.ResponseWriter, r *http.Request) {
+	userID := mux.Vars(r)["userId"]
+
+	horizon := prediction.DefaultHorizon
+	if minutesStr := r.URL.Query().Get("minutes"); minutesStr != "" {
+		minutes, err := strconv.Atoi(minutesStr)
+		if err != nil || time.Duration(minutes)*time.Minute < prediction.MinHorizon || time.Duration(minutes)*time.Minute > prediction.MaxHorizon {
+			respondError(w, http.StatusBadRequest, "minutes must be a number between 30 and 180")
+			return
+		}
+		horizon = time.Duration(minutes) * time.Minute
+	}
+
+	forecast, err := h.prediction.Forecast(r.Context(), userID, horizon)
+	if errors.Is(err, prediction.ErrNoGlucose) {
+		respondError(w, http.StatusUnprocessableEntity, "No glucose reading in the last 15 minutes to predict from")
+		return
+	}
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Error predicting glucose: %v", err))
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{"forecast": forecast})
+}
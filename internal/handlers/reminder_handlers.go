@@ -0,0 +1,203 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gorilla/mux"
+	"github.com/yourusername/diabetes-assistant/internal/models"
+	"github.com/yourusername/diabetes-assistant/internal/services/reminders"
+	"github.com/yourusername/diabetes-assistant/internal/storage"
+)
+
+// maxReminderIntervalDays is the longest interval of a repeating reminder
+const maxReminderIntervalDays = 90
+
+// reminderTypes are the reminder types users can create; recheck reminders after a low are
+// only scheduled by the hypo treatment advisor
+var reminderTypes = map[string]bool{
+	models.ReminderBasal:        true,
+	models.ReminderSensorChange: true,
+	models.ReminderNeedleChange: true,
+	models.ReminderMealCheck:    true,
+	models.ReminderCheck:        true,
+}
+
+// ReminderHandler handles the reminders API
+type ReminderHandler struct {
+	reminders *reminders.Service
+	storage   storage.Storage
+}
+
+// NewReminderHandler creates a new reminder handler
+func NewReminderHandler(reminderService *reminders.Service, storage storage.Storage) *ReminderHandler {
+	return &ReminderHandler{
+		reminders: reminderService,
+		storage:   storage,
+	}
+}
+
+// reminderRequest is the body of creating or changing a reminder. The type can't be changed.
+type reminderRequest struct {
+	Type         string     `json:"type"`
+	Note         string     `json:"note"`
+	DueAt        *time.Time `json:"dueAt"`
+	TimeOfDay    string     `json:"timeOfDay"`
+	IntervalDays int        `json:"intervalDays"`
+}
+
+// reminder returns the reminder the request describes
+func (req *reminderRequest) reminder() models.Reminder {
+	reminder := models.Reminder{
+		Type:         req.Type,
+		Note:         req.Note,
+		TimeOfDay:    req.TimeOfDay,
+		IntervalDays: req.IntervalDays,
+	}
+	if req.DueAt != nil {
+		reminder.DueAt = *req.DueAt
+	}
+	return reminder
+}
+
+// GetReminders handles GET /api/reminders/{userId}: pending reminders and the others due in the
+// last week, or since ?startDate
+func (h *ReminderHandler) GetReminders(w http.ResponseWriter, r *http.Request) {
+	userID := mux.Vars(r)["userId"]
+
+	// Default to 1 week ago if no start date provided
+	startDate := time.Now().AddDate(0, 0, -7)
+	if startDateStr := r.URL.Query().Get("startDate"); startDateStr != "" {
+		var err error
+		startDate, err = time.Parse(time.RFC3339, startDateStr)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "Invalid startDate parameter")
+			return
+		}
+	}
+
+	userReminders, err := h.storage.GetReminders(r.Context(), userID, startDate)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Error fetching reminders: %v", err))
+		return
+	}
+
+	if status := r.URL.Query().Get("status"); status != "" {
+		filtered := []models.Reminder{}
+		for _, reminder := range userReminders {
+			if reminder.Status == status {
+				filtered = append(filtered, reminder)
+			}
+		}
+		userReminders = filtered
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{"reminders": userReminders})
+}
+
+// CreateReminder handles POST /api/reminders/{userId}, e.g. {"type": "basal", "timeOfDay": "22:00",
+// "note": "Tresiba 18 U"} or {"type": "sensor_change", "dueAt": "…", "intervalDays": 14}
+func (h *ReminderHandler) CreateReminder(w http.ResponseWriter, r *http.Request) {
+	userID := mux.Vars(r)["userId"]
+
+	var req reminderRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, fmt.Sprintf("Invalid request body: %v", err))
+		return
+	}
+	if !reminderTypes[req.Type] {
+		respondError(w, http.StatusBadRequest, "type must be basal, sensor_change, needle_change, meal_check or check")
+		return
+	}
+	if msg := validateReminder(&req, time.Now()); msg != "" {
+		respondError(w, http.StatusBadRequest, msg)
+		return
+	}
+
+	reminder := req.reminder()
+	reminder.UserID = userID
+	if err := h.reminders.Create(r.Context(), &reminder); err != nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Error creating reminder: %v", err))
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, map[string]interface{}{"success": true, "reminder": reminder})
+}
+
+// UpdateReminder handles PUT /api/reminders/{userId}/{id}, replacing the note and schedule of a
+// pending reminder
+func (h *ReminderHandler) UpdateReminder(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	var req reminderRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, fmt.Sprintf("Invalid request body: %v", err))
+		return
+	}
+	if msg := validateReminder(&req, time.Now()); msg != "" {
+		respondError(w, http.StatusBadRequest, msg)
+		return
+	}
+
+	reminder, err := h.reminders.Update(r.Context(), vars["userId"], vars["id"], req.reminder())
+	if err != nil {
+		respondReminderError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{"success": true, "reminder": reminder})
+}
+
+// DeleteReminder handles DELETE /api/reminders/{userId}/{id}. The reminder is kept as cancelled.
+func (h *ReminderHandler) DeleteReminder(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	reminder, err := h.reminders.Cancel(r.Context(), vars["userId"], vars["id"])
+	if err != nil {
+		respondReminderError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{"success": true, "reminder": reminder})
+}
+
+// validateReminder checks the schedule of a reminder and returns a message describing the
+// first problem, or "" if it's valid
+func validateReminder(req *reminderRequest, now time.Time) string {
+	if utf8.RuneCountInString(req.Note) > 200 {
+		return "note must be at most 200 characters"
+	}
+	if req.TimeOfDay != "" && req.IntervalDays != 0 {
+		return "a reminder repeats either daily at timeOfDay or every intervalDays, not both"
+	}
+	if req.TimeOfDay != "" && !models.IsClock(req.TimeOfDay) {
+		return "timeOfDay must be in HH:MM format"
+	}
+	if req.IntervalDays < 0 || req.IntervalDays > maxReminderIntervalDays {
+		return fmt.Sprintf("intervalDays must be between 1 and %d", maxReminderIntervalDays)
+	}
+	if req.TimeOfDay == "" {
+		if req.DueAt == nil {
+			return "dueAt is required unless the reminder repeats daily at timeOfDay"
+		}
+		if req.IntervalDays == 0 && req.DueAt.Before(now.Add(-time.Minute)) {
+			return "dueAt must not be in the past"
+		}
+	}
+	return ""
+}
+
+func respondReminderError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, reminders.ErrNotFound):
+		respondError(w, http.StatusNotFound, "Reminder not found")
+	case errors.Is(err, reminders.ErrNotPending):
+		respondError(w, http.StatusConflict, "Reminder is no longer pending")
+	default:
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Error updating reminder: %v", err))
+	}
+}
@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/yourusername/diabetes-assistant/internal/services/reports"
+)
+
+// ReportHandler handles clinic reports
+type ReportHandler struct {
+	reports *reports.Service
+}
+
+// NewReportHandler creates a new report handler
+func NewReportHandler(reportService *reports.Service) *ReportHandler {
+	return &ReportHandler{reports: reportService}
+}
+
+// GetPDF handles GET /api/reports/{userId}/pdf?from=&to=&tz=, a printable report for a clinic
+// visit. The parameters are the same as for exports; without from the report covers the
+// reports.DefaultDays days up to and including the day of to.
+func (h *ReportHandler) GetPDF(w http.ResponseWriter, r *http.Request) {
+	userID := mux.Vars(r)["userId"]
+	query := r.URL.Query()
+
+	opts, message := parseExportOptions(query)
+	if message != "" {
+		respondError(w, http.StatusBadRequest, message)
+		return
+	}
+	if query.Get("from") == "" {
+		opts.From = reports.DefaultFrom(opts.To, opts.Location)
+	}
+	if opts.From.Before(opts.To.In(opts.Location).AddDate(0, 0, -reports.MaxDays)) {
+		respondError(w, http.StatusBadRequest, fmt.Sprintf("A report covers at most %d days", reports.MaxDays))
+		return
+	}
+
+	var buf bytes.Buffer
+	err := h.reports.WritePDF(r.Context(), &buf, userID, reports.Options{From: opts.From, To: opts.To, Location: opts.Location})
+	if errors.Is(err, reports.ErrUserNotFound) {
+		respondError(w, http.StatusNotFound, "User not found")
+		return
+	}
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Error generating report: %v", err))
+		return
+	}
+
+	// Name the report after the last day it covers
+	lastDay := opts.To.Add(-time.Nanosecond).In(opts.Location)
+	filename := fmt.Sprintf("glucose-report-%s.pdf", lastDay.Format("20060102"))
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("inline; filename=%q", filename))
+	w.Write(buf.Bytes())
+}
@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/yourusername/diabetes-assistant/internal/models"
+	"github.com/yourusername/diabetes-assistant/internal/storage"
+)
+
+// telegramLinkTTL is how long a Telegram link code can be used
+const telegramLinkTTL = 15 * time.Minute
+
+// linkCodeAlphabet leaves out characters that are easily confused, such as 0 and O
+const linkCodeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+// TelegramHandler handles linking Telegram chats to users
+type TelegramHandler struct {
+	storage storage.Storage
+	botName string
+}
+
+// NewTelegramHandler creates a new Telegram handler. botName is the bot's username, used for
+// t.me links; without it only the /link command is returned.
+func NewTelegramHandler(storage storage.Storage, botName string) *TelegramHandler {
+	return &TelegramHandler{
+		storage: storage,
+		botName: botName,
+	}
+}
+
+// CreateLinkCode handles POST /api/telegram/{userId}/link-code. The user sends the code to the
+// bot, or opens the returned t.me link, to link their chat.
+func (h *TelegramHandler) CreateLinkCode(w http.ResponseWriter, r *http.Request) {
+	userID := mux.Vars(r)["userId"]
+
+	code, err := newLinkCode()
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Error creating link code: %v", err))
+		return
+	}
+
+	linkCode := &models.TelegramLinkCode{
+		Code:      code,
+		UserID:    userID,
+		ExpiresAt: time.Now().Add(telegramLinkTTL),
+	}
+	if err := h.storage.SaveTelegramLinkCode(r.Context(), linkCode); err != nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Error saving link code: %v", err))
+		return
+	}
+
+	response := map[string]interface{}{
+		"code":      linkCode.Code,
+		"expiresAt": linkCode.ExpiresAt,
+		"command":   "/link " + linkCode.Code,
+	}
+	if h.botName != "" {
+		response["url"] = fmt.Sprintf("https://t.me/%s?start=%s", h.botName, linkCode.Code)
+	}
+	respondJSON(w, http.StatusOK, response)
+}
+
+// newLinkCode returns a random 8 character code
+func newLinkCode() (string, error) {
+	random := make([]byte, 8)
+	if _, err := rand.Read(random); err != nil {
+		return "", err
+	}
+
+	code := make([]byte, len(random))
+	for i, b := range random {
+		code[i] = linkCodeAlphabet[int(b)%len(linkCodeAlphabet)]
+	}
+	return string(code), nil
+}
@@ -0,0 +1,52 @@
+package models
+
+import "time"
+
+// AI call operations
+const (
+	AIOperationFoodAnalysis   = "food_analysis"
+	AIOperationNutritionLabel = "nutrition_label"
+	AIOperationChat           = "chat"
+)
+
+// AIUsage records a single call to an AI provider
+type AIUsage struct {
+	ID           string    `json:"id" bson:"_id"`
+	UserID       string    `json:"userId" bson:"userId"`
+	Timestamp    time.Time `json:"timestamp" bson:"timestamp"`
+	Provider     string    `json:"provider" bson:"provider"`
+	Model        string    `json:"model" bson:"model"`
+	Operation    string    `json:"operation" bson:"operation"` // food_analysis, nutrition_label or chat
+	InputTokens  int       `json:"inputTokens" bson:"inputTokens"`
+	OutputTokens int       `json:"outputTokens" bson:"outputTokens"`
+	LatencyMs    int64     `json:"latencyMs" bson:"latencyMs"`
+	CostUSD      float64   `json:"costUsd" bson:"costUsd"` // Estimated from list prices, 0 for unknown models
+	Error        string    `json:"error,omitempty" bson:"error,omitempty"`
+}
+
+// AIUsageSummary aggregates AI calls of one user on one day
+type AIUsageSummary struct {
+	UserID       string  `json:"userId"`
+	Date         string  `json:"date"` // YYYY-MM-DD in server time
+	Calls        int     `json:"calls"`
+	Errors       int     `json:"errors"`
+	InputTokens  int     `json:"inputTokens"`
+	OutputTokens int     `json:"outputTokens"`
+	CostUSD      float64 `json:"costUsd"`
+	AvgLatencyMs int64   `json:"avgLatencyMs"`
+}
+
+// AIQuota overrides the default daily number of AI food analyses for a user
+type AIQuota struct {
+	UserID     string    `json:"userId" bson:"_id"`
+	DailyLimit int       `json:"dailyLimit" bson:"dailyLimit"` // 0 means unlimited
+	UpdatedAt  time.Time `json:"updatedAt" bson:"updatedAt"`
+}
+
+// AIQuotaStatus is a user's daily quota and how much of it is used
+type AIQuotaStatus struct {
+	UserID     string `json:"userId"`
+	DailyLimit int    `json:"dailyLimit"` // 0 means unlimited
+	UsedToday  int    `json:"usedToday"`
+	Custom     bool   `json:"custom"` // The limit is a per-user override rather than the default
+}
@@ -0,0 +1,151 @@
+package models
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Alert types
+const (
+	AlertUrgentLow      = "urgent_low"
+	AlertLow            = "low"
+	AlertHigh           = "high"
+	AlertRapidRise      = "rapid_rise"
+	AlertRapidFall      = "rapid_fall"
+	AlertPredictedLow   = "predicted_low"
+	AlertStaleData      = "stale_data"
+	AlertPersistentHigh = "persistent_high"
+)
+
+// Alert severities
+const (
+	AlertSeverityUrgent  = "urgent" // Delivered even during quiet hours
+	AlertSeverityWarning = "warning"
+	AlertSeverityInfo    = "info"
+)
+
+// Alert is raised when a rule matches the user's glucose
+type Alert struct {
+	ID        string    `json:"id" bson:"_id"`
+	UserID    string    `json:"userId" bson:"userId"`
+	Type      string    `json:"type" bson:"type"`
+	Severity  string    `json:"severity" bson:"severity"`
+	CreatedAt time.Time `json:"createdAt" bson:"createdAt"`
+	Glucose   float64   `json:"glucose,omitempty" bson:"glucose,omitempty"` // The reading or forecast that matched, in mmol/L
+	Message   string    `json:"message" bson:"message"`
+	// Raised during quiet hours, stored but not delivered
+	Silenced bool `json:"silenced" bson:"silenced"`
+
+	AcknowledgedAt *time.Time `json:"acknowledgedAt,omitempty" bson:"acknowledgedAt,omitempty"`
+	// No new alert of this type is raised before this time
+	SnoozedUntil *time.Time `json:"snoozedUntil,omitempty" bson:"snoozedUntil,omitempty"`
+}
+
+// AlertRule configures one alert type
+type AlertRule struct {
+	Enabled bool `json:"enabled" bson:"enabled"`
+	// In mmol/L, or mmol/L per minute for rapid rise and fall; unused for stale data
+	Threshold float64 `json:"threshold" bson:"threshold"`
+	// How far ahead a low is predicted, how long without readings data is stale,
+	// or how long glucose must stay high
+	Minutes int `json:"minutes,omitempty" bson:"minutes,omitempty"`
+	// How long the rule stays quiet after it fires or its alert is acknowledged
+	SnoozeMinutes int `json:"snoozeMinutes" bson:"snoozeMinutes"`
+}
+
+// QuietHours is a daily period in which only urgent alerts are delivered
+type QuietHours struct {
+	Enabled bool   `json:"enabled" bson:"enabled"`
+	Start   string `json:"start" bson:"start"` // "HH:MM"
+	End     string `json:"end" bson:"end"`     // "HH:MM", may be past midnight
+}
+
+// AlertSettings are the user's alert rules
+type AlertSettings struct {
+	UrgentLow      AlertRule  `json:"urgentLow" bson:"urgentLow"`
+	Low            AlertRule  `json:"low" bson:"low"`
+	High           AlertRule  `json:"high" bson:"high"`
+	RapidRise      AlertRule  `json:"rapidRise" bson:"rapidRise"`
+	RapidFall      AlertRule  `json:"rapidFall" bson:"rapidFall"`
+	PredictedLow   AlertRule  `json:"predictedLow" bson:"predictedLow"`
+	StaleData      AlertRule  `json:"staleData" bson:"staleData"`
+	PersistentHigh AlertRule  `json:"persistentHigh" bson:"persistentHigh"`
+	QuietHours     QuietHours `json:"quietHours" bson:"quietHours"`
+}
+
+// DefaultAlertSettings are used until the user configures alerts
+var DefaultAlertSettings = AlertSettings{
+	UrgentLow:      AlertRule{Enabled: true, Threshold: 3.0, SnoozeMinutes: 15},
+	Low:            AlertRule{Enabled: true, Threshold: 3.9, SnoozeMinutes: 30},
+	High:           AlertRule{Enabled: true, Threshold: 13.9, SnoozeMinutes: 60},
+	RapidRise:      AlertRule{Enabled: true, Threshold: 0.17, SnoozeMinutes: 30},
+	RapidFall:      AlertRule{Enabled: true, Threshold: 0.17, SnoozeMinutes: 30},
+	PredictedLow:   AlertRule{Enabled: true, Threshold: 3.9, Minutes: 20, SnoozeMinutes: 30},
+	StaleData:      AlertRule{Enabled: true, Minutes: 20, SnoozeMinutes: 60},
+	PersistentHigh: AlertRule{Enabled: true, Threshold: 10.0, Minutes: 120, SnoozeMinutes: 120},
+	QuietHours:     QuietHours{Start: "22:00", End: "07:00"},
+}
+
+// Rule returns the rule of an alert type
+func (a *AlertSettings) Rule(alertType string) AlertRule {
+	switch alertType {
+	case AlertUrgentLow:
+		return a.UrgentLow
+	case AlertLow:
+		return a.Low
+	case AlertHigh:
+		return a.High
+	case AlertRapidRise:
+		return a.RapidRise
+	case AlertRapidFall:
+		return a.RapidFall
+	case AlertPredictedLow:
+		return a.PredictedLow
+	case AlertStaleData:
+		return a.StaleData
+	case AlertPersistentHigh:
+		return a.PersistentHigh
+	}
+	return AlertRule{}
+}
+
+// Contains reports whether the time of day of t is within the quiet hours
+func (q QuietHours) Contains(t time.Time) bool {
+	if !q.Enabled {
+		return false
+	}
+	start, startOK := minuteOfDay(q.Start)
+	end, endOK := minuteOfDay(q.End)
+	if !startOK || !endOK {
+		return false
+	}
+
+	const day = 24 * 60
+	length := ((end-start)%day + day) % day
+	offset := ((t.Hour()*60+t.Minute()-start)%day + day) % day
+	return offset < length
+}
+
+// IsClock reports whether s is a time of day in "HH:MM" form
+func IsClock(s string) bool {
+	_, ok := minuteOfDay(s)
+	return ok
+}
+
+// minuteOfDay parses "HH:MM" into minutes since midnight
+func minuteOfDay(s string) (int, bool) {
+	parts := strings.Split(s, ":")
+	if len(parts) != 2 {
+		return 0, false
+	}
+	hour, err := strconv.Atoi(parts[0])
+	if err != nil || hour < 0 || hour > 23 {
+		return 0, false
+	}
+	minute, err := strconv.Atoi(parts[1])
+	if err != nil || minute < 0 || minute > 59 {
+		return 0, false
+	}
+	return hour*60 + minute, true
+}
@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// AnalysisCacheEntry is a cached AI food analysis. The key is a hash of the image,
+// the rendered prompt and the provider, so identical requests reuse the result.
+type AnalysisCacheEntry struct {
+	Key       string    `json:"key" bson:"_id"`
+	Result    string    `json:"result" bson:"result"` // JSON-encoded analysis result
+	Provider  string    `json:"provider" bson:"provider"`
+	CreatedAt time.Time `json:"createdAt" bson:"createdAt"`
+	ExpiresAt time.Time `json:"expiresAt" bson:"expiresAt"`
+}
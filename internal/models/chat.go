@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// ChatMessage represents a single message in a user's conversation with the assistant
+type ChatMessage struct {
+	ID        string    `json:"id" bson:"_id"`
+	UserID    string    `json:"userId" bson:"userId"`
+	Role      string    `json:"role" bson:"role"` // user or assistant
+	Content   string    `json:"content" bson:"content"`
+	Timestamp time.Time `json:"timestamp" bson:"timestamp"`
+	// Guarded is set when dose numbers were removed from an assistant reply
+	Guarded bool `json:"guarded,omitempty" bson:"guarded,omitempty"`
+}
@@ -1,5 +1,7 @@
 package models
 
+import "time"
+
 // LibreViewCredentials contains authentication information for the LibreView API
 type LibreViewCredentials struct {
 	Email    string `json:"email" bson:"email"`
@@ -11,3 +13,12 @@ type NightscoutCredentials struct {
 	URL       string `json:"url" bson:"url"`
 	APISecret string `json:"apiSecret" bson:"apiSecret"`
 }
+
+// NightscoutSecret is the API secret Nightscout uploaders such as xDrip+ and AndroidAPS use to push
+// a user's data to us. Only its SHA-1 hash is stored, which is what uploaders send in the api-secret
+// header. A user has one secret, creating a new one replaces it.
+type NightscoutSecret struct {
+	UserID    string    `json:"userId" bson:"_id"`
+	Hash      string    `json:"-" bson:"hash"` // Lowercase hex SHA-1 of the secret
+	CreatedAt time.Time `json:"createdAt" bson:"createdAt"`
+}
@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// Insulin dose types
+const (
+	DoseTypeBolus      = "bolus"
+	DoseTypeCorrection = "correction"
+	DoseTypeBasal      = "basal"
+)
+
+// InsulinDose represents an insulin injection logged by the user
+type InsulinDose struct {
+	ID        string    `json:"id" bson:"_id"`
+	UserID    string    `json:"userId" bson:"userId"`
+	Timestamp time.Time `json:"timestamp" bson:"timestamp"`
+	Units     float64   `json:"units" bson:"units"`                       // Insulin units injected
+	Type      string    `json:"type" bson:"type"`                         // bolus, correction or basal
+	MealID    string    `json:"mealId,omitempty" bson:"mealId,omitempty"` // Meal the bolus was taken for
+	Note      string    `json:"note,omitempty" bson:"note,omitempty"`
+}
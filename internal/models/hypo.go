@@ -0,0 +1,43 @@
+package models
+
+import "time"
+
+// Hypo treatment statuses
+const (
+	HypoTreatmentSuggested = "suggested" // Advice given, the user hasn't confirmed eating yet
+	HypoTreatmentConfirmed = "confirmed" // Eaten and logged as a meal
+)
+
+// HypoTreatment is the fast carbohydrate suggested for a low reading, with the recheck
+// reminder that follows it
+type HypoTreatment struct {
+	ID        string    `json:"id" bson:"_id"`
+	UserID    string    `json:"userId" bson:"userId"`
+	CreatedAt time.Time `json:"createdAt" bson:"createdAt"`
+	Status    string    `json:"status" bson:"status"` // suggested or confirmed
+
+	// How the suggestion was computed
+	Glucose       float64 `json:"glucose" bson:"glucose"`             // The low reading in mmol/L
+	TargetGlucose float64 `json:"targetGlucose" bson:"targetGlucose"` // Middle of the target range
+	IOB           float64 `json:"iob" bson:"iob"`                     // Insulin on board in units
+	IOBCarbs      float64 `json:"iobCarbs" bson:"iobCarbs"`           // Grams covering the insulin on board
+	COB           float64 `json:"cob" bson:"cob"`
+	AbsorbingSoon float64 `json:"absorbingSoon" bson:"absorbingSoon"` // Carbs on board absorbed within 30 minutes, subtracted
+	Grams         float64 `json:"grams" bson:"grams"`                 // Suggested fast carbs
+	Message       string  `json:"message" bson:"message"`
+
+	// The reminder to check glucose again. RecheckedAt is set when a reading arrives before it's sent.
+	RecheckAt     time.Time  `json:"recheckAt" bson:"recheckAt"`
+	RecheckSentAt *time.Time `json:"recheckSentAt,omitempty" bson:"recheckSentAt,omitempty"`
+	RecheckedAt   *time.Time `json:"recheckedAt,omitempty" bson:"recheckedAt,omitempty"`
+
+	// Set once the user confirms the treatment
+	ConfirmedAt    *time.Time `json:"confirmedAt,omitempty" bson:"confirmedAt,omitempty"`
+	ConfirmedGrams float64    `json:"confirmedGrams,omitempty" bson:"confirmedGrams,omitempty"`
+	MealID         string     `json:"mealId,omitempty" bson:"mealId,omitempty"`
+}
+
+// RecheckPending reports whether the recheck reminder is neither sent nor made unnecessary by a new reading
+func (t *HypoTreatment) RecheckPending() bool {
+	return t.RecheckSentAt == nil && t.RecheckedAt == nil
+}
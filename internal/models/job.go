@@ -0,0 +1,111 @@
+package models
+
+import "time"
+
+// Analysis job statuses
+const (
+	JobStatusQueued  = "queued"
+	JobStatusRunning = "running"
+	JobStatusDone    = "done"
+	JobStatusFailed  = "failed"
+)
+
+// AnalysisRequest holds the inputs of a food analysis. The photo is referenced by its
+// blob key so a job can be picked up again after a restart.
+type AnalysisRequest struct {
+	UserID      string  `json:"userId" bson:"userId"`
+	PhotoID     string  `json:"photoId,omitempty" bson:"photoId,omitempty"`
+	Description string  `json:"description,omitempty" bson:"description,omitempty"`
+	UserNotes   string  `json:"notes,omitempty" bson:"notes,omitempty"`
+	MealContext string  `json:"mealContext,omitempty" bson:"mealContext,omitempty"`
+	Barcode     string  `json:"barcode,omitempty" bson:"barcode,omitempty"`
+	Label       bool    `json:"label,omitempty" bson:"label,omitempty"` // Read a nutrition facts label instead of estimating a dish
+	Weight      float64 `json:"foodWeight,omitempty" bson:"weight,omitempty"`
+}
+
+// MealAnalysis is the result of a food analysis
+type MealAnalysis struct {
+	MealID        string              `json:"mealId" bson:"mealId"`
+	DetectedFood  string              `json:"detectedFood" bson:"detectedFood"`
+	Carbs         float64             `json:"carbs" bson:"carbs"`
+	InsulinDose   float64             `json:"insulinDose" bson:"insulinDose"`
+	Reasoning     string              `json:"reasoning" bson:"reasoning"`
+	PhotoProvided bool                `json:"photoProvided" bson:"photoProvided"`
+	PhotoURL      string              `json:"photoUrl,omitempty" bson:"-"` // Signed when the job is returned
+	Cached        bool                `json:"cached" bson:"cached"`        // The AI result came from the analysis cache
+	Analysis      MealAnalysisDetails `json:"analysis" bson:"analysis"`
+}
+
+// MealAnalysisDetails breaks down the carb estimate and the suggested dose
+type MealAnalysisDetails struct {
+	Dish              string  `json:"dish" bson:"dish"`
+	Carbs             float64 `json:"carbs" bson:"carbs"`
+	Confidence        float64 `json:"confidence" bson:"confidence"`
+	Reasoning         string  `json:"reasoning" bson:"reasoning"`
+	MealInsulin       float64 `json:"mealInsulin" bson:"mealInsulin"`
+	CorrectionInsulin float64 `json:"correctionInsulin" bson:"correctionInsulin"`
+	TotalInsulin      float64 `json:"totalInsulin" bson:"totalInsulin"`
+	PeriodCoefficient float64 `json:"periodCoefficient" bson:"periodCoefficient"`
+	PromptVersion     string  `json:"promptVersion" bson:"promptVersion"`
+	Source            string  `json:"source" bson:"source"`
+	CarbsPer100g      float64 `json:"carbsPer100g" bson:"carbsPer100g"`
+	CarbsPerServing   float64 `json:"carbsPerServing" bson:"carbsPerServing"`
+	Weight            float64 `json:"weight" bson:"weight"`
+	Fat               float64 `json:"fat" bson:"fat"`
+	Protein           float64 `json:"protein" bson:"protein"`
+	Absorption        string  `json:"absorption" bson:"absorption"` // fast, medium or slow
+
+	// Suggested split for meals with enough fat and protein, TotalInsulin is then the immediate part
+	ExtendedBolus *ExtendedBolus `json:"extendedBolus,omitempty" bson:"extendedBolus,omitempty"`
+
+	// When to inject relative to the meal
+	PreBolus *PreBolusAdvice `json:"preBolus,omitempty" bson:"preBolus,omitempty"`
+
+	// Predicted outcome of the suggested dose, nil without a recent CGM reading
+	DoseCheck *DoseCheck `json:"doseCheck,omitempty" bson:"doseCheck,omitempty"`
+}
+
+// PreBolusAdvice recommends when to inject relative to eating, based on the current glucose,
+// its trend and how fast the meal raises blood sugar
+type PreBolusAdvice struct {
+	Action      string  `json:"action" bson:"action"`             // wait, with_meal or after_meal
+	WaitMinutes int     `json:"waitMinutes" bson:"waitMinutes"`   // Minutes between injecting and eating when action is wait
+	Glucose     float64 `json:"glucose,omitempty" bson:"glucose"` // Latest CGM reading in mmol/L, 0 without recent data
+	Trend       float64 `json:"trend" bson:"trend"`               // Rate of change in mmol/L per minute
+	TrendKnown  bool    `json:"trendKnown" bson:"trendKnown"`
+	MealProfile string  `json:"mealProfile" bson:"mealProfile"` // fast, medium or slow
+	Message     string  `json:"message" bson:"message"`
+}
+
+// ExtendedBolus is a dual-wave bolus: the carb and correction dose now, and insulin for
+// the fat and protein of the meal delivered over several hours
+type ExtendedBolus struct {
+	FPU              float64 `json:"fpu" bson:"fpu"`             // Fat-protein units, 100 kcal from fat and protein each
+	FPUFactor        float64 `json:"fpuFactor" bson:"fpuFactor"` // Grams of carbohydrate dosed per FPU
+	ImmediateInsulin float64 `json:"immediateInsulin" bson:"immediateInsulin"`
+	ExtendedInsulin  float64 `json:"extendedInsulin" bson:"extendedInsulin"`
+	DurationHours    float64 `json:"durationHours" bson:"durationHours"`
+	TotalInsulin     float64 `json:"totalInsulin" bson:"totalInsulin"`
+	ImmediatePercent int     `json:"immediatePercent" bson:"immediatePercent"` // Share of TotalInsulin given now
+}
+
+// AnalysisJob is a queued food analysis
+type AnalysisJob struct {
+	ID          string          `json:"id" bson:"_id"`
+	UserID      string          `json:"userId" bson:"userId"`
+	Status      string          `json:"status" bson:"status"` // queued, running, done or failed
+	Request     AnalysisRequest `json:"request" bson:"request"`
+	Result      *MealAnalysis   `json:"result,omitempty" bson:"result,omitempty"`
+	Error       string          `json:"error,omitempty" bson:"error,omitempty"`
+	Attempts    int             `json:"attempts" bson:"attempts"`      // How many times a worker started the job
+	Owner       string          `json:"-" bson:"owner,omitempty"`      // Queue instance running the job
+	LeaseUntil  *time.Time      `json:"-" bson:"leaseUntil,omitempty"` // The owner renews this while it runs the job
+	CreatedAt   time.Time       `json:"createdAt" bson:"createdAt"`
+	UpdatedAt   time.Time       `json:"updatedAt" bson:"updatedAt"`
+	CompletedAt *time.Time      `json:"completedAt,omitempty" bson:"completedAt,omitempty"`
+}
+
+// Finished reports whether the job is done or failed
+func (j *AnalysisJob) Finished() bool {
+	return j.Status == JobStatusDone || j.Status == JobStatusFailed
+}
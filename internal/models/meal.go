@@ -0,0 +1,62 @@
+package models
+
+import "time"
+
+// How the carbohydrates of a meal were determined
+const (
+	MealSourcePhoto      = "photo"      // AI estimate from a dish photo, optionally with a description
+	MealSourceText       = "text"       // AI estimate from a description without a photo
+	MealSourceLabel      = "label"      // AI reading of a nutrition facts label
+	MealSourceBarcode    = "barcode"    // Product database lookup
+	MealSourceHypo       = "hypo"       // Fast carbs eaten to treat a low
+	MealSourceNightscout = "nightscout" // Carbs entered in a Nightscout uploader such as AndroidAPS
+)
+
+// MealRecord represents an analyzed meal together with the dose suggested for it
+type MealRecord struct {
+	ID         string    `json:"id" bson:"_id"`
+	UserID     string    `json:"userId" bson:"userId"`
+	Timestamp  time.Time `json:"timestamp" bson:"timestamp"`
+	Name       string    `json:"name" bson:"name"`
+	Carbs      float64   `json:"carbs" bson:"carbs"`           // Estimated carbohydrates in grams
+	Weight     float64   `json:"weight" bson:"weight"`         // Food weight in grams, 0 if unknown
+	Confidence float64   `json:"confidence" bson:"confidence"` // Confidence level (0-1)
+	Reasoning  string    `json:"reasoning" bson:"reasoning"`   // AI explanation of the estimate
+	UserNotes  string    `json:"userNotes,omitempty" bson:"userNotes,omitempty"`
+	// What the user ate in their own words, for text or photo+text analysis
+	Description string `json:"description,omitempty" bson:"description,omitempty"`
+	Source      string `json:"source" bson:"source"` // photo, text, label, barcode, hypo or nightscout
+	Barcode     string `json:"barcode,omitempty" bson:"barcode,omitempty"`
+	PhotoID     string `json:"photoId,omitempty" bson:"photoId,omitempty"` // Blob key of the uploaded photo
+	PhotoURL    string `json:"photoUrl,omitempty" bson:"-"`                // Signed URL, set when meals are listed
+	Provider    string `json:"provider" bson:"provider"`                   // AI provider that produced the estimate
+	// Prompt template used for the analysis, kept so prompt changes can be compared against outcomes
+	PromptVersion string `json:"promptVersion" bson:"promptVersion"`
+	PromptLocale  string `json:"promptLocale" bson:"promptLocale"`
+	// Suggested insulin dose at the time of the meal
+	MealInsulin       float64 `json:"mealInsulin" bson:"mealInsulin"`
+	CorrectionInsulin float64 `json:"correctionInsulin" bson:"correctionInsulin"`
+	TotalInsulin      float64 `json:"totalInsulin" bson:"totalInsulin"`
+	// Fat and protein in grams, 0 if the analysis didn't estimate them
+	Fat     float64 `json:"fat,omitempty" bson:"fat,omitempty"`
+	Protein float64 `json:"protein,omitempty" bson:"protein,omitempty"`
+	// Suggested dual-wave split for high fat-protein meals
+	ExtendedBolus *ExtendedBolus `json:"extendedBolus,omitempty" bson:"extendedBolus,omitempty"`
+	// How fast the carbs are absorbed: fast, medium or slow. Empty for meals recorded before it was
+	// stored, their profile is then derived from the macronutrients.
+	Absorption string `json:"absorption,omitempty" bson:"absorption,omitempty"`
+	// When the user confirmed eating the meal. An AI analysis is only an estimate until then, hypo
+	// treatments and Nightscout uploads record food that was eaten and are confirmed when saved.
+	EatenAt *time.Time `json:"eatenAt,omitempty" bson:"eatenAt,omitempty"`
+}
+
+// ActiveCarbs is a meal whose carbohydrates are still being absorbed
+type ActiveCarbs struct {
+	MealID     string    `json:"mealId"`
+	Name       string    `json:"name"`
+	EatenAt    time.Time `json:"eatenAt"`
+	Carbs      float64   `json:"carbs"`
+	Remaining  float64   `json:"remaining"` // Grams not absorbed yet
+	Absorption string    `json:"absorption"`
+	AbsorbedBy time.Time `json:"absorbedBy"` // When the meal will be fully absorbed
+}
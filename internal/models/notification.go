@@ -0,0 +1,59 @@
+package models
+
+import "time"
+
+// Notification channel types
+const (
+	ChannelWebhook  = "webhook"
+	ChannelEmail    = "email"
+	ChannelTelegram = "telegram"
+)
+
+// Notification kinds
+const (
+	NotificationAlert    = "alert"
+	NotificationRecheck  = "recheck"  // Reminder to check glucose again after treating a low
+	NotificationReminder = "reminder" // Any other scheduled reminder
+	NotificationTest     = "test"
+)
+
+// Notification delivery statuses
+const (
+	NotificationPending = "pending" // Waiting for its next attempt
+	NotificationSent    = "sent"
+	NotificationFailed  = "failed" // Gave up, kept in the dead-letter log
+)
+
+// NotificationChannel is where a user, or one of their caregivers, receives notifications
+type NotificationChannel struct {
+	ID      string `json:"id" bson:"id"`
+	Type    string `json:"type" bson:"type"` // webhook, email or telegram
+	Name    string `json:"name,omitempty" bson:"name,omitempty"`
+	Enabled bool   `json:"enabled" bson:"enabled"`
+	// Webhook URL, email address or Telegram chat ID
+	Target string `json:"target" bson:"target"`
+	// Key the webhook payload is signed with
+	Secret string `json:"secret,omitempty" bson:"secret,omitempty"`
+	// Only deliver urgent notifications, e.g. for a caregiver
+	UrgentOnly bool `json:"urgentOnly" bson:"urgentOnly"`
+}
+
+// Notification is the delivery of a message to one channel
+type Notification struct {
+	ID          string    `json:"id" bson:"_id"`
+	UserID      string    `json:"userId" bson:"userId"`
+	ChannelID   string    `json:"channelId" bson:"channelId"`
+	ChannelType string    `json:"channelType" bson:"channelType"`
+	Kind        string    `json:"kind" bson:"kind"`                   // alert, recheck, reminder or test
+	Ref         string    `json:"ref,omitempty" bson:"ref,omitempty"` // ID of the alert or hypo treatment
+	Subject     string    `json:"subject" bson:"subject"`
+	Text        string    `json:"text" bson:"text"`
+	Urgent      bool      `json:"urgent" bson:"urgent"`
+	CreatedAt   time.Time `json:"createdAt" bson:"createdAt"`
+
+	Status        string     `json:"status" bson:"status"`
+	Attempts      int        `json:"attempts" bson:"attempts"`
+	NextAttemptAt time.Time  `json:"nextAttemptAt" bson:"nextAttemptAt"`
+	LastError     string     `json:"lastError,omitempty" bson:"lastError,omitempty"`
+	SentAt        *time.Time `json:"sentAt,omitempty" bson:"sentAt,omitempty"`
+}
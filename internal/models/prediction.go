@@ -0,0 +1,52 @@
+package models
+
+import "time"
+
+// Dose check statuses
+const (
+	DoseCheckOK   = "ok"
+	DoseCheckLow  = "low"  // The dose is predicted to cause a low
+	DoseCheckHigh = "high" // Glucose is predicted to end above the target range
+)
+
+// Forecast is a short-term glucose prediction combining the current trend, insulin on board
+// and carbs on board
+type Forecast struct {
+	UserID          string          `json:"userId"`
+	GeneratedAt     time.Time       `json:"generatedAt"`
+	StartGlucose    float64         `json:"startGlucose"`    // Latest reading in mmol/L
+	EventualGlucose float64         `json:"eventualGlucose"` // Predicted glucose at the end of the horizon
+	MinGlucose      float64         `json:"minGlucose"`
+	MinGlucoseAt    time.Time       `json:"minGlucoseAt"`
+	MaxGlucose      float64         `json:"maxGlucose"`
+	IOB             float64         `json:"iob"` // Insulin on board in units
+	COB             float64         `json:"cob"` // Carbs on board in grams
+	Effects         ForecastEffects `json:"effects"`
+	Points          []ForecastPoint `json:"points"`
+}
+
+// ForecastEffects is how much each factor moves glucose over the whole horizon, in mmol/L
+type ForecastEffects struct {
+	Momentum float64 `json:"momentum"`
+	Insulin  float64 `json:"insulin"`
+	Carbs    float64 `json:"carbs"`
+}
+
+// ForecastPoint is one predicted glucose value
+type ForecastPoint struct {
+	Time    time.Time `json:"time"`
+	Minutes int       `json:"minutes"` // Minutes after GeneratedAt
+	Glucose float64   `json:"glucose"`
+}
+
+// DoseCheck is the predicted outcome of taking a suggested dose for a meal
+type DoseCheck struct {
+	Status          string  `json:"status" bson:"status"` // ok, low or high
+	EventualGlucose float64 `json:"eventualGlucose" bson:"eventualGlucose"`
+	MinGlucose      float64 `json:"minGlucose" bson:"minGlucose"`
+	MinutesToMin    int     `json:"minutesToMin" bson:"minutesToMin"`
+	IOB             float64 `json:"iob" bson:"iob"` // Insulin already on board before the dose
+	// Units to take off the dose to keep the predicted minimum in range, set when status is low
+	SuggestedReduction float64 `json:"suggestedReduction,omitempty" bson:"suggestedReduction,omitempty"`
+	Message            string  `json:"message" bson:"message"`
+}
@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// Product represents a packaged food from the local product database
+type Product struct {
+	Barcode         string    `json:"barcode" bson:"_id"`
+	Name            string    `json:"name" bson:"name"`
+	Brand           string    `json:"brand,omitempty" bson:"brand,omitempty"`
+	CarbsPer100g    float64   `json:"carbsPer100g" bson:"carbsPer100g"`
+	ServingSize     string    `json:"servingSize,omitempty" bson:"servingSize,omitempty"`         // As printed, e.g. "2 biscuits (25 g)"
+	ServingQuantity float64   `json:"servingQuantity,omitempty" bson:"servingQuantity,omitempty"` // Serving size in grams
+	Source          string    `json:"source" bson:"source"`                                       // e.g. "openfoodfacts"
+	UpdatedAt       time.Time `json:"updatedAt" bson:"updatedAt"`
+}
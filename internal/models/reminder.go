@@ -0,0 +1,81 @@
+package models
+
+import "time"
+
+// Reminder types
+const (
+	ReminderMealCheck    = "meal_check"    // Check glucose after a logged meal
+	ReminderHypoRecheck  = "hypo_recheck"  // Check glucose again after treating a low
+	ReminderBasal        = "basal"         // Daily basal injection
+	ReminderSensorChange = "sensor_change" // Every few days
+	ReminderNeedleChange = "needle_change" // Pen needle, every few days
+	ReminderCheck        = "check"         // One-off glucose check set by the user
+)
+
+// Reminder statuses. Repeating reminders stay pending, their due time moves on after each delivery.
+const (
+	ReminderPending   = "pending"
+	ReminderSent      = "sent"
+	ReminderCancelled = "cancelled" // Deleted by the user, or made unnecessary by a new reading
+	ReminderMissed    = "missed"    // Due while the server was down for too long
+)
+
+// DefaultMealCheckMinutes is when glucose is checked after a meal
+const DefaultMealCheckMinutes = 120
+
+// Reminder is a scheduled reminder, either one-off or repeating daily at a time of day or every
+// few days
+type Reminder struct {
+	ID     string `json:"id" bson:"_id"`
+	UserID string `json:"userId" bson:"userId"`
+	Type   string `json:"type" bson:"type"`
+	Note   string `json:"note,omitempty" bson:"note,omitempty"` // e.g. "Tresiba 18 U"
+	// Text of automatic reminders, user reminders are worded by type and note when delivered
+	Message string    `json:"message,omitempty" bson:"message,omitempty"`
+	Status  string    `json:"status" bson:"status"`
+	DueAt   time.Time `json:"dueAt" bson:"dueAt"`
+
+	// Repeating reminders: daily at "HH:MM" server time, or every IntervalDays from DueAt
+	TimeOfDay    string `json:"timeOfDay,omitempty" bson:"timeOfDay,omitempty"`
+	IntervalDays int    `json:"intervalDays,omitempty" bson:"intervalDays,omitempty"`
+
+	Ref        string     `json:"ref,omitempty" bson:"ref,omitempty"` // ID of the meal or hypo treatment
+	CreatedAt  time.Time  `json:"createdAt" bson:"createdAt"`
+	LastSentAt *time.Time `json:"lastSentAt,omitempty" bson:"lastSentAt,omitempty"`
+
+	// Set while a replica delivers the reminder, others skip it until then
+	ClaimedUntil *time.Time `json:"-" bson:"claimedUntil,omitempty"`
+}
+
+// Repeats reports whether the reminder is delivered again after it's due
+func (r *Reminder) Repeats() bool {
+	return r.TimeOfDay != "" || r.IntervalDays > 0
+}
+
+// NextDue returns the first due time of a repeating reminder after t
+func (r *Reminder) NextDue(t time.Time) time.Time {
+	if r.TimeOfDay != "" {
+		minute, _ := minuteOfDay(r.TimeOfDay)
+		day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+		next := day.Add(time.Duration(minute) * time.Minute)
+		if !next.After(t) {
+			next = next.AddDate(0, 0, 1)
+		}
+		return next
+	}
+
+	next := r.DueAt
+	for !next.After(t) {
+		next = next.AddDate(0, 0, r.IntervalDays)
+	}
+	return next
+}
+
+// ReminderSettings configures the reminders created automatically
+type ReminderSettings struct {
+	MealCheck        bool `json:"mealCheck" bson:"mealCheck"`
+	MealCheckMinutes int  `json:"mealCheckMinutes" bson:"mealCheckMinutes"`
+}
+
+// DefaultReminderSettings are used until the user configures reminders
+var DefaultReminderSettings = ReminderSettings{MealCheck: true, MealCheckMinutes: DefaultMealCheckMinutes}
@@ -1,6 +1,25 @@
 package models
 
-import "time"
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultLocale is the language used for AI responses when the user hasn't chosen one
+const DefaultLocale = "ru"
+
+// DefaultFPUFactor is the grams of carbohydrate one fat-protein unit is dosed as (Warsaw method)
+const DefaultFPUFactor = 10.0
+
+// Carb absorption curves
+const (
+	AbsorptionCurveLinear    = "linear"    // Constant rate over the absorption time
+	AbsorptionCurvePiecewise = "piecewise" // Rate rises, plateaus and tails off, like Loop's nonlinear model
+)
+
+// DefaultAbsorptionTimes are the hours meals of each glycemic profile take to be absorbed
+var DefaultAbsorptionTimes = CarbAbsorptionTimes{Fast: 2, Medium: 3, Slow: 4}
 
 type TargetBloodSugarRange struct {
 	Min float64 `json:"min" bson:"min"`
@@ -25,6 +44,14 @@ type CarbRatioPeriod struct {
 	Hours     float64 `json:"hours" bson:"hours"`
 }
 
+// CarbAbsorptionTimes are the hours it takes to absorb the carbohydrates of fast, medium
+// and slow meals, 0 means the default for that profile
+type CarbAbsorptionTimes struct {
+	Fast   float64 `json:"fast" bson:"fast"`
+	Medium float64 `json:"medium" bson:"medium"`
+	Slow   float64 `json:"slow" bson:"slow"`
+}
+
 // Settings represents user-specific settings for the diabetes assistant
 type Settings struct {
 	ID string `json:"id" bson:"_id,omitempty"`
@@ -41,10 +68,31 @@ type Settings struct {
 	SensitivityPeriods []SensitivityPeriod `json:"sensitivityPeriods" bson:"sensitivityPeriods"`
 	// Carb ratio periods
 	CarbRatioPeriods []CarbRatioPeriod `json:"carbRatioPeriods" bson:"carbRatioPeriods"`
+	// Language used for AI responses (e.g. "ru", "en")
+	Locale string `json:"locale" bson:"locale"`
+	// Grams of carbohydrate dosed per fat-protein unit, 0 means DefaultFPUFactor
+	FPUFactor float64 `json:"fpuFactor" bson:"fpuFactor"`
+	// Carb absorption times used for glucose predictions
+	AbsorptionTimes CarbAbsorptionTimes `json:"absorptionTimes" bson:"absorptionTimes"`
+	// Carb absorption curve, linear or piecewise; empty means piecewise
+	AbsorptionCurve string `json:"absorptionCurve" bson:"absorptionCurve"`
+	// Alert rules and quiet hours, nil means DefaultAlertSettings
+	Alerts *AlertSettings `json:"alerts,omitempty" bson:"alerts,omitempty"`
+	// Automatic reminders, nil means DefaultReminderSettings
+	Reminders *ReminderSettings `json:"reminders,omitempty" bson:"reminders,omitempty"`
+	// Where alerts and reminders are delivered, they are only logged without channels
+	NotificationChannels []NotificationChannel `json:"notificationChannels,omitempty" bson:"notificationChannels,omitempty"`
 	// Timestamp when settings were last updated
 	UpdatedAt time.Time `json:"updatedAt" bson:"updatedAt"`
 }
 
+// SettingsRevision is a copy of a user's settings, one is kept every time they are saved
+type SettingsRevision struct {
+	UserID   string    `json:"userId" bson:"userId"`
+	SavedAt  time.Time `json:"savedAt" bson:"savedAt"`
+	Settings Settings  `json:"settings" bson:"settings"`
+}
+
 // CreateDefaultSettings creates a new settings object with default values
 func CreateDefaultSettings(userID string) *Settings {
 	return &Settings{
@@ -61,6 +109,107 @@ func CreateDefaultSettings(userID string) *Settings {
 		CarbRatioPeriods: []CarbRatioPeriod{
 			{StartTime: "00:00", Ratio: 1.0, Hours: 24},
 		},
-		UpdatedAt: time.Now(),
+		Locale:          DefaultLocale,
+		FPUFactor:       DefaultFPUFactor,
+		AbsorptionTimes: DefaultAbsorptionTimes,
+		AbsorptionCurve: AbsorptionCurvePiecewise,
+		UpdatedAt:       time.Now(),
 	}
 }
+
+// AbsorptionHours returns the carb absorption time for a meal profile (fast, medium or slow)
+func (s *Settings) AbsorptionHours(profile string) float64 {
+	hours, fallback := s.AbsorptionTimes.Medium, DefaultAbsorptionTimes.Medium
+	switch profile {
+	case "fast":
+		hours, fallback = s.AbsorptionTimes.Fast, DefaultAbsorptionTimes.Fast
+	case "slow":
+		hours, fallback = s.AbsorptionTimes.Slow, DefaultAbsorptionTimes.Slow
+	}
+	if hours <= 0 {
+		return fallback
+	}
+	return hours
+}
+
+// AlertSettings returns the user's alert rules, the defaults if they haven't configured any
+func (s *Settings) AlertSettings() AlertSettings {
+	if s.Alerts == nil {
+		return DefaultAlertSettings
+	}
+	return *s.Alerts
+}
+
+// ReminderSettings returns the user's automatic reminder settings, the defaults if they haven't configured any
+func (s *Settings) ReminderSettings() ReminderSettings {
+	if s.Reminders == nil {
+		return DefaultReminderSettings
+	}
+	return *s.Reminders
+}
+
+// SensitivityAt returns the insulin sensitivity (mmol/L per unit) in effect at t
+func (s *Settings) SensitivityAt(t time.Time) float64 {
+	for _, period := range s.SensitivityPeriods {
+		if inPeriod(period.StartTime, period.Hours, t) {
+			return period.Sensitivity
+		}
+	}
+	if len(s.SensitivityPeriods) > 0 {
+		return s.SensitivityPeriods[0].Sensitivity
+	}
+	return 0
+}
+
+// CarbRatioAt returns the carb ratio (grams per unit) in effect at t
+func (s *Settings) CarbRatioAt(t time.Time) float64 {
+	for _, period := range s.CarbRatioPeriods {
+		if inPeriod(period.StartTime, period.Hours, t) {
+			return period.Ratio
+		}
+	}
+	if len(s.CarbRatioPeriods) > 0 {
+		return s.CarbRatioPeriods[0].Ratio
+	}
+	return 0
+}
+
+// CarbSensitivityAt returns how much one gram of carbohydrate raises glucose at t, in mmol/L.
+// Meal doses are scaled by the insulin period coefficient, so the carbs a unit covers are too.
+func (s *Settings) CarbSensitivityAt(t time.Time) float64 {
+	ratio := s.CarbRatioAt(t)
+	if ratio <= 0 {
+		return 0
+	}
+	return s.SensitivityAt(t) * s.InsulinCoefficientAt(t) / ratio
+}
+
+// InsulinCoefficientAt returns the meal insulin coefficient in effect at t, 1 outside all periods
+func (s *Settings) InsulinCoefficientAt(t time.Time) float64 {
+	for _, period := range s.InsulinPeriods {
+		if inPeriod(period.StartTime, period.Hours, t) {
+			return period.Coefficient
+		}
+	}
+	return 1
+}
+
+// inPeriod reports whether the time of day of t falls within a period starting at "HH:MM"
+// and lasting the given hours. Periods may wrap past midnight.
+func inPeriod(startTime string, hours float64, t time.Time) bool {
+	parts := strings.SplitN(startTime, ":", 2)
+	startHour, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return false
+	}
+	startMinute := 0
+	if len(parts) == 2 {
+		startMinute, _ = strconv.Atoi(parts[1])
+	}
+
+	const day = 24 * 60
+	start := startHour*60 + startMinute
+	minute := t.Hour()*60 + t.Minute()
+	offset := ((minute-start)%day + day) % day
+	return float64(offset) < hours*60
+}
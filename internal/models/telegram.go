@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// TelegramLink connects a Telegram chat to a user, the bot acts as that user in the chat
+type TelegramLink struct {
+	ChatID   int64     `json:"chatId" bson:"_id"`
+	UserID   string    `json:"userId" bson:"userId"`
+	LinkedAt time.Time `json:"linkedAt" bson:"linkedAt"`
+}
+
+// TelegramLinkCode is a one-time code a user sends to the bot to link their chat
+type TelegramLinkCode struct {
+	Code      string    `json:"code" bson:"_id"`
+	UserID    string    `json:"userId" bson:"userId"`
+	ExpiresAt time.Time `json:"expiresAt" bson:"expiresAt"`
+}
@@ -8,10 +8,11 @@ import (
 
 // User represents a diabetes app user
 type User struct {
-	ID                 primitive.ObjectID  `bson:"_id,omitempty" json:"id"`
-	UserID             string              `json:"userId" bson:"userId"`
-	Settings           Settings            `json:"settings" bson:"settings"`
-	BloodSugarReadings []BloodSugarReading `json:"bloodSugarReadings" bson:"bloodSugarReadings"`
+	ID       primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID   string             `json:"userId" bson:"userId"`
+	Settings Settings           `json:"settings" bson:"settings"`
+	// Only the in-memory storage keeps readings here, MongoDB has a readings collection
+	BloodSugarReadings []BloodSugarReading `json:"bloodSugarReadings" bson:"bloodSugarReadings,omitempty"`
 }
 
 // BloodSugarReading represents a blood sugar reading
@@ -19,15 +20,8 @@ type BloodSugarReading struct {
 	Value     float64   `json:"value" bson:"value"`                       // Blood sugar value in mmol/L
 	Timestamp time.Time `json:"timestamp" bson:"timestamp"`               // When the reading was taken
 	Source    string    `json:"source,omitempty" bson:"source,omitempty"` // Optional source of reading
-}
-
-// FoodAnalysis represents the result of food analysis
-type FoodAnalysis struct {
-	Dish              string  `json:"dish"`
-	Carbs             float64 `json:"carbs"`
-	Confidence        string  `json:"confidence"`
-	MealInsulin       float64 `json:"mealInsulin"`
-	CorrectionInsulin float64 `json:"correctionInsulin"`
-	TotalInsulin      float64 `json:"totalInsulin"`
-	PeriodCoefficient float64 `json:"periodCoefficient"`
+	// Trend arrow (e.g. "FortyFiveDown") and rate of change in mmol/L per minute. Stored when the CGM
+	// reports them, otherwise computed from the previous readings when readings are returned.
+	Trend        string   `json:"trend,omitempty" bson:"trend,omitempty"`
+	RateOfChange *float64 `json:"rateOfChange,omitempty" bson:"rateOfChange,omitempty"`
 }
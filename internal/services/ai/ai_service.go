@@ -1,26 +1,92 @@
 package ai
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"log"
+	"strings"
+	"time"
 
 	"github.com/yourusername/diabetes-assistant/internal/config"
+	"github.com/yourusername/diabetes-assistant/internal/models"
+)
+
+// Confidence values used when a provider answers with a low/medium/high label
+const (
+	ConfidenceLow    = 0.3
+	ConfidenceMedium = 0.6
+	ConfidenceHigh   = 0.9
 )
 
 // FoodAnalysisResult represents the result of food analysis
 type FoodAnalysisResult struct {
 	Name       string  `json:"name"`
 	Carbs      float64 `json:"carbs"`
-	Confidence string  `json:"confidence"`
-	Reasoning  string  `json:"reasoning"` // Explanation of how carbs were estimated
+	Confidence float64 `json:"confidence"` // Confidence level (0-1)
+	Reasoning  string  `json:"reasoning"`  // Explanation of how carbs were estimated
+
+	// Fat and protein in grams, estimated from food_analysis v3 on; used for extended bolus suggestions
+	Fat     float64 `json:"fat,omitempty"`
+	Protein float64 `json:"protein,omitempty"`
+
+	// How fast the carbs are absorbed (fast, medium or slow), classified from food_analysis v4 on
+	Absorption string `json:"absorption,omitempty"`
+
+	// Values read from a nutrition facts label, only set by AnalyzeLabel
+	CarbsPer100g     float64 `json:"carbsPer100g,omitempty"`
+	CarbsPerServing  float64 `json:"carbsPerServing,omitempty"`
+	ServingSizeGrams float64 `json:"servingSizeGrams,omitempty"`
+
+	// Prompt template used for this analysis, set by the Service
+	PromptVersion string `json:"promptVersion,omitempty"`
+	PromptLocale  string `json:"promptLocale,omitempty"`
+
+	// Cached is set when the result came from the analysis cache instead of the provider
+	Cached bool `json:"cached"`
+
+	// Usage is reported by the provider for usage accounting
+	Usage *Usage `json:"-"`
 }
 
+// Usage is the model and token usage a provider reported for a call
+type Usage struct {
+	Model        string
+	InputTokens  int
+	OutputTokens int
+}
+
+// Image is a photo sent to a provider for analysis
+type Image struct {
+	Data     []byte
+	MIMEType string // image/jpeg or image/png
+}
+
+// Message represents a chat message
+type Message struct {
+	Role    string `json:"role"` // system, user or assistant
+	Content string `json:"content"`
+}
+
+// Chat message roles
+const (
+	RoleSystem    = "system"
+	RoleUser      = "user"
+	RoleAssistant = "assistant"
+)
+
 // Provider represents the interface that all AI providers must implement
 type Provider interface {
-	// AnalyzeFood analyzes a food image and returns estimated carbohydrates
-	// The second parameter (originally for ingredient images) is deprecated and not used
-	AnalyzeFood(foodImagePath, unusedDescriptionParam string, foodWeight float64) (*FoodAnalysisResult, error)
+	// AnalyzeFood analyzes a food image and returns estimated carbohydrates.
+	// image is nil for text-only analysis, the meal description is then part of the prompt.
+	// The prompt is already rendered from a template; foodWeight is passed for providers that don't use it
+	AnalyzeFood(ctx context.Context, image *Image, prompt string, foodWeight float64) (*FoodAnalysisResult, error)
+
+	// ChatCompletion returns the assistant's reply to a conversation and the usage, if the API reports it
+	ChatCompletion(ctx context.Context, messages []Message) (string, *Usage, error)
+
+	// ChatCompletionStream is like ChatCompletion but calls onDelta with each piece of the reply as it arrives
+	ChatCompletionStream(ctx context.Context, messages []Message, onDelta func(string) error) (string, *Usage, error)
 }
 
 // Service is the main AI service that delegates to the appropriate provider
@@ -28,6 +94,14 @@ type Service struct {
 	config       *config.Config
 	provider     Provider
 	providerName string // Stores which provider is being used
+	prompts      *PromptStore
+
+	// Optional analysis cache, see SetCache
+	cache    AnalysisCache
+	cacheTTL time.Duration
+
+	// Optional usage accounting, see SetUsageRecorder
+	usage UsageRecorder
 }
 
 // NewService creates a new AI service
@@ -36,10 +110,15 @@ func NewService(cfg *config.Config) (*Service, error) {
 	var providerName string
 	var err error
 
+	prompts, err := LoadPromptStore(cfg.PromptsDir, cfg.PromptVersion)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load prompt templates: %w", err)
+	}
+
 	// Try to use OpenAI if the API key is available
 	if cfg.OpenAIToken != "" {
 		log.Println("Using OpenAI provider for AI analysis")
-		provider, err = NewOpenAIProvider(cfg.OpenAIToken)
+		provider, err = NewOpenAIProvider(cfg.OpenAIToken, cfg.DefaultModel)
 		providerName = "openai"
 		if err != nil {
 			log.Printf("Failed to initialize OpenAI provider: %v", err)
@@ -77,25 +156,141 @@ func NewService(cfg *config.Config) (*Service, error) {
 		config:       cfg,
 		provider:     provider,
 		providerName: providerName,
+		prompts:      prompts,
 	}, nil
 }
 
-// AnalyzeFood analyzes a food image and returns the estimated carbohydrates
-func (s *Service) AnalyzeFood(foodImagePath, ingredientImagePath string, foodWeight float64) (*FoodAnalysisResult, error) {
-	// Note: ingredientImagePath parameter is deprecated and not used anymore, but kept for backward compatibility
+// ErrDescriptionUnsupported is returned for a text-only analysis when the active food analysis
+// prompt only understands photos
+var ErrDescriptionUnsupported = errors.New("the active food analysis prompt doesn't support descriptions, a photo is required")
+
+// AnalyzeFood analyzes a food image, a text description of the meal, or both, and returns the
+// estimated carbohydrates. The prompt is rendered from the active template version in the user's locale.
+// Provider calls are accounted to userID.
+func (s *Service) AnalyzeFood(ctx context.Context, userID string, image *Image, locale string, vars PromptVariables) (*FoodAnalysisResult, error) {
+	if image == nil && strings.TrimSpace(vars.Description) == "" {
+		return nil, errors.New("food photo or description is required")
+	}
+	if image == nil {
+		// A template that doesn't read the description would be sent without any food at all
+		uses, err := s.prompts.UsesField(PromptFoodAnalysis, locale, "Description")
+		if err != nil {
+			return nil, err
+		}
+		if !uses {
+			return nil, ErrDescriptionUnsupported
+		}
+	}
+
+	return s.analyze(ctx, userID, PromptFoodAnalysis, image, locale, vars)
+}
+
+// AnalyzeLabel reads a nutrition facts label and returns the carbohydrates for the given weight.
+// The total is computed from the per 100g value when a weight is known, otherwise one serving is assumed.
+func (s *Service) AnalyzeLabel(ctx context.Context, userID string, image *Image, locale string, vars PromptVariables) (*FoodAnalysisResult, error) {
+	if image == nil {
+		return nil, errors.New("nutrition label photo is required")
+	}
+
+	result, err := s.analyze(ctx, userID, PromptNutritionLabel, image, locale, vars)
+	if err != nil {
+		return nil, err
+	}
+
+	// Don't trust the model's arithmetic when the label values are known
+	if vars.Weight > 0 && result.CarbsPer100g > 0 {
+		result.Carbs = result.CarbsPer100g * vars.Weight / 100
+	} else if vars.Weight == 0 && result.CarbsPerServing > 0 {
+		result.Carbs = result.CarbsPerServing
+	}
+
+	return result, nil
+}
+
+// analyze renders a food analysis template and sends it with the image to the provider,
+// unless the same request was answered recently
+func (s *Service) analyze(ctx context.Context, userID, templateName string, image *Image, locale string, vars PromptVariables) (*FoodAnalysisResult, error) {
 	if s.provider == nil {
 		return nil, errors.New("AI provider not initialized")
 	}
 
-	result, err := s.provider.AnalyzeFood(foodImagePath, ingredientImagePath, foodWeight)
+	vars.HasPhoto = image != nil
+	prompt, err := s.prompts.Render(templateName, locale, vars)
+	if err != nil {
+		return nil, err
+	}
+
+	var cacheKey string
+	if s.cache != nil && s.cacheTTL > 0 {
+		cacheKey = analysisCacheKey(s.providerName, prompt, image)
+		if cached := s.cachedAnalysis(ctx, cacheKey); cached != nil {
+			return cached, nil
+		}
+	}
+
+	operation := models.AIOperationFoodAnalysis
+	if templateName == PromptNutritionLabel {
+		operation = models.AIOperationNutritionLabel
+	}
+
+	started := time.Now()
+	result, err := s.provider.AnalyzeFood(ctx, image, prompt.Text, vars.Weight)
 	if err != nil {
+		s.recordUsage(ctx, userID, operation, started, nil, err)
 		return nil, err
 	}
+	s.recordUsage(ctx, userID, operation, started, result.Usage, nil)
 
-	// Return the food analysis result
+	result.PromptVersion = prompt.Version
+	result.PromptLocale = prompt.Locale
+	if cacheKey != "" {
+		s.cacheAnalysis(ctx, cacheKey, result)
+	}
 	return result, nil
 }
 
+// ChatCompletion returns the assistant's reply to a conversation, accounting the call to userID
+func (s *Service) ChatCompletion(ctx context.Context, userID string, messages []Message) (string, error) {
+	if s.provider == nil {
+		return "", errors.New("AI provider not initialized")
+	}
+
+	if len(messages) == 0 {
+		return "", errors.New("at least one message is required")
+	}
+
+	started := time.Now()
+	reply, usage, err := s.provider.ChatCompletion(ctx, messages)
+	s.recordUsage(ctx, userID, models.AIOperationChat, started, usage, err)
+	return reply, err
+}
+
+// ChatCompletionStream is like ChatCompletion but calls onDelta with each piece of the reply as it arrives
+func (s *Service) ChatCompletionStream(ctx context.Context, userID string, messages []Message, onDelta func(string) error) (string, error) {
+	if s.provider == nil {
+		return "", errors.New("AI provider not initialized")
+	}
+
+	if len(messages) == 0 {
+		return "", errors.New("at least one message is required")
+	}
+
+	started := time.Now()
+	reply, usage, err := s.provider.ChatCompletionStream(ctx, messages, onDelta)
+	s.recordUsage(ctx, userID, models.AIOperationChat, started, usage, err)
+	return reply, err
+}
+
+// Locales returns the locales that have prompt templates, users can choose one of them
+func (s *Service) Locales() []string {
+	return s.prompts.Locales()
+}
+
+// RenderPrompt renders the active version of a prompt template in the given locale
+func (s *Service) RenderPrompt(name, locale string, data interface{}) (*RenderedPrompt, error) {
+	return s.prompts.Render(name, locale, data)
+}
+
 // ChangeProvider changes the AI provider
 func (s *Service) ChangeProvider(providerName string, key string) error {
 	var provider Provider
@@ -103,7 +298,7 @@ func (s *Service) ChangeProvider(providerName string, key string) error {
 
 	switch providerName {
 	case "openai":
-		provider, err = NewOpenAIProvider(key)
+		provider, err = NewOpenAIProvider(key, s.config.DefaultModel)
 	case "gemini":
 		provider, err = NewGeminiProvider(key)
 	case "grok":
@@ -130,12 +325,15 @@ func (s *Service) GetCurrentProvider() string {
 type mockProvider struct{}
 
 // AnalyzeFood implements the Provider interface for the mock provider
-func (p *mockProvider) AnalyzeFood(foodImagePath, unusedDescriptionParam string, foodWeight float64) (*FoodAnalysisResult, error) {
+func (p *mockProvider) AnalyzeFood(ctx context.Context, image *Image, prompt string, foodWeight float64) (*FoodAnalysisResult, error) {
 	// Prepare response
 	result := &FoodAnalysisResult{
 		Name:       "Пицца",
 		Carbs:      45.0,
-		Confidence: "high",
+		Fat:        10.0,
+		Protein:    11.0,
+		Absorption: "slow",
+		Confidence: ConfidenceHigh,
 		Reasoning:  "Это тестовый анализ для демонстрационных целей. Типичная пицца (среднего размера) содержит примерно 45г углеводов на кусок, в основном из-за теста.",
 	}
 
@@ -145,9 +343,35 @@ func (p *mockProvider) AnalyzeFood(foodImagePath, unusedDescriptionParam string,
 		standardSliceWeight := 100.0
 		weightRatio := foodWeight / standardSliceWeight
 		result.Carbs = result.Carbs * weightRatio
+		result.Fat = result.Fat * weightRatio
+		result.Protein = result.Protein * weightRatio
 		result.Reasoning = fmt.Sprintf("Это тестовый анализ для демонстрационных целей. Для указанного веса %.1f г пиццы (стандартный кусок ~100г) содержит примерно %.1fг углеводов.",
 			foodWeight, result.Carbs)
 	}
 
 	return result, nil
 }
+
+// ChatCompletion implements the Provider interface for the mock provider
+func (p *mockProvider) ChatCompletion(ctx context.Context, messages []Message) (string, *Usage, error) {
+	return fmt.Sprintf("Это тестовый ответ для демонстрационных целей (сообщений в диалоге: %d).", len(messages)), nil, nil
+}
+
+// ChatCompletionStream implements the Provider interface for the mock provider, streaming the reply word by word
+func (p *mockProvider) ChatCompletionStream(ctx context.Context, messages []Message, onDelta func(string) error) (string, *Usage, error) {
+	reply, _, err := p.ChatCompletion(ctx, messages)
+	if err != nil {
+		return "", nil, err
+	}
+
+	for i, word := range strings.Fields(reply) {
+		if i > 0 {
+			word = " " + word
+		}
+		if err := onDelta(word); err != nil {
+			return "", nil, err
+		}
+	}
+
+	return reply, nil, nil
+}
@@ -0,0 +1,78 @@
+package ai
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/yourusername/diabetes-assistant/internal/models"
+)
+
+// AnalysisCache stores analysis results, storage.Storage implements it
+type AnalysisCache interface {
+	GetCachedAnalysis(ctx context.Context, key string) (*models.AnalysisCacheEntry, error)
+	SaveCachedAnalysis(ctx context.Context, entry *models.AnalysisCacheEntry) error
+}
+
+// SetCache enables caching of food analysis results for ttl. Retries and double taps with
+// the same photo, weight and prompt version then reuse the first result instead of calling the provider.
+func (s *Service) SetCache(cache AnalysisCache, ttl time.Duration) {
+	s.cache = cache
+	s.cacheTTL = ttl
+}
+
+// analysisCacheKey hashes everything that affects an analysis: the provider, the prompt template
+// version and its rendered text (which includes weight, notes and description) and the image
+func analysisCacheKey(providerName string, prompt *RenderedPrompt, image *Image) string {
+	hash := sha256.New()
+	fmt.Fprintf(hash, "%s\x00%s\x00%s\x00%s\x00%s\x00", providerName, prompt.Name, prompt.Version, prompt.Locale, prompt.Text)
+	if image != nil {
+		hash.Write(image.Data)
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// cachedAnalysis returns a cached result, or nil. Cache errors only cost a provider call, so they are logged.
+func (s *Service) cachedAnalysis(ctx context.Context, key string) *FoodAnalysisResult {
+	entry, err := s.cache.GetCachedAnalysis(ctx, key)
+	if err != nil {
+		log.Printf("AI: analysis cache lookup failed: %v", err)
+		return nil
+	}
+	if entry == nil {
+		return nil
+	}
+
+	var result FoodAnalysisResult
+	if err := json.Unmarshal([]byte(entry.Result), &result); err != nil {
+		log.Printf("AI: invalid analysis cache entry %s: %v", key, err)
+		return nil
+	}
+	result.Cached = true
+	return &result
+}
+
+// cacheAnalysis stores a provider result
+func (s *Service) cacheAnalysis(ctx context.Context, key string, result *FoodAnalysisResult) {
+	data, err := json.Marshal(result)
+	if err != nil {
+		log.Printf("AI: failed to encode analysis for the cache: %v", err)
+		return
+	}
+
+	now := time.Now()
+	entry := &models.AnalysisCacheEntry{
+		Key:       key,
+		Result:    string(data),
+		Provider:  s.providerName,
+		CreatedAt: now,
+		ExpiresAt: now.Add(s.cacheTTL),
+	}
+	if err := s.cache.SaveCachedAnalysis(ctx, entry); err != nil {
+		log.Printf("AI: failed to cache analysis: %v", err)
+	}
+}
@@ -2,15 +2,19 @@ package ai
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
-	"os"
+	"strings"
 
 	"github.com/google/generative-ai-go/genai"
+	"google.golang.org/api/iterator"
 	"google.golang.org/api/option"
 )
 
+// geminiModel is the model used for analysis and chat
+const geminiModel = "gemini-1.5-pro"
+
 // GeminiProvider implements the Provider interface for Google's Gemini API
 type GeminiProvider struct {
 	client *genai.Client
@@ -26,7 +30,7 @@ func NewGeminiProvider(apiKey string) (*GeminiProvider, error) {
 	}
 
 	// Use gemini-1.5-pro as it offers the best quality/performance while being free for reasonable usage
-	model := client.GenerativeModel("gemini-1.5-pro")
+	model := client.GenerativeModel(geminiModel)
 
 	// Configure the model with appropriate settings for medical analysis
 	model.SetTemperature(0.2) // Lower temperature for more deterministic, accurate responses
@@ -41,67 +45,19 @@ func NewGeminiProvider(apiKey string) (*GeminiProvider, error) {
 }
 
 // AnalyzeFood analyzes a food image and returns the estimated carbohydrates
-func (p *GeminiProvider) AnalyzeFood(foodImagePath, unusedDescriptionParam string, foodWeight float64) (*FoodAnalysisResult, error) {
-	// Description parameter is no longer used, only photo and weight
-	ctx := context.Background()
-
-	// Read food image file
-	foodImgData, err := os.ReadFile(foodImagePath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read food image: %w", err)
-	}
-
-	// Create the base prompt
-	promptText := `You are a certified diabetes educator specializing in nutrition analysis. 
-You will analyze the food in the image to estimate its carbohydrate content accurately for diabetes management.
-
-TASK:
-1. Identify the food items in the image
-2. Estimate total carbohydrates (in grams) based on standard nutritional databases
-3. Assess your confidence in this estimation (low, medium, high)
-4. Provide the information in a specific JSON format
-
-REQUIREMENTS:
-- Be medically precise in your carbohydrate estimation
-- Include both visible ingredients and likely hidden ingredients that contain carbs
-- Consider portion sizes carefully
-- Account for various cooking methods that might affect carbohydrate content
-- If the image contains nutritional information or packaging, prioritize that data
-- IMPORTANT: Provide all text responses in Russian language for Russian users
-- Food names should be in Russian
-- Reasoning/descriptions should be in Russian`
-
-	// Add weight information if provided
-	if foodWeight > 0 {
-		promptText += fmt.Sprintf(`
-
-IMPORTANT WEIGHT INFORMATION:
-- The user has specified that the food weighs %.1f grams
-- Adjust your carbohydrate calculation based on this exact weight
-- Make sure to mention the weight in your reasoning`, foodWeight)
-	}
-
-	promptText += `
-
-RESPONSE FORMAT:
-Respond ONLY with valid JSON matching this exact structure:
-{
-  "name": "Complete name of the dish in Russian",
-  "carbs": number, 
-  "confidence": "low|medium|high",
-  "reasoning": "Brief explanation of how you estimated the carbs in Russian"
-}
-
-This information will be used for insulin dosing, so accuracy is critically important for patient safety.`
+func (p *GeminiProvider) AnalyzeFood(ctx context.Context, image *Image, prompt string, foodWeight float64) (*FoodAnalysisResult, error) {
+	// The prompt is rendered by the Service from a versioned template, so weight is already part of it
 
-	prompt := genai.Text(promptText)
+	parts := []genai.Part{genai.Text(prompt)}
 
-	// Create image part
-	img := genai.ImageData("image/jpeg", foodImgData)
-	parts := []genai.Part{prompt, img}
+	// Text-only analysis has no image
+	if image != nil {
+		parts = append(parts, genai.Blob{MIMEType: image.MIMEType, Data: image.Data})
+	}
 
 	// Generate content
-	log.Printf("Sending request to Gemini for food analysis with model: gemini-1.5-pro")
+	log.Printf("Sending request to Gemini for food analysis with model: %s", geminiModel)
+	inputTokens := p.countTokens(ctx, parts)
 	resp, err := p.model.GenerateContent(ctx, parts...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate content: %w", err)
@@ -119,41 +75,153 @@ This information will be used for insulin dosing, so accuracy is critically impo
 
 	log.Printf("Received Gemini response: %s", string(responseText)[:min(100, len(string(responseText)))]+"...")
 
-	// Parse the JSON response
-	var result struct {
-		Name       string  `json:"name"`
-		Carbs      float64 `json:"carbs"`
-		Confidence string  `json:"confidence"`
-		Reasoning  string  `json:"reasoning"`
+	result, err := parseFoodAnalysisResponse(string(responseText))
+	if err != nil {
+		return nil, err
+	}
+	result.Usage = geminiUsage(<-inputTokens, resp)
+	return result, nil
+}
+
+// ChatCompletion returns the assistant's reply to a conversation
+func (p *GeminiProvider) ChatCompletion(ctx context.Context, messages []Message) (string, *Usage, error) {
+	chat, last, err := p.startChat(messages)
+	if err != nil {
+		return "", nil, err
+	}
+
+	inputTokens := p.countTokens(ctx, chatParts(chat.History, last))
+	resp, err := chat.SendMessage(ctx, last.Parts...)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to generate content: %w", err)
+	}
+
+	if len(resp.Candidates) == 0 || resp.Candidates[0].Content == nil || len(resp.Candidates[0].Content.Parts) == 0 {
+		return "", nil, fmt.Errorf("no response from Gemini")
+	}
+
+	return geminiText(resp), geminiUsage(<-inputTokens, resp), nil
+}
+
+// ChatCompletionStream is like ChatCompletion but calls onDelta with each piece of the reply as it arrives
+func (p *GeminiProvider) ChatCompletionStream(ctx context.Context, messages []Message, onDelta func(string) error) (string, *Usage, error) {
+	chat, last, err := p.startChat(messages)
+	if err != nil {
+		return "", nil, err
 	}
 
-	if err := json.Unmarshal([]byte(string(responseText)), &result); err != nil {
-		// Try to extract JSON from a text response
-		jsonStr, extractErr := extractJSONFromText(string(responseText))
-		if extractErr != nil {
-			return nil, fmt.Errorf("failed to parse response: %w (response was: %s)", err, truncateString(string(responseText), 200))
+	inputTokens := p.countTokens(ctx, chatParts(chat.History, last))
+
+	var reply strings.Builder
+	var outputTokens int
+	iter := chat.SendMessageStream(ctx, last.Parts...)
+	for {
+		resp, err := iter.Next()
+		if errors.Is(err, iterator.Done) {
+			break
+		}
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to generate content: %w", err)
+		}
+		// Chunks report the token count of the reply so far
+		if len(resp.Candidates) > 0 {
+			outputTokens = max(outputTokens, int(resp.Candidates[0].TokenCount))
+		}
+
+		delta := geminiText(resp)
+		if delta == "" {
+			continue
 		}
 
-		if err := json.Unmarshal([]byte(jsonStr), &result); err != nil {
-			return nil, fmt.Errorf("failed to parse extracted JSON: %w", err)
+		reply.WriteString(delta)
+		if err := onDelta(delta); err != nil {
+			return "", nil, err
 		}
 	}
 
-	// Convert to the expected return format
-	return &FoodAnalysisResult{
-		Name:       result.Name,
-		Carbs:      result.Carbs,
-		Confidence: result.Confidence,
-		Reasoning:  result.Reasoning,
-	}, nil
+	usage := geminiUsage(<-inputTokens, nil)
+	usage.OutputTokens = outputTokens
+	return reply.String(), usage, nil
+}
+
+// countTokens counts the prompt tokens alongside the request. The SDK version in use doesn't return
+// usage metadata with responses, so input tokens come from the free countTokens endpoint; 0 if it fails.
+func (p *GeminiProvider) countTokens(ctx context.Context, parts []genai.Part) <-chan int {
+	tokens := make(chan int, 1)
+	go func() {
+		resp, err := p.model.CountTokens(ctx, parts...)
+		if err != nil {
+			log.Printf("Gemini: failed to count tokens: %v", err)
+			tokens <- 0
+			return
+		}
+		tokens <- int(resp.TotalTokens)
+	}()
+	return tokens
+}
+
+// chatParts flattens a conversation for counting its tokens
+func chatParts(history []*genai.Content, last *genai.Content) []genai.Part {
+	var parts []genai.Part
+	for _, content := range history {
+		parts = append(parts, content.Parts...)
+	}
+	return append(parts, last.Parts...)
 }
 
-// truncateString truncates a string to the specified length and adds "..." if truncated
-func truncateString(s string, maxLength int) string {
-	if len(s) <= maxLength {
-		return s
+// geminiUsage builds the usage of a call from the counted input tokens and the candidate's token count
+func geminiUsage(inputTokens int, resp *genai.GenerateContentResponse) *Usage {
+	usage := &Usage{Model: geminiModel, InputTokens: inputTokens}
+	if resp != nil && len(resp.Candidates) > 0 {
+		usage.OutputTokens = int(resp.Candidates[0].TokenCount)
+	}
+	return usage
+}
+
+// startChat converts messages to a Gemini chat session and returns it with the last user message.
+// Gemini has no system role, so system messages are prepended to the first user message.
+func (p *GeminiProvider) startChat(messages []Message) (*genai.ChatSession, *genai.Content, error) {
+	var systemText string
+	var history []*genai.Content
+	for _, msg := range messages {
+		switch msg.Role {
+		case RoleSystem:
+			systemText += msg.Content + "\n\n"
+		case RoleAssistant:
+			history = append(history, &genai.Content{Role: "model", Parts: []genai.Part{genai.Text(msg.Content)}})
+		default:
+			text := msg.Content
+			if systemText != "" {
+				text = systemText + text
+				systemText = ""
+			}
+			history = append(history, &genai.Content{Role: "user", Parts: []genai.Part{genai.Text(text)}})
+		}
+	}
+
+	if len(history) == 0 || history[len(history)-1].Role != "user" {
+		return nil, nil, fmt.Errorf("conversation must end with a user message")
+	}
+
+	// The last user message is sent, everything before it is history
+	chat := p.model.StartChat()
+	chat.History = history[:len(history)-1]
+	return chat, history[len(history)-1], nil
+}
+
+// geminiText concatenates the text parts of the first candidate
+func geminiText(resp *genai.GenerateContentResponse) string {
+	if len(resp.Candidates) == 0 || resp.Candidates[0].Content == nil {
+		return ""
+	}
+
+	var text strings.Builder
+	for _, part := range resp.Candidates[0].Content.Parts {
+		if t, ok := part.(genai.Text); ok {
+			text.WriteString(string(t))
+		}
 	}
-	return s[:maxLength] + "..."
+	return text.String()
 }
 
 // min returns the minimum of two integers
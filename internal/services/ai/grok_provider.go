@@ -2,18 +2,18 @@ package ai
 
 import (
 	"bytes"
+	"context"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
-	"os"
 )
 
 // GrokProvider implements the Provider interface for Grok's API
 // Note: As of writing, Grok doesn't have a public API. This is a placeholder implementation
 // that follows a similar pattern to other AI APIs. It will need to be updated when Grok's
-// API becomes available.
+// API becomes available. Chat goes through xAI's OpenAI-compatible chat completions endpoint.
 type GrokProvider struct {
 	apiKey string
 }
@@ -24,9 +24,21 @@ type grokImageAnalysisRequest struct {
 	MaxTokens int      `json:"max_tokens"`
 }
 
+// grokChatModel is the model used for chat completions
+const grokChatModel = "grok-beta"
+
+type grokChatRequest struct {
+	Model         string               `json:"model"`
+	Messages      []Message            `json:"messages"`
+	Stream        bool                 `json:"stream,omitempty"`
+	StreamOptions *openAIStreamOptions `json:"stream_options,omitempty"`
+}
+
 type grokResponse struct {
-	Response string `json:"response"`
-	Error    string `json:"error"`
+	Response string       `json:"response"`
+	Model    string       `json:"model"`
+	Usage    *openAIUsage `json:"usage"` // Assumed to follow the chat completions format
+	Error    string       `json:"error"`
 }
 
 // NewGrokProvider creates a new Grok provider
@@ -41,66 +53,17 @@ func NewGrokProvider(apiKey string) (*GrokProvider, error) {
 }
 
 // AnalyzeFood analyzes a food image and returns the estimated carbohydrates
-func (p *GrokProvider) AnalyzeFood(foodImagePath, unusedDescriptionParam string, foodWeight float64) (*FoodAnalysisResult, error) {
-	// Description parameter is no longer used, only photo and weight
-	// Read food image file
-	foodImg, err := os.ReadFile(foodImagePath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read food image: %w", err)
-	}
-
-	// Convert image to base64
-	foodImgBase64 := base64.StdEncoding.EncodeToString(foodImg)
-
-	// Create image array
-	images := []string{foodImgBase64}
-
-	// Create the prompt with improved diabetes management focus
-	promptText := `You are a certified diabetes educator specializing in nutrition analysis. 
-You will analyze the food in the image to estimate its carbohydrate content accurately for diabetes management.
-
-TASK:
-1. Identify the food items in the image
-2. Estimate total carbohydrates (in grams) based on standard nutritional databases
-3. Assess your confidence in this estimation (low, medium, high)
-4. Provide the information in a specific JSON format
-
-REQUIREMENTS:
-- Be medically precise in your carbohydrate estimation
-- Include both visible ingredients and likely hidden ingredients that contain carbs
-- Consider portion sizes carefully
-- Account for various cooking methods that might affect carbohydrate content
-- If the image contains nutritional information or packaging, prioritize that data
-- IMPORTANT: Provide all text responses in Russian language for Russian users
-- Food names should be in Russian
-- Reasoning/descriptions should be in Russian`
-
-	// Add weight information if provided
-	if foodWeight > 0 {
-		promptText += fmt.Sprintf(`
-
-IMPORTANT WEIGHT INFORMATION:
-- The user has specified that the food weighs %.1f grams
-- Adjust your carbohydrate calculation based on this exact weight
-- Make sure to mention the weight in your reasoning`, foodWeight)
-	}
-
-	promptText += `
-
-RESPONSE FORMAT:
-Respond ONLY with valid JSON matching this exact structure:
-{
-  "name": "Complete name of the dish in Russian",
-  "carbs": number, 
-  "confidence": "low|medium|high",
-  "reasoning": "Brief explanation of how you estimated the carbs in Russian"
-}
-
-This information will be used for insulin dosing, so accuracy is critically important for patient safety.`
+func (p *GrokProvider) AnalyzeFood(ctx context.Context, image *Image, prompt string, foodWeight float64) (*FoodAnalysisResult, error) {
+	// The prompt is rendered by the Service from a versioned template, so weight is already part of it
+	// Create image array, empty for text-only analysis
+	images := []string{}
+	if image != nil {
+		images = append(images, base64.StdEncoding.EncodeToString(image.Data))
+	}
 
 	// Create the request payload
 	payload := grokImageAnalysisRequest{
-		Prompt:    promptText,
+		Prompt:    prompt,
 		Images:    images,
 		MaxTokens: 1024, // Increased token limit to allow for detailed reasoning
 	}
@@ -113,7 +76,7 @@ This information will be used for insulin dosing, so accuracy is critically impo
 
 	// Create the HTTP request
 	// Note: Update this URL when Grok's API is available
-	req, err := http.NewRequest("POST", "https://api.grok.ai/v1/analysis", bytes.NewBuffer(payloadJSON))
+	req, err := http.NewRequestWithContext(ctx, "POST", "https://api.grok.ai/v1/analysis", bytes.NewBuffer(payloadJSON))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create HTTP request: %w", err)
 	}
@@ -147,31 +110,69 @@ This information will be used for insulin dosing, so accuracy is critically impo
 		return nil, fmt.Errorf("Grok API error: %s", grokResp.Error)
 	}
 
-	// Parse the JSON response
-	var result struct {
-		Name       string  `json:"name"`
-		Carbs      float64 `json:"carbs"`
-		Confidence string  `json:"confidence"`
-		Reasoning  string  `json:"reasoning"`
+	result, err := parseFoodAnalysisResponse(grokResp.Response)
+	if err != nil {
+		return nil, err
 	}
+	result.Usage = grokResp.Usage.toUsage(grokResp.Model)
+	return result, nil
+}
 
-	if err := json.Unmarshal([]byte(grokResp.Response), &result); err != nil {
-		// Try to extract JSON from a text response
-		jsonStr, extractErr := extractJSONFromText(grokResp.Response)
-		if extractErr != nil {
-			return nil, fmt.Errorf("failed to parse response: %w (response was: %s)", err, grokResp.Response)
-		}
+// ChatCompletion returns the assistant's reply to a conversation
+func (p *GrokProvider) ChatCompletion(ctx context.Context, messages []Message) (string, *Usage, error) {
+	resp, err := p.sendChatRequest(ctx, grokChatRequest{
+		Model:    grokChatModel,
+		Messages: messages,
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	defer resp.Body.Close()
 
-		if err := json.Unmarshal([]byte(jsonStr), &result); err != nil {
-			return nil, fmt.Errorf("failed to parse extracted JSON: %w", err)
-		}
+	// The response uses the OpenAI chat completions format
+	return parseOpenAIResponse(resp.Body, "Grok")
+}
+
+// ChatCompletionStream is like ChatCompletion but calls onDelta with each piece of the reply as it arrives
+func (p *GrokProvider) ChatCompletionStream(ctx context.Context, messages []Message, onDelta func(string) error) (string, *Usage, error) {
+	resp, err := p.sendChatRequest(ctx, grokChatRequest{
+		Model:         grokChatModel,
+		Messages:      messages,
+		Stream:        true,
+		StreamOptions: &openAIStreamOptions{IncludeUsage: true},
+	})
+	if err != nil {
+		return "", nil, err
 	}
+	defer resp.Body.Close()
 
-	// Convert to the expected return format
-	return &FoodAnalysisResult{
-		Name:       result.Name,
-		Carbs:      result.Carbs,
-		Confidence: result.Confidence,
-		Reasoning:  result.Reasoning,
-	}, nil
+	if resp.StatusCode != http.StatusOK {
+		_, _, err := parseOpenAIResponse(resp.Body, "Grok")
+		return "", nil, err
+	}
+
+	return readChatCompletionStream(resp.Body, onDelta)
+}
+
+// sendChatRequest posts a payload to xAI's chat completions endpoint
+func (p *GrokProvider) sendChatRequest(ctx context.Context, payload grokChatRequest) (*http.Response, error) {
+	payloadJSON, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request payload: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", "https://api.x.ai/v1/chat/completions", bytes.NewBuffer(payloadJSON))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create HTTP request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+p.apiKey)
+
+	client := &http.Client{}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request to Grok: %w", err)
+	}
+	return resp, nil
 }
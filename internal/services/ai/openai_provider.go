@@ -1,18 +1,24 @@
 package ai
 
 import (
+	"bufio"
 	"bytes"
+	"context"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
-	"os"
+	"strings"
 )
 
+// openAIVisionModel is the model used for food photo analysis
+const openAIVisionModel = "gpt-4-vision-preview"
+
 // OpenAIProvider implements the Provider interface for OpenAI's API
 type OpenAIProvider struct {
-	apiKey string
+	apiKey    string
+	chatModel string
 }
 
 type openAIImageAnalysisRequest struct {
@@ -26,6 +32,36 @@ type openAIMessageInput struct {
 	Content []interface{} `json:"content"`
 }
 
+type openAIChatRequest struct {
+	Model         string               `json:"model"`
+	Messages      []Message            `json:"messages"`
+	MaxTokens     int                  `json:"max_tokens"`
+	Stream        bool                 `json:"stream,omitempty"`
+	StreamOptions *openAIStreamOptions `json:"stream_options,omitempty"`
+}
+
+// openAIStreamOptions asks for a final stream chunk with the token usage
+type openAIStreamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+// openAIUsage is the token usage of a chat completion
+type openAIUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+}
+
+// openAIStreamChunk is a single server-sent event of a streamed chat completion
+type openAIStreamChunk struct {
+	Model   string `json:"model"`
+	Choices []struct {
+		Delta struct {
+			Content string `json:"content"`
+		} `json:"delta"`
+	} `json:"choices"`
+	Usage *openAIUsage `json:"usage"`
+}
+
 type openAITextContent struct {
 	Type string `json:"type"`
 	Text string `json:"text"`
@@ -41,98 +77,60 @@ type openAIImageURLData struct {
 }
 
 type openAIResponse struct {
+	Model   string `json:"model"`
 	Choices []struct {
 		Message struct {
 			Content string `json:"content"`
 		} `json:"message"`
 	} `json:"choices"`
+	Usage *openAIUsage `json:"usage"`
 	Error *struct {
 		Message string `json:"message"`
 	} `json:"error"`
 }
 
-// NewOpenAIProvider creates a new OpenAI provider
-func NewOpenAIProvider(apiKey string) (*OpenAIProvider, error) {
+// NewOpenAIProvider creates a new OpenAI provider. chatModel is used for ChatCompletion.
+func NewOpenAIProvider(apiKey, chatModel string) (*OpenAIProvider, error) {
 	if apiKey == "" {
 		return nil, fmt.Errorf("OpenAI API key is required")
 	}
 
+	if chatModel == "" {
+		chatModel = "gpt-3.5-turbo"
+	}
+
 	return &OpenAIProvider{
-		apiKey: apiKey,
+		apiKey:    apiKey,
+		chatModel: chatModel,
 	}, nil
 }
 
 // AnalyzeFood analyzes a food image and returns the estimated carbohydrates
-func (p *OpenAIProvider) AnalyzeFood(foodImagePath, unusedDescriptionParam string, foodWeight float64) (*FoodAnalysisResult, error) {
-	// Description parameter is no longer used, only photo and weight
-	// Read food image file
-	foodImg, err := os.ReadFile(foodImagePath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read food image: %w", err)
-	}
-
-	// Convert image to base64
-	foodImgBase64 := base64.StdEncoding.EncodeToString(foodImg)
-
-	// Create content items for the OpenAI request
-	promptText := `You are a certified diabetes educator specializing in nutrition analysis. 
-You will analyze the food in the image to estimate its carbohydrate content accurately for diabetes management.
-
-TASK:
-1. Identify the food items in the image
-2. Estimate total carbohydrates (in grams) based on standard nutritional databases
-3. Assess your confidence in this estimation (low, medium, high)
-4. Provide the information in a specific JSON format
-
-REQUIREMENTS:
-- Be medically precise in your carbohydrate estimation
-- Include both visible ingredients and likely hidden ingredients that contain carbs
-- Consider portion sizes carefully
-- Account for various cooking methods that might affect carbohydrate content
-- If the image contains nutritional information or packaging, prioritize that data
-- IMPORTANT: Provide all text responses in Russian language for Russian users
-- Food names should be in Russian
-- Reasoning/descriptions should be in Russian`
-
-	// Add weight information if provided
-	if foodWeight > 0 {
-		promptText += fmt.Sprintf(`
-
-IMPORTANT WEIGHT INFORMATION:
-- The user has specified that the food weighs %.1f grams
-- Adjust your carbohydrate calculation based on this exact weight
-- Make sure to mention the weight in your reasoning`, foodWeight)
-	}
-
-	promptText += `
-
-RESPONSE FORMAT:
-Respond ONLY with valid JSON matching this exact structure:
-{
-  "name": "Complete name of the dish in Russian",
-  "carbs": number, 
-  "confidence": "low|medium|high",
-  "reasoning": "Brief explanation of how you estimated the carbs in Russian"
-}
-
-This information will be used for insulin dosing, so accuracy is critically important for patient safety.`
-
+func (p *OpenAIProvider) AnalyzeFood(ctx context.Context, image *Image, prompt string, foodWeight float64) (*FoodAnalysisResult, error) {
+	// The prompt is rendered by the Service from a versioned template, so weight is already part of it
 	contentItems := []interface{}{
 		openAITextContent{
 			Type: "text",
-			Text: promptText,
+			Text: prompt,
 		},
-		openAIImageContent{
+	}
+
+	// Text-only analysis has no image
+	if image != nil {
+		// Convert image to base64
+		foodImgBase64 := base64.StdEncoding.EncodeToString(image.Data)
+
+		contentItems = append(contentItems, openAIImageContent{
 			Type: "image_url",
 			ImageURL: openAIImageURLData{
-				URL: "data:image/jpeg;base64," + foodImgBase64,
+				URL: "data:" + image.MIMEType + ";base64," + foodImgBase64,
 			},
-		},
+		})
 	}
 
 	// Create the request payload
 	payload := openAIImageAnalysisRequest{
-		Model: "gpt-4-vision-preview",
+		Model: openAIVisionModel,
 		Messages: []openAIMessageInput{
 			{
 				Role:    "user",
@@ -142,6 +140,67 @@ This information will be used for insulin dosing, so accuracy is critically impo
 		MaxTokens: 1024, // Increased token limit to allow for detailed reasoning
 	}
 
+	content, usage, err := p.createChatCompletion(ctx, payload)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := parseFoodAnalysisResponse(content)
+	if err != nil {
+		return nil, err
+	}
+	result.Usage = usage
+	return result, nil
+}
+
+// ChatCompletion returns the assistant's reply to a conversation
+func (p *OpenAIProvider) ChatCompletion(ctx context.Context, messages []Message) (string, *Usage, error) {
+	payload := openAIChatRequest{
+		Model:     p.chatModel,
+		Messages:  messages,
+		MaxTokens: 1024,
+	}
+
+	return p.createChatCompletion(ctx, payload)
+}
+
+// ChatCompletionStream is like ChatCompletion but calls onDelta with each piece of the reply as it arrives
+func (p *OpenAIProvider) ChatCompletionStream(ctx context.Context, messages []Message, onDelta func(string) error) (string, *Usage, error) {
+	payload := openAIChatRequest{
+		Model:         p.chatModel,
+		Messages:      messages,
+		MaxTokens:     1024,
+		Stream:        true,
+		StreamOptions: &openAIStreamOptions{IncludeUsage: true},
+	}
+
+	resp, err := p.sendRequest(ctx, payload)
+	if err != nil {
+		return "", nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		_, _, err := parseOpenAIResponse(resp.Body, "OpenAI")
+		return "", nil, err
+	}
+
+	return readChatCompletionStream(resp.Body, onDelta)
+}
+
+// createChatCompletion sends a request to the chat completions endpoint and returns the first choice
+func (p *OpenAIProvider) createChatCompletion(ctx context.Context, payload interface{}) (string, *Usage, error) {
+	resp, err := p.sendRequest(ctx, payload)
+	if err != nil {
+		return "", nil, err
+	}
+	defer resp.Body.Close()
+
+	return parseOpenAIResponse(resp.Body, "OpenAI")
+}
+
+// sendRequest posts a payload to the chat completions endpoint
+func (p *OpenAIProvider) sendRequest(ctx context.Context, payload interface{}) (*http.Response, error) {
 	// Marshal the payload to JSON
 	payloadJSON, err := json.Marshal(payload)
 	if err != nil {
@@ -149,7 +208,7 @@ This information will be used for insulin dosing, so accuracy is critically impo
 	}
 
 	// Create the HTTP request
-	req, err := http.NewRequest("POST", "https://api.openai.com/v1/chat/completions", bytes.NewBuffer(payloadJSON))
+	req, err := http.NewRequestWithContext(ctx, "POST", "https://api.openai.com/v1/chat/completions", bytes.NewBuffer(payloadJSON))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create HTTP request: %w", err)
 	}
@@ -164,58 +223,85 @@ This information will be used for insulin dosing, so accuracy is critically impo
 	if err != nil {
 		return nil, fmt.Errorf("failed to send request to OpenAI: %w", err)
 	}
-	defer resp.Body.Close()
+	return resp, nil
+}
 
+// parseOpenAIResponse reads a chat completions response body (also used by OpenAI-compatible APIs)
+// and returns the content of the first choice with the token usage
+func parseOpenAIResponse(body io.Reader, apiName string) (string, *Usage, error) {
 	// Read the response
-	body, err := io.ReadAll(resp.Body)
+	data, err := io.ReadAll(body)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response body: %w", err)
+		return "", nil, fmt.Errorf("failed to read response body: %w", err)
 	}
 
 	// Parse the response
 	var openAIResp openAIResponse
-	if err := json.Unmarshal(body, &openAIResp); err != nil {
-		return nil, fmt.Errorf("failed to parse response: %w", err)
+	if err := json.Unmarshal(data, &openAIResp); err != nil {
+		return "", nil, fmt.Errorf("failed to parse response: %w", err)
 	}
 
 	// Check for errors
 	if openAIResp.Error != nil {
-		return nil, fmt.Errorf("OpenAI API error: %s", openAIResp.Error.Message)
+		return "", nil, fmt.Errorf("%s API error: %s", apiName, openAIResp.Error.Message)
 	}
 
 	// Check if we got a response
 	if len(openAIResp.Choices) == 0 {
-		return nil, fmt.Errorf("no response from OpenAI")
+		return "", nil, fmt.Errorf("no response from %s", apiName)
 	}
 
-	// Extract the content
-	content := openAIResp.Choices[0].Message.Content
+	return openAIResp.Choices[0].Message.Content, openAIResp.Usage.toUsage(openAIResp.Model), nil
+}
 
-	// Parse the JSON response
-	var result struct {
-		Name       string  `json:"name"`
-		Carbs      float64 `json:"carbs"`
-		Confidence string  `json:"confidence"`
-		Reasoning  string  `json:"reasoning"`
+// toUsage converts the usage field of a response, nil if the API didn't report it
+func (u *openAIUsage) toUsage(model string) *Usage {
+	if u == nil {
+		return nil
 	}
+	return &Usage{Model: model, InputTokens: u.PromptTokens, OutputTokens: u.CompletionTokens}
+}
+
+// readChatCompletionStream reads a streamed chat completion in the OpenAI server-sent events format.
+// The usage comes in the last chunk when it was requested with stream_options.
+func readChatCompletionStream(body io.Reader, onDelta func(string) error) (string, *Usage, error) {
+	var reply strings.Builder
+	var usage *Usage
+	scanner := bufio.NewScanner(body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
 
-	if err := json.Unmarshal([]byte(content), &result); err != nil {
-		// Try to extract JSON from a text response
-		jsonStr, extractErr := extractJSONFromText(content)
-		if extractErr != nil {
-			return nil, fmt.Errorf("failed to parse response: %w (response was: %s)", err, content)
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if data == "[DONE]" {
+			break
 		}
 
-		if err := json.Unmarshal([]byte(jsonStr), &result); err != nil {
-			return nil, fmt.Errorf("failed to parse extracted JSON: %w", err)
+		var chunk openAIStreamChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return "", nil, fmt.Errorf("failed to parse stream chunk: %w", err)
+		}
+
+		if chunk.Usage != nil {
+			usage = chunk.Usage.toUsage(chunk.Model)
+		}
+
+		if len(chunk.Choices) == 0 || chunk.Choices[0].Delta.Content == "" {
+			continue
+		}
+
+		delta := chunk.Choices[0].Delta.Content
+		reply.WriteString(delta)
+		if err := onDelta(delta); err != nil {
+			return "", nil, err
 		}
 	}
 
-	// Convert to the expected return format
-	return &FoodAnalysisResult{
-		Name:       result.Name,
-		Carbs:      result.Carbs,
-		Confidence: result.Confidence,
-		Reasoning:  result.Reasoning,
-	}, nil
+	if err := scanner.Err(); err != nil {
+		return "", nil, fmt.Errorf("failed to read stream: %w", err)
+	}
+
+	return reply.String(), usage, nil
 }
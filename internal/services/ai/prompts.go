@@ -0,0 +1,244 @@
+package ai
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"text/template/parse"
+
+	"github.com/yourusername/diabetes-assistant/internal/models"
+)
+
+// Template names
+const (
+	PromptFoodAnalysis   = "food_analysis"   // Food photo analysis
+	PromptNutritionLabel = "nutrition_label" // Reading a nutrition facts label
+)
+
+// PromptVariables holds the values substituted into the food analysis templates
+type PromptVariables struct {
+	Weight      float64 // Food weight in grams, 0 if unknown
+	UserNotes   string  // Free-form notes from the user (hidden ingredients, preparation)
+	MealContext string  // Meal context such as "breakfast" or "after workout"
+	Description string  // What the user ate in their own words, e.g. "2 slices rye bread with cheese"
+	HasPhoto    bool    // Set by the Service when an image is sent with the prompt
+}
+
+// RenderedPrompt is a prompt template rendered for a specific request
+type RenderedPrompt struct {
+	Name    string
+	Version string
+	Locale  string
+	Text    string
+}
+
+// PromptStore holds versioned prompt templates loaded from disk.
+// Templates are laid out as <dir>/<name>/<version>.<locale>.tmpl, e.g. prompts/food_analysis/v2.ru.tmpl
+type PromptStore struct {
+	templates     map[string]map[string]map[string]*template.Template // name -> version -> locale
+	versions      map[string][]string                                 // name -> versions, oldest first
+	pinnedVersion string
+}
+
+// LoadPromptStore loads all prompt templates from dir. If pinnedVersion is not empty
+// it is used for every template that has it, otherwise the latest version is used.
+func LoadPromptStore(dir, pinnedVersion string) (*PromptStore, error) {
+	store := &PromptStore{
+		templates:     make(map[string]map[string]map[string]*template.Template),
+		versions:      make(map[string][]string),
+		pinnedVersion: pinnedVersion,
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*", "*.tmpl"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list prompt templates: %w", err)
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no prompt templates found in %s", dir)
+	}
+
+	for _, file := range files {
+		name := filepath.Base(filepath.Dir(file))
+		parts := strings.Split(strings.TrimSuffix(filepath.Base(file), ".tmpl"), ".")
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid prompt template file name %s (expected <version>.<locale>.tmpl)", file)
+		}
+		version, locale := parts[0], parts[1]
+
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read prompt template %s: %w", file, err)
+		}
+
+		tmpl, err := template.New(filepath.Base(file)).Option("missingkey=error").Parse(string(content))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse prompt template %s: %w", file, err)
+		}
+
+		if store.templates[name] == nil {
+			store.templates[name] = make(map[string]map[string]*template.Template)
+		}
+		if store.templates[name][version] == nil {
+			store.templates[name][version] = make(map[string]*template.Template)
+			store.versions[name] = append(store.versions[name], version)
+		}
+		store.templates[name][version][locale] = tmpl
+	}
+
+	for name := range store.versions {
+		sortVersions(store.versions[name])
+	}
+
+	return store, nil
+}
+
+// ActiveVersion returns the template version used for new requests
+func (s *PromptStore) ActiveVersion(name string) (string, error) {
+	versions := s.versions[name]
+	if len(versions) == 0 {
+		return "", fmt.Errorf("unknown prompt template: %s", name)
+	}
+
+	if s.pinnedVersion != "" {
+		if _, ok := s.templates[name][s.pinnedVersion]; ok {
+			return s.pinnedVersion, nil
+		}
+	}
+
+	return versions[len(versions)-1], nil
+}
+
+// Locales returns the locales the active version of every template is available in, sorted
+func (s *PromptStore) Locales() []string {
+	counts := make(map[string]int)
+	for name := range s.versions {
+		version, err := s.ActiveVersion(name)
+		if err != nil {
+			continue
+		}
+		for locale := range s.templates[name][version] {
+			counts[locale]++
+		}
+	}
+
+	var locales []string
+	for locale, count := range counts {
+		if count == len(s.versions) {
+			locales = append(locales, locale)
+		}
+	}
+	sort.Strings(locales)
+	return locales
+}
+
+// Render renders the active version of a template for the given locale.
+// It falls back to models.DefaultLocale when there is no template for the requested one.
+func (s *PromptStore) Render(name, locale string, data interface{}) (*RenderedPrompt, error) {
+	tmpl, version, locale, err := s.lookup(name, locale)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return nil, fmt.Errorf("failed to render prompt template %s %s.%s: %w", name, version, locale, err)
+	}
+
+	return &RenderedPrompt{
+		Name:    name,
+		Version: version,
+		Locale:  locale,
+		Text:    strings.TrimSpace(buf.String()),
+	}, nil
+}
+
+// UsesField reports whether the template Render would use for the locale refers to a field of
+// its data, e.g. "Description". Older versions may not know about newer request fields.
+func (s *PromptStore) UsesField(name, locale, field string) (bool, error) {
+	tmpl, _, _, err := s.lookup(name, locale)
+	if err != nil {
+		return false, err
+	}
+	return usesField(tmpl.Tree.Root, field), nil
+}
+
+// lookup returns the active version of a template in the locale, or in models.DefaultLocale
+func (s *PromptStore) lookup(name, locale string) (*template.Template, string, string, error) {
+	version, err := s.ActiveVersion(name)
+	if err != nil {
+		return nil, "", "", err
+	}
+
+	locales := s.templates[name][version]
+	tmpl, ok := locales[locale]
+	if !ok {
+		locale = models.DefaultLocale
+		tmpl, ok = locales[locale]
+	}
+	if !ok {
+		return nil, "", "", fmt.Errorf("prompt template %s %s has no %s locale", name, version, models.DefaultLocale)
+	}
+	return tmpl, version, locale, nil
+}
+
+// usesField walks a template's parse tree looking for .field
+func usesField(node parse.Node, field string) bool {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return false
+		}
+		for _, child := range n.Nodes {
+			if usesField(child, field) {
+				return true
+			}
+		}
+	case *parse.ActionNode:
+		return usesField(n.Pipe, field)
+	case *parse.PipeNode:
+		if n == nil {
+			return false
+		}
+		for _, cmd := range n.Cmds {
+			if usesField(cmd, field) {
+				return true
+			}
+		}
+	case *parse.CommandNode:
+		for _, arg := range n.Args {
+			if usesField(arg, field) {
+				return true
+			}
+		}
+	case *parse.FieldNode:
+		return len(n.Ident) > 0 && n.Ident[0] == field
+	case *parse.ChainNode:
+		return usesField(n.Node, field)
+	case *parse.IfNode:
+		return usesField(n.Pipe, field) || usesField(n.List, field) || usesField(n.ElseList, field)
+	case *parse.RangeNode:
+		return usesField(n.Pipe, field) || usesField(n.List, field) || usesField(n.ElseList, field)
+	case *parse.WithNode:
+		return usesField(n.Pipe, field) || usesField(n.List, field) || usesField(n.ElseList, field)
+	case *parse.TemplateNode:
+		return usesField(n.Pipe, field)
+	}
+	return false
+}
+
+// sortVersions sorts versions like v1, v2, v10 numerically, falling back to string order
+func sortVersions(versions []string) {
+	sort.Slice(versions, func(i, j int) bool {
+		a, errA := strconv.Atoi(strings.TrimPrefix(versions[i], "v"))
+		b, errB := strconv.Atoi(strings.TrimPrefix(versions[j], "v"))
+		if errA == nil && errB == nil {
+			return a < b
+		}
+		return versions[i] < versions[j]
+	})
+}
@@ -0,0 +1,89 @@
+package ai
+
+import (
+	"context"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/yourusername/diabetes-assistant/internal/models"
+)
+
+// UsageRecorder stores AI usage records, storage.Storage implements it
+type UsageRecorder interface {
+	SaveAIUsage(ctx context.Context, usage *models.AIUsage) error
+}
+
+// modelPrice is a list price in USD per million tokens
+type modelPrice struct {
+	input  float64
+	output float64
+}
+
+// modelPrices are used to estimate the cost of a call. Models are matched by prefix,
+// so dated versions such as gpt-4o-2024-05-13 use the price of gpt-4o.
+var modelPrices = map[string]modelPrice{
+	"gpt-4-vision-preview": {input: 10, output: 30},
+	"gpt-4-turbo":          {input: 10, output: 30},
+	"gpt-4o-mini":          {input: 0.15, output: 0.6},
+	"gpt-4o":               {input: 5, output: 15},
+	"gpt-4":                {input: 30, output: 60},
+	"gpt-3.5-turbo":        {input: 0.5, output: 1.5},
+	"gemini-1.5-pro":       {input: 3.5, output: 10.5},
+	"gemini-1.5-flash":     {input: 0.35, output: 1.05},
+	"grok-beta":            {input: 5, output: 15},
+}
+
+// EstimateCost returns the estimated cost of a call in USD, 0 for unknown models
+func EstimateCost(model string, inputTokens, outputTokens int) float64 {
+	// The longest matching prefix wins, so gpt-4o doesn't use the gpt-4 price
+	var price modelPrice
+	matched := ""
+	for name, p := range modelPrices {
+		if strings.HasPrefix(model, name) && len(name) > len(matched) {
+			price = p
+			matched = name
+		}
+	}
+
+	return (float64(inputTokens)*price.input + float64(outputTokens)*price.output) / 1e6
+}
+
+// SetUsageRecorder enables recording of every provider call with its token usage, latency and cost
+func (s *Service) SetUsageRecorder(recorder UsageRecorder) {
+	s.usage = recorder
+}
+
+// recordUsage stores a provider call. Failures are logged, accounting must not fail the call itself.
+func (s *Service) recordUsage(ctx context.Context, userID, operation string, started time.Time, usage *Usage, callErr error) {
+	if s.usage == nil {
+		return
+	}
+
+	record := &models.AIUsage{
+		ID:        uuid.New().String(),
+		UserID:    userID,
+		Timestamp: started,
+		Provider:  s.providerName,
+		Operation: operation,
+		LatencyMs: time.Since(started).Milliseconds(),
+	}
+	if usage != nil {
+		record.Model = usage.Model
+		record.InputTokens = usage.InputTokens
+		record.OutputTokens = usage.OutputTokens
+		record.CostUSD = EstimateCost(usage.Model, usage.InputTokens, usage.OutputTokens)
+	}
+	if callErr != nil {
+		record.Error = callErr.Error()
+	}
+
+	// A cancelled request, e.g. a closed chat stream, still used tokens
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+	defer cancel()
+
+	if err := s.usage.SaveAIUsage(ctx, record); err != nil {
+		log.Printf("AI: failed to record usage: %v", err)
+	}
+}
@@ -1,7 +1,10 @@
 package ai
 
 import (
+	"encoding/json"
 	"fmt"
+	"math"
+	"strings"
 )
 
 // extractJSONFromText attempts to extract JSON content from a text block
@@ -44,3 +47,93 @@ func extractJSONFromText(text string) (string, error) {
 
 	return "", fmt.Errorf("no JSON object found in text")
 }
+
+// parseFoodAnalysisResponse parses the JSON answer of a provider into a FoodAnalysisResult.
+// Nutrition label fields, fat, protein and absorption are optional and stay zero when the prompt doesn't ask for them.
+// Confidence may be returned either as a number between 0 and 1 or as a low/medium/high label.
+func parseFoodAnalysisResponse(text string) (*FoodAnalysisResult, error) {
+	var result struct {
+		Name       string          `json:"name"`
+		Carbs      float64         `json:"carbs"`
+		Confidence json.RawMessage `json:"confidence"`
+		Reasoning  string          `json:"reasoning"`
+		Fat        float64         `json:"fat"`
+		Protein    float64         `json:"protein"`
+		Absorption string          `json:"absorption"`
+
+		CarbsPer100g     float64 `json:"carbsPer100g"`
+		CarbsPerServing  float64 `json:"carbsPerServing"`
+		ServingSizeGrams float64 `json:"servingSizeGrams"`
+	}
+
+	if err := json.Unmarshal([]byte(text), &result); err != nil {
+		// Try to extract JSON from a text response
+		jsonStr, extractErr := extractJSONFromText(text)
+		if extractErr != nil {
+			return nil, fmt.Errorf("failed to parse response: %w (response was: %s)", err, truncateString(text, 200))
+		}
+
+		if err := json.Unmarshal([]byte(jsonStr), &result); err != nil {
+			return nil, fmt.Errorf("failed to parse extracted JSON: %w", err)
+		}
+	}
+
+	return &FoodAnalysisResult{
+		Name:       result.Name,
+		Carbs:      result.Carbs,
+		Confidence: parseConfidence(result.Confidence),
+		Reasoning:  result.Reasoning,
+		Fat:        result.Fat,
+		Protein:    result.Protein,
+		Absorption: parseAbsorption(result.Absorption),
+
+		CarbsPer100g:     result.CarbsPer100g,
+		CarbsPerServing:  result.CarbsPerServing,
+		ServingSizeGrams: result.ServingSizeGrams,
+	}, nil
+}
+
+// parseConfidence converts a confidence value to a number between 0 and 1
+func parseConfidence(raw json.RawMessage) float64 {
+	var value float64
+	if err := json.Unmarshal(raw, &value); err == nil {
+		// Some models answer in percent
+		if value > 1 {
+			value /= 100
+		}
+		return math.Max(0, math.Min(1, value))
+	}
+
+	var label string
+	if err := json.Unmarshal(raw, &label); err != nil {
+		return ConfidenceMedium
+	}
+
+	switch strings.ToLower(strings.TrimSpace(label)) {
+	case "low":
+		return ConfidenceLow
+	case "high":
+		return ConfidenceHigh
+	default:
+		return ConfidenceMedium
+	}
+}
+
+// parseAbsorption normalizes the absorption class, anything but fast, medium or slow is dropped
+func parseAbsorption(label string) string {
+	label = strings.ToLower(strings.TrimSpace(label))
+	switch label {
+	case "fast", "medium", "slow":
+		return label
+	default:
+		return ""
+	}
+}
+
+// truncateString truncates a string to the specified length and adds "..." if truncated
+func truncateString(s string, maxLength int) string {
+	if len(s) <= maxLength {
+		return s
+	}
+	return s[:maxLength] + "..."
+}
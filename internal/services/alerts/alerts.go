@@ -0,0 +1,473 @@
+package alerts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/yourusername/diabetes-assistant/internal/models"
+	"github.com/yourusername/diabetes-assistant/internal/services/glucose"
+	"github.com/yourusername/diabetes-assistant/internal/services/prediction"
+	"github.com/yourusername/diabetes-assistant/internal/storage"
+)
+
+// MaxSnooze is the longest an alert can be snoozed
+const MaxSnooze = 24 * time.Hour
+
+const (
+	// coverageGap is the largest gap between readings that still counts as glucose staying high
+	coverageGap = 15 * time.Minute
+	// Users with at least cgmReadings in the hour before their last reading wear a sensor;
+	// only they get missed reading alerts
+	cgmReadings = 3
+	// staleLookback is how long after the last reading missed readings are still alerted
+	staleLookback = 24 * time.Hour
+)
+
+// ErrNotFound is returned when acknowledging or snoozing an alert that doesn't exist
+var ErrNotFound = errors.New("alert not found")
+
+// NotifyFunc delivers an alert to the user
+type NotifyFunc func(ctx context.Context, alert models.Alert) error
+
+// Service evaluates the user's alert rules on new readings, stores the alerts raised and delivers them
+type Service struct {
+	storage    storage.Storage
+	prediction *prediction.Service
+	notify     NotifyFunc
+}
+
+// NewService creates a new alert service. Alerts are only logged until SetNotifyFunc sets a delivery.
+func NewService(storage storage.Storage) *Service {
+	return &Service{
+		storage:    storage,
+		prediction: prediction.NewService(storage),
+		notify:     logAlert,
+	}
+}
+
+// SetNotifyFunc sets how alerts are delivered
+func (s *Service) SetNotifyFunc(notify NotifyFunc) {
+	s.notify = notify
+}
+
+// match is a rule that matched the user's glucose
+type match struct {
+	id        string // Set when every replica raises the alert, only the first one to store it delivers it
+	alertType string
+	glucose   float64
+	minutes   int // Minutes to the predicted low, or how long glucose has been high
+	rate      float64
+}
+
+// Evaluate checks the user's rules against their readings at the given time and returns the
+// alerts raised. Rules that are snoozed don't raise alerts; alerts raised during quiet hours are
+// stored silenced unless they are urgent.
+func (s *Service) Evaluate(ctx context.Context, userID string, settings *models.Settings, at time.Time) ([]models.Alert, error) {
+	config := settings.AlertSettings()
+
+	window := time.Hour
+	if high := time.Duration(config.PersistentHigh.Minutes) * time.Minute; high > window {
+		window = high
+	}
+	readings, err := s.storage.GetRecentBloodSugarReadings(userID, 0, at.Add(-window-coverageGap))
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch readings: %w", err)
+	}
+
+	latest, ok := glucose.Latest(readings, at)
+	if !ok {
+		return nil, nil
+	}
+	value := latest.Value
+
+	var matches []match
+	switch {
+	case config.UrgentLow.Enabled && value < config.UrgentLow.Threshold:
+		matches = append(matches, match{alertType: models.AlertUrgentLow, glucose: value})
+	case config.Low.Enabled && value < config.Low.Threshold:
+		matches = append(matches, match{alertType: models.AlertLow, glucose: value})
+	}
+
+	if config.High.Enabled && value > config.High.Threshold {
+		matches = append(matches, match{alertType: models.AlertHigh, glucose: value})
+	}
+
+	if rate, ok := glucose.RateOfChange(readings, at); ok {
+		if config.RapidRise.Enabled && rate >= config.RapidRise.Threshold {
+			matches = append(matches, match{alertType: models.AlertRapidRise, glucose: value, rate: rate})
+		}
+		if config.RapidFall.Enabled && rate <= -config.RapidFall.Threshold {
+			matches = append(matches, match{alertType: models.AlertRapidFall, glucose: value, rate: rate})
+		}
+	}
+
+	// A low that has already arrived is alerted by the low rules
+	if config.PredictedLow.Enabled && value >= config.PredictedLow.Threshold {
+		point, err := s.predictedLow(ctx, userID, config.PredictedLow)
+		if err != nil {
+			return nil, err
+		}
+		if point != nil {
+			matches = append(matches, match{alertType: models.AlertPredictedLow, glucose: point.Glucose, minutes: point.Minutes})
+		}
+	}
+
+	if config.PersistentHigh.Enabled && highSince(readings, config.PersistentHigh, at) {
+		matches = append(matches, match{alertType: models.AlertPersistentHigh, glucose: value, minutes: config.PersistentHigh.Minutes})
+	}
+
+	return s.raise(ctx, userID, settings, matches, at)
+}
+
+// CheckStale raises missed reading alerts for sensor users whose readings stopped arriving
+func (s *Service) CheckStale(ctx context.Context) error {
+	userIDs, err := s.storage.GetUserIDs(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to fetch users: %w", err)
+	}
+
+	now := time.Now()
+	for _, userID := range userIDs {
+		user, err := s.storage.GetUser(userID)
+		if err != nil {
+			return fmt.Errorf("failed to fetch user %s: %w", userID, err)
+		}
+		if user == nil {
+			continue
+		}
+
+		config := user.Settings.AlertSettings()
+		if !config.StaleData.Enabled || config.StaleData.Minutes <= 0 {
+			continue
+		}
+
+		readings, err := s.storage.GetRecentBloodSugarReadings(userID, 0, now.Add(-staleLookback))
+		if err != nil {
+			return fmt.Errorf("failed to fetch readings of user %s: %w", userID, err)
+		}
+
+		last, ok := lastSensorReading(readings)
+		if !ok {
+			continue
+		}
+		gap := now.Sub(last)
+		if gap < time.Duration(config.StaleData.Minutes)*time.Minute {
+			continue
+		}
+
+		stale := match{
+			id:        staleAlertID(userID, last, gap, config.StaleData),
+			alertType: models.AlertStaleData,
+			minutes:   int(gap.Minutes()),
+		}
+		if _, err := s.raise(ctx, userID, &user.Settings, []match{stale}, now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Run checks for missed readings every interval until ctx is cancelled
+func (s *Service) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.CheckStale(ctx); err != nil {
+			log.Printf("Alerts: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Acknowledge marks an alert as seen. Its rule stays quiet for the rule's snooze time.
+func (s *Service) Acknowledge(ctx context.Context, userID, id string) (*models.Alert, error) {
+	alert, err := s.userAlert(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	var snooze time.Duration
+	user, err := s.storage.GetUser(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch user: %w", err)
+	}
+	if user != nil {
+		config := user.Settings.AlertSettings()
+		snooze = time.Duration(config.Rule(alert.Type).SnoozeMinutes) * time.Minute
+	}
+
+	return s.acknowledge(ctx, alert, snooze)
+}
+
+// Snooze acknowledges an alert and keeps its rule quiet for the given time, at most MaxSnooze
+func (s *Service) Snooze(ctx context.Context, userID, id string, snooze time.Duration) (*models.Alert, error) {
+	alert, err := s.userAlert(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if snooze > MaxSnooze {
+		snooze = MaxSnooze
+	}
+	return s.acknowledge(ctx, alert, snooze)
+}
+
+// raise stores and delivers an alert for each match whose rule isn't snoozed
+func (s *Service) raise(ctx context.Context, userID string, settings *models.Settings, matches []match, at time.Time) ([]models.Alert, error) {
+	raised := []models.Alert{}
+	if len(matches) == 0 {
+		return raised, nil
+	}
+
+	// Snoozes never last longer than MaxSnooze, older alerts can't hold a rule back
+	previous, err := s.storage.GetAlerts(ctx, userID, at.Add(-MaxSnooze))
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch alerts: %w", err)
+	}
+
+	config := settings.AlertSettings()
+	for _, m := range matches {
+		if snoozed(previous, m.alertType, config.Rule(m.alertType), at) {
+			continue
+		}
+
+		id := m.id
+		if id == "" {
+			id = uuid.New().String()
+		}
+		alert := models.Alert{
+			ID:        id,
+			UserID:    userID,
+			Type:      m.alertType,
+			Severity:  severity(m.alertType),
+			CreatedAt: at,
+			Glucose:   round(m.glucose, 1),
+			Message:   alertMessage(settings.Locale, m),
+		}
+		alert.Silenced = alert.Severity != models.AlertSeverityUrgent && config.QuietHours.Contains(at)
+
+		created, err := s.storage.CreateAlert(ctx, &alert)
+		if err != nil {
+			return nil, fmt.Errorf("failed to save alert: %w", err)
+		}
+		if !created {
+			// Another replica raised it
+			continue
+		}
+		raised = append(raised, alert)
+
+		if alert.Silenced {
+			continue
+		}
+		if err := s.notify(ctx, alert); err != nil {
+			log.Printf("Alerts: failed to deliver alert %s: %v", alert.ID, err)
+		}
+	}
+	return raised, nil
+}
+
+// predictedLow returns the first forecast point below the rule's threshold within its look-ahead,
+// or nil if there is none
+func (s *Service) predictedLow(ctx context.Context, userID string, rule models.AlertRule) (*models.ForecastPoint, error) {
+	forecast, err := s.prediction.Forecast(ctx, userID, prediction.MinHorizon)
+	if errors.Is(err, prediction.ErrNoGlucose) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to predict glucose: %w", err)
+	}
+
+	for _, point := range forecast.Points {
+		if point.Minutes > rule.Minutes {
+			break
+		}
+		if point.Glucose < rule.Threshold {
+			return &point, nil
+		}
+	}
+	return nil, nil
+}
+
+// userAlert returns one of the user's alerts, ErrNotFound if it belongs to someone else
+func (s *Service) userAlert(ctx context.Context, userID, id string) (*models.Alert, error) {
+	alert, err := s.storage.GetAlert(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch alert: %w", err)
+	}
+	if alert == nil || alert.UserID != userID {
+		return nil, ErrNotFound
+	}
+	return alert, nil
+}
+
+func (s *Service) acknowledge(ctx context.Context, alert *models.Alert, snooze time.Duration) (*models.Alert, error) {
+	now := time.Now()
+	if alert.AcknowledgedAt == nil {
+		alert.AcknowledgedAt = &now
+	}
+	until := now.Add(snooze)
+	alert.SnoozedUntil = &until
+
+	if err := s.storage.SaveAlert(ctx, alert); err != nil {
+		return nil, fmt.Errorf("failed to save alert: %w", err)
+	}
+	return alert, nil
+}
+
+// snoozed reports whether the newest alert of a type keeps its rule quiet at the given time
+func snoozed(previous []models.Alert, alertType string, rule models.AlertRule, at time.Time) bool {
+	for _, alert := range previous {
+		if alert.Type != alertType {
+			continue
+		}
+		if alert.SnoozedUntil != nil && at.Before(*alert.SnoozedUntil) {
+			return true
+		}
+		return at.Before(alert.CreatedAt.Add(time.Duration(rule.SnoozeMinutes) * time.Minute))
+	}
+	return false
+}
+
+// staleAlertID identifies the missed reading alert for a gap. Every replica checking the same gap
+// in the same snooze period gets the same ID, so the alert is stored and delivered once.
+func staleAlertID(userID string, last time.Time, gap time.Duration, rule models.AlertRule) string {
+	period := time.Duration(rule.SnoozeMinutes) * time.Minute
+	if period < time.Minute {
+		period = time.Minute
+	}
+	repeat := (gap - time.Duration(rule.Minutes)*time.Minute) / period
+	name := fmt.Sprintf("diabetes-assistant/alert/%s/%s/%s/%d", models.AlertStaleData, userID, last.UTC().Format(time.RFC3339Nano), repeat)
+	return uuid.NewSHA1(uuid.NameSpaceURL, []byte(name)).String()
+}
+
+// highSince reports whether every reading of the rule's period is above its threshold, with
+// readings from the start of the period on
+func highSince(readings []models.BloodSugarReading, rule models.AlertRule, at time.Time) bool {
+	if rule.Minutes <= 0 {
+		return false
+	}
+	start := at.Add(-time.Duration(rule.Minutes) * time.Minute)
+
+	covered := false
+	for _, reading := range readings {
+		if reading.Timestamp.After(at) || reading.Timestamp.Before(start.Add(-coverageGap)) {
+			continue
+		}
+		if reading.Timestamp.After(start) && reading.Value <= rule.Threshold {
+			return false
+		}
+		if !reading.Timestamp.After(start.Add(coverageGap)) {
+			covered = true
+		}
+	}
+	return covered
+}
+
+// lastSensorReading returns the time of the last reading when readings arrived continuously
+// in the hour before it, as they do from a sensor
+func lastSensorReading(readings []models.BloodSugarReading) (time.Time, bool) {
+	var last time.Time
+	for _, reading := range readings {
+		if reading.Timestamp.After(last) {
+			last = reading.Timestamp
+		}
+	}
+
+	count := 0
+	for _, reading := range readings {
+		if !reading.Timestamp.Before(last.Add(-time.Hour)) {
+			count++
+		}
+	}
+	return last, count >= cgmReadings
+}
+
+// severity returns how urgent an alert type is
+func severity(alertType string) string {
+	switch alertType {
+	case models.AlertUrgentLow:
+		return models.AlertSeverityUrgent
+	case models.AlertRapidRise, models.AlertStaleData:
+		return models.AlertSeverityInfo
+	}
+	return models.AlertSeverityWarning
+}
+
+// logAlert is the default alert delivery
+func logAlert(ctx context.Context, alert models.Alert) error {
+	log.Printf("Alerts: user %s: %s", alert.UserID, alert.Message)
+	return nil
+}
+
+// alertMessage describes a match in the user's language
+func alertMessage(locale string, m match) string {
+	if locale == "en" {
+		switch m.alertType {
+		case models.AlertUrgentLow:
+			return fmt.Sprintf("Urgent low: %.1f mmol/L. Eat fast carbs now.", m.glucose)
+		case models.AlertLow:
+			return fmt.Sprintf("Low: %.1f mmol/L.", m.glucose)
+		case models.AlertHigh:
+			return fmt.Sprintf("High: %.1f mmol/L.", m.glucose)
+		case models.AlertRapidRise:
+			return fmt.Sprintf("Rising fast: %.1f mmol/L, +%.2f mmol/L per minute.", m.glucose, m.rate)
+		case models.AlertRapidFall:
+			return fmt.Sprintf("Falling fast: %.1f mmol/L, %.2f mmol/L per minute.", m.glucose, m.rate)
+		case models.AlertPredictedLow:
+			return fmt.Sprintf("Low expected: %.1f mmol/L in %d minutes.", m.glucose, m.minutes)
+		case models.AlertStaleData:
+			return fmt.Sprintf("No glucose readings for %d minutes. Check your sensor.", m.minutes)
+		case models.AlertPersistentHigh:
+			return fmt.Sprintf("High for over %s: %.1f mmol/L.", duration(m.minutes, locale), m.glucose)
+		}
+	}
+
+	switch m.alertType {
+	case models.AlertUrgentLow:
+		return fmt.Sprintf("Очень низкий сахар: %.1f ммоль/л. Срочно съешьте быстрые углеводы.", m.glucose)
+	case models.AlertLow:
+		return fmt.Sprintf("Низкий сахар: %.1f ммоль/л.", m.glucose)
+	case models.AlertHigh:
+		return fmt.Sprintf("Высокий сахар: %.1f ммоль/л.", m.glucose)
+	case models.AlertRapidRise:
+		return fmt.Sprintf("Сахар быстро растёт: %.1f ммоль/л, +%.2f ммоль/л в минуту.", m.glucose, m.rate)
+	case models.AlertRapidFall:
+		return fmt.Sprintf("Сахар быстро падает: %.1f ммоль/л, %.2f ммоль/л в минуту.", m.glucose, m.rate)
+	case models.AlertPredictedLow:
+		return fmt.Sprintf("Ожидается низкий сахар: %.1f ммоль/л через %d мин.", m.glucose, m.minutes)
+	case models.AlertStaleData:
+		return fmt.Sprintf("Нет данных о сахаре %d мин. Проверьте сенсор.", m.minutes)
+	case models.AlertPersistentHigh:
+		return fmt.Sprintf("Сахар высокий дольше %s: %.1f ммоль/л.", duration(m.minutes, locale), m.glucose)
+	}
+	return ""
+}
+
+// duration formats minutes as hours when they are whole hours
+func duration(minutes int, locale string) string {
+	if minutes%60 == 0 {
+		if locale == "en" {
+			return fmt.Sprintf("%d h", minutes/60)
+		}
+		return fmt.Sprintf("%d ч", minutes/60)
+	}
+	if locale == "en" {
+		return fmt.Sprintf("%d min", minutes)
+	}
+	return fmt.Sprintf("%d мин", minutes)
+}
+
+func round(x float64, decimals int) float64 {
+	pow := math.Pow(10, float64(decimals))
+	return math.Round(x*pow) / pow
+}
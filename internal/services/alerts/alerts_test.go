@@ -0,0 +1,64 @@
+package alerts
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/yourusername/diabetes-assistant/internal/models"
+	"github.com/yourusername/diabetes-assistant/internal/storage"
+)
+
+func TestCheckStaleAlertsOnceAcrossReplicas(t *testing.T) {
+	store := storage.NewInMemoryStorage()
+	ctx := context.Background()
+	if err := store.CreateUser(&models.User{UserID: "user1", Settings: *models.CreateDefaultSettings("user1")}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Sensor readings every 5 minutes that stopped 30 minutes ago
+	last := time.Now().Add(-30 * time.Minute)
+	var readings []models.BloodSugarReading
+	for minutes := 60; minutes >= 0; minutes -= 5 {
+		readings = append(readings, models.BloodSugarReading{Value: 6, Timestamp: last.Add(-time.Duration(minutes) * time.Minute), Source: "sensor"})
+	}
+	if err := store.AddBloodSugarReadings(ctx, "user1", readings); err != nil {
+		t.Fatal(err)
+	}
+
+	// Both replicas read the alerts before either of them stored one
+	var delivered int
+	for i := 0; i < 2; i++ {
+		service := NewService(noAlertsYet{store})
+		service.SetNotifyFunc(func(ctx context.Context, alert models.Alert) error {
+			delivered++
+			return nil
+		})
+		if err := service.CheckStale(ctx); err != nil {
+			t.Fatalf("CheckStale: %v", err)
+		}
+	}
+
+	alerts, err := store.GetAlerts(ctx, "user1", last.Add(-time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(alerts) != 1 || alerts[0].Type != models.AlertStaleData {
+		t.Fatalf("alerts = %+v, want one missed reading alert", alerts)
+	}
+	if alerts[0].Silenced {
+		return
+	}
+	if delivered != 1 {
+		t.Errorf("delivered %d times, want once", delivered)
+	}
+}
+
+// noAlertsYet is the storage as seen by a replica that checks while another one raises an alert
+type noAlertsYet struct {
+	storage.Storage
+}
+
+func (noAlertsYet) GetAlerts(ctx context.Context, userID string, startDate time.Time) ([]models.Alert, error) {
+	return nil, nil
+}
@@ -0,0 +1,278 @@
+package analysis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/yourusername/diabetes-assistant/internal/models"
+	"github.com/yourusername/diabetes-assistant/internal/services/ai"
+	"github.com/yourusername/diabetes-assistant/internal/services/insulin"
+	"github.com/yourusername/diabetes-assistant/internal/services/prediction"
+	"github.com/yourusername/diabetes-assistant/internal/services/products"
+	"github.com/yourusername/diabetes-assistant/internal/services/uploads"
+	"github.com/yourusername/diabetes-assistant/internal/services/usage"
+	"github.com/yourusername/diabetes-assistant/internal/storage"
+)
+
+// Errors caused by the request itself, retrying won't help
+var (
+	ErrNothingToAnalyze = errors.New("food photo, description or barcode is required for analysis")
+	ErrProductNotFound  = errors.New("product not found in the product database")
+	ErrWeightRequired   = errors.New("food weight is required: the product has no serving size")
+	ErrNoCarbRatio      = errors.New("no carb ratio is set in the user's settings")
+)
+
+// MealFunc is called with every meal recorded, e.g. to schedule a glucose check after it
+type MealFunc func(ctx context.Context, meal models.MealRecord) error
+
+// Service turns a photo, a description or a barcode into a carb estimate and a dose suggestion,
+// and records the meal
+type Service struct {
+	storage    storage.Storage
+	ai         *ai.Service
+	uploads    *uploads.Store
+	usage      *usage.Service
+	prediction *prediction.Service
+	mealLogged MealFunc
+}
+
+// NewService creates a new analysis service
+func NewService(storage storage.Storage, aiService *ai.Service, uploadStore *uploads.Store, usageService *usage.Service) *Service {
+	return &Service{
+		storage:    storage,
+		ai:         aiService,
+		uploads:    uploadStore,
+		usage:      usageService,
+		prediction: prediction.NewService(storage),
+	}
+}
+
+// SetMealFunc sets what happens after a meal is recorded
+func (s *Service) SetMealFunc(mealLogged MealFunc) {
+	s.mealLogged = mealLogged
+}
+
+// Analyze runs a food analysis and saves the meal record
+func (s *Service) Analyze(ctx context.Context, req *models.AnalysisRequest) (*models.MealAnalysis, error) {
+	// Get user settings for insulin calculations
+	user, err := s.storage.GetUser(req.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch user: %w", err)
+	}
+
+	// Use default settings if user not found
+	var userSettings *models.Settings
+	if user != nil {
+		// Make a copy of the settings
+		settingsCopy := user.Settings
+		userSettings = &settingsCopy
+	} else {
+		// Create default settings
+		userSettings = models.CreateDefaultSettings(req.UserID)
+	}
+
+	var photo *ai.Image
+	if req.PhotoID != "" {
+		data, mimeType, err := s.uploads.Open(ctx, req.PhotoID, false)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load photo: %w", err)
+		}
+		photo = &ai.Image{Data: data, MIMEType: mimeType}
+	}
+
+	foodWeight := req.Weight
+	var mealSource string
+
+	// A barcode found in the product database is more accurate than any photo
+	var foodAnalysisResult *ai.FoodAnalysisResult
+	if req.Barcode != "" {
+		product, err := products.Lookup(ctx, s.storage, req.Barcode)
+		if err != nil {
+			return nil, fmt.Errorf("failed to look up barcode: %w", err)
+		}
+
+		if product != nil {
+			carbs, weight, err := products.CarbsForWeight(product, foodWeight)
+			if err != nil {
+				return nil, ErrWeightRequired
+			}
+
+			foodWeight = weight
+			mealSource = models.MealSourceBarcode
+			foodAnalysisResult = &ai.FoodAnalysisResult{
+				Name:         product.Name,
+				Carbs:        carbs,
+				Confidence:   ai.ConfidenceHigh,
+				Reasoning:    products.Describe(product, weight, carbs, userSettings.Locale),
+				CarbsPer100g: product.CarbsPer100g,
+			}
+		} else if photo == nil && req.Description == "" {
+			return nil, ErrProductNotFound
+		}
+	}
+
+	// Analyze food using AI service with the prompt template in the user's language
+	if foodAnalysisResult == nil && (photo != nil || req.Description != "") {
+		// Barcode lookups are free, only AI analyses count against the daily quota
+		release, err := s.usage.ReserveQuota(ctx, req.UserID)
+		if err != nil {
+			return nil, err
+		}
+
+		vars := ai.PromptVariables{
+			Weight:      foodWeight,
+			UserNotes:   req.UserNotes,
+			MealContext: req.MealContext,
+			Description: req.Description,
+		}
+
+		// Analyze the photo and/or description with optional weight, notes and meal context
+		if req.Label {
+			mealSource = models.MealSourceLabel
+			foodAnalysisResult, err = s.ai.AnalyzeLabel(ctx, req.UserID, photo, userSettings.Locale, vars)
+		} else {
+			mealSource = models.MealSourcePhoto
+			if photo == nil {
+				mealSource = models.MealSourceText
+			}
+			foodAnalysisResult, err = s.ai.AnalyzeFood(ctx, req.UserID, photo, userSettings.Locale, vars)
+		}
+		// Failed calls and cached results don't use up the quota
+		if err != nil || foodAnalysisResult.Cached {
+			release()
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error analyzing food: %w", err)
+		}
+	}
+
+	if foodAnalysisResult == nil {
+		return nil, ErrNothingToAnalyze
+	}
+
+	// Calculate insulin dose based on carbs and the ratio in effect now
+	now := time.Now()
+	carbRatio := userSettings.CarbRatioAt(now)
+	if carbRatio <= 0 {
+		return nil, ErrNoCarbRatio
+	}
+	mealInsulin := foodAnalysisResult.Carbs / carbRatio
+
+	// Apply the time-based coefficient in effect now
+	periodCoefficient := userSettings.InsulinCoefficientAt(now)
+	mealInsulin *= periodCoefficient
+
+	// Add correction insulin if needed
+	lastReading, _ := s.storage.GetRecentBloodSugarReadings(req.UserID, 1, now.AddDate(0, 0, -7))
+
+	correctionInsulin := 0.0
+	sensitivity := userSettings.SensitivityAt(now)
+	if len(lastReading) > 0 && userSettings.TargetMin > 0 && sensitivity > 0 {
+		bloodSugarDiff := lastReading[0].Value - userSettings.TargetMin
+		if bloodSugarDiff > 0 {
+			correctionInsulin = bloodSugarDiff / sensitivity
+		}
+	}
+
+	// Calculate total insulin
+	totalInsulin := mealInsulin + correctionInsulin
+
+	// Fat and protein raise blood sugar hours later, suggest covering them with an extended bolus
+	extendedBolus := insulin.CalculateExtendedBolus(totalInsulin, foodAnalysisResult.Fat, foodAnalysisResult.Protein,
+		userSettings.FPUFactor, carbRatio, periodCoefficient)
+
+	// When to inject depends on the current glucose trend and how fast the meal is absorbed
+	recentReadings, _ := s.storage.GetRecentBloodSugarReadings(req.UserID, 0, now.Add(-time.Hour))
+	// The AI classifies how fast the meal is absorbed from food analysis prompt v4 on
+	mealProfile := foodAnalysisResult.Absorption
+	if mealProfile == "" {
+		mealProfile = insulin.ClassifyMeal(foodAnalysisResult.Carbs, foodAnalysisResult.Fat, foodAnalysisResult.Protein)
+	}
+	preBolus := insulin.RecommendPreBolus(recentReadings, now, mealProfile, userSettings.TargetMin, userSettings.TargetMax, userSettings.Locale)
+
+	// The dose ignores insulin and carbs on board, so check the predicted outcome before suggesting it.
+	// Users without a record have no readings to predict from.
+	var doseCheck *models.DoseCheck
+	if user != nil {
+		meal := models.MealRecord{
+			Carbs:      foodAnalysisResult.Carbs,
+			Fat:        foodAnalysisResult.Fat,
+			Protein:    foodAnalysisResult.Protein,
+			Absorption: mealProfile,
+		}
+		doseCheck, err = s.prediction.CheckDose(ctx, req.UserID, userSettings, meal, totalInsulin)
+		if err != nil && !errors.Is(err, prediction.ErrNoGlucose) {
+			log.Printf("Analysis: error predicting glucose: %v", err)
+		}
+	}
+
+	// Record the meal together with the prompt version used, so prompt changes can be compared against outcomes
+	meal := &models.MealRecord{
+		ID:                uuid.New().String(),
+		UserID:            req.UserID,
+		Timestamp:         time.Now(),
+		Name:              foodAnalysisResult.Name,
+		Carbs:             foodAnalysisResult.Carbs,
+		Weight:            foodWeight,
+		Confidence:        foodAnalysisResult.Confidence,
+		Reasoning:         foodAnalysisResult.Reasoning,
+		UserNotes:         req.UserNotes,
+		Description:       req.Description,
+		Source:            mealSource,
+		Barcode:           req.Barcode,
+		PhotoID:           req.PhotoID,
+		Provider:          s.ai.GetCurrentProvider(),
+		PromptVersion:     foodAnalysisResult.PromptVersion,
+		PromptLocale:      foodAnalysisResult.PromptLocale,
+		MealInsulin:       mealInsulin,
+		CorrectionInsulin: correctionInsulin,
+		TotalInsulin:      totalInsulin,
+		Fat:               foodAnalysisResult.Fat,
+		Protein:           foodAnalysisResult.Protein,
+		ExtendedBolus:     extendedBolus,
+		Absorption:        mealProfile,
+	}
+	if err := s.storage.SaveMealRecord(ctx, meal); err != nil {
+		// The analysis is still useful to the user, so only log the failure
+		log.Printf("Analysis: error saving meal record: %v", err)
+	} else if s.mealLogged != nil {
+		if err := s.mealLogged(ctx, *meal); err != nil {
+			log.Printf("Analysis: error handling logged meal: %v", err)
+		}
+	}
+
+	return &models.MealAnalysis{
+		MealID:        meal.ID,
+		DetectedFood:  foodAnalysisResult.Name,
+		Carbs:         foodAnalysisResult.Carbs,
+		InsulinDose:   totalInsulin,
+		Reasoning:     foodAnalysisResult.Reasoning,
+		PhotoProvided: photo != nil,
+		Cached:        foodAnalysisResult.Cached,
+		Analysis: models.MealAnalysisDetails{
+			Dish:              foodAnalysisResult.Name,
+			Carbs:             foodAnalysisResult.Carbs,
+			Confidence:        foodAnalysisResult.Confidence,
+			Reasoning:         foodAnalysisResult.Reasoning,
+			MealInsulin:       mealInsulin,
+			CorrectionInsulin: correctionInsulin,
+			TotalInsulin:      totalInsulin,
+			PeriodCoefficient: periodCoefficient,
+			PromptVersion:     foodAnalysisResult.PromptVersion,
+			Source:            mealSource,
+			CarbsPer100g:      foodAnalysisResult.CarbsPer100g,
+			CarbsPerServing:   foodAnalysisResult.CarbsPerServing,
+			Weight:            foodWeight,
+			Fat:               foodAnalysisResult.Fat,
+			Protein:           foodAnalysisResult.Protein,
+			Absorption:        mealProfile,
+			ExtendedBolus:     extendedBolus,
+			PreBolus:          preBolus,
+			DoseCheck:         doseCheck,
+		},
+	}, nil
+}
@@ -0,0 +1,150 @@
+package chat
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/yourusername/diabetes-assistant/internal/models"
+	"github.com/yourusername/diabetes-assistant/internal/services/ai"
+	"github.com/yourusername/diabetes-assistant/internal/storage"
+)
+
+// PromptChatSystem is the template name of the assistant's system prompt
+const PromptChatSystem = "chat_system"
+
+const (
+	historyLimit  = 20             // Previous messages sent to the provider with each request
+	contextWindow = 24 * time.Hour // How far back readings, meals and doses are summarized
+	maxMessageLen = 2000           // Longest accepted user message in bytes
+)
+
+// Service holds multi-turn conversations between users and the AI provider
+type Service struct {
+	storage storage.Storage
+	ai      *ai.Service
+}
+
+// NewService creates a new chat service
+func NewService(storage storage.Storage, aiService *ai.Service) *Service {
+	return &Service{
+		storage: storage,
+		ai:      aiService,
+	}
+}
+
+// SendMessage sends a user message with the recent conversation and a summary of the user's data
+// to the AI provider. Checked pieces of the reply are passed to onDelta as they arrive, and both
+// messages are stored in the history once the reply is complete.
+func (s *Service) SendMessage(ctx context.Context, userID, text string, onDelta func(string) error) (*models.ChatMessage, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return nil, errors.New("message is required")
+	}
+	if len(text) > maxMessageLen {
+		return nil, fmt.Errorf("message is too long (max %d characters)", maxMessageLen)
+	}
+
+	settings, contextData, err := s.loadContext(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	systemPrompt, err := s.ai.RenderPrompt(PromptChatSystem, settings.Locale, struct{ Summary string }{BuildSummary(contextData)})
+	if err != nil {
+		return nil, err
+	}
+
+	history, err := s.storage.GetChatHistory(ctx, userID, historyLimit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load chat history: %w", err)
+	}
+
+	messages := []ai.Message{{Role: ai.RoleSystem, Content: systemPrompt.Text}}
+	for _, msg := range history {
+		messages = append(messages, ai.Message{Role: msg.Role, Content: msg.Content})
+	}
+	messages = append(messages, ai.Message{Role: ai.RoleUser, Content: text})
+
+	// Every piece of the reply goes through the dose guard before reaching the user
+	guard := NewDoseGuard(systemPrompt.Locale, onDelta)
+	if _, err := s.ai.ChatCompletionStream(ctx, userID, messages, guard.Write); err != nil {
+		return nil, err
+	}
+	if err := guard.Close(); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	userMessage := &models.ChatMessage{
+		ID:        uuid.New().String(),
+		UserID:    userID,
+		Role:      ai.RoleUser,
+		Content:   text,
+		Timestamp: now,
+	}
+	reply := &models.ChatMessage{
+		ID:        uuid.New().String(),
+		UserID:    userID,
+		Role:      ai.RoleAssistant,
+		Content:   strings.TrimSpace(guard.Text()),
+		Timestamp: now.Add(time.Millisecond), // Keep the reply ordered after the question
+		Guarded:   guard.Guarded(),
+	}
+
+	if err := s.storage.SaveChatMessage(ctx, userMessage); err != nil {
+		return nil, fmt.Errorf("failed to save chat message: %w", err)
+	}
+	if err := s.storage.SaveChatMessage(ctx, reply); err != nil {
+		return nil, fmt.Errorf("failed to save chat message: %w", err)
+	}
+
+	return reply, nil
+}
+
+// GetHistory returns the user's conversation, oldest first
+func (s *Service) GetHistory(ctx context.Context, userID string) ([]models.ChatMessage, error) {
+	return s.storage.GetChatHistory(ctx, userID, 0)
+}
+
+// ClearHistory starts a new conversation for the user
+func (s *Service) ClearHistory(ctx context.Context, userID string) error {
+	return s.storage.ClearChatHistory(ctx, userID)
+}
+
+// loadContext loads the user's settings and recent data
+func (s *Service) loadContext(ctx context.Context, userID string) (*models.Settings, ContextData, error) {
+	now := time.Now()
+	since := now.Add(-contextWindow)
+
+	user, err := s.storage.GetUser(userID)
+	if err != nil {
+		return nil, ContextData{}, fmt.Errorf("failed to fetch user: %w", err)
+	}
+
+	data := ContextData{Now: now}
+	if user == nil {
+		data.Settings = *models.CreateDefaultSettings(userID)
+	} else {
+		data.Settings = user.Settings
+		data.Readings, err = s.storage.GetRecentBloodSugarReadings(userID, 0, since)
+		if err != nil {
+			return nil, ContextData{}, fmt.Errorf("failed to fetch readings: %w", err)
+		}
+	}
+
+	data.Meals, err = s.storage.GetMealRecords(ctx, userID, since)
+	if err != nil {
+		return nil, ContextData{}, fmt.Errorf("failed to fetch meals: %w", err)
+	}
+
+	data.Doses, err = s.storage.GetInsulinDoses(ctx, userID, since)
+	if err != nil {
+		return nil, ContextData{}, fmt.Errorf("failed to fetch doses: %w", err)
+	}
+
+	return &data.Settings, data, nil
+}
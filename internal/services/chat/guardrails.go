@@ -0,0 +1,159 @@
+package chat
+
+import (
+	"regexp"
+	"strings"
+)
+
+// doseRe matches insulin amounts such as "4 units", "2,5 ЕД", "1 единицу", "3-4 U" or "6 of insulin".
+// Russian unit words are inflected ("единицы", "юнитов"), so their stems match any ending; "ед"
+// and the English abbreviations must end the word so "еда" or "until" don't match.
+var doseRe = regexp.MustCompile(`(?i)\d+(?:[.,]\d+)?(?:\s*(?:-|–|to|до)\s*\d+(?:[.,]\d+)?)?\s*(?:(?:единиц|юнит)\p{L}*|(?:ед|units?|iu|u)(?:[^\p{L}]|$)|(?:of\s+)?(?:insulin|инсулин))`)
+
+// guardNotices replace sentences that contain dose numbers
+var guardNotices = map[string]string{
+	"ru": "[Конкретную дозу инсулина рассчитывает только калькулятор болюса в приложении.]",
+	"en": "[Only the bolus calculator in the app can suggest a specific insulin dose.]",
+}
+
+// DoseGuard removes insulin dose numbers from assistant replies so the chat can never
+// bypass the bolus calculator. Streamed text is released sentence by sentence, and any
+// sentence that mentions a dose is replaced with a notice before it reaches the user.
+type DoseGuard struct {
+	notice  string
+	emit    func(string) error
+	pending strings.Builder
+	output  strings.Builder
+	guarded bool
+}
+
+// NewDoseGuard creates a guard that passes checked text to emit
+func NewDoseGuard(locale string, emit func(string) error) *DoseGuard {
+	notice, ok := guardNotices[locale]
+	if !ok {
+		notice = guardNotices["ru"]
+	}
+
+	return &DoseGuard{
+		notice: notice,
+		emit:   emit,
+	}
+}
+
+// Write buffers a piece of the reply and emits every sentence completed so far
+func (g *DoseGuard) Write(delta string) error {
+	g.pending.WriteString(delta)
+
+	text := g.pending.String()
+	end := lastSentenceEnd(text)
+	if end == 0 {
+		return nil
+	}
+
+	g.pending.Reset()
+	g.pending.WriteString(text[end:])
+	return g.release(text[:end])
+}
+
+// Close emits whatever is left in the buffer
+func (g *DoseGuard) Close() error {
+	text := g.pending.String()
+	g.pending.Reset()
+	if text == "" {
+		return nil
+	}
+	return g.release(text)
+}
+
+// Text returns the checked reply emitted so far
+func (g *DoseGuard) Text() string {
+	return g.output.String()
+}
+
+// Guarded reports whether any dose numbers were removed
+func (g *DoseGuard) Guarded() bool {
+	return g.guarded
+}
+
+// release checks complete sentences and emits them
+func (g *DoseGuard) release(text string) error {
+	var checked strings.Builder
+	for _, sentence := range splitSentences(text) {
+		if !doseRe.MatchString(sentence) {
+			checked.WriteString(sentence)
+			continue
+		}
+
+		// Show the notice once per reply, drop further offending sentences
+		if !g.guarded {
+			checked.WriteString(g.notice)
+			checked.WriteString(trailingSpace(sentence))
+		}
+		g.guarded = true
+	}
+
+	if checked.Len() == 0 {
+		return nil
+	}
+
+	g.output.WriteString(checked.String())
+	return g.emit(checked.String())
+}
+
+// GuardText removes dose numbers from a complete reply
+func GuardText(locale, text string) (string, bool) {
+	guard := NewDoseGuard(locale, func(string) error { return nil })
+	guard.Write(text)
+	guard.Close()
+	return guard.Text(), guard.Guarded()
+}
+
+// lastSentenceEnd returns the index just past the last complete sentence in text, or 0.
+// A sentence ends with a newline, or with . ! ? followed by whitespace, so decimals like 2.5 don't split.
+func lastSentenceEnd(text string) int {
+	for i := len(text) - 1; i >= 0; i-- {
+		switch text[i] {
+		case '\n':
+			return i + 1
+		case ' ', '\t':
+			if i > 0 && strings.ContainsRune(".!?", rune(text[i-1])) {
+				return i + 1
+			}
+		}
+	}
+	return 0
+}
+
+// splitSentences splits text into sentences, keeping the separators attached
+func splitSentences(text string) []string {
+	var sentences []string
+	for text != "" {
+		end := firstSentenceEnd(text)
+		if end == 0 {
+			end = len(text)
+		}
+		sentences = append(sentences, text[:end])
+		text = text[end:]
+	}
+	return sentences
+}
+
+// firstSentenceEnd returns the index just past the first complete sentence in text, or 0
+func firstSentenceEnd(text string) int {
+	for i := 0; i < len(text); i++ {
+		switch text[i] {
+		case '\n':
+			return i + 1
+		case '.', '!', '?':
+			if i+1 < len(text) && (text[i+1] == ' ' || text[i+1] == '\t') {
+				return i + 2
+			}
+		}
+	}
+	return 0
+}
+
+// trailingSpace returns the whitespace at the end of a sentence
+func trailingSpace(sentence string) string {
+	return sentence[len(strings.TrimRight(sentence, " \t\n")):]
+}
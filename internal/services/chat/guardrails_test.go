@@ -0,0 +1,91 @@
+package chat
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// amountRe finds the number of a dose in guarded text
+var amountRe = regexp.MustCompile(`\d`)
+
+func TestGuardTextBlocksDoses(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+	}{
+		{"russian plural", "Введите 4 единицы инсулина."},
+		{"russian accusative", "Сделайте 1 единицу."},
+		{"russian genitive plural", "Нужно 5 единиц."},
+		{"russian slang", "Подколите 2 юнита."},
+		{"russian slang plural", "Хватит 6 юнитов."},
+		{"russian abbreviation", "Введите 3 ЕД перед едой."},
+		{"russian abbreviation with dot", "Введите 2,5 ед. на завтрак."},
+		{"russian range", "Обычно 3-4 единицы."},
+		{"russian insulin", "Добавьте 2 инсулина."},
+		{"english units", "Take 4 units now."},
+		{"english unit", "Add 1 unit for the snack."},
+		{"english decimal", "Inject 2.5 U before eating."},
+		{"english range", "Usually 3 to 4 IU is enough."},
+		{"english insulin", "You need 6 of insulin."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, guarded := GuardText("ru", tt.text)
+			if !guarded {
+				t.Fatalf("GuardText(%q) passed a dose through: %q", tt.text, got)
+			}
+			if !strings.HasPrefix(got, guardNotices["ru"]) || amountRe.MatchString(got) {
+				t.Errorf("GuardText(%q) = %q, want the notice without the dose", tt.text, got)
+			}
+		})
+	}
+}
+
+func TestGuardTextKeepsOtherNumbers(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+	}{
+		{"carbs", "Съешьте 15 г быстрых углеводов."},
+		{"food", "Лучше 2 еды в день, чем одна."},
+		{"glucose", "Your glucose is 5.5 mmol/L."},
+		{"minutes", "Check again in 15 minutes."},
+		{"until", "Wait 20 until it settles."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, guarded := GuardText("en", tt.text)
+			if guarded || got != tt.text {
+				t.Errorf("GuardText(%q) = %q, %v; want the text unchanged", tt.text, got, guarded)
+			}
+		})
+	}
+}
+
+func TestDoseGuardStreamsSentences(t *testing.T) {
+	var emitted []string
+	guard := NewDoseGuard("en", func(s string) error {
+		emitted = append(emitted, s)
+		return nil
+	})
+	for _, delta := range []string{"Your glucose is fine. Take 4 ", "units now. Then eat. ", "Also 2 units later."} {
+		if err := guard.Write(delta); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := guard.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "Your glucose is fine. " + guardNotices["en"] + " Then eat. "
+	if guard.Text() != want {
+		t.Errorf("Text() = %q, want %q", guard.Text(), want)
+	}
+	if !guard.Guarded() {
+		t.Error("Guarded() = false, want true")
+	}
+	if len(emitted) == 0 {
+		t.Error("nothing was emitted")
+	}
+}
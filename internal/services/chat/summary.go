@@ -0,0 +1,107 @@
+package chat
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/yourusername/diabetes-assistant/internal/models"
+)
+
+// Limits keep the summary compact enough to send with every message
+const (
+	maxSummaryMeals = 5
+	maxSummaryDoses = 10
+)
+
+// ContextData is the user's recent data used to ground the conversation
+type ContextData struct {
+	Settings models.Settings
+	Readings []models.BloodSugarReading // Newest first
+	Meals    []models.MealRecord        // Newest first
+	Doses    []models.InsulinDose       // Newest first
+	Now      time.Time
+}
+
+// BuildSummary builds a compact plain-text summary of the user's recent data and settings schedule
+func BuildSummary(data ContextData) string {
+	var b strings.Builder
+
+	settings := data.Settings
+	fmt.Fprintf(&b, "Current time: %s\n", data.Now.Format("2006-01-02 15:04"))
+	fmt.Fprintf(&b, "Target range: %.1f-%.1f mmol/L, insulin action time: %.1f h\n", settings.TargetMin, settings.TargetMax, settings.IOBDuration)
+
+	b.WriteString("Carb ratio schedule (g per unit): ")
+	for i, period := range settings.CarbRatioPeriods {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		fmt.Fprintf(&b, "%s for %.0fh: %.1f", period.StartTime, period.Hours, period.Ratio)
+	}
+	b.WriteString("\nSensitivity schedule (mmol/L per unit): ")
+	for i, period := range settings.SensitivityPeriods {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		fmt.Fprintf(&b, "%s for %.0fh: %.1f", period.StartTime, period.Hours, period.Sensitivity)
+	}
+	b.WriteString("\nInsulin time coefficients: ")
+	for i, period := range settings.InsulinPeriods {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		fmt.Fprintf(&b, "%s for %.0fh: x%.2f", period.StartTime, period.Hours, period.Coefficient)
+	}
+	b.WriteString("\n")
+
+	if len(data.Readings) == 0 {
+		b.WriteString("Glucose (last 24h): no readings\n")
+	} else {
+		latest := data.Readings[0]
+		minValue, maxValue, sum := latest.Value, latest.Value, 0.0
+		inRange := 0
+		for _, reading := range data.Readings {
+			sum += reading.Value
+			if reading.Value < minValue {
+				minValue = reading.Value
+			}
+			if reading.Value > maxValue {
+				maxValue = reading.Value
+			}
+			if reading.Value >= settings.TargetMin && reading.Value <= settings.TargetMax {
+				inRange++
+			}
+		}
+		fmt.Fprintf(&b, "Glucose (last 24h): latest %.1f mmol/L at %s, %d readings, min %.1f, max %.1f, average %.1f, %d%% in target range\n",
+			latest.Value, latest.Timestamp.Format("15:04"), len(data.Readings), minValue, maxValue,
+			sum/float64(len(data.Readings)), inRange*100/len(data.Readings))
+	}
+
+	if len(data.Meals) == 0 {
+		b.WriteString("Meals (last 24h): none logged\n")
+	} else {
+		b.WriteString("Meals (last 24h):\n")
+		for i, meal := range data.Meals {
+			if i == maxSummaryMeals {
+				fmt.Fprintf(&b, "- and %d more\n", len(data.Meals)-maxSummaryMeals)
+				break
+			}
+			fmt.Fprintf(&b, "- %s %s: %.0fg carbs\n", meal.Timestamp.Format("15:04"), meal.Name, meal.Carbs)
+		}
+	}
+
+	if len(data.Doses) == 0 {
+		b.WriteString("Insulin doses (last 24h): none logged\n")
+	} else {
+		b.WriteString("Insulin doses (last 24h):\n")
+		for i, dose := range data.Doses {
+			if i == maxSummaryDoses {
+				fmt.Fprintf(&b, "- and %d more\n", len(data.Doses)-maxSummaryDoses)
+				break
+			}
+			fmt.Fprintf(&b, "- %s %s: %.1fU\n", dose.Timestamp.Format("15:04"), dose.Type, dose.Units)
+		}
+	}
+
+	return strings.TrimSpace(b.String())
+}
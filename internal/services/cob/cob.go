@@ -0,0 +1,145 @@
+package cob
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/yourusername/diabetes-assistant/internal/models"
+	"github.com/yourusername/diabetes-assistant/internal/services/insulin"
+)
+
+// absorptionDelay is how long after eating carbs start raising glucose
+const absorptionDelay = 10 * time.Minute
+
+// Shape of the piecewise curve, as fractions of the absorption time: the rate rises until
+// pieceRiseEnd, stays flat until pieceFallStart and falls to zero at the end
+const (
+	pieceRiseEnd   = 0.15
+	pieceFallStart = 0.5
+)
+
+// Profile returns how fast a meal is absorbed: the AI's classification when the analysis made one,
+// otherwise it is estimated from the macronutrients
+func Profile(meal models.MealRecord) string {
+	switch meal.Absorption {
+	case insulin.MealProfileFast, insulin.MealProfileMedium, insulin.MealProfileSlow:
+		return meal.Absorption
+	}
+	return insulin.ClassifyMeal(meal.Carbs, meal.Fat, meal.Protein)
+}
+
+// AbsorptionTime returns how long a meal takes to be absorbed with the user's absorption times
+func AbsorptionTime(meal models.MealRecord, settings *models.Settings) time.Duration {
+	return time.Duration(settings.AbsorptionHours(Profile(meal)) * float64(time.Hour))
+}
+
+// AbsorbedFraction returns the share of a meal's carbs absorbed elapsed after eating
+func AbsorbedFraction(curve string, elapsed, absorption time.Duration) float64 {
+	elapsed -= absorptionDelay
+	if elapsed <= 0 || absorption <= 0 {
+		return 0
+	}
+
+	t := elapsed.Minutes() / absorption.Minutes()
+	if t >= 1 {
+		return 1
+	}
+	if curve == models.AbsorptionCurveLinear {
+		return t
+	}
+
+	// The rate's peak makes the area under the curve 1
+	peak := 2 / (1 + pieceFallStart - pieceRiseEnd)
+	switch {
+	case t < pieceRiseEnd:
+		return 0.5 * peak * t * t / pieceRiseEnd
+	case t < pieceFallStart:
+		return peak * (0.5*pieceRiseEnd + t - pieceRiseEnd)
+	default:
+		falling := t - pieceFallStart
+		return peak * (0.5*pieceRiseEnd + pieceFallStart - pieceRiseEnd + falling - falling*falling/(2*(1-pieceFallStart)))
+	}
+}
+
+// Eaten returns the meals that were eaten: confirmed ones and ones with a bolus logged for them.
+// Every analysis is stored as a meal, so without this an analysis the user didn't act on, or the
+// same plate analyzed twice, would add carbs that were never eaten.
+func Eaten(meals []models.MealRecord, doses []models.InsulinDose) []models.MealRecord {
+	dosed := make(map[string]bool)
+	for _, dose := range doses {
+		if dose.MealID != "" && dose.Type != models.DoseTypeBasal {
+			dosed[dose.MealID] = true
+		}
+	}
+
+	eaten := make([]models.MealRecord, 0, len(meals))
+	for _, meal := range meals {
+		if meal.EatenAt != nil || dosed[meal.ID] {
+			eaten = append(eaten, meal)
+		}
+	}
+	return eaten
+}
+
+// OnBoard returns the grams of carbohydrate not yet absorbed at the given time. Callers pass
+// the meals that were eaten, see Eaten.
+func OnBoard(meals []models.MealRecord, settings *models.Settings, at time.Time) float64 {
+	total := 0.0
+	for _, meal := range meals {
+		if meal.Timestamp.After(at) {
+			continue
+		}
+		total += meal.Carbs * (1 - absorbed(meal, settings, at))
+	}
+	return total
+}
+
+// Absorbed returns the grams of carbohydrate absorbed between from and to
+func Absorbed(meals []models.MealRecord, settings *models.Settings, from, to time.Time) float64 {
+	total := 0.0
+	for _, meal := range meals {
+		total += meal.Carbs * (absorbed(meal, settings, to) - absorbed(meal, settings, from))
+	}
+	return total
+}
+
+// Active lists the meals still being absorbed at the given time, newest first
+func Active(meals []models.MealRecord, settings *models.Settings, at time.Time) []models.ActiveCarbs {
+	active := []models.ActiveCarbs{}
+	for _, meal := range meals {
+		if meal.Timestamp.After(at) || meal.Carbs <= 0 {
+			continue
+		}
+
+		remaining := meal.Carbs * (1 - absorbed(meal, settings, at))
+		if remaining < 0.5 {
+			continue
+		}
+
+		active = append(active, models.ActiveCarbs{
+			MealID:     meal.ID,
+			Name:       meal.Name,
+			EatenAt:    meal.Timestamp,
+			Carbs:      meal.Carbs,
+			Remaining:  round(remaining, 1),
+			Absorption: Profile(meal),
+			AbsorbedBy: meal.Timestamp.Add(absorptionDelay + AbsorptionTime(meal, settings)),
+		})
+	}
+
+	sort.Slice(active, func(i, j int) bool {
+		return active[i].EatenAt.After(active[j].EatenAt)
+	})
+	return active
+}
+
+// absorbed returns the share of a meal absorbed at the given time with the user's curve
+func absorbed(meal models.MealRecord, settings *models.Settings, at time.Time) float64 {
+	return AbsorbedFraction(settings.AbsorptionCurve, at.Sub(meal.Timestamp), AbsorptionTime(meal, settings))
+}
+
+func round(x float64, decimals int) float64 {
+	pow := math.Pow(10, float64(decimals))
+	return math.Round(x*pow) / pow
+}
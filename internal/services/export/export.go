@@ -0,0 +1,329 @@
+package export
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yourusername/diabetes-assistant/internal/models"
+	"github.com/yourusername/diabetes-assistant/internal/services/glucose"
+	"github.com/yourusername/diabetes-assistant/internal/storage"
+)
+
+// Export formats
+const (
+	FormatCSV  = "csv"  // ZIP archive with one CSV file per kind of record
+	FormatJSON = "json" // One JSON document
+)
+
+// Column layouts of the CSV files. Columns are only ever added at the end, so readers can rely
+// on their positions.
+var (
+	readingColumns = []string{"timestamp", "value_mmol_l", "value_mg_dl", "source", "trend", "rate_mmol_l_min"}
+	mealColumns    = []string{"id", "timestamp", "name", "carbs_g", "fat_g", "protein_g", "weight_g", "absorption", "source",
+		"meal_insulin_u", "correction_insulin_u", "total_insulin_u", "confidence", "description", "user_notes"}
+	doseColumns     = []string{"id", "timestamp", "type", "units", "meal_id", "note"}
+	settingsColumns = []string{"saved_at", "target_min_mmol_l", "target_max_mmol_l", "iob_duration_h", "locale", "fpu_factor",
+		"absorption_fast_h", "absorption_medium_h", "absorption_slow_h", "absorption_curve",
+		"insulin_periods", "sensitivity_periods", "carb_ratio_periods"}
+)
+
+// Options select what is exported. Records in [From, To) are included, and timestamps are written
+// in Location with their UTC offset.
+type Options struct {
+	From     time.Time
+	To       time.Time
+	Location *time.Location
+}
+
+// Service writes a user's readings, meals, doses and settings revisions as CSV or JSON. Records
+// are streamed from storage one at a time, so large ranges don't have to fit in memory.
+type Service struct {
+	storage storage.Storage
+}
+
+// NewService creates a new export service
+func NewService(storage storage.Storage) *Service {
+	return &Service{storage: storage}
+}
+
+// WriteCSV writes a ZIP archive with readings.csv, meals.csv, doses.csv and settings.csv
+func (s *Service) WriteCSV(ctx context.Context, w io.Writer, userID string, opts Options) error {
+	archive := zip.NewWriter(w)
+	loc := opts.Location
+
+	err := s.writeCSVFile(archive, "readings.csv", readingColumns, func(out *csv.Writer) error {
+		return s.storage.StreamBloodSugarReadings(ctx, userID, opts.From, opts.To, func(reading models.BloodSugarReading) error {
+			rate := ""
+			if reading.RateOfChange != nil {
+				rate = formatFloat(*reading.RateOfChange, 4)
+			}
+			return out.Write([]string{
+				formatTime(reading.Timestamp, loc),
+				formatFloat(reading.Value, 1),
+				strconv.Itoa(int(math.Round(glucose.ToMgdl(reading.Value)))),
+				safeText(reading.Source), // Sources and trends come from uploaders as they are
+				safeText(reading.Trend),
+				rate,
+			})
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("failed to export readings: %w", err)
+	}
+
+	err = s.writeCSVFile(archive, "meals.csv", mealColumns, func(out *csv.Writer) error {
+		return s.storage.StreamMealRecords(ctx, userID, opts.From, opts.To, func(meal models.MealRecord) error {
+			return out.Write([]string{
+				meal.ID,
+				formatTime(meal.Timestamp, loc),
+				safeText(meal.Name),
+				formatFloat(meal.Carbs, 1),
+				formatFloat(meal.Fat, 1),
+				formatFloat(meal.Protein, 1),
+				formatFloat(meal.Weight, 0),
+				meal.Absorption,
+				meal.Source,
+				formatFloat(meal.MealInsulin, 2),
+				formatFloat(meal.CorrectionInsulin, 2),
+				formatFloat(meal.TotalInsulin, 2),
+				formatFloat(meal.Confidence, 2),
+				safeText(meal.Description),
+				safeText(meal.UserNotes),
+			})
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("failed to export meals: %w", err)
+	}
+
+	err = s.writeCSVFile(archive, "doses.csv", doseColumns, func(out *csv.Writer) error {
+		return s.storage.StreamInsulinDoses(ctx, userID, opts.From, opts.To, func(dose models.InsulinDose) error {
+			return out.Write([]string{
+				dose.ID,
+				formatTime(dose.Timestamp, loc),
+				dose.Type,
+				formatFloat(dose.Units, 2),
+				dose.MealID,
+				safeText(dose.Note),
+			})
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("failed to export doses: %w", err)
+	}
+
+	revisions, err := s.storage.GetSettingsRevisions(ctx, userID, opts.From, opts.To)
+	if err != nil {
+		return fmt.Errorf("failed to fetch settings revisions: %w", err)
+	}
+	err = s.writeCSVFile(archive, "settings.csv", settingsColumns, func(out *csv.Writer) error {
+		for _, revision := range revisions {
+			settings := revision.Settings
+			err := out.Write([]string{
+				formatTime(revision.SavedAt, loc),
+				formatFloat(settings.TargetMin, 1),
+				formatFloat(settings.TargetMax, 1),
+				formatFloat(settings.IOBDuration, 1),
+				settings.Locale,
+				formatFloat(settings.FPUFactor, 1),
+				formatFloat(settings.AbsorptionTimes.Fast, 1),
+				formatFloat(settings.AbsorptionTimes.Medium, 1),
+				formatFloat(settings.AbsorptionTimes.Slow, 1),
+				settings.AbsorptionCurve,
+				formatPeriods(len(settings.InsulinPeriods), func(i int) (string, float64, float64) {
+					period := settings.InsulinPeriods[i]
+					return period.StartTime, period.Hours, period.Coefficient
+				}),
+				formatPeriods(len(settings.SensitivityPeriods), func(i int) (string, float64, float64) {
+					period := settings.SensitivityPeriods[i]
+					return period.StartTime, period.Hours, period.Sensitivity
+				}),
+				formatPeriods(len(settings.CarbRatioPeriods), func(i int) (string, float64, float64) {
+					period := settings.CarbRatioPeriods[i]
+					return period.StartTime, period.Hours, period.Ratio
+				}),
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to export settings: %w", err)
+	}
+
+	return archive.Close()
+}
+
+// writeCSVFile adds a CSV file with a header row to the archive, write adds the records
+func (s *Service) writeCSVFile(archive *zip.Writer, name string, columns []string, write func(*csv.Writer) error) error {
+	file, err := archive.Create(name)
+	if err != nil {
+		return err
+	}
+	out := csv.NewWriter(file)
+	if err := out.Write(columns); err != nil {
+		return err
+	}
+	if err := write(out); err != nil {
+		return err
+	}
+	out.Flush()
+	return out.Error()
+}
+
+// safeText makes free text safe to open in a spreadsheet. Cells starting with =, +, - or @ are run
+// as formulas, some spreadsheets also skip a leading tab or carriage return, so these get a quote.
+func safeText(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+// WriteJSON writes one JSON document:
+//
+//	{"userId": …, "from": …, "to": …, "timezone": …, "readings": […], "meals": […], "doses": […], "settingsRevisions": […]}
+//
+// The records have the same fields as in the rest of the API.
+func (s *Service) WriteJSON(ctx context.Context, w io.Writer, userID string, opts Options) error {
+	loc := opts.Location
+	header, err := json.Marshal(map[string]interface{}{
+		"userId":   userID,
+		"from":     opts.From.In(loc),
+		"to":       opts.To.In(loc),
+		"timezone": loc.String(),
+	})
+	if err != nil {
+		return err
+	}
+	// Keep the header's fields and continue the object with the arrays
+	if _, err := w.Write(header[:len(header)-1]); err != nil {
+		return err
+	}
+
+	readings := newJSONArray(w, "readings")
+	err = s.storage.StreamBloodSugarReadings(ctx, userID, opts.From, opts.To, func(reading models.BloodSugarReading) error {
+		reading.Timestamp = reading.Timestamp.In(loc)
+		return readings.add(reading)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to export readings: %w", err)
+	}
+	if err := readings.close(); err != nil {
+		return err
+	}
+
+	meals := newJSONArray(w, "meals")
+	err = s.storage.StreamMealRecords(ctx, userID, opts.From, opts.To, func(meal models.MealRecord) error {
+		meal.Timestamp = meal.Timestamp.In(loc)
+		return meals.add(meal)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to export meals: %w", err)
+	}
+	if err := meals.close(); err != nil {
+		return err
+	}
+
+	doses := newJSONArray(w, "doses")
+	err = s.storage.StreamInsulinDoses(ctx, userID, opts.From, opts.To, func(dose models.InsulinDose) error {
+		dose.Timestamp = dose.Timestamp.In(loc)
+		return doses.add(dose)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to export doses: %w", err)
+	}
+	if err := doses.close(); err != nil {
+		return err
+	}
+
+	revisions, err := s.storage.GetSettingsRevisions(ctx, userID, opts.From, opts.To)
+	if err != nil {
+		return fmt.Errorf("failed to fetch settings revisions: %w", err)
+	}
+	settings := newJSONArray(w, "settingsRevisions")
+	for _, revision := range revisions {
+		revision.SavedAt = revision.SavedAt.In(loc)
+		if err := settings.add(revision); err != nil {
+			return err
+		}
+	}
+	if err := settings.close(); err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(w, "}\n")
+	return err
+}
+
+// jsonArray writes the elements of an object field one at a time
+type jsonArray struct {
+	w     io.Writer
+	name  string
+	count int
+}
+
+func newJSONArray(w io.Writer, name string) *jsonArray {
+	return &jsonArray{w: w, name: name}
+}
+
+// add writes one element, opening the array before the first
+func (a *jsonArray) add(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	prefix := ","
+	if a.count == 0 {
+		prefix = fmt.Sprintf(",%q:[", a.name)
+	}
+	a.count++
+	_, err = io.WriteString(a.w, prefix+string(data))
+	return err
+}
+
+// close ends the array, an empty one is written as []
+func (a *jsonArray) close() error {
+	if a.count == 0 {
+		_, err := fmt.Fprintf(a.w, ",%q:[]", a.name)
+		return err
+	}
+	_, err := io.WriteString(a.w, "]")
+	return err
+}
+
+// formatTime writes a timestamp in RFC 3339 with the UTC offset of the export's time zone
+func formatTime(t time.Time, loc *time.Location) string {
+	return t.In(loc).Format(time.RFC3339)
+}
+
+// formatFloat rounds to the given decimals and drops trailing zeros
+func formatFloat(x float64, decimals int) string {
+	return strconv.FormatFloat(round(x, decimals), 'f', -1, 64)
+}
+
+func round(x float64, decimals int) float64 {
+	pow := math.Pow(10, float64(decimals))
+	return math.Round(x*pow) / pow
+}
+
+// formatPeriods writes a settings schedule as "start hours value" triples separated by "; ",
+// e.g. "00:00 6h 1.2; 06:00 18h 1"
+func formatPeriods(n int, period func(i int) (string, float64, float64)) string {
+	parts := make([]string, n)
+	for i := range parts {
+		start, hours, value := period(i)
+		parts[i] = fmt.Sprintf("%s %sh %s", start, formatFloat(hours, 2), formatFloat(value, 3))
+	}
+	return strings.Join(parts, "; ")
+}
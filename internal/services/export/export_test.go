@@ -0,0 +1,107 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"testing"
+	"time"
+
+	"github.com/yourusername/diabetes-assistant/internal/models"
+	"github.com/yourusername/diabetes-assistant/internal/storage"
+)
+
+func TestWriteCSVQuotesFormulas(t *testing.T) {
+	store := storage.NewInMemoryStorage()
+	ctx := context.Background()
+	at := time.Date(2024, 5, 14, 8, 30, 0, 0, time.UTC)
+	meal := &models.MealRecord{
+		ID:          "m1",
+		UserID:      "user1",
+		Timestamp:   at,
+		Name:        `=HYPERLINK("https://example.com","Porridge")`,
+		Carbs:       45,
+		Description: "+1 banana",
+		UserNotes:   "-- no sugar",
+	}
+	if err := store.SaveMealRecord(ctx, meal); err != nil {
+		t.Fatal(err)
+	}
+	dose := &models.InsulinDose{ID: "d1", UserID: "user1", Timestamp: at, Type: models.DoseTypeBolus, Units: 4, Note: "@pump"}
+	if err := store.SaveInsulinDose(ctx, dose); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.CreateUser(&models.User{UserID: "user1"}); err != nil {
+		t.Fatal(err)
+	}
+	reading := models.BloodSugarReading{Value: 5.4, Timestamp: at, Source: "=cmd|' /C calc'!A0", Trend: "+Flat"}
+	if err := store.AddBloodSugarReadings(ctx, "user1", []models.BloodSugarReading{reading}); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	opts := Options{From: at.Add(-time.Hour), To: at.Add(time.Hour), Location: time.UTC}
+	if err := NewService(store).WriteCSV(ctx, &out, "user1", opts); err != nil {
+		t.Fatalf("WriteCSV: %v", err)
+	}
+
+	meals := readCSV(t, out.Bytes(), "meals.csv")
+	if len(meals) != 2 {
+		t.Fatalf("meals.csv has %d rows, want a header and one meal", len(meals))
+	}
+	row := meals[1]
+	if row[2] != `'=HYPERLINK("https://example.com","Porridge")` || row[13] != "'+1 banana" || row[14] != "'-- no sugar" {
+		t.Errorf("meal row = %q", row)
+	}
+	if row[3] != "45" {
+		t.Errorf("carbs_g = %q, numbers must stay unquoted", row[3])
+	}
+
+	readings := readCSV(t, out.Bytes(), "readings.csv")
+	if len(readings) != 2 || readings[1][3] != "'=cmd|' /C calc'!A0" || readings[1][4] != "'+Flat" {
+		t.Errorf("readings.csv = %q", readings)
+	}
+
+	doses := readCSV(t, out.Bytes(), "doses.csv")
+	if len(doses) != 2 || doses[1][5] != "'@pump" {
+		t.Errorf("doses.csv = %q", doses)
+	}
+}
+
+func TestSafeText(t *testing.T) {
+	tests := map[string]string{
+		"":            "",
+		"Porridge":    "Porridge",
+		"=1+1":        "'=1+1",
+		"+7 999":      "'+7 999",
+		"-2 units":    "'-2 units",
+		"@SUM(A1)":    "'@SUM(A1)",
+		"\t=1+1":      "'\t=1+1",
+		"Borscht = 1": "Borscht = 1",
+	}
+	for value, want := range tests {
+		if got := safeText(value); got != want {
+			t.Errorf("safeText(%q) = %q, want %q", value, got, want)
+		}
+	}
+}
+
+// readCSV returns the rows of a file in an export archive
+func readCSV(t *testing.T, archive []byte, name string) [][]string {
+	t.Helper()
+	reader, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+	if err != nil {
+		t.Fatalf("reading archive: %v", err)
+	}
+	file, err := reader.Open(name)
+	if err != nil {
+		t.Fatalf("opening %s: %v", name, err)
+	}
+	defer file.Close()
+	rows, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		t.Fatalf("parsing %s: %v", name, err)
+	}
+	return rows
+}
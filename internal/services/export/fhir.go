@@ -0,0 +1,302 @@
+package export
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/yourusername/diabetes-assistant/internal/models"
+	"github.com/yourusername/diabetes-assistant/internal/services/imports"
+	"github.com/yourusername/diabetes-assistant/internal/services/nightscout"
+)
+
+// Code systems of the FHIR resources
+const (
+	fhirLOINC    = "http://loinc.org"
+	fhirSNOMED   = "http://snomed.info/sct"
+	fhirUCUM     = "http://unitsofmeasure.org"
+	fhirCategory = "http://terminology.hl7.org/CodeSystem/observation-category"
+	// fhirUserSystem identifies patients by their user ID
+	fhirUserSystem = "urn:diabetes-assistant:user-id"
+)
+
+// LOINC codes of glucose in mmol/L by where it was measured, and of meal carbs
+var (
+	loincInterstitialGlucose = fhirCoding{System: fhirLOINC, Code: "105272-9", Display: "Glucose [Moles/volume] in Interstitial fluid"}
+	loincCapillaryGlucose    = fhirCoding{System: fhirLOINC, Code: "14743-9", Display: "Glucose [Moles/volume] in Capillary blood by Glucometer"}
+	loincBloodGlucose        = fhirCoding{System: fhirLOINC, Code: "15074-8", Display: "Glucose [Moles/volume] in Blood"}
+	loincCarbIntake          = fhirCoding{System: fhirLOINC, Code: "9059-7", Display: "Carbohydrate intake Estimated"}
+)
+
+var (
+	snomedInsulin      = fhirCoding{System: fhirSNOMED, Code: "67866001", Display: "Insulin"}
+	snomedSubcutaneous = fhirCoding{System: fhirSNOMED, Code: "34206005", Display: "Subcutaneous route"}
+	laboratoryCategory = fhirCodeableConcept{Coding: []fhirCoding{{System: fhirCategory, Code: "laboratory", Display: "Laboratory"}}}
+)
+
+// Reading sources by where glucose was measured. Readings from other sources, such as manual
+// entries, are reported as blood glucose.
+var (
+	sensorSources = map[string]bool{
+		imports.SourceLibreViewHistoric: true,
+		imports.SourceLibreViewScan:     true,
+		imports.SourceDexcom:            true,
+		nightscout.SourceSensor:         true,
+	}
+	meterSources = map[string]bool{
+		imports.SourceLibreViewStrip: true,
+		nightscout.SourceMeter:       true,
+	}
+)
+
+// doseTexts describe the dose types
+var doseTexts = map[string]string{
+	models.DoseTypeBolus:      "Bolus insulin",
+	models.DoseTypeCorrection: "Correction bolus insulin",
+	models.DoseTypeBasal:      "Basal insulin",
+}
+
+type fhirCoding struct {
+	System  string `json:"system"`
+	Code    string `json:"code"`
+	Display string `json:"display,omitempty"`
+}
+
+type fhirCodeableConcept struct {
+	Coding []fhirCoding `json:"coding,omitempty"`
+	Text   string       `json:"text,omitempty"`
+}
+
+type fhirQuantity struct {
+	Value  float64 `json:"value"`
+	Unit   string  `json:"unit"`
+	System string  `json:"system"`
+	Code   string  `json:"code"`
+}
+
+type fhirReference struct {
+	Reference string `json:"reference"`
+}
+
+type fhirAnnotation struct {
+	Text string `json:"text"`
+}
+
+type fhirIdentifier struct {
+	System string `json:"system"`
+	Value  string `json:"value"`
+}
+
+type fhirPatient struct {
+	ResourceType string           `json:"resourceType"`
+	ID           string           `json:"id"`
+	Identifier   []fhirIdentifier `json:"identifier"`
+}
+
+type fhirComponent struct {
+	Code          fhirCodeableConcept `json:"code"`
+	ValueQuantity fhirQuantity        `json:"valueQuantity"`
+}
+
+type fhirObservation struct {
+	ResourceType      string                `json:"resourceType"`
+	ID                string                `json:"id"`
+	Status            string                `json:"status"`
+	Category          []fhirCodeableConcept `json:"category,omitempty"`
+	Code              fhirCodeableConcept   `json:"code"`
+	Subject           fhirReference         `json:"subject"`
+	EffectiveDateTime string                `json:"effectiveDateTime"`
+	ValueQuantity     fhirQuantity          `json:"valueQuantity"`
+	Component         []fhirComponent       `json:"component,omitempty"`
+	Note              []fhirAnnotation      `json:"note,omitempty"`
+}
+
+type fhirDosage struct {
+	Text  string              `json:"text,omitempty"`
+	Route fhirCodeableConcept `json:"route"`
+	Dose  fhirQuantity        `json:"dose"`
+}
+
+type fhirMedicationAdministration struct {
+	ResourceType              string              `json:"resourceType"`
+	ID                        string              `json:"id"`
+	Status                    string              `json:"status"`
+	MedicationCodeableConcept fhirCodeableConcept `json:"medicationCodeableConcept"`
+	Subject                   fhirReference       `json:"subject"`
+	EffectiveDateTime         string              `json:"effectiveDateTime"`
+	// The meal the bolus was taken for, when it is in the bundle
+	SupportingInformation []fhirReference  `json:"supportingInformation,omitempty"`
+	Note                  []fhirAnnotation `json:"note,omitempty"`
+	Dosage                fhirDosage       `json:"dosage"`
+}
+
+// fhirBundle is the start of the bundle, its entries are streamed after it
+type fhirBundle struct {
+	ResourceType string `json:"resourceType"`
+	ID           string `json:"id"`
+	Type         string `json:"type"`
+	Timestamp    string `json:"timestamp"`
+}
+
+type fhirEntry struct {
+	FullURL  string      `json:"fullUrl"`
+	Resource interface{} `json:"resource"`
+}
+
+// WriteFHIR writes a FHIR R4 collection Bundle: a Patient for the user, a nutrition Observation
+// per meal with its carbs, a MedicationAdministration per insulin dose and an Observation per
+// reading with the LOINC code of where glucose was measured. Resources are referenced by urn:uuid
+// full URLs derived from the records, so exporting the same range again gives the same IDs.
+func (s *Service) WriteFHIR(ctx context.Context, w io.Writer, userID string, opts Options) error {
+	loc := opts.Location
+	header, err := json.Marshal(fhirBundle{
+		ResourceType: "Bundle",
+		ID:           uuid.New().String(),
+		Type:         "collection",
+		Timestamp:    formatTime(time.Now(), loc),
+	})
+	if err != nil {
+		return err
+	}
+	// Keep the header's fields and continue the object with the entries
+	if _, err := w.Write(header[:len(header)-1]); err != nil {
+		return err
+	}
+
+	entries := newJSONArray(w, "entry")
+	patientID := fhirID("patient", userID)
+	subject := fhirReference{Reference: "urn:uuid:" + patientID}
+	err = entries.add(fhirEntry{
+		FullURL: subject.Reference,
+		Resource: fhirPatient{
+			ResourceType: "Patient",
+			ID:           patientID,
+			Identifier:   []fhirIdentifier{{System: fhirUserSystem, Value: userID}},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	// Meals go first so boluses can reference them
+	mealURLs := make(map[string]string)
+	err = s.storage.StreamMealRecords(ctx, userID, opts.From, opts.To, func(meal models.MealRecord) error {
+		id := fhirID("meal", meal.ID)
+		mealURLs[meal.ID] = "urn:uuid:" + id
+		return entries.add(fhirEntry{FullURL: mealURLs[meal.ID], Resource: mealObservation(id, meal, subject, loc)})
+	})
+	if err != nil {
+		return fmt.Errorf("failed to export meals: %w", err)
+	}
+
+	err = s.storage.StreamInsulinDoses(ctx, userID, opts.From, opts.To, func(dose models.InsulinDose) error {
+		id := fhirID("dose", dose.ID)
+		administration := doseAdministration(id, dose, subject, loc)
+		if url, ok := mealURLs[dose.MealID]; ok {
+			administration.SupportingInformation = []fhirReference{{Reference: url}}
+		}
+		return entries.add(fhirEntry{FullURL: "urn:uuid:" + id, Resource: administration})
+	})
+	if err != nil {
+		return fmt.Errorf("failed to export doses: %w", err)
+	}
+
+	err = s.storage.StreamBloodSugarReadings(ctx, userID, opts.From, opts.To, func(reading models.BloodSugarReading) error {
+		id := fhirID("reading", userID+"/"+reading.Timestamp.UTC().Format(time.RFC3339Nano))
+		return entries.add(fhirEntry{FullURL: "urn:uuid:" + id, Resource: readingObservation(id, reading, subject, loc)})
+	})
+	if err != nil {
+		return fmt.Errorf("failed to export readings: %w", err)
+	}
+
+	if err := entries.close(); err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, "}\n")
+	return err
+}
+
+// readingObservation maps a reading to a glucose Observation in mmol/L
+func readingObservation(id string, reading models.BloodSugarReading, subject fhirReference, loc *time.Location) fhirObservation {
+	code := loincBloodGlucose
+	switch {
+	case sensorSources[reading.Source]:
+		code = loincInterstitialGlucose
+	case meterSources[reading.Source]:
+		code = loincCapillaryGlucose
+	}
+
+	return fhirObservation{
+		ResourceType:      "Observation",
+		ID:                id,
+		Status:            "final",
+		Category:          []fhirCodeableConcept{laboratoryCategory},
+		Code:              fhirCodeableConcept{Coding: []fhirCoding{code}, Text: "Glucose"},
+		Subject:           subject,
+		EffectiveDateTime: formatTime(reading.Timestamp, loc),
+		ValueQuantity:     ucumQuantity(round(reading.Value, 1), "mmol/L", "mmol/L"),
+	}
+}
+
+// mealObservation maps a meal to a carbohydrate intake Observation, with fat and protein as
+// components when they are known
+func mealObservation(id string, meal models.MealRecord, subject fhirReference, loc *time.Location) fhirObservation {
+	observation := fhirObservation{
+		ResourceType:      "Observation",
+		ID:                id,
+		Status:            "final",
+		Code:              fhirCodeableConcept{Coding: []fhirCoding{loincCarbIntake}, Text: "Meal carbohydrates"},
+		Subject:           subject,
+		EffectiveDateTime: formatTime(meal.Timestamp, loc),
+		ValueQuantity:     ucumQuantity(round(meal.Carbs, 1), "g", "g"),
+	}
+	if meal.Fat > 0 {
+		observation.Component = append(observation.Component, fhirComponent{
+			Code:          fhirCodeableConcept{Text: "Fat intake"},
+			ValueQuantity: ucumQuantity(round(meal.Fat, 1), "g", "g"),
+		})
+	}
+	if meal.Protein > 0 {
+		observation.Component = append(observation.Component, fhirComponent{
+			Code:          fhirCodeableConcept{Text: "Protein intake"},
+			ValueQuantity: ucumQuantity(round(meal.Protein, 1), "g", "g"),
+		})
+	}
+	if meal.Name != "" {
+		observation.Note = []fhirAnnotation{{Text: meal.Name}}
+	}
+	return observation
+}
+
+// doseAdministration maps an insulin dose to a subcutaneous MedicationAdministration
+func doseAdministration(id string, dose models.InsulinDose, subject fhirReference, loc *time.Location) fhirMedicationAdministration {
+	administration := fhirMedicationAdministration{
+		ResourceType:              "MedicationAdministration",
+		ID:                        id,
+		Status:                    "completed",
+		MedicationCodeableConcept: fhirCodeableConcept{Coding: []fhirCoding{snomedInsulin}, Text: doseTexts[dose.Type]},
+		Subject:                   subject,
+		EffectiveDateTime:         formatTime(dose.Timestamp, loc),
+		Dosage: fhirDosage{
+			Text:  dose.Type,
+			Route: fhirCodeableConcept{Coding: []fhirCoding{snomedSubcutaneous}},
+			Dose:  ucumQuantity(round(dose.Units, 2), "U", "[iU]"),
+		},
+	}
+	if dose.Note != "" {
+		administration.Note = []fhirAnnotation{{Text: dose.Note}}
+	}
+	return administration
+}
+
+func ucumQuantity(value float64, unit, code string) fhirQuantity {
+	return fhirQuantity{Value: value, Unit: unit, System: fhirUCUM, Code: code}
+}
+
+// fhirID returns a UUID for a record that stays the same across exports
+func fhirID(kind, key string) string {
+	return uuid.NewSHA1(uuid.NameSpaceURL, []byte("diabetes-assistant/"+kind+"/"+key)).String()
+}
@@ -0,0 +1,152 @@
+package export
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/yourusername/diabetes-assistant/internal/models"
+	"github.com/yourusername/diabetes-assistant/internal/services/imports"
+	"github.com/yourusername/diabetes-assistant/internal/services/nightscout"
+	"github.com/yourusername/diabetes-assistant/internal/storage"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+var (
+	fhirTestTime    = time.Date(2024, 5, 14, 5, 30, 0, 0, time.UTC)
+	fhirTestZone    = time.FixedZone("MSK", 3*60*60)
+	fhirTestSubject = fhirReference{Reference: "urn:uuid:" + fhirID("patient", "user1")}
+)
+
+func TestFHIRResources(t *testing.T) {
+	rate := -0.05
+	tests := []struct {
+		golden   string
+		resource interface{}
+	}{
+		{
+			golden: "reading_sensor.golden",
+			resource: readingObservation(fhirID("reading", "sensor"), models.BloodSugarReading{
+				Value:        6.84,
+				Timestamp:    fhirTestTime,
+				Source:       imports.SourceLibreViewHistoric,
+				Trend:        "FortyFiveDown",
+				RateOfChange: &rate,
+			}, fhirTestSubject, fhirTestZone),
+		},
+		{
+			golden: "reading_fingerstick.golden",
+			resource: readingObservation(fhirID("reading", "fingerstick"), models.BloodSugarReading{
+				Value:     5.4,
+				Timestamp: fhirTestTime,
+				Source:    nightscout.SourceMeter,
+			}, fhirTestSubject, fhirTestZone),
+		},
+		{
+			golden: "meal.golden",
+			resource: mealObservation(fhirID("meal", "m1"), models.MealRecord{
+				ID:        "m1",
+				UserID:    "user1",
+				Timestamp: fhirTestTime,
+				Name:      "Pizza Margherita",
+				Carbs:     72.46,
+				Fat:       24.3,
+				Protein:   28,
+			}, fhirTestSubject, fhirTestZone),
+		},
+		{
+			golden: "dose_bolus.golden",
+			resource: doseAdministration(fhirID("dose", "d1"), models.InsulinDose{
+				ID:        "d1",
+				UserID:    "user1",
+				Timestamp: fhirTestTime.Add(-10 * time.Minute),
+				Type:      models.DoseTypeBolus,
+				Units:     6.5,
+				MealID:    "m1",
+				Note:      "Pre-bolus",
+			}, fhirTestSubject, fhirTestZone),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.golden, func(t *testing.T) {
+			got, err := json.MarshalIndent(tt.resource, "", "  ")
+			if err != nil {
+				t.Fatal(err)
+			}
+			compareGolden(t, tt.golden, got)
+		})
+	}
+}
+
+func TestWriteFHIR(t *testing.T) {
+	store := storage.NewInMemoryStorage()
+	ctx := context.Background()
+	if err := store.CreateUser(&models.User{UserID: "user1", Settings: *models.CreateDefaultSettings("user1")}); err != nil {
+		t.Fatal(err)
+	}
+	readings := []models.BloodSugarReading{
+		{Value: 6.8, Timestamp: fhirTestTime, Source: nightscout.SourceSensor},
+		{Value: 5.4, Timestamp: fhirTestTime.Add(5 * time.Minute), Source: nightscout.SourceMeter},
+	}
+	if err := store.AddBloodSugarReadings(ctx, "user1", readings); err != nil {
+		t.Fatal(err)
+	}
+	meal := &models.MealRecord{ID: "m1", UserID: "user1", Timestamp: fhirTestTime, Name: "Pizza Margherita", Carbs: 72, Fat: 24, Protein: 28}
+	if err := store.SaveMealRecord(ctx, meal); err != nil {
+		t.Fatal(err)
+	}
+	dose := &models.InsulinDose{ID: "d1", UserID: "user1", Timestamp: fhirTestTime, Type: models.DoseTypeBolus, Units: 6.5, MealID: "m1"}
+	if err := store.SaveInsulinDose(ctx, dose); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	opts := Options{From: fhirTestTime.Add(-time.Hour), To: fhirTestTime.Add(time.Hour), Location: fhirTestZone}
+	if err := NewService(store).WriteFHIR(ctx, &out, "user1", opts); err != nil {
+		t.Fatalf("WriteFHIR: %v", err)
+	}
+
+	// The bundle's own ID and timestamp change with every export
+	var bundle map[string]interface{}
+	if err := json.Unmarshal(out.Bytes(), &bundle); err != nil {
+		t.Fatalf("the bundle isn't valid JSON: %v\n%s", err, out.Bytes())
+	}
+	if bundle["id"] == "" || bundle["timestamp"] == "" {
+		t.Errorf("bundle id = %v, timestamp = %v", bundle["id"], bundle["timestamp"])
+	}
+	bundle["id"] = "BUNDLE-ID"
+	bundle["timestamp"] = "EXPORT-TIME"
+	got, err := json.MarshalIndent(bundle, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	compareGolden(t, "bundle.golden", got)
+}
+
+// compareGolden compares got with testdata/name, or rewrites the file with -update
+func compareGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	path := filepath.Join("testdata", name)
+	got = append(got, '\n')
+	if *update {
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v (run go test with -update to create it)", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s differs from the golden file:\n%s", name, got)
+	}
+}
@@ -0,0 +1,209 @@
+package glucose
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/yourusername/diabetes-assistant/internal/models"
+)
+
+// Trend arrows, named as in Dexcom and Nightscout
+const (
+	ArrowDoubleUp      = "DoubleUp"
+	ArrowSingleUp      = "SingleUp"
+	ArrowFortyFiveUp   = "FortyFiveUp"
+	ArrowFlat          = "Flat"
+	ArrowFortyFiveDown = "FortyFiveDown"
+	ArrowSingleDown    = "SingleDown"
+	ArrowDoubleDown    = "DoubleDown"
+	ArrowNotComputable = "NotComputable"
+)
+
+const (
+	trendWindow   = 30 * time.Minute // Readings used for the rate of change
+	minTrendSpan  = 10 * time.Minute // Shortest span of readings a rate is computed from
+	maxReadingAge = 15 * time.Minute // Older readings don't describe the current glucose
+)
+
+// Arrow thresholds in mmol/L per minute, the usual 1, 2 and 3 mg/dL per minute
+const (
+	rateFortyFive = 1.0 / 18
+	rateSingle    = 2.0 / 18
+	rateDouble    = 3.0 / 18
+)
+
+// arrowSymbols are the arrows shown to users
+var arrowSymbols = map[string]string{
+	ArrowDoubleUp:      "⇈",
+	ArrowSingleUp:      "↑",
+	ArrowFortyFiveUp:   "↗",
+	ArrowFlat:          "→",
+	ArrowFortyFiveDown: "↘",
+	ArrowSingleDown:    "↓",
+	ArrowDoubleDown:    "⇊",
+	ArrowNotComputable: "?",
+}
+
+// trendPhrases describe an arrow in the user's language
+var trendPhrases = map[string]map[string]string{
+	"en": {
+		ArrowDoubleUp:      "rising fast",
+		ArrowSingleUp:      "rising",
+		ArrowFortyFiveUp:   "rising slowly",
+		ArrowFlat:          "steady",
+		ArrowFortyFiveDown: "falling slowly",
+		ArrowSingleDown:    "falling",
+		ArrowDoubleDown:    "falling fast",
+	},
+	"ru": {
+		ArrowDoubleUp:      "быстро растёт",
+		ArrowSingleUp:      "растёт",
+		ArrowFortyFiveUp:   "медленно растёт",
+		ArrowFlat:          "стабилен",
+		ArrowFortyFiveDown: "медленно падает",
+		ArrowSingleDown:    "падает",
+		ArrowDoubleDown:    "быстро падает",
+	},
+}
+
+// IsArrow reports whether s is a known trend arrow
+func IsArrow(s string) bool {
+	_, ok := arrowSymbols[s]
+	return ok
+}
+
+// Symbol returns the arrow character for a trend arrow
+func Symbol(arrow string) string {
+	if symbol, ok := arrowSymbols[arrow]; ok {
+		return symbol
+	}
+	return arrowSymbols[ArrowNotComputable]
+}
+
+// ArrowForRate returns the trend arrow for a rate of change in mmol/L per minute
+func ArrowForRate(rate float64) string {
+	switch {
+	case rate >= rateDouble:
+		return ArrowDoubleUp
+	case rate >= rateSingle:
+		return ArrowSingleUp
+	case rate >= rateFortyFive:
+		return ArrowFortyFiveUp
+	case rate > -rateFortyFive:
+		return ArrowFlat
+	case rate > -rateSingle:
+		return ArrowFortyFiveDown
+	case rate > -rateDouble:
+		return ArrowSingleDown
+	default:
+		return ArrowDoubleDown
+	}
+}
+
+// Latest returns the newest reading at or before at, and false if there is none within 15 minutes
+func Latest(readings []models.BloodSugarReading, at time.Time) (models.BloodSugarReading, bool) {
+	var latest models.BloodSugarReading
+	found := false
+	for _, reading := range readings {
+		if reading.Timestamp.After(at) {
+			continue
+		}
+		if !found || reading.Timestamp.After(latest.Timestamp) {
+			latest = reading
+			found = true
+		}
+	}
+
+	return latest, found && at.Sub(latest.Timestamp) <= maxReadingAge
+}
+
+// RateOfChange returns the glucose rate of change in mmol/L per minute at the given time. The rate the
+// CGM reported with the latest reading is used when there is one, otherwise it is the least-squares slope
+// of the readings of the preceding 30 minutes. ok is false without a reading in the last 15 minutes or
+// when the readings span less than 10 minutes.
+func RateOfChange(readings []models.BloodSugarReading, at time.Time) (rate float64, ok bool) {
+	latest, ok := Latest(readings, at)
+	if !ok {
+		return 0, false
+	}
+	if latest.RateOfChange != nil {
+		return *latest.RateOfChange, true
+	}
+
+	var recent []models.BloodSugarReading
+	for _, reading := range readings {
+		if !reading.Timestamp.Before(at.Add(-trendWindow)) && !reading.Timestamp.After(at) {
+			recent = append(recent, reading)
+		}
+	}
+	sort.Slice(recent, func(i, j int) bool {
+		return recent[i].Timestamp.Before(recent[j].Timestamp)
+	})
+	if recent[len(recent)-1].Timestamp.Sub(recent[0].Timestamp) < minTrendSpan {
+		return 0, false
+	}
+
+	// Least squares over minutes since the first reading
+	var sumX, sumY, sumXY, sumXX float64
+	for _, reading := range recent {
+		x := reading.Timestamp.Sub(recent[0].Timestamp).Minutes()
+		sumX += x
+		sumY += reading.Value
+		sumXY += x * reading.Value
+		sumXX += x * x
+	}
+	n := float64(len(recent))
+	return (n*sumXY - sumX*sumY) / (n*sumXX - sumX*sumX), true
+}
+
+// Annotate fills in the trend and rate of change of readings the CGM didn't provide them for,
+// computed from the readings before each one
+func Annotate(readings []models.BloodSugarReading) {
+	for i := range readings {
+		AnnotateReading(&readings[i], readings)
+	}
+}
+
+// AnnotateReading fills in the trend and rate of change of one reading from history, which may
+// include the reading itself. Without enough history the trend is NotComputable.
+func AnnotateReading(reading *models.BloodSugarReading, history []models.BloodSugarReading) {
+	if reading.Trend != "" && reading.RateOfChange != nil {
+		return
+	}
+
+	rate, ok := RateOfChange(history, reading.Timestamp)
+	if !ok {
+		if reading.Trend == "" {
+			reading.Trend = ArrowNotComputable
+		}
+		return
+	}
+
+	rate = math.Round(rate*1000) / 1000
+	if reading.RateOfChange == nil {
+		reading.RateOfChange = &rate
+	}
+	if reading.Trend == "" {
+		reading.Trend = ArrowForRate(rate)
+	}
+}
+
+// Describe returns a short status such as "6.5 and falling fast", or just the value when the
+// trend is unknown
+func Describe(value float64, arrow, locale string) string {
+	phrases, ok := trendPhrases[locale]
+	if !ok {
+		phrases = trendPhrases["en"]
+	}
+
+	phrase, ok := phrases[arrow]
+	if !ok {
+		return fmt.Sprintf("%.1f", value)
+	}
+	if locale == "ru" {
+		return fmt.Sprintf("%.1f и %s", value, phrase)
+	}
+	return fmt.Sprintf("%.1f and %s", value, phrase)
+}
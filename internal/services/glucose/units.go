@@ -0,0 +1,14 @@
+package glucose
+
+// MgdlPerMmol is the mg/dL in one mmol/L of glucose
+const MgdlPerMmol = 18.0182
+
+// ToMgdl converts a glucose value from mmol/L to mg/dL
+func ToMgdl(mmol float64) float64 {
+	return mmol * MgdlPerMmol
+}
+
+// FromMgdl converts a glucose value from mg/dL to mmol/L
+func FromMgdl(mgdl float64) float64 {
+	return mgdl / MgdlPerMmol
+}
@@ -0,0 +1,232 @@
+package hypo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/yourusername/diabetes-assistant/internal/models"
+	"github.com/yourusername/diabetes-assistant/internal/services/cob"
+	"github.com/yourusername/diabetes-assistant/internal/services/insulin"
+	"github.com/yourusername/diabetes-assistant/internal/services/prediction"
+	"github.com/yourusername/diabetes-assistant/internal/storage"
+)
+
+// Threshold is the glucose below which a reading is treated as a low, in mmol/L
+const Threshold = 3.9
+
+// RecheckAfter is when glucose should be checked again after treating a low
+const RecheckAfter = 15 * time.Minute
+
+const (
+	// rescueWindow is how soon carbs on board must be absorbed to help against a low
+	rescueWindow = 30 * time.Minute
+	// Bounds of a single treatment: some fast carbs are always needed below the threshold,
+	// and larger needs are met over several rechecks
+	minGrams = 5.0
+	maxGrams = 45.0
+	// ruleOf15Grams is the textbook treatment, used when the settings can't personalise it
+	ruleOf15Grams = 15.0
+)
+
+// Errors returned by Confirm
+var (
+	ErrNotFound         = errors.New("hypo treatment not found")
+	ErrAlreadyConfirmed = errors.New("hypo treatment already confirmed")
+)
+
+// RecheckFunc schedules the reminder to check glucose again after treating a low
+type RecheckFunc func(ctx context.Context, treatment models.HypoTreatment) error
+
+// Service suggests treatments for lows, schedules recheck reminders and logs confirmed treatments as meals
+type Service struct {
+	storage storage.Storage
+	recheck RecheckFunc
+}
+
+// NewService creates a new hypo treatment service. Recheck reminders are only logged until
+// SetRecheckFunc sets a scheduler.
+func NewService(storage storage.Storage) *Service {
+	return &Service{
+		storage: storage,
+		recheck: logRecheck,
+	}
+}
+
+// SetRecheckFunc sets how recheck reminders are scheduled
+func (s *Service) SetRecheckFunc(recheck RecheckFunc) {
+	s.recheck = recheck
+}
+
+// Advise suggests fast carbs for a low reading and saves the treatment with a recheck in 15 minutes
+func (s *Service) Advise(ctx context.Context, settings *models.Settings, userID string, reading models.BloodSugarReading) (*models.HypoTreatment, error) {
+	since := reading.Timestamp.Add(-prediction.HistoryWindow)
+	doses, err := s.storage.GetInsulinDoses(ctx, userID, since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch doses: %w", err)
+	}
+
+	meals, err := s.storage.GetMealRecords(ctx, userID, since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch meals: %w", err)
+	}
+
+	treatment := Suggest(reading.Value, settings, doses, meals, reading.Timestamp)
+	treatment.ID = uuid.New().String()
+	treatment.UserID = userID
+	if err := s.storage.SaveHypoTreatment(ctx, treatment); err != nil {
+		return nil, fmt.Errorf("failed to save hypo treatment: %w", err)
+	}
+
+	// The advice is still useful without the reminder, so only log the failure
+	if err := s.recheck(ctx, *treatment); err != nil {
+		log.Printf("Hypo rechecks: failed to schedule reminder for treatment %s: %v", treatment.ID, err)
+	}
+	return treatment, nil
+}
+
+// Suggest computes the grams of fast carbs that bring glucose from value back to the middle of the
+// target range: the carbs for the difference, plus carbs covering the insulin still on board, less
+// the carbs on board absorbed within 30 minutes (later ones arrive too late to help).
+// The result is kept between 5 and 45 grams; without sensitivity and carb ratio it is 15 grams.
+// Only meals that were eaten count, see cob.Eaten.
+func Suggest(value float64, settings *models.Settings, doses []models.InsulinDose, meals []models.MealRecord, at time.Time) *models.HypoTreatment {
+	meals = cob.Eaten(meals, doses)
+	treatment := &models.HypoTreatment{
+		CreatedAt:     at,
+		Status:        models.HypoTreatmentSuggested,
+		Glucose:       value,
+		TargetGlucose: round((settings.TargetMin+settings.TargetMax)/2, 1),
+		IOB:           insulin.OnBoard(doses, at, settings.IOBDuration),
+		COB:           round(cob.OnBoard(meals, settings, at), 1),
+		AbsorbingSoon: round(cob.Absorbed(meals, settings, at, at.Add(rescueWindow)), 1),
+		Grams:         ruleOf15Grams,
+		RecheckAt:     at.Add(RecheckAfter),
+	}
+
+	if carbSensitivity := settings.CarbSensitivityAt(at); carbSensitivity > 0 {
+		toTarget := (treatment.TargetGlucose - value) / carbSensitivity
+		treatment.IOBCarbs = round(treatment.IOB*settings.SensitivityAt(at)/carbSensitivity, 1)
+		grams := math.Ceil(toTarget + treatment.IOBCarbs - treatment.AbsorbingSoon)
+		treatment.Grams = math.Min(maxGrams, math.Max(minGrams, grams))
+	}
+
+	treatment.Message = treatmentMessage(settings.Locale, treatment)
+	return treatment
+}
+
+// ReadingReceived cancels the user's pending recheck reminders, a new reading is the recheck
+func (s *Service) ReadingReceived(ctx context.Context, userID string, at time.Time) error {
+	treatments, err := s.storage.GetHypoTreatments(ctx, userID, at.Add(-RecheckAfter))
+	if err != nil {
+		return fmt.Errorf("failed to fetch hypo treatments: %w", err)
+	}
+
+	for _, treatment := range treatments {
+		if !treatment.RecheckPending() || !treatment.CreatedAt.Before(at) {
+			continue
+		}
+		treatment.RecheckedAt = &at
+		if err := s.storage.SaveHypoTreatment(ctx, &treatment); err != nil {
+			return fmt.Errorf("failed to save hypo treatment: %w", err)
+		}
+	}
+	return nil
+}
+
+// Confirm records that the user ate the treatment and logs it as a fast meal. grams defaults
+// to the suggested amount and name to a generic one.
+func (s *Service) Confirm(ctx context.Context, userID, id string, grams float64, name string) (*models.HypoTreatment, *models.MealRecord, error) {
+	treatment, err := s.storage.GetHypoTreatment(ctx, id)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to fetch hypo treatment: %w", err)
+	}
+	if treatment == nil || treatment.UserID != userID {
+		return nil, nil, ErrNotFound
+	}
+	if treatment.Status == models.HypoTreatmentConfirmed {
+		return nil, nil, ErrAlreadyConfirmed
+	}
+
+	if grams <= 0 {
+		grams = treatment.Grams
+	}
+	if name == "" {
+		name = treatmentName(treatmentLocale(s.storage, userID))
+	}
+
+	now := time.Now()
+	meal := &models.MealRecord{
+		ID:         uuid.New().String(),
+		UserID:     userID,
+		Timestamp:  now,
+		Name:       name,
+		Carbs:      grams,
+		Confidence: 1,
+		Source:     models.MealSourceHypo,
+		Absorption: insulin.MealProfileFast,
+		EatenAt:    &now,
+	}
+	if err := s.storage.SaveMealRecord(ctx, meal); err != nil {
+		return nil, nil, fmt.Errorf("failed to save meal: %w", err)
+	}
+
+	treatment.Status = models.HypoTreatmentConfirmed
+	treatment.ConfirmedAt = &now
+	treatment.ConfirmedGrams = grams
+	treatment.MealID = meal.ID
+	if err := s.storage.SaveHypoTreatment(ctx, treatment); err != nil {
+		return nil, nil, fmt.Errorf("failed to save hypo treatment: %w", err)
+	}
+	return treatment, meal, nil
+}
+
+// logRecheck is the default recheck scheduler
+func logRecheck(ctx context.Context, treatment models.HypoTreatment) error {
+	log.Printf("Hypo rechecks: user %s should check glucose again at %s (%.1f mmol/L)",
+		treatment.UserID, treatment.RecheckAt.Format(time.Kitchen), treatment.Glucose)
+	return nil
+}
+
+// treatmentLocale returns the user's language, the default one if the user can't be loaded
+func treatmentLocale(storage storage.Storage, userID string) string {
+	user, err := storage.GetUser(userID)
+	if err != nil || user == nil || user.Settings.Locale == "" {
+		return models.DefaultLocale
+	}
+	return user.Settings.Locale
+}
+
+// treatmentName is the meal name of a confirmed treatment
+func treatmentName(locale string) string {
+	if locale == "en" {
+		return "Hypo treatment"
+	}
+	return "Купирование гипогликемии"
+}
+
+// treatmentMessage explains the treatment in the user's language
+func treatmentMessage(locale string, treatment *models.HypoTreatment) string {
+	if locale == "en" {
+		message := fmt.Sprintf("Low blood sugar: eat %.0f g of fast carbs (glucose tablets, juice or regular soda) and check again in 15 minutes.", treatment.Grams)
+		if treatment.IOBCarbs > 0 {
+			message += fmt.Sprintf(" This includes %.0f g for %.1f U of insulin still active.", treatment.IOBCarbs, treatment.IOB)
+		}
+		return message
+	}
+
+	message := fmt.Sprintf("Низкий сахар: съешьте %.0f г быстрых углеводов (таблетки глюкозы, сок или сладкий напиток) и проверьте сахар через 15 минут.", treatment.Grams)
+	if treatment.IOBCarbs > 0 {
+		message += fmt.Sprintf(" Учтено %.0f г на %.1f ЕД ещё активного инсулина.", treatment.IOBCarbs, treatment.IOB)
+	}
+	return message
+}
+
+func round(x float64, decimals int) float64 {
+	pow := math.Pow(10, float64(decimals))
+	return math.Round(x*pow) / pow
+}
@@ -0,0 +1,117 @@
+package imports
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yourusername/diabetes-assistant/internal/models"
+	"github.com/yourusername/diabetes-assistant/internal/services/glucose"
+)
+
+// Column names of Dexcom Clarity exports, the glucose ones are followed by the unit,
+// e.g. "Glucose Value (mg/dL)"
+const (
+	dexcomTimestampColumn = "Timestamp"
+	dexcomEventTypeColumn = "Event Type"
+	dexcomGlucoseColumn   = "Glucose Value"
+	dexcomRateColumn      = "Glucose Rate of Change"
+)
+
+// dexcomEGV is the event type of sensor readings; calibrations, insulin, carbs and alerts are skipped
+const dexcomEGV = "EGV"
+
+// Sensor readings outside the Dexcom range are exported as "Low" and "High", they are imported
+// at the range limits
+const (
+	dexcomLowMgdl  = 40
+	dexcomHighMgdl = 400
+)
+
+var dexcomTimestampLayouts = []string{
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04",
+}
+
+func isDexcomHeader(columns map[string]int) bool {
+	_, _, hasTimestamp := findColumn(columns, dexcomTimestampColumn)
+	_, _, hasEventType := findColumn(columns, dexcomEventTypeColumn)
+	_, _, hasGlucose := findColumn(columns, dexcomGlucoseColumn)
+	return hasTimestamp && hasEventType && hasGlucose
+}
+
+// parseDexcom reads the sensor readings of a Dexcom Clarity export. The rows with patient and
+// device details that follow the header have no timestamp and are skipped.
+func parseDexcom(reader *csv.Reader, columns map[string]int, loc *time.Location, stats *ImportStats) ([]models.BloodSugarReading, error) {
+	_, timestampIndex, _ := findColumn(columns, dexcomTimestampColumn)
+	_, eventTypeIndex, _ := findColumn(columns, dexcomEventTypeColumn)
+	glucoseName, glucoseIndex, _ := findColumn(columns, dexcomGlucoseColumn)
+	rateName, rateIndex, _ := findColumn(columns, dexcomRateColumn)
+	glucoseMgdl := isMgdl(glucoseName)
+	rateMgdl := isMgdl(rateName)
+
+	var readings []models.BloodSugarReading
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) {
+				stats.Rows++
+				stats.rowError(parseErr.Line, "unreadable row: %v", parseErr.Err)
+				continue
+			}
+			return nil, fmt.Errorf("failed to read file: %w", err)
+		}
+		stats.Rows++
+		line, _ := reader.FieldPos(0)
+
+		if field(record, eventTypeIndex) != dexcomEGV {
+			stats.Skipped++
+			continue
+		}
+
+		timestamp, err := parseTimestamp(field(record, timestampIndex), dexcomTimestampLayouts, loc)
+		if err != nil {
+			stats.rowError(line, "%v", err)
+			continue
+		}
+
+		var value float64
+		switch raw := field(record, glucoseIndex); strings.ToLower(raw) {
+		case "low":
+			value = round(glucose.FromMgdl(dexcomLowMgdl), 1)
+		case "high":
+			value = round(glucose.FromMgdl(dexcomHighMgdl), 1)
+		default:
+			value, err = parseGlucose(raw, glucoseMgdl)
+			if err != nil {
+				stats.rowError(line, "%v", err)
+				continue
+			}
+		}
+
+		reading := models.BloodSugarReading{
+			Value:     value,
+			Timestamp: timestamp,
+			Source:    SourceDexcom,
+		}
+		if rate, err := strconv.ParseFloat(field(record, rateIndex), 64); err == nil {
+			if rateMgdl {
+				rate = glucose.FromMgdl(rate)
+			}
+			rate = round(rate, 3)
+			reading.RateOfChange = &rate
+			reading.Trend = glucose.ArrowForRate(rate)
+		}
+		readings = append(readings, reading)
+	}
+	return readings, nil
+}
@@ -0,0 +1,268 @@
+package imports
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yourusername/diabetes-assistant/internal/models"
+	"github.com/yourusername/diabetes-assistant/internal/services/glucose"
+	"github.com/yourusername/diabetes-assistant/internal/storage"
+)
+
+// Export formats that can be imported
+const (
+	FormatLibreView = "libreview"
+	FormatDexcom    = "dexcom"
+)
+
+// Sources of imported readings
+const (
+	SourceLibreViewHistoric = "libreview_historic" // Sensor reading stored every 15 minutes
+	SourceLibreViewScan     = "libreview_scan"     // Sensor scanned by the user
+	SourceLibreViewStrip    = "libreview_strip"    // Fingerstick with the reader's strip port
+	SourceDexcom            = "dexcom"             // Dexcom estimated glucose value
+)
+
+const (
+	// headerSearchRows is how far into the file the header row is looked for; exports start
+	// with a few rows of patient and device details
+	headerSearchRows = 20
+	// batchSize is the number of readings saved per database write
+	batchSize = 1000
+	// maxRowErrors is how many row errors are reported, the rest are only counted
+	maxRowErrors = 20
+	// maxGlucose is the highest plausible reading in mmol/L, higher values are treated as errors
+	maxGlucose = 40.0
+)
+
+// ErrUnknownFormat is returned for files that are neither a LibreView nor a Dexcom Clarity export
+var ErrUnknownFormat = errors.New("unknown file format, expected a LibreView or Dexcom Clarity CSV export")
+
+// RowError describes a row that couldn't be imported
+type RowError struct {
+	Line    int    `json:"line"`
+	Message string `json:"message"`
+}
+
+// ImportStats summarizes an import
+type ImportStats struct {
+	Format     string     `json:"format"`     // libreview or dexcom
+	Rows       int        `json:"rows"`       // Data rows read
+	Imported   int        `json:"imported"`   // Readings saved
+	Skipped    int        `json:"skipped"`    // Rows that aren't glucose readings, and duplicates
+	Duplicates int        `json:"duplicates"` // Readings already stored, or repeated in the file
+	Errored    int        `json:"errored"`    // Rows with an unreadable time or value
+	Errors     []RowError `json:"errors,omitempty"`
+}
+
+// rowError counts a row that couldn't be imported and keeps its description
+func (s *ImportStats) rowError(line int, format string, args ...interface{}) {
+	s.Errored++
+	if len(s.Errors) < maxRowErrors {
+		s.Errors = append(s.Errors, RowError{Line: line, Message: fmt.Sprintf(format, args...)})
+	}
+}
+
+// Service imports readings from CGM vendor exports
+type Service struct {
+	storage storage.Storage
+}
+
+// NewService creates a new import service
+func NewService(storage storage.Storage) *Service {
+	return &Service{storage: storage}
+}
+
+// Import reads a LibreView or Dexcom Clarity CSV export and saves its glucose readings for the
+// user. The exports hold the device's local time without a zone, so timestamps are read in loc.
+// Values in mg/dL are converted to mmol/L. A reading in the same minute as a stored one, or as
+// an earlier row of the file, counts as a duplicate.
+func (s *Service) Import(ctx context.Context, userID string, r io.Reader, loc *time.Location) (*ImportStats, error) {
+	reader := csv.NewReader(bufio.NewReader(r))
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+
+	format, header, err := findHeader(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &ImportStats{Format: format}
+	var readings []models.BloodSugarReading
+	if format == FormatLibreView {
+		readings, err = parseLibreView(reader, header, loc, stats)
+	} else {
+		readings, err = parseDexcom(reader, header, loc, stats)
+	}
+	if err != nil {
+		return stats, err
+	}
+
+	saved, duplicates, err := s.Save(ctx, userID, readings)
+	stats.Imported += saved
+	stats.Duplicates += duplicates
+	stats.Skipped += duplicates
+	return stats, err
+}
+
+// Save saves readings for the user, creating the user if they don't exist yet. A reading in the
+// same minute as a stored one, or as an earlier one of readings, is a duplicate and skipped.
+// It returns how many readings were saved and how many were duplicates.
+func (s *Service) Save(ctx context.Context, userID string, readings []models.BloodSugarReading) (int, int, error) {
+	if len(readings) == 0 {
+		return 0, 0, nil
+	}
+	if err := s.ensureUser(userID); err != nil {
+		return 0, 0, err
+	}
+	unique, err := s.dedupe(ctx, userID, readings)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	saved := 0
+	for start := 0; start < len(unique); start += batchSize {
+		end := min(start+batchSize, len(unique))
+		if err := s.storage.AddBloodSugarReadings(ctx, userID, unique[start:end]); err != nil {
+			return saved, len(readings) - len(unique), fmt.Errorf("failed to save readings: %w", err)
+		}
+		saved += end - start
+	}
+	return saved, len(readings) - len(unique), nil
+}
+
+// dedupe drops readings in the same minute as a stored reading or an earlier one of the import
+func (s *Service) dedupe(ctx context.Context, userID string, readings []models.BloodSugarReading) ([]models.BloodSugarReading, error) {
+	from, to := readings[0].Timestamp, readings[0].Timestamp
+	for _, reading := range readings {
+		if reading.Timestamp.Before(from) {
+			from = reading.Timestamp
+		}
+		if reading.Timestamp.After(to) {
+			to = reading.Timestamp
+		}
+	}
+
+	seen := make(map[int64]bool)
+	err := s.storage.StreamBloodSugarReadings(ctx, userID, from.Truncate(time.Minute), to.Add(time.Minute), func(reading models.BloodSugarReading) error {
+		seen[minuteKey(reading.Timestamp)] = true
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch stored readings: %w", err)
+	}
+
+	var unique []models.BloodSugarReading
+	for _, reading := range readings {
+		key := minuteKey(reading.Timestamp)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		unique = append(unique, reading)
+	}
+	return unique, nil
+}
+
+// ensureUser creates the user with default settings if they don't exist yet
+func (s *Service) ensureUser(userID string) error {
+	user, err := s.storage.GetUser(userID)
+	if err != nil {
+		return fmt.Errorf("failed to fetch user: %w", err)
+	}
+	if user != nil {
+		return nil
+	}
+
+	user = &models.User{
+		UserID:             userID,
+		Settings:           *models.CreateDefaultSettings(userID),
+		BloodSugarReadings: []models.BloodSugarReading{},
+	}
+	if err := s.storage.CreateUser(user); err != nil {
+		return fmt.Errorf("failed to create user: %w", err)
+	}
+	return nil
+}
+
+// findHeader skips the patient and device details at the top of the file and returns the
+// format and the column indexes of the header row by name
+func findHeader(reader *csv.Reader) (string, map[string]int, error) {
+	for i := 0; i < headerSearchRows; i++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to read file: %w", err)
+		}
+
+		columns := make(map[string]int, len(record))
+		for j, name := range record {
+			columns[strings.TrimSpace(strings.TrimPrefix(name, "\ufeff"))] = j
+		}
+		if isLibreViewHeader(columns) {
+			return FormatLibreView, columns, nil
+		}
+		if isDexcomHeader(columns) {
+			return FormatDexcom, columns, nil
+		}
+	}
+	return "", nil, ErrUnknownFormat
+}
+
+// field returns a trimmed field of the record, "" if the record is too short
+func field(record []string, i int) string {
+	if i < 0 || i >= len(record) {
+		return ""
+	}
+	return strings.TrimSpace(record[i])
+}
+
+// parseGlucose reads a glucose value in the given unit and returns it in mmol/L
+func parseGlucose(s string, mgdl bool) (float64, error) {
+	value, err := strconv.ParseFloat(strings.Replace(s, ",", ".", 1), 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid glucose value %q", s)
+	}
+	if mgdl {
+		value = glucose.FromMgdl(value)
+	}
+	if value <= 0 || value > maxGlucose {
+		return 0, fmt.Errorf("glucose value %q out of range", s)
+	}
+	return round(value, 1), nil
+}
+
+// parseTimestamp reads a local timestamp in the first layout that fits
+func parseTimestamp(s string, layouts []string, loc *time.Location) (time.Time, error) {
+	for _, layout := range layouts {
+		if t, err := time.ParseInLocation(layout, s, loc); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid timestamp %q", s)
+}
+
+// isMgdl reports whether a column name gives its unit as mg/dL
+func isMgdl(column string) bool {
+	column = strings.ToLower(column)
+	return strings.Contains(column, "mg/dl") || strings.Contains(column, "мг/дл")
+}
+
+func minuteKey(t time.Time) int64 {
+	return t.Unix() / 60
+}
+
+func round(x float64, decimals int) float64 {
+	pow := math.Pow(10, float64(decimals))
+	return math.Round(x*pow) / pow
+}
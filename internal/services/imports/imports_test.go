@@ -0,0 +1,162 @@
+package imports
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yourusername/diabetes-assistant/internal/models"
+	"github.com/yourusername/diabetes-assistant/internal/services/glucose"
+	"github.com/yourusername/diabetes-assistant/internal/storage"
+)
+
+var (
+	moscow  = time.FixedZone("MSK", 3*60*60)
+	newYork = time.FixedZone("EDT", -4*60*60)
+)
+
+// wantReading is an imported reading, the time in UTC
+type wantReading struct {
+	at     string
+	value  float64
+	source string
+	trend  string
+}
+
+func TestImport(t *testing.T) {
+	tests := []struct {
+		file     string
+		loc      *time.Location
+		stats    ImportStats
+		readings []wantReading
+	}{
+		{
+			// Day-first 24-hour times, decimal commas, a scan in the same minute as a historic
+			// reading, an insulin row, an implausible value and an unreadable time
+			file:  "libreview_mmol.csv",
+			loc:   moscow,
+			stats: ImportStats{Format: FormatLibreView, Rows: 7, Imported: 3, Skipped: 2, Duplicates: 1, Errored: 2},
+			readings: []wantReading{
+				{at: "2024-05-14T05:00:00Z", value: 5.4, source: SourceLibreViewHistoric},
+				{at: "2024-05-14T05:15:00Z", value: 6.1, source: SourceLibreViewHistoric},
+				{at: "2024-05-14T05:30:00Z", value: 5.9, source: SourceLibreViewStrip},
+			},
+		},
+		{
+			// US export: month-first 12-hour times in mg/dL, a zero and an implausible value
+			file:  "libreview_mgdl.csv",
+			loc:   newYork,
+			stats: ImportStats{Format: FormatLibreView, Rows: 4, Imported: 2, Errored: 2},
+			readings: []wantReading{
+				{at: "2024-05-14T12:00:00Z", value: 5.4, source: SourceLibreViewHistoric},
+				{at: "2024-05-14T17:05:00Z", value: 10, source: SourceLibreViewScan},
+			},
+		},
+		{
+			// A byte order mark, patient and device rows after the header, Low and High at the
+			// range limits, a reading in the same minute as another, a carbs event, an implausible
+			// and an unreadable value
+			file:  "dexcom_mgdl.csv",
+			loc:   time.UTC,
+			stats: ImportStats{Format: FormatDexcom, Rows: 10, Imported: 3, Skipped: 5, Duplicates: 1, Errored: 2},
+			readings: []wantReading{
+				{at: "2024-05-14T08:00:00Z", value: 5.4, source: SourceDexcom, trend: glucose.ArrowFortyFiveUp},
+				{at: "2024-05-14T08:05:00Z", value: 2.2, source: SourceDexcom},
+				{at: "2024-05-14T08:10:00Z", value: 22.2, source: SourceDexcom},
+			},
+		},
+		{
+			// mmol/L values and rates, timestamps with a space and without seconds
+			file:  "dexcom_mmol.csv",
+			loc:   moscow,
+			stats: ImportStats{Format: FormatDexcom, Rows: 3, Imported: 1, Skipped: 1, Errored: 1},
+			readings: []wantReading{
+				{at: "2024-05-14T05:00:00Z", value: 5.4, source: SourceDexcom, trend: glucose.ArrowSingleDown},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			store := storage.NewInMemoryStorage()
+			stats := importFile(t, NewService(store), tt.file, tt.loc)
+
+			if len(stats.Errors) != stats.Errored {
+				t.Errorf("%d row errors described, %d counted: %+v", len(stats.Errors), stats.Errored, stats.Errors)
+			}
+			stats.Errors = nil
+			if !reflect.DeepEqual(*stats, tt.stats) {
+				t.Errorf("stats = %+v, want %+v", *stats, tt.stats)
+			}
+
+			readings := storedReadings(t, store)
+			if len(readings) != len(tt.readings) {
+				t.Fatalf("got %d readings, want %d: %+v", len(readings), len(tt.readings), readings)
+			}
+			for i, want := range tt.readings {
+				got := readings[i]
+				if at := got.Timestamp.UTC().Format(time.RFC3339); at != want.at || got.Value != want.value || got.Source != want.source || got.Trend != want.trend {
+					t.Errorf("reading %d = %s %.1f %s %q, want %s %.1f %s %q", i, at, got.Value, got.Source, got.Trend, want.at, want.value, want.source, want.trend)
+				}
+			}
+		})
+	}
+}
+
+func TestImportSkipsStoredReadings(t *testing.T) {
+	store := storage.NewInMemoryStorage()
+	service := NewService(store)
+
+	importFile(t, service, "libreview_mmol.csv", moscow)
+	stats := importFile(t, service, "libreview_mmol.csv", moscow)
+	if stats.Imported != 0 || stats.Duplicates != 4 {
+		t.Errorf("second import: %+v, want every reading as a duplicate", stats)
+	}
+	if readings := storedReadings(t, store); len(readings) != 3 {
+		t.Errorf("got %d readings after importing twice, want 3", len(readings))
+	}
+}
+
+func TestImportUnknownFormat(t *testing.T) {
+	csv := "Date,Time,Value\n2024-05-14,08:00,5.4\n"
+	_, err := NewService(storage.NewInMemoryStorage()).Import(context.Background(), "user1", strings.NewReader(csv), time.UTC)
+	if !errors.Is(err, ErrUnknownFormat) {
+		t.Errorf("err = %v, want ErrUnknownFormat", err)
+	}
+}
+
+// importFile imports testdata/name for user1
+func importFile(t *testing.T, service *Service, name string, loc *time.Location) *ImportStats {
+	t.Helper()
+	file, err := os.Open(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	stats, err := service.Import(context.Background(), "user1", file, loc)
+	if err != nil {
+		t.Fatalf("Import: %v", err)
+	}
+	return stats
+}
+
+// storedReadings returns the readings of user1, oldest first
+func storedReadings(t *testing.T, store storage.Storage) []models.BloodSugarReading {
+	t.Helper()
+	var readings []models.BloodSugarReading
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	err := store.StreamBloodSugarReadings(context.Background(), "user1", from, from.AddDate(1, 0, 0), func(reading models.BloodSugarReading) error {
+		readings = append(readings, reading)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return readings
+}
@@ -0,0 +1,130 @@
+package imports
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/yourusername/diabetes-assistant/internal/models"
+)
+
+// LibreView record types that hold glucose readings; the others are insulin, food, notes and
+// device events
+const (
+	libreHistoric = "0"
+	libreScan     = "1"
+	libreStrip    = "2"
+)
+
+// Column names of English LibreView exports, the glucose ones are followed by the unit,
+// e.g. "Historic Glucose mmol/L"
+const (
+	libreTimestampColumn  = "Device Timestamp"
+	libreRecordTypeColumn = "Record Type"
+	libreHistoricColumn   = "Historic Glucose"
+	libreScanColumn       = "Scan Glucose"
+	libreStripColumn      = "Strip Glucose"
+)
+
+// libreTimestampLayouts are the device timestamp formats of the LibreView regions: US exports
+// use a 12-hour clock with the month first, the others a 24-hour clock with the day first
+var libreTimestampLayouts = []string{
+	"01-02-2006 03:04 PM",
+	"01/02/2006 03:04 PM",
+	"02-01-2006 15:04",
+	"02.01.2006 15:04",
+	"02/01/2006 15:04",
+	"2006-01-02 15:04",
+	"2006-01-02 15:04:05",
+}
+
+// libreColumn is a glucose column and its unit
+type libreColumn struct {
+	index int
+	mgdl  bool
+}
+
+func isLibreViewHeader(columns map[string]int) bool {
+	_, _, hasTimestamp := findColumn(columns, libreTimestampColumn)
+	_, _, hasRecordType := findColumn(columns, libreRecordTypeColumn)
+	return hasTimestamp && hasRecordType
+}
+
+// parseLibreView reads the historic, scan and strip readings of a LibreView export
+func parseLibreView(reader *csv.Reader, columns map[string]int, loc *time.Location, stats *ImportStats) ([]models.BloodSugarReading, error) {
+	_, timestampIndex, _ := findColumn(columns, libreTimestampColumn)
+	_, recordTypeIndex, _ := findColumn(columns, libreRecordTypeColumn)
+	glucoseColumns := map[string]libreColumn{
+		libreHistoric: glucoseColumn(columns, libreHistoricColumn),
+		libreScan:     glucoseColumn(columns, libreScanColumn),
+		libreStrip:    glucoseColumn(columns, libreStripColumn),
+	}
+	sources := map[string]string{
+		libreHistoric: SourceLibreViewHistoric,
+		libreScan:     SourceLibreViewScan,
+		libreStrip:    SourceLibreViewStrip,
+	}
+
+	var readings []models.BloodSugarReading
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) {
+				stats.Rows++
+				stats.rowError(parseErr.Line, "unreadable row: %v", parseErr.Err)
+				continue
+			}
+			return nil, fmt.Errorf("failed to read file: %w", err)
+		}
+		stats.Rows++
+		line, _ := reader.FieldPos(0)
+
+		recordType := field(record, recordTypeIndex)
+		column, ok := glucoseColumns[recordType]
+		if !ok {
+			stats.Skipped++
+			continue
+		}
+
+		timestamp, err := parseTimestamp(field(record, timestampIndex), libreTimestampLayouts, loc)
+		if err != nil {
+			stats.rowError(line, "%v", err)
+			continue
+		}
+		value, err := parseGlucose(field(record, column.index), column.mgdl)
+		if err != nil {
+			stats.rowError(line, "%v", err)
+			continue
+		}
+
+		readings = append(readings, models.BloodSugarReading{
+			Value:     value,
+			Timestamp: timestamp,
+			Source:    sources[recordType],
+		})
+	}
+	return readings, nil
+}
+
+// glucoseColumn finds a glucose column and its unit. A missing column makes its rows errors.
+func glucoseColumn(columns map[string]int, prefix string) libreColumn {
+	name, index, _ := findColumn(columns, prefix)
+	return libreColumn{index: index, mgdl: isMgdl(name)}
+}
+
+// findColumn returns the column whose name starts with prefix
+func findColumn(columns map[string]int, prefix string) (string, int, bool) {
+	for name, i := range columns {
+		if strings.HasPrefix(strings.ToLower(name), strings.ToLower(prefix)) {
+			return name, i, true
+		}
+	}
+	return "", -1, false
+}
@@ -0,0 +1,64 @@
+package insulin
+
+import (
+	"math"
+
+	"github.com/yourusername/diabetes-assistant/internal/models"
+)
+
+// minExtendedFPU is the smallest amount of fat and protein worth an extended bolus
+const minExtendedFPU = 1.0
+
+// CalculateFPU returns the fat-protein units of a meal (Warsaw method):
+// one FPU is 100 kcal from fat (9 kcal/g) and protein (4 kcal/g)
+func CalculateFPU(fatGrams, proteinGrams float64) float64 {
+	return (fatGrams*9 + proteinGrams*4) / 100
+}
+
+// ExtendedBolusHours returns how long the FPU insulin is delivered over:
+// 3 hours for 1 FPU, 4 for 2, 5 for 3 and 8 hours for 4 FPU and more
+func ExtendedBolusHours(fpu float64) float64 {
+	switch {
+	case fpu < 2:
+		return 3
+	case fpu < 3:
+		return 4
+	case fpu < 4:
+		return 5
+	default:
+		return 8
+	}
+}
+
+// CalculateExtendedBolus splits a meal dose into an immediate part (immediateInsulin, the carb and
+// correction dose) and an extended part for the meal's fat and protein. Each FPU is dosed like
+// fpuFactor grams of carbohydrate. Returns nil when the meal has less than one FPU.
+func CalculateExtendedBolus(immediateInsulin, fatGrams, proteinGrams, fpuFactor, carbRatio, timeCoefficient float64) *models.ExtendedBolus {
+	fpu := CalculateFPU(fatGrams, proteinGrams)
+	if fpu < minExtendedFPU || carbRatio <= 0 {
+		return nil
+	}
+
+	if fpuFactor <= 0 {
+		fpuFactor = models.DefaultFPUFactor
+	}
+
+	extended := CalculateMealInsulin(fpu*fpuFactor, carbRatio, timeCoefficient)
+	total := immediateInsulin + extended
+
+	return &models.ExtendedBolus{
+		FPU:              round(fpu, 1),
+		FPUFactor:        fpuFactor,
+		ImmediateInsulin: immediateInsulin,
+		ExtendedInsulin:  extended,
+		DurationHours:    ExtendedBolusHours(fpu),
+		TotalInsulin:     total,
+		ImmediatePercent: int(math.Round(immediateInsulin / total * 100)),
+	}
+}
+
+// round rounds x to the given number of decimals
+func round(x float64, decimals int) float64 {
+	pow := math.Pow(10, float64(decimals))
+	return math.Round(x*pow) / pow
+}
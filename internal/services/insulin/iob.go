@@ -0,0 +1,55 @@
+package insulin
+
+import (
+	"math"
+	"time"
+
+	"github.com/yourusername/diabetes-assistant/internal/models"
+)
+
+const (
+	// insulinPeakMinutes is when rapid-acting insulin works hardest
+	insulinPeakMinutes = 75.0
+	// minActionMinutes keeps the activity curve valid, it needs an action time over twice the peak
+	minActionMinutes = 180.0
+)
+
+// RemainingFraction returns the share of a dose still active minutesSince after injection,
+// using the exponential insulin activity curve of oref0 and Loop with the given action time in hours
+func RemainingFraction(minutesSince, actionHours float64) float64 {
+	if minutesSince <= 0 {
+		return 1
+	}
+
+	td := math.Max(actionHours*60, minActionMinutes)
+	if minutesSince >= td {
+		return 0
+	}
+
+	tp := insulinPeakMinutes
+	tau := tp * (1 - tp/td) / (1 - 2*tp/td)
+	a := 2 * tau / td
+	s := 1 / (1 - a + (1+a)*math.Exp(-td/tau))
+	t := minutesSince
+
+	remaining := 1 - s*(1-a)*((t*t/(tau*td*(1-a))-t/tau-1)*math.Exp(-t/tau)+1)
+	return math.Min(1, math.Max(0, remaining))
+}
+
+// IsRapidActing reports whether a dose counts towards insulin on board. Long-acting basal
+// injections work flat over a day and are part of the baseline rather than IOB.
+func IsRapidActing(dose models.InsulinDose) bool {
+	return dose.Type != models.DoseTypeBasal
+}
+
+// OnBoard returns the units of rapid-acting insulin still active at the given time
+func OnBoard(doses []models.InsulinDose, at time.Time, actionHours float64) float64 {
+	total := 0.0
+	for _, dose := range doses {
+		if !IsRapidActing(dose) || dose.Timestamp.After(at) {
+			continue
+		}
+		total += dose.Units * RemainingFraction(at.Sub(dose.Timestamp).Minutes(), actionHours)
+	}
+	return round(total, 2)
+}
@@ -0,0 +1,149 @@
+package insulin
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/yourusername/diabetes-assistant/internal/models"
+	"github.com/yourusername/diabetes-assistant/internal/services/glucose"
+)
+
+// Meal glycemic profiles, how fast a meal raises blood sugar
+const (
+	MealProfileFast   = "fast"   // Mostly carbohydrates
+	MealProfileMedium = "medium" // Mixed meal, or fat and protein are unknown
+	MealProfileSlow   = "slow"   // Fat and protein delay absorption
+)
+
+// Pre-bolus actions
+const (
+	PreBolusWait      = "wait"       // Inject, then wait WaitMinutes before eating
+	PreBolusWithMeal  = "with_meal"  // Inject right before eating
+	PreBolusAfterMeal = "after_meal" // Eat first, inject after the meal
+)
+
+const (
+	hypoThreshold   = 3.9   // mmol/L
+	trendLookahead  = 20.0  // Minutes ahead the trend is projected to spot a coming low
+	fallingFast     = -0.1  // mmol/L per minute
+	fallingSlowly   = -0.03 // mmol/L per minute
+	rising          = 0.06  // mmol/L per minute
+	maxPreBolusWait = 30    // Minutes
+)
+
+// baseWaitMinutes is the pre-bolus for each profile when glucose is in range and stable
+var baseWaitMinutes = map[string]int{
+	MealProfileFast:   15,
+	MealProfileMedium: 10,
+	MealProfileSlow:   0,
+}
+
+// ClassifyMeal estimates a meal's glycemic profile from its macronutrients.
+// Meals without fat and protein estimates are treated as medium.
+func ClassifyMeal(carbs, fat, protein float64) string {
+	if fat == 0 && protein == 0 {
+		return MealProfileMedium
+	}
+
+	fpu := CalculateFPU(fat, protein)
+	switch {
+	case fpu >= 2 || fpu*10 >= carbs:
+		return MealProfileSlow
+	case fpu < 1:
+		return MealProfileFast
+	default:
+		return MealProfileMedium
+	}
+}
+
+// RecommendPreBolus recommends when to inject for a meal with the given profile. Low or falling
+// glucose moves the injection to the meal or after it; high or rising glucose lengthens the wait.
+func RecommendPreBolus(readings []models.BloodSugarReading, now time.Time, profile string, targetMin, targetMax float64, locale string) *models.PreBolusAdvice {
+	advice := &models.PreBolusAdvice{MealProfile: profile}
+
+	latest, ok := glucose.Latest(readings, now)
+	if !ok {
+		// Without a current reading a pre-bolus could run into an unnoticed low
+		advice.Action = PreBolusWithMeal
+		advice.Message = preBolusMessage(locale, "no_data", 0)
+		return advice
+	}
+
+	current := latest.Value
+	slope, trendKnown := glucose.RateOfChange(readings, now)
+	advice.Glucose = current
+	advice.Trend = round(slope, 3)
+	advice.TrendKnown = trendKnown
+
+	projected := current
+	if trendKnown {
+		projected += slope * trendLookahead
+	}
+
+	switch {
+	case current < hypoThreshold || projected < hypoThreshold:
+		advice.Action = PreBolusAfterMeal
+		advice.Message = preBolusMessage(locale, "low", 0)
+		return advice
+	case current < targetMin || slope <= fallingFast:
+		advice.Action = PreBolusWithMeal
+		advice.Message = preBolusMessage(locale, "falling", 0)
+		return advice
+	}
+
+	wait := baseWaitMinutes[profile]
+	if current > targetMax+4 {
+		wait += 20
+	} else if current > targetMax {
+		wait += 10
+	}
+	if trendKnown && slope >= rising {
+		wait += 5
+	} else if trendKnown && slope <= fallingSlowly {
+		wait -= 5
+	}
+	wait = int(math.Min(float64(wait), maxPreBolusWait))
+
+	if wait <= 0 {
+		advice.Action = PreBolusWithMeal
+		advice.Message = preBolusMessage(locale, "slow_meal", 0)
+		return advice
+	}
+
+	advice.Action = PreBolusWait
+	advice.WaitMinutes = wait
+	advice.Message = preBolusMessage(locale, "wait", wait)
+	return advice
+}
+
+// preBolusMessage returns the advice text for a reason in the user's language
+func preBolusMessage(locale, reason string, wait int) string {
+	if locale == "en" {
+		switch reason {
+		case "no_data":
+			return "No recent CGM reading: inject right before eating."
+		case "low":
+			return "Glucose is low or about to be: treat it, eat first and inject after the meal."
+		case "falling":
+			return "Glucose is below target or falling: inject right before eating, don't wait."
+		case "slow_meal":
+			return "This meal raises glucose slowly: inject right before eating."
+		default:
+			return fmt.Sprintf("Inject %d minutes before eating.", wait)
+		}
+	}
+
+	switch reason {
+	case "no_data":
+		return "Нет свежих данных CGM: сделайте укол непосредственно перед едой."
+	case "low":
+		return "Сахар низкий или скоро будет низким: купируйте гипогликемию, сначала поешьте и сделайте укол после еды."
+	case "falling":
+		return "Сахар ниже цели или падает: сделайте укол непосредственно перед едой, не выжидайте."
+	case "slow_meal":
+		return "Эта еда повышает сахар медленно: сделайте укол непосредственно перед едой."
+	default:
+		return fmt.Sprintf("Сделайте укол за %d минут до еды.", wait)
+	}
+}
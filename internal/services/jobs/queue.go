@@ -0,0 +1,308 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/yourusername/diabetes-assistant/internal/models"
+	"github.com/yourusername/diabetes-assistant/internal/services/analysis"
+	"github.com/yourusername/diabetes-assistant/internal/storage"
+)
+
+const (
+	queueSize   = 256             // Jobs waiting for a worker before Submit rejects new ones
+	jobTimeout  = 2 * time.Minute // Longest a single analysis may take
+	maxAttempts = 3               // Jobs interrupted by restarts this often are given up
+
+	// leaseDuration is how long a running job stays with its worker without a heartbeat.
+	// Workers renew the lease every leaseRenewal, and a job whose lease ran out is requeued,
+	// so a job interrupted by a crashed replica is retried after about this long.
+	leaseDuration = time.Minute
+	leaseRenewal  = leaseDuration / 3
+)
+
+// ErrQueueFull is returned by Submit when every worker is busy and the queue is full
+var ErrQueueFull = errors.New("analysis queue is full, try again later")
+
+// Queue runs food analysis jobs on a bounded pool of workers. Jobs are kept in storage,
+// so with MongoDB they are picked up again after a restart or by another replica.
+type Queue struct {
+	storage  storage.Storage
+	analysis *analysis.Service
+	workers  int
+	pending  chan string
+	owner    string // Identifies this queue in the jobs it runs
+
+	mu          sync.Mutex
+	subscribers map[string][]chan *models.AnalysisJob
+}
+
+// NewQueue creates a queue with the given number of workers
+func NewQueue(storage storage.Storage, analysisService *analysis.Service, workers int) *Queue {
+	if workers < 1 {
+		workers = 1
+	}
+
+	return &Queue{
+		storage:     storage,
+		analysis:    analysisService,
+		workers:     workers,
+		pending:     make(chan string, queueSize),
+		owner:       uuid.New().String(),
+		subscribers: make(map[string][]chan *models.AnalysisJob),
+	}
+}
+
+// Start requeues jobs whose worker went away, queues the waiting ones and starts the workers.
+// While it runs the queue keeps requeueing jobs whose lease expires, e.g. because the replica
+// running them crashed. Workers stop taking new jobs when ctx is cancelled.
+func (q *Queue) Start(ctx context.Context) error {
+	if _, err := q.storage.RequeueExpiredAnalysisJobs(ctx, time.Now()); err != nil {
+		return fmt.Errorf("failed to requeue interrupted jobs: %w", err)
+	}
+	unfinished, err := q.storage.GetUnfinishedAnalysisJobs(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load unfinished jobs: %w", err)
+	}
+
+	// Jobs still running have a live lease on another replica
+	var queued []string
+	for _, job := range unfinished {
+		if job.Status == models.JobStatusQueued {
+			queued = append(queued, job.ID)
+		}
+	}
+
+	for i := 0; i < q.workers; i++ {
+		go q.work(ctx)
+	}
+
+	if len(queued) > 0 {
+		log.Printf("Jobs: resuming %d unfinished analysis jobs", len(queued))
+	}
+	go func() {
+		q.enqueue(ctx, queued)
+		q.requeueExpired(ctx)
+	}()
+
+	return nil
+}
+
+// requeueExpired periodically requeues jobs whose lease has expired until ctx is cancelled
+func (q *Queue) requeueExpired(ctx context.Context) {
+	ticker := time.NewTicker(leaseDuration)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			ids, err := q.storage.RequeueExpiredAnalysisJobs(ctx, now)
+			if err != nil {
+				log.Printf("Jobs: failed to requeue expired jobs: %v", err)
+			}
+			if len(ids) > 0 {
+				log.Printf("Jobs: requeued %d analysis jobs whose worker stopped responding", len(ids))
+			}
+			q.enqueue(ctx, ids)
+		}
+	}
+}
+
+// enqueue hands job IDs to the workers, waiting while the queue is full
+func (q *Queue) enqueue(ctx context.Context, ids []string) {
+	for _, id := range ids {
+		select {
+		case q.pending <- id:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Submit stores a new job and queues it
+func (q *Queue) Submit(ctx context.Context, req models.AnalysisRequest) (*models.AnalysisJob, error) {
+	now := time.Now()
+	job := &models.AnalysisJob{
+		ID:        uuid.New().String(),
+		UserID:    req.UserID,
+		Status:    models.JobStatusQueued,
+		Request:   req,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	if err := q.storage.SaveAnalysisJob(ctx, job); err != nil {
+		return nil, fmt.Errorf("failed to save job: %w", err)
+	}
+
+	select {
+	case q.pending <- job.ID:
+		return job, nil
+	default:
+		q.finish(job, nil, ErrQueueFull)
+		return nil, ErrQueueFull
+	}
+}
+
+// Get returns a job, or nil if it doesn't exist
+func (q *Queue) Get(ctx context.Context, id string) (*models.AnalysisJob, error) {
+	return q.storage.GetAnalysisJob(ctx, id)
+}
+
+// Subscribe returns a channel that receives the job whenever its status changes on this server,
+// and a function to stop the subscription
+func (q *Queue) Subscribe(id string) (<-chan *models.AnalysisJob, func()) {
+	ch := make(chan *models.AnalysisJob, 4)
+
+	q.mu.Lock()
+	q.subscribers[id] = append(q.subscribers[id], ch)
+	q.mu.Unlock()
+
+	return ch, func() {
+		q.mu.Lock()
+		defer q.mu.Unlock()
+
+		subs := q.subscribers[id]
+		for i, sub := range subs {
+			if sub == ch {
+				q.subscribers[id] = append(subs[:i], subs[i+1:]...)
+				break
+			}
+		}
+		if len(q.subscribers[id]) == 0 {
+			delete(q.subscribers, id)
+		}
+	}
+}
+
+// work runs queued jobs until ctx is cancelled
+func (q *Queue) work(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case id := <-q.pending:
+			q.run(id)
+		}
+	}
+}
+
+// run claims and executes a job. The analysis doesn't use the queue's context, so stopping the
+// server doesn't mark jobs as failed: interrupted jobs stay running until their lease expires and
+// are then requeued by this or another replica. Losing the lease cancels the analysis.
+func (q *Queue) run(id string) {
+	ctx, cancel := context.WithTimeout(context.Background(), jobTimeout)
+	defer cancel()
+
+	// Another replica may have taken the job already
+	job, err := q.storage.ClaimAnalysisJob(ctx, id, q.owner, time.Now().Add(leaseDuration))
+	if err != nil {
+		log.Printf("Jobs: failed to claim job %s: %v", id, err)
+		return
+	}
+	if job == nil {
+		return
+	}
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go q.renewLease(id, stop, cancel)
+
+	if job.Attempts > maxAttempts {
+		q.finish(job, nil, fmt.Errorf("analysis was interrupted %d times, giving up", maxAttempts))
+		return
+	}
+	q.notify(job)
+
+	result, err := q.analysis.Analyze(ctx, &job.Request)
+	if err != nil {
+		log.Printf("Jobs: analysis job %s failed: %v", id, err)
+	}
+	q.finish(job, result, err)
+}
+
+// renewLease extends the lease of a running job until stop is closed. If the job was taken
+// away it calls lost, its outcome would not be stored anyway.
+func (q *Queue) renewLease(id string, stop <-chan struct{}, lost context.CancelFunc) {
+	ticker := time.NewTicker(leaseRenewal)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), leaseRenewal)
+			owned, err := q.storage.RenewAnalysisJobLease(ctx, id, q.owner, now.Add(leaseDuration))
+			cancel()
+			if err != nil {
+				log.Printf("Jobs: failed to renew lease of job %s: %v", id, err)
+			} else if !owned {
+				log.Printf("Jobs: lost the lease of job %s, it was requeued", id)
+				lost()
+				return
+			}
+		}
+	}
+}
+
+// finish stores the outcome of a job and notifies subscribers. A claimed job is only stored while
+// this queue still owns it, once it was requeued the worker that took it over reports the outcome.
+func (q *Queue) finish(job *models.AnalysisJob, result *models.MealAnalysis, err error) {
+	owner := job.Owner
+	now := time.Now()
+	job.UpdatedAt = now
+	job.CompletedAt = &now
+	job.Owner = ""
+	job.LeaseUntil = nil
+	if err != nil {
+		job.Status = models.JobStatusFailed
+		job.Error = err.Error()
+	} else {
+		job.Status = models.JobStatusDone
+		job.Result = result
+	}
+
+	// The request context may already be gone, the outcome still has to be stored
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if owner == "" {
+		// Rejected by Submit before any worker claimed it
+		if err := q.storage.SaveAnalysisJob(ctx, job); err != nil {
+			log.Printf("Jobs: failed to save job %s: %v", job.ID, err)
+		}
+		q.notify(job)
+		return
+	}
+
+	finished, err := q.storage.FinishAnalysisJob(ctx, job, owner)
+	if err != nil {
+		log.Printf("Jobs: failed to save job %s: %v", job.ID, err)
+	} else if !finished {
+		log.Printf("Jobs: job %s was requeued while it ran, dropping its outcome", job.ID)
+		return
+	}
+	q.notify(job)
+}
+
+// notify sends a copy of the job to its subscribers without blocking the worker
+func (q *Queue) notify(job *models.AnalysisJob) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for _, ch := range q.subscribers[job.ID] {
+		update := *job
+		select {
+		case ch <- &update:
+		default:
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/yourusername/diabetes-assistant/internal/models"
+	"github.com/yourusername/diabetes-assistant/internal/storage"
+)
+
+func TestFinishDropsTheOutcomeOfARequeuedJob(t *testing.T) {
+	store := storage.NewInMemoryStorage()
+	ctx := context.Background()
+	stale := NewQueue(store, nil, 1)
+	current := NewQueue(store, nil, 1)
+
+	now := time.Now()
+	job := &models.AnalysisJob{ID: "job1", UserID: "user1", Status: models.JobStatusQueued, CreatedAt: now, UpdatedAt: now}
+	if err := store.SaveAnalysisJob(ctx, job); err != nil {
+		t.Fatal(err)
+	}
+
+	// The first worker's lease runs out and another worker takes the job over
+	claimed, err := store.ClaimAnalysisJob(ctx, job.ID, stale.owner, now.Add(-time.Second))
+	if err != nil || claimed == nil {
+		t.Fatalf("claim = %v, %v", claimed, err)
+	}
+	if _, err := store.RequeueExpiredAnalysisJobs(ctx, now); err != nil {
+		t.Fatal(err)
+	}
+	if job, err := store.ClaimAnalysisJob(ctx, job.ID, current.owner, now.Add(leaseDuration)); err != nil || job == nil {
+		t.Fatalf("second claim = %v, %v", job, err)
+	}
+
+	stale.finish(claimed, nil, errors.New("context canceled"))
+	stored, err := store.GetAnalysisJob(ctx, job.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Status != models.JobStatusRunning || stored.Owner != current.owner {
+		t.Fatalf("job = %+v, want it still running for the worker that took it over", stored)
+	}
+
+	current.finish(stored, &models.MealAnalysis{}, nil)
+	stored, err = store.GetAnalysisJob(ctx, job.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Status != models.JobStatusDone || stored.Owner != "" || stored.LeaseUntil != nil {
+		t.Errorf("job = %+v, want done and released", stored)
+	}
+}
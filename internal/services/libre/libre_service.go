@@ -2,9 +2,11 @@ package libre
 
 import (
 	"fmt"
+	"math"
 	"time"
 
 	"github.com/yourusername/diabetes-assistant/internal/models"
+	"github.com/yourusername/diabetes-assistant/internal/services/glucose"
 )
 
 // LibreService handles integration with Freestyle Libre 2
@@ -41,6 +43,21 @@ type NightscoutReading struct {
 	SGV        int    `json:"sgv"`        // Blood sugar in mg/dL
 	Date       int64  `json:"date"`       // Timestamp in milliseconds
 	DateString string `json:"dateString"` // Timestamp as string
+	Direction  string `json:"direction"`  // Trend arrow, e.g. "FortyFiveDown"
+}
+
+// Reading converts a Nightscout entry to a reading in mmol/L. The direction becomes the trend
+// when it is one of the known arrows.
+func (r NightscoutReading) Reading() models.BloodSugarReading {
+	reading := models.BloodSugarReading{
+		Value:     math.Round(glucose.FromMgdl(float64(r.SGV))*10) / 10,
+		Timestamp: time.UnixMilli(r.Date),
+		Source:    "nightscout",
+	}
+	if glucose.IsArrow(r.Direction) {
+		reading.Trend = r.Direction
+	}
+	return reading
 }
 
 // GetReadingsFromLibreView gets readings from LibreView
@@ -75,17 +92,21 @@ func (s *LibreService) GetReadingsFromNightscout(nightscoutURL, apiSecret string
 		count = 10 // Default to 10 readings
 	}
 
-	// Generate mock readings data (for demonstration purposes)
+	// Generate mock entries (for demonstration purposes), newest first like the Nightscout API
+	now := time.Now()
 	readings := []models.BloodSugarReading{}
 	for i := 0; i < count; i++ {
 		// Generate a somewhat realistic blood sugar pattern
 		baseValue := 5.5                // Base value in mmol/L
 		variation := float64(i%5) * 0.4 // Some variation
 
-		readings = append(readings, models.BloodSugarReading{
-			Value:     baseValue + variation,
-			Timestamp: time.Now().Add(time.Duration(-i) * time.Hour),
-		})
+		// Changes of at most 1.6 mmol/L per hour are steady
+		entry := NightscoutReading{
+			SGV:       int(math.Round(glucose.ToMgdl(baseValue + variation))),
+			Date:      now.Add(time.Duration(-i) * time.Hour).UnixMilli(),
+			Direction: glucose.ArrowFlat,
+		}
+		readings = append(readings, entry.Reading())
 	}
 
 	return readings, nil
@@ -0,0 +1,281 @@
+package nightscout
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yourusername/diabetes-assistant/internal/models"
+	"github.com/yourusername/diabetes-assistant/internal/services/alerts"
+	"github.com/yourusername/diabetes-assistant/internal/services/glucose"
+	"github.com/yourusername/diabetes-assistant/internal/services/hypo"
+	"github.com/yourusername/diabetes-assistant/internal/services/imports"
+	"github.com/yourusername/diabetes-assistant/internal/services/reminders"
+	"github.com/yourusername/diabetes-assistant/internal/storage"
+)
+
+// Version is the Nightscout version reported by GET /api/v1/status; uploaders check it before
+// uploading. The implemented subset of the API behaves like this version.
+const Version = "14.2.6"
+
+// Sources of uploaded readings
+const (
+	SourceSensor = "nightscout"       // CGM reading, an sgv entry
+	SourceMeter  = "nightscout_meter" // Fingerstick, an mbg entry or a BG Check treatment
+)
+
+// Entry types
+const (
+	EntrySensor = "sgv"
+	EntryMeter  = "mbg"
+)
+
+const (
+	// minSGV is the lowest sensor value in mg/dL, lower ones are CGM error codes such as
+	// "sensor not active"
+	minSGV = 39
+	// minMBG is the lowest meter value in mg/dL, meters show "LO" below it
+	minMBG = 20
+	// maxGlucose is the highest plausible reading in mmol/L
+	maxGlucose = 40.0
+	// freshReading is how old the newest uploaded reading can be to be handled like a reading
+	// logged now, cancelling rechecks and evaluating alerts. Older uploads are backfill.
+	freshReading = 15 * time.Minute
+	// secretBytes is the length of generated secrets before hex encoding
+	secretBytes = 12
+)
+
+// sha1Hex matches the hashed secret uploaders send in the api-secret header
+var sha1Hex = regexp.MustCompile(`^[0-9a-fA-F]{40}$`)
+
+// Number is a number that may also be sent as a string, as the Nightscout careportal does
+type Number float64
+
+// UnmarshalJSON reads a JSON number, a numeric string or an empty string
+func (n *Number) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(data, []byte("null")) {
+		*n = 0
+		return nil
+	}
+	if len(data) > 0 && data[0] == '"' {
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+		if s = strings.TrimSpace(s); s == "" {
+			*n = 0
+			return nil
+		}
+		data = []byte(s)
+	}
+
+	value, err := strconv.ParseFloat(string(data), 64)
+	if err != nil {
+		return fmt.Errorf("invalid number %s", data)
+	}
+	*n = Number(value)
+	return nil
+}
+
+// Entry is a Nightscout glucose entry
+type Entry struct {
+	ID         string `json:"_id,omitempty"`
+	Type       string `json:"type"`                 // sgv or mbg, others such as cal are skipped
+	SGV        Number `json:"sgv,omitempty"`        // Sensor glucose in mg/dL
+	MBG        Number `json:"mbg,omitempty"`        // Meter glucose in mg/dL
+	Date       int64  `json:"date,omitempty"`       // Unix milliseconds
+	DateString string `json:"dateString,omitempty"` // ISO 8601, used when date is missing
+	Direction  string `json:"direction,omitempty"`  // Trend arrow, e.g. "FortyFiveDown"
+	Device     string `json:"device,omitempty"`
+}
+
+// Service stores the glucose entries and treatments Nightscout uploaders push. New readings are
+// handled like readings logged in the app: they cancel pending rechecks and evaluate alerts.
+type Service struct {
+	storage   storage.Storage
+	imports   *imports.Service
+	hypo      *hypo.Service
+	alerts    *alerts.Service
+	reminders *reminders.Service
+}
+
+// NewService creates a new Nightscout upload service
+func NewService(storage storage.Storage, hypoService *hypo.Service, alertService *alerts.Service, reminderService *reminders.Service) *Service {
+	return &Service{
+		storage:   storage,
+		imports:   imports.NewService(storage),
+		hypo:      hypoService,
+		alerts:    alertService,
+		reminders: reminderService,
+	}
+}
+
+// CreateSecret generates a new upload secret for the user, replacing their previous one. Only
+// its hash is stored, so the secret can't be shown again.
+func (s *Service) CreateSecret(ctx context.Context, userID string) (string, error) {
+	random := make([]byte, secretBytes)
+	if _, err := rand.Read(random); err != nil {
+		return "", err
+	}
+	secret := hex.EncodeToString(random)
+
+	err := s.storage.SaveNightscoutSecret(ctx, &models.NightscoutSecret{
+		UserID:    userID,
+		Hash:      hashSecret(secret),
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to save secret: %w", err)
+	}
+	return secret, nil
+}
+
+// Authenticate returns the user an api-secret header belongs to, or "" if it matches no user.
+// Uploaders send the SHA-1 hash of the secret; the plain secret is accepted as well.
+func (s *Service) Authenticate(ctx context.Context, apiSecret string) (string, error) {
+	apiSecret = strings.TrimSpace(apiSecret)
+	if apiSecret == "" {
+		return "", nil
+	}
+
+	hash := strings.ToLower(apiSecret)
+	if !sha1Hex.MatchString(apiSecret) {
+		hash = hashSecret(apiSecret)
+	}
+	secret, err := s.storage.GetNightscoutSecret(ctx, hash)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch secret: %w", err)
+	}
+	if secret == nil {
+		return "", nil
+	}
+	return secret.UserID, nil
+}
+
+// SaveEntries stores sensor and meter entries as readings. Entries in the same minute as a stored
+// reading are duplicates, uploaders resend entries after connection problems.
+func (s *Service) SaveEntries(ctx context.Context, userID string, entries []Entry) error {
+	var readings []models.BloodSugarReading
+	for _, entry := range entries {
+		reading, ok := entryReading(entry)
+		if !ok {
+			continue
+		}
+		readings = append(readings, reading)
+	}
+	return s.saveReadings(ctx, userID, readings)
+}
+
+// saveReadings stores readings and, when the newest one is fresh, handles it like a reading
+// logged now
+func (s *Service) saveReadings(ctx context.Context, userID string, readings []models.BloodSugarReading) error {
+	saved, _, err := s.imports.Save(ctx, userID, readings)
+	if err != nil {
+		return err
+	}
+	if saved == 0 {
+		return nil
+	}
+
+	newest := readings[0]
+	for _, reading := range readings {
+		if reading.Timestamp.After(newest.Timestamp) {
+			newest = reading
+		}
+	}
+	if time.Since(newest.Timestamp) > freshReading {
+		return nil
+	}
+	s.readingReceived(ctx, userID, newest.Timestamp)
+	return nil
+}
+
+// readingReceived cancels the rechecks a new reading makes unnecessary and evaluates the alert
+// rules. Uploads are accepted anyway, so failures are only logged.
+func (s *Service) readingReceived(ctx context.Context, userID string, at time.Time) {
+	if err := s.hypo.ReadingReceived(ctx, userID, at); err != nil {
+		log.Printf("Nightscout: error updating hypo rechecks: %v", err)
+	}
+	if err := s.reminders.ReadingReceived(ctx, userID, at); err != nil {
+		log.Printf("Nightscout: error updating reminders: %v", err)
+	}
+
+	user, err := s.storage.GetUser(userID)
+	if err != nil || user == nil {
+		log.Printf("Nightscout: error fetching user %s: %v", userID, err)
+		return
+	}
+	if _, err := s.alerts.Evaluate(ctx, userID, &user.Settings, at); err != nil {
+		log.Printf("Nightscout: error evaluating alerts: %v", err)
+	}
+}
+
+// entryReading converts an entry to a reading, false for entries that aren't glucose readings
+func entryReading(entry Entry) (models.BloodSugarReading, bool) {
+	var reading models.BloodSugarReading
+	switch entry.Type {
+	case EntrySensor:
+		if entry.SGV < minSGV {
+			return reading, false
+		}
+		reading.Value = round(glucose.FromMgdl(float64(entry.SGV)), 1)
+		reading.Source = SourceSensor
+		if glucose.IsArrow(entry.Direction) {
+			reading.Trend = entry.Direction
+		}
+	case EntryMeter:
+		if entry.MBG < minMBG {
+			return reading, false
+		}
+		reading.Value = round(glucose.FromMgdl(float64(entry.MBG)), 1)
+		reading.Source = SourceMeter
+	default:
+		return reading, false
+	}
+	if reading.Value > maxGlucose {
+		return reading, false
+	}
+
+	timestamp, ok := parseTime(entry.Date, entry.DateString)
+	if !ok {
+		return reading, false
+	}
+	reading.Timestamp = timestamp
+	return reading, true
+}
+
+// parseTime reads Unix milliseconds, or an ISO 8601 time if millis is 0
+func parseTime(millis int64, iso string) (time.Time, bool) {
+	if millis > 0 {
+		return time.UnixMilli(millis), true
+	}
+	if iso == "" {
+		return time.Time{}, false
+	}
+	t, err := time.Parse(time.RFC3339, iso)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+// hashSecret returns the hex SHA-1 of a secret, as Nightscout uploaders send it
+func hashSecret(secret string) string {
+	sum := sha1.Sum([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+func round(x float64, decimals int) float64 {
+	pow := math.Pow(10, float64(decimals))
+	return math.Round(x*pow) / pow
+}
@@ -0,0 +1,49 @@
+package nightscout
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEntryReading(t *testing.T) {
+	at := time.Date(2024, 5, 14, 8, 30, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		entry  Entry
+		want   float64
+		source string
+		ok     bool
+	}{
+		{name: "sensor", entry: Entry{Type: EntrySensor, SGV: 97, Direction: "Flat"}, want: 5.4, source: SourceSensor, ok: true},
+		{name: "sensor error code", entry: Entry{Type: EntrySensor, SGV: 5}},
+		{name: "meter", entry: Entry{Type: EntryMeter, MBG: 97}, want: 5.4, source: SourceMeter, ok: true},
+		{name: "meter at its lowest", entry: Entry{Type: EntryMeter, MBG: minMBG}, want: 1.1, source: SourceMeter, ok: true},
+		{name: "meter below its range", entry: Entry{Type: EntryMeter, MBG: minMBG - 1}},
+		{name: "meter zero", entry: Entry{Type: EntryMeter}},
+		{name: "meter too high", entry: Entry{Type: EntryMeter, MBG: 900}},
+		{name: "calibration", entry: Entry{Type: "cal", MBG: 97}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.entry.Date = at.UnixMilli()
+			reading, ok := entryReading(tt.entry)
+			if ok != tt.ok {
+				t.Fatalf("ok = %v, want %v (reading %+v)", ok, tt.ok, reading)
+			}
+			if ok && (reading.Value != tt.want || reading.Source != tt.source || !reading.Timestamp.Equal(at)) {
+				t.Errorf("reading = %+v, want %.1f mmol/L from %s", reading, tt.want, tt.source)
+			}
+		})
+	}
+}
+
+func TestEntryReadingWithoutTime(t *testing.T) {
+	if reading, ok := entryReading(Entry{Type: EntrySensor, SGV: 97}); ok {
+		t.Errorf("reading = %+v, want entries without a time skipped", reading)
+	}
+	reading, ok := entryReading(Entry{Type: EntrySensor, SGV: 97, DateString: "2024-05-14T08:30:00Z"})
+	if !ok || !reading.Timestamp.Equal(time.Date(2024, 5, 14, 8, 30, 0, 0, time.UTC)) {
+		t.Errorf("reading = %+v, %v, want the time of dateString", reading, ok)
+	}
+}
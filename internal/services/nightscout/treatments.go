@@ -0,0 +1,149 @@
+package nightscout
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/yourusername/diabetes-assistant/internal/models"
+	"github.com/yourusername/diabetes-assistant/internal/services/glucose"
+	"github.com/yourusername/diabetes-assistant/internal/services/insulin"
+)
+
+// Treatment event types with a meaning of their own; the others are stored by their insulin and carbs
+const (
+	EventCorrectionBolus = "Correction Bolus"
+	EventCarbCorrection  = "Carb Correction" // Fast carbs eaten to treat a low
+	EventBGCheck         = "BG Check"
+)
+
+// maxUnits is the largest accepted dose, as for doses logged in the app
+const maxUnits = 100
+
+// Treatment is a Nightscout treatment: a bolus, carbs, both, or a fingerstick. Pump events such as
+// temp basals and site changes carry no insulin or carbs and are skipped.
+type Treatment struct {
+	ID          string `json:"_id,omitempty"`
+	EventType   string `json:"eventType"`
+	CreatedAt   string `json:"created_at,omitempty"` // ISO 8601
+	Date        int64  `json:"date,omitempty"`       // Unix milliseconds, used when created_at is missing
+	Insulin     Number `json:"insulin,omitempty"`    // Units
+	Carbs       Number `json:"carbs,omitempty"`      // Grams
+	Fat         Number `json:"fat,omitempty"`
+	Protein     Number `json:"protein,omitempty"`
+	Glucose     Number `json:"glucose,omitempty"`     // Fingerstick in units
+	Units       string `json:"units,omitempty"`       // mg/dl or mmol
+	GlucoseType string `json:"glucoseType,omitempty"` // Finger, Sensor or Manual
+	Notes       string `json:"notes,omitempty"`
+	EnteredBy   string `json:"enteredBy,omitempty"`
+}
+
+// SaveTreatments stores the carbs of treatments as meals, their insulin as doses linked to the
+// meal, and BG Checks as fingerstick readings. Meals and doses get IDs derived from the user and
+// the treatment time, so a treatment uploaded again replaces the stored one. Treatments without a
+// time are skipped: they would be stored again with every retry.
+func (s *Service) SaveTreatments(ctx context.Context, userID string, treatments []Treatment) error {
+	var readings []models.BloodSugarReading
+	for _, treatment := range treatments {
+		timestamp, ok := parseTime(treatment.Date, treatment.CreatedAt)
+		if !ok {
+			continue
+		}
+
+		var mealID string
+		if treatment.Carbs > 0 {
+			meal := treatmentMeal(userID, treatment, timestamp)
+			if err := s.storage.SaveMealRecord(ctx, meal); err != nil {
+				return fmt.Errorf("failed to save meal: %w", err)
+			}
+			mealID = meal.ID
+		}
+
+		if treatment.Insulin > 0 && treatment.Insulin <= maxUnits {
+			doseType := models.DoseTypeBolus
+			if treatment.EventType == EventCorrectionBolus {
+				doseType = models.DoseTypeCorrection
+			}
+			dose := &models.InsulinDose{
+				ID:        treatmentID(userID, "dose", timestamp),
+				UserID:    userID,
+				Timestamp: timestamp,
+				Units:     float64(treatment.Insulin),
+				Type:      doseType,
+				MealID:    mealID,
+				Note:      treatment.Notes,
+			}
+			if err := s.storage.SaveInsulinDose(ctx, dose); err != nil {
+				return fmt.Errorf("failed to save dose: %w", err)
+			}
+		}
+
+		if treatment.EventType == EventBGCheck {
+			if reading, ok := bgCheckReading(treatment, timestamp); ok {
+				readings = append(readings, reading)
+			}
+		}
+	}
+	return s.saveReadings(ctx, userID, readings)
+}
+
+// bgCheckReading converts the glucose of a BG Check to a fingerstick reading, false if it's out of
+// the meter's range. Without units the value is in mmol/L, the units the server reports in its
+// status, unless it's too high for mmol/L.
+func bgCheckReading(treatment Treatment, timestamp time.Time) (models.BloodSugarReading, bool) {
+	value := float64(treatment.Glucose)
+	units := strings.ToLower(treatment.Units)
+	switch {
+	case strings.HasPrefix(units, "mmol"):
+	case strings.HasPrefix(units, "mg"), value > maxGlucose:
+		value = glucose.FromMgdl(value)
+	}
+
+	value = round(value, 1)
+	if value < round(glucose.FromMgdl(minMBG), 1) || value > maxGlucose {
+		return models.BloodSugarReading{}, false
+	}
+	return models.BloodSugarReading{
+		Value:     value,
+		Timestamp: timestamp,
+		Source:    SourceMeter,
+	}, true
+}
+
+// treatmentMeal converts the carbs of a treatment to a meal. Carb corrections treat lows and
+// are absorbed fast; other meals get their absorption from the macronutrients.
+func treatmentMeal(userID string, treatment Treatment, timestamp time.Time) *models.MealRecord {
+	name := strings.TrimSpace(treatment.Notes)
+	if name == "" {
+		name = treatment.EventType
+	}
+	if name == "" {
+		name = "Nightscout"
+	}
+
+	meal := &models.MealRecord{
+		ID:         treatmentID(userID, "meal", timestamp),
+		UserID:     userID,
+		Timestamp:  timestamp,
+		Name:       name,
+		Carbs:      float64(treatment.Carbs),
+		Fat:        float64(treatment.Fat),
+		Protein:    float64(treatment.Protein),
+		Confidence: 1,
+		Source:     models.MealSourceNightscout,
+		EatenAt:    &timestamp,
+	}
+	if treatment.EventType == EventCarbCorrection {
+		meal.Absorption = insulin.MealProfileFast
+	}
+	return meal
+}
+
+// treatmentID returns a stable ID for the meal or dose of the user's treatment at the given time
+func treatmentID(userID, kind string, timestamp time.Time) string {
+	name := userID + "/" + kind + "/" + strconv.FormatInt(timestamp.UnixMilli(), 10)
+	return uuid.NewSHA1(uuid.NameSpaceURL, []byte(name)).String()
+}
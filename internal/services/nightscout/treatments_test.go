@@ -0,0 +1,124 @@
+package nightscout
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/yourusername/diabetes-assistant/internal/models"
+	"github.com/yourusername/diabetes-assistant/internal/storage"
+)
+
+func TestBGCheckReading(t *testing.T) {
+	tests := []struct {
+		name    string
+		glucose Number
+		units   string
+		want    float64
+		ok      bool
+	}{
+		{name: "mmol", glucose: 5.4, units: "mmol", want: 5.4, ok: true},
+		{name: "mg/dl", glucose: 97, units: "mg/dl", want: 5.4, ok: true},
+		{name: "no units in mmol", glucose: 5.4, want: 5.4, ok: true},
+		{name: "no units in mg/dl", glucose: 180, want: 10, ok: true},
+		{name: "low mg/dl", glucose: 20, units: "mg/dl", want: 1.1, ok: true},
+		{name: "below meter range", glucose: 10, units: "mg/dl"},
+		{name: "below meter range in mmol", glucose: 0.5, units: "mmol"},
+		{name: "zero", glucose: 0},
+		{name: "too high for mmol", glucose: 97, units: "mmol"},
+	}
+
+	at := time.Date(2024, 5, 14, 8, 30, 0, 0, time.UTC)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			treatment := Treatment{EventType: EventBGCheck, Glucose: tt.glucose, Units: tt.units}
+			reading, ok := bgCheckReading(treatment, at)
+			if ok != tt.ok {
+				t.Fatalf("ok = %v, want %v (reading %+v)", ok, tt.ok, reading)
+			}
+			if ok && (reading.Value != tt.want || reading.Source != SourceMeter || !reading.Timestamp.Equal(at)) {
+				t.Errorf("reading = %+v, want %.1f mmol/L from the meter", reading, tt.want)
+			}
+		})
+	}
+}
+
+func TestSaveTreatmentsSkipsTreatmentsWithoutTime(t *testing.T) {
+	store := storage.NewInMemoryStorage()
+	if err := store.CreateUser(&models.User{UserID: "user1", Settings: *models.CreateDefaultSettings("user1")}); err != nil {
+		t.Fatal(err)
+	}
+	service := NewService(store, nil, nil, nil)
+	ctx := context.Background()
+
+	treatments := []Treatment{
+		{ID: "a", EventType: "Meal Bolus", Carbs: 40, Insulin: 4},
+		{ID: "b", EventType: "Meal Bolus", Carbs: 30, Insulin: 3, CreatedAt: "2024-05-14T08:30:00Z"},
+	}
+	// Uploaders resend treatments when they don't get an answer
+	for i := 0; i < 2; i++ {
+		if err := service.SaveTreatments(ctx, "user1", treatments); err != nil {
+			t.Fatalf("SaveTreatments: %v", err)
+		}
+	}
+
+	since := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	meals, err := store.GetMealRecords(ctx, "user1", since)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(meals) != 1 || meals[0].Carbs != 30 {
+		t.Errorf("meals = %+v, want only the treatment with a time, once", meals)
+	}
+	doses, err := store.GetInsulinDoses(ctx, "user1", since)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(doses) != 1 || doses[0].Units != 3 || doses[0].MealID != meals[0].ID {
+		t.Errorf("doses = %+v, want one dose linked to the meal", doses)
+	}
+}
+
+func TestSaveTreatmentsBGChecks(t *testing.T) {
+	store := storage.NewInMemoryStorage()
+	if err := store.CreateUser(&models.User{UserID: "user1", Settings: *models.CreateDefaultSettings("user1")}); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	treatments := []Treatment{
+		// Without units the value is in mmol/L
+		{EventType: EventBGCheck, Glucose: 5.4, CreatedAt: "2024-05-14T08:00:00Z"},
+		// Unless it's too high for mmol/L
+		{EventType: EventBGCheck, Glucose: 180, CreatedAt: "2024-05-14T09:00:00Z"},
+		{EventType: EventBGCheck, Glucose: 97, Units: "mg/dl", CreatedAt: "2024-05-14T10:00:00Z"},
+		// Implausible values
+		{EventType: EventBGCheck, Glucose: 0.5, Units: "mmol", CreatedAt: "2024-05-14T11:00:00Z"},
+		{EventType: EventBGCheck, Glucose: 10, Units: "mg/dl", CreatedAt: "2024-05-14T12:00:00Z"},
+		{EventType: EventBGCheck, Glucose: 97, Units: "mmol", CreatedAt: "2024-05-14T13:00:00Z"},
+		// No time
+		{EventType: EventBGCheck, Glucose: 6.1},
+	}
+	if err := NewService(store, nil, nil, nil).SaveTreatments(ctx, "user1", treatments); err != nil {
+		t.Fatalf("SaveTreatments: %v", err)
+	}
+
+	readings, err := store.GetBloodSugarReadings(ctx, "user1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]float64{
+		"2024-05-14T08:00:00Z": 5.4,
+		"2024-05-14T09:00:00Z": 10,
+		"2024-05-14T10:00:00Z": 5.4,
+	}
+	if len(readings) != len(want) {
+		t.Fatalf("got %d readings, want %d: %+v", len(readings), len(want), readings)
+	}
+	for _, reading := range readings {
+		at := reading.Timestamp.UTC().Format(time.RFC3339)
+		if value, ok := want[at]; !ok || reading.Value != value || reading.Source != SourceMeter {
+			t.Errorf("reading at %s = %.1f from %s, want %.1f from the meter", at, reading.Value, reading.Source, value)
+		}
+	}
+}
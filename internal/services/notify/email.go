@@ -0,0 +1,104 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/yourusername/diabetes-assistant/internal/models"
+)
+
+// SMTPConfig is the mail server notifications are sent through
+type SMTPConfig struct {
+	Host     string
+	Port     int
+	Username string // Empty sends without authentication
+	Password string
+	From     string
+}
+
+// EmailNotifier sends notifications as plain text email to the channel's address
+type EmailNotifier struct {
+	config SMTPConfig
+}
+
+// NewEmailNotifier creates a new email notifier. The connection is upgraded with STARTTLS when
+// the server offers it; credentials are only sent over TLS or to localhost.
+func NewEmailNotifier(config SMTPConfig) *EmailNotifier {
+	return &EmailNotifier{config: config}
+}
+
+// Send sends the notification as an email
+func (n *EmailNotifier) Send(ctx context.Context, channel models.NotificationChannel, notification models.Notification) error {
+	to, err := mail.ParseAddress(channel.Target)
+	if err != nil {
+		return Permanent(fmt.Errorf("invalid email address: %w", err))
+	}
+	from, err := mail.ParseAddress(n.config.From)
+	if err != nil {
+		return Permanent(fmt.Errorf("invalid sender address: %w", err))
+	}
+
+	var auth smtp.Auth
+	if n.config.Username != "" {
+		auth = smtp.PlainAuth("", n.config.Username, n.config.Password, n.config.Host)
+	}
+
+	addr := net.JoinHostPort(n.config.Host, strconv.Itoa(n.config.Port))
+	message := emailMessage(from, to, notification)
+
+	// smtp.SendMail has no context, give up when the caller does
+	done := make(chan error, 1)
+	go func() {
+		done <- smtp.SendMail(addr, auth, from.Address, []string{to.Address}, message)
+	}()
+	select {
+	case err := <-done:
+		if err == nil {
+			return nil
+		}
+		// 5xx replies such as an unknown mailbox won't change on retry
+		var reply *textproto.Error
+		if errors.As(err, &reply) && reply.Code >= 500 {
+			return Permanent(fmt.Errorf("mail server rejected the email: %w", err))
+		}
+		return fmt.Errorf("failed to send email: %w", err)
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// emailMessage builds a UTF-8 plain text message
+func emailMessage(from, to *mail.Address, notification models.Notification) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", from.String())
+	fmt.Fprintf(&buf, "To: %s\r\n", to.String())
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", notification.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&buf, "Message-ID: <%s@diabetes-assistant>\r\n", uuid.New().String())
+	if notification.Urgent {
+		buf.WriteString("X-Priority: 1\r\n")
+	}
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	buf.WriteString("Content-Transfer-Encoding: base64\r\n")
+	buf.WriteString("\r\n")
+
+	encoded := base64.StdEncoding.EncodeToString([]byte(notification.Text))
+	for len(encoded) > 76 {
+		buf.WriteString(encoded[:76] + "\r\n")
+		encoded = encoded[76:]
+	}
+	buf.WriteString(encoded + "\r\n")
+	return buf.Bytes()
+}
@@ -0,0 +1,214 @@
+package notify
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/mail"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/yourusername/diabetes-assistant/internal/models"
+)
+
+// fakeSMTP is a stand-in mail server that speaks just enough SMTP for net/smtp. Recipients in
+// reject get the reply code stored for them.
+type fakeSMTP struct {
+	listener net.Listener
+	reject   map[string]string
+
+	mu       sync.Mutex
+	auth     []string // AUTH lines received
+	from     string
+	rcpt     []string
+	messages []string
+}
+
+func newFakeSMTP(t *testing.T) *fakeSMTP {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &fakeSMTP{listener: listener, reject: make(map[string]string)}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go server.serve(conn)
+		}
+	}()
+	return server
+}
+
+func (s *fakeSMTP) config() SMTPConfig {
+	host, port, _ := net.SplitHostPort(s.listener.Addr().String())
+	var portNumber int
+	fmt.Sscan(port, &portNumber)
+	return SMTPConfig{Host: host, Port: portNumber, From: "Diabetes Assistant <alerts@example.com>"}
+}
+
+func (s *fakeSMTP) serve(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	reply := func(line string) { fmt.Fprintf(conn, "%s\r\n", line) }
+
+	reply("220 fake ESMTP")
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		command := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+
+		switch command {
+		case "EHLO", "HELO":
+			reply("250-fake greets you")
+			reply("250 AUTH PLAIN")
+		case "AUTH":
+			s.mu.Lock()
+			s.auth = append(s.auth, line)
+			s.mu.Unlock()
+			reply("235 Authentication successful")
+		case "MAIL":
+			s.mu.Lock()
+			s.from = line
+			s.mu.Unlock()
+			reply("250 OK")
+		case "RCPT":
+			address := strings.Trim(strings.TrimPrefix(line, "RCPT TO:"), "<>")
+			if code, ok := s.reject[address]; ok {
+				reply(code + " rejected")
+				continue
+			}
+			s.mu.Lock()
+			s.rcpt = append(s.rcpt, address)
+			s.mu.Unlock()
+			reply("250 OK")
+		case "DATA":
+			reply("354 End data with <CR><LF>.<CR><LF>")
+			var data strings.Builder
+			for {
+				dataLine, err := reader.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if dataLine == ".\r\n" {
+					break
+				}
+				data.WriteString(dataLine)
+			}
+			s.mu.Lock()
+			s.messages = append(s.messages, data.String())
+			s.mu.Unlock()
+			reply("250 OK: queued")
+		case "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("250 OK")
+		}
+	}
+}
+
+func TestEmailNotifierSendsMessage(t *testing.T) {
+	server := newFakeSMTP(t)
+	notification := testNotification()
+	notification.Subject = "Срочное оповещение о сахаре"
+	notification.Text = "Сахар 3,1 и быстро падает"
+
+	channel := models.NotificationChannel{ID: "c1", Type: models.ChannelEmail, Enabled: true, Target: "Parent <parent@example.com>"}
+	if err := NewEmailNotifier(server.config()).Send(context.Background(), channel, notification); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	if len(server.messages) != 1 {
+		t.Fatalf("received %d messages, want 1", len(server.messages))
+	}
+	if !strings.HasPrefix(server.from, "MAIL FROM:<alerts@example.com>") {
+		t.Errorf("MAIL = %q", server.from)
+	}
+	if len(server.rcpt) != 1 || server.rcpt[0] != "parent@example.com" {
+		t.Errorf("RCPT = %v", server.rcpt)
+	}
+	if len(server.auth) != 0 {
+		t.Errorf("authenticated without a username: %v", server.auth)
+	}
+
+	message, err := mail.ReadMessage(strings.NewReader(server.messages[0]))
+	if err != nil {
+		t.Fatalf("parsing message: %v", err)
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(message.Header.Get("Subject"))
+	if err != nil || subject != notification.Subject {
+		t.Errorf("Subject = %q (%v), want %q", subject, err, notification.Subject)
+	}
+	if message.Header.Get("X-Priority") != "1" {
+		t.Errorf("X-Priority = %q, want 1 for an urgent notification", message.Header.Get("X-Priority"))
+	}
+	if to := message.Header.Get("To"); !strings.Contains(to, "parent@example.com") {
+		t.Errorf("To = %q", to)
+	}
+	encoded, _ := io.ReadAll(message.Body)
+	body, err := base64.StdEncoding.DecodeString(strings.ReplaceAll(string(encoded), "\r\n", ""))
+	if err != nil || string(body) != notification.Text {
+		t.Errorf("body = %q (%v), want %q", body, err, notification.Text)
+	}
+}
+
+func TestEmailNotifierAuthenticates(t *testing.T) {
+	server := newFakeSMTP(t)
+	config := server.config()
+	config.Username = "mailer"
+	config.Password = "secret"
+
+	channel := models.NotificationChannel{ID: "c1", Type: models.ChannelEmail, Enabled: true, Target: "parent@example.com"}
+	if err := NewEmailNotifier(config).Send(context.Background(), channel, testNotification()); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	want := "AUTH PLAIN " + base64.StdEncoding.EncodeToString([]byte("\x00mailer\x00secret"))
+	if len(server.auth) != 1 || server.auth[0] != want {
+		t.Errorf("AUTH = %v, want %q", server.auth, want)
+	}
+}
+
+func TestEmailNotifierErrors(t *testing.T) {
+	server := newFakeSMTP(t)
+	server.reject["unknown@example.com"] = "550"
+	server.reject["busy@example.com"] = "451"
+	notifier := NewEmailNotifier(server.config())
+
+	tests := []struct {
+		target    string
+		permanent bool
+	}{
+		{target: "unknown@example.com", permanent: true},
+		{target: "busy@example.com", permanent: false},
+		{target: "not an address", permanent: true},
+	}
+	for _, tt := range tests {
+		channel := models.NotificationChannel{ID: "c1", Type: models.ChannelEmail, Enabled: true, Target: tt.target}
+		err := notifier.Send(context.Background(), channel, testNotification())
+		if err == nil {
+			t.Errorf("%s: expected an error", tt.target)
+			continue
+		}
+		if IsPermanent(err) != tt.permanent {
+			t.Errorf("%s: IsPermanent(%v) = %v, want %v", tt.target, err, IsPermanent(err), tt.permanent)
+		}
+	}
+}
@@ -0,0 +1,319 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/yourusername/diabetes-assistant/internal/models"
+	"github.com/yourusername/diabetes-assistant/internal/storage"
+)
+
+// DefaultMaxAttempts is how often a delivery is tried before it goes to the dead-letter log
+const DefaultMaxAttempts = 5
+
+// retryDelay is the wait before the first retry, it doubles with every further attempt
+const retryDelay = time.Minute
+
+// claimTimeout is how long a claimed retry is hidden from other replicas. It is well above a
+// notifier's timeout, a delivery whose replica stopped before saving it is retried after this.
+const claimTimeout = 2 * time.Minute
+
+// ErrNotFound is returned when retrying a delivery that doesn't exist
+var ErrNotFound = errors.New("notification not found")
+
+// ErrNotFailed is returned when retrying a delivery that hasn't failed
+var ErrNotFailed = errors.New("notification has not failed")
+
+// Notifier delivers a notification to a channel of its type
+type Notifier interface {
+	Send(ctx context.Context, channel models.NotificationChannel, notification models.Notification) error
+}
+
+// permanentError is a delivery error that retrying won't fix, such as a rejected chat ID
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent marks a delivery error as not worth retrying
+func Permanent(err error) error {
+	return &permanentError{err: err}
+}
+
+// IsPermanent reports whether a delivery error is not worth retrying
+func IsPermanent(err error) bool {
+	var permanent *permanentError
+	return errors.As(err, &permanent)
+}
+
+// Service delivers notifications to the user's channels, retrying failed deliveries and keeping
+// those that never got through in a dead-letter log
+type Service struct {
+	storage     storage.Storage
+	notifiers   map[string]Notifier
+	maxAttempts int
+}
+
+// NewService creates a new notification service. Channel types are delivered once their
+// notifier is registered.
+func NewService(storage storage.Storage, maxAttempts int) *Service {
+	if maxAttempts <= 0 {
+		maxAttempts = DefaultMaxAttempts
+	}
+	return &Service{
+		storage:     storage,
+		notifiers:   make(map[string]Notifier),
+		maxAttempts: maxAttempts,
+	}
+}
+
+// Register sets the notifier of a channel type
+func (s *Service) Register(channelType string, notifier Notifier) {
+	s.notifiers[channelType] = notifier
+}
+
+// Send delivers a notification to each of the user's enabled channels. The first attempt is made
+// right away, failed ones are retried by Run. Without channels the notification is only logged.
+func (s *Service) Send(ctx context.Context, userID, kind, ref, text string, urgent bool) ([]models.Notification, error) {
+	settings, err := s.settings(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var channels []models.NotificationChannel
+	for _, channel := range settings.NotificationChannels {
+		if channel.Enabled && (urgent || !channel.UrgentOnly) {
+			channels = append(channels, channel)
+		}
+	}
+	if len(channels) == 0 {
+		log.Printf("Notifications: user %s: %s", userID, text)
+		return nil, nil
+	}
+
+	now := time.Now()
+	sent := make([]models.Notification, 0, len(channels))
+	for _, channel := range channels {
+		notification := models.Notification{
+			ID:            uuid.New().String(),
+			UserID:        userID,
+			ChannelID:     channel.ID,
+			ChannelType:   channel.Type,
+			Kind:          kind,
+			Ref:           ref,
+			Subject:       subject(settings.Locale, kind, urgent),
+			Text:          text,
+			Urgent:        urgent,
+			CreatedAt:     now,
+			Status:        models.NotificationPending,
+			NextAttemptAt: now,
+		}
+		s.attempt(ctx, &notification, &channel)
+
+		if err := s.storage.SaveNotification(ctx, &notification); err != nil {
+			return nil, fmt.Errorf("failed to save notification: %w", err)
+		}
+		sent = append(sent, notification)
+	}
+	return sent, nil
+}
+
+// Alert delivers an alert, it can be used as the alert service's NotifyFunc
+func (s *Service) Alert(ctx context.Context, alert models.Alert) error {
+	_, err := s.Send(ctx, alert.UserID, models.NotificationAlert, alert.ID, alert.Message, alert.Severity == models.AlertSeverityUrgent)
+	return err
+}
+
+// Reminder delivers a due reminder, it can be used as the reminder service's DeliverFunc.
+// Recheck reminders after a low are urgent, so caregivers get them as well: the user may still be low.
+func (s *Service) Reminder(ctx context.Context, reminder models.Reminder, text string) error {
+	kind := models.NotificationReminder
+	if reminder.Type == models.ReminderHypoRecheck {
+		kind = models.NotificationRecheck
+	}
+	_, err := s.Send(ctx, reminder.UserID, kind, reminder.ID, text, kind == models.NotificationRecheck)
+	return err
+}
+
+// Test sends a test notification to all of the user's enabled channels
+func (s *Service) Test(ctx context.Context, userID string) ([]models.Notification, error) {
+	settings, err := s.settings(userID)
+	if err != nil {
+		return nil, err
+	}
+	return s.Send(ctx, userID, models.NotificationTest, "", testText(settings.Locale), true)
+}
+
+// Run retries due deliveries every interval until ctx is cancelled
+func (s *Service) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.RetryDue(ctx); err != nil {
+			log.Printf("Notifications: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RetryDue makes the next attempt of deliveries whose retry is due. Each one is claimed first,
+// so replicas running at the same time never deliver it twice.
+func (s *Service) RetryDue(ctx context.Context) error {
+	for {
+		now := time.Now()
+		notification, err := s.storage.ClaimDueNotification(ctx, now, now.Add(claimTimeout))
+		if err != nil {
+			return fmt.Errorf("failed to claim due notification: %w", err)
+		}
+		if notification == nil {
+			return nil
+		}
+
+		settings, err := s.settings(notification.UserID)
+		if err != nil {
+			return err
+		}
+		s.attempt(ctx, notification, findChannel(settings.NotificationChannels, notification.ChannelID))
+
+		if err := s.storage.SaveNotification(ctx, notification); err != nil {
+			return fmt.Errorf("failed to save notification: %w", err)
+		}
+	}
+}
+
+// Retry takes a delivery out of the dead-letter log and attempts it again
+func (s *Service) Retry(ctx context.Context, userID, id string) (*models.Notification, error) {
+	notification, err := s.storage.GetNotification(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch notification: %w", err)
+	}
+	if notification == nil || notification.UserID != userID {
+		return nil, ErrNotFound
+	}
+	if notification.Status != models.NotificationFailed {
+		return nil, ErrNotFailed
+	}
+
+	settings, err := s.settings(userID)
+	if err != nil {
+		return nil, err
+	}
+	notification.Attempts = 0
+	s.attempt(ctx, notification, findChannel(settings.NotificationChannels, notification.ChannelID))
+
+	if err := s.storage.SaveNotification(ctx, notification); err != nil {
+		return nil, fmt.Errorf("failed to save notification: %w", err)
+	}
+	return notification, nil
+}
+
+// attempt tries to deliver a notification once and records the outcome: sent, pending with the
+// next retry scheduled, or failed once the attempts are used up or the error is permanent.
+// A nil channel means it has been removed from the user's settings.
+func (s *Service) attempt(ctx context.Context, notification *models.Notification, channel *models.NotificationChannel) {
+	now := time.Now()
+	notification.Attempts++
+
+	var err error
+	switch {
+	case channel == nil:
+		err = Permanent(errors.New("channel was removed"))
+	case !channel.Enabled:
+		err = Permanent(errors.New("channel is disabled"))
+	default:
+		notifier, ok := s.notifiers[channel.Type]
+		if !ok {
+			err = Permanent(fmt.Errorf("%s delivery is not configured", channel.Type))
+			break
+		}
+		err = notifier.Send(ctx, *channel, *notification)
+	}
+
+	if err == nil {
+		notification.Status = models.NotificationSent
+		notification.SentAt = &now
+		notification.LastError = ""
+		return
+	}
+
+	notification.LastError = err.Error()
+	if IsPermanent(err) || notification.Attempts >= s.maxAttempts {
+		notification.Status = models.NotificationFailed
+		log.Printf("Notifications: giving up on %s to %s channel %s after %d attempts: %v",
+			notification.ID, notification.ChannelType, notification.ChannelID, notification.Attempts, err)
+		return
+	}
+	notification.Status = models.NotificationPending
+	notification.NextAttemptAt = now.Add(retryDelay << (notification.Attempts - 1))
+}
+
+// settings returns the user's settings, the defaults if the user doesn't exist
+func (s *Service) settings(userID string) (*models.Settings, error) {
+	user, err := s.storage.GetUser(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch user: %w", err)
+	}
+	if user == nil {
+		return models.CreateDefaultSettings(userID), nil
+	}
+	return &user.Settings, nil
+}
+
+// findChannel returns the channel with the given ID, nil if there is none
+func findChannel(channels []models.NotificationChannel, id string) *models.NotificationChannel {
+	for i := range channels {
+		if channels[i].ID == id {
+			return &channels[i]
+		}
+	}
+	return nil
+}
+
+// subject is the email subject of a notification in the user's language
+func subject(locale, kind string, urgent bool) string {
+	if locale == "en" {
+		switch {
+		case kind == models.NotificationRecheck:
+			return "Check your glucose"
+		case kind == models.NotificationReminder:
+			return "Reminder"
+		case kind == models.NotificationTest:
+			return "Test notification"
+		case urgent:
+			return "Urgent glucose alert"
+		}
+		return "Glucose alert"
+	}
+
+	switch {
+	case kind == models.NotificationRecheck:
+		return "Проверьте сахар"
+	case kind == models.NotificationReminder:
+		return "Напоминание"
+	case kind == models.NotificationTest:
+		return "Тестовое уведомление"
+	case urgent:
+		return "Срочное оповещение о сахаре"
+	}
+	return "Оповещение о сахаре"
+}
+
+// testText is the text of a test notification in the user's language
+func testText(locale string) string {
+	if locale == "en" {
+		return "This is a test notification from Diabetes Assistant."
+	}
+	return "Это тестовое уведомление от Diabetes Assistant."
+}
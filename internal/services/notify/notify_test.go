@@ -0,0 +1,73 @@
+package notify
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/yourusername/diabetes-assistant/internal/models"
+	"github.com/yourusername/diabetes-assistant/internal/storage"
+)
+
+// countingNotifier counts deliveries and fails none of them
+type countingNotifier struct {
+	sent atomic.Int32
+}
+
+func (n *countingNotifier) Send(ctx context.Context, channel models.NotificationChannel, notification models.Notification) error {
+	n.sent.Add(1)
+	return nil
+}
+
+func TestRetryDueDeliversOnceAcrossReplicas(t *testing.T) {
+	store := storage.NewInMemoryStorage()
+	user := &models.User{UserID: "user1", Settings: *models.CreateDefaultSettings("user1")}
+	user.Settings.NotificationChannels = []models.NotificationChannel{
+		{ID: "c1", Type: models.ChannelWebhook, Enabled: true, Target: "https://example.com/hook"},
+	}
+	if err := store.CreateUser(user); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	for _, id := range []string{"n1", "n2", "n3"} {
+		notification := testNotification()
+		notification.ID = id
+		notification.Attempts = 1
+		notification.NextAttemptAt = time.Now().Add(-time.Minute)
+		if err := store.SaveNotification(ctx, &notification); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Each replica has its own service over the shared storage
+	notifier := &countingNotifier{}
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		service := NewService(store, 0)
+		service.Register(models.ChannelWebhook, notifier)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := service.RetryDue(ctx); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if sent := notifier.sent.Load(); sent != 3 {
+		t.Errorf("delivered %d times, want each of the 3 notifications once", sent)
+	}
+	for _, id := range []string{"n1", "n2", "n3"} {
+		notification, err := store.GetNotification(ctx, id)
+		if err != nil || notification == nil {
+			t.Fatalf("GetNotification(%s) = %v, %v", id, notification, err)
+		}
+		if notification.Status != models.NotificationSent || notification.Attempts != 2 {
+			t.Errorf("%s: status %s after %d attempts, want sent after 2", id, notification.Status, notification.Attempts)
+		}
+	}
+}
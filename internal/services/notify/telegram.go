@@ -0,0 +1,85 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/yourusername/diabetes-assistant/internal/models"
+)
+
+// DefaultTelegramAPIURL is the Telegram Bot API
+const DefaultTelegramAPIURL = "https://api.telegram.org"
+
+// TelegramNotifier sends notifications as messages of a Telegram bot to the channel's chat ID.
+// The user has to start a chat with the bot first.
+type TelegramNotifier struct {
+	apiURL string
+	token  string
+	client *http.Client
+}
+
+// NewTelegramNotifier creates a new Telegram notifier. apiURL may point at a stand-in server,
+// empty means DefaultTelegramAPIURL.
+func NewTelegramNotifier(apiURL, token string) *TelegramNotifier {
+	if apiURL == "" {
+		apiURL = DefaultTelegramAPIURL
+	}
+	return &TelegramNotifier{
+		apiURL: strings.TrimRight(apiURL, "/"),
+		token:  token,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Send sends the notification with the sendMessage method
+func (n *TelegramNotifier) Send(ctx context.Context, channel models.NotificationChannel, notification models.Notification) error {
+	text := notification.Text
+	if notification.Urgent && notification.Kind == models.NotificationAlert {
+		text = "‼️ " + text
+	}
+	body, err := json.Marshal(map[string]interface{}{
+		"chat_id": channel.Target,
+		"text":    text,
+	})
+	if err != nil {
+		return Permanent(fmt.Errorf("failed to encode message: %w", err))
+	}
+
+	url := fmt.Sprintf("%s/bot%s/sendMessage", n.apiURL, n.token)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return Permanent(fmt.Errorf("invalid Telegram API URL: %w", err))
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		// The error contains the URL and with it the bot token
+		return fmt.Errorf("Telegram request failed: %w", redactToken(err, n.token))
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		OK          bool   `json:"ok"`
+		Description string `json:"description"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err == nil && !result.OK && result.Description != "" {
+		if statusErr := statusError("Telegram", resp.StatusCode); statusErr != nil {
+			return fmt.Errorf("%w: %s", statusErr, result.Description)
+		}
+	}
+	return statusError("Telegram", resp.StatusCode)
+}
+
+// redactToken removes the bot token from an error message
+func redactToken(err error, token string) error {
+	if token == "" {
+		return err
+	}
+	return fmt.Errorf("%s", strings.ReplaceAll(err.Error(), token, "<token>"))
+}
@@ -0,0 +1,117 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/yourusername/diabetes-assistant/internal/models"
+)
+
+const testBotToken = "123456:ABC-test-token"
+
+// fakeBotAPI answers sendMessage like the Bot API, with status and description set by the test
+func fakeBotAPI(t *testing.T, status int, description string, sent *[]map[string]interface{}) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/bot"+testBotToken+"/sendMessage" {
+			t.Errorf("request to %s", r.URL.Path)
+			http.NotFound(w, r)
+			return
+		}
+		var message map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&message); err != nil {
+			t.Errorf("decoding message: %v", err)
+		}
+		*sent = append(*sent, message)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		if status == http.StatusOK {
+			json.NewEncoder(w).Encode(map[string]interface{}{"ok": true, "result": map[string]interface{}{"message_id": 1}})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"ok": false, "error_code": status, "description": description})
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestTelegramNotifierSendsMessage(t *testing.T) {
+	var sent []map[string]interface{}
+	server := fakeBotAPI(t, http.StatusOK, "", &sent)
+	notifier := NewTelegramNotifier(server.URL+"/", testBotToken)
+	channel := models.NotificationChannel{ID: "c1", Type: models.ChannelTelegram, Enabled: true, Target: "42"}
+
+	alert := testNotification()
+	reminder := testNotification()
+	reminder.Kind = models.NotificationReminder
+	reminder.Text = "Time to check your glucose"
+	for _, notification := range []models.Notification{alert, reminder} {
+		if err := notifier.Send(context.Background(), channel, notification); err != nil {
+			t.Fatalf("Send: %v", err)
+		}
+	}
+
+	if len(sent) != 2 {
+		t.Fatalf("sent %d messages, want 2", len(sent))
+	}
+	if sent[0]["chat_id"] != "42" || sent[0]["text"] != "‼️ Glucose is 3.1 and falling" {
+		t.Errorf("urgent alert = %v", sent[0])
+	}
+	if sent[1]["text"] != "Time to check your glucose" {
+		t.Errorf("reminder = %v, want the text without the urgent marker", sent[1])
+	}
+}
+
+func TestTelegramNotifierErrors(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      int
+		description string
+		permanent   bool
+	}{
+		{name: "blocked", status: http.StatusForbidden, description: "Forbidden: bot was blocked by the user", permanent: true},
+		{name: "chat not found", status: http.StatusBadRequest, description: "Bad Request: chat not found", permanent: true},
+		{name: "rate limited", status: http.StatusTooManyRequests, description: "Too Many Requests: retry after 5"},
+		{name: "server error", status: http.StatusBadGateway, description: "Bad Gateway"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var sent []map[string]interface{}
+			server := fakeBotAPI(t, tt.status, tt.description, &sent)
+			notifier := NewTelegramNotifier(server.URL, testBotToken)
+			channel := models.NotificationChannel{ID: "c1", Type: models.ChannelTelegram, Enabled: true, Target: "42"}
+
+			err := notifier.Send(context.Background(), channel, testNotification())
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			if IsPermanent(err) != tt.permanent {
+				t.Errorf("IsPermanent(%v) = %v, want %v", err, IsPermanent(err), tt.permanent)
+			}
+			if !strings.Contains(err.Error(), tt.description) {
+				t.Errorf("err = %v, want the API's description", err)
+			}
+		})
+	}
+}
+
+func TestTelegramNotifierRedactsToken(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	url := server.URL
+	server.Close()
+
+	notifier := NewTelegramNotifier(url, testBotToken)
+	channel := models.NotificationChannel{ID: "c1", Type: models.ChannelTelegram, Enabled: true, Target: "42"}
+	err := notifier.Send(context.Background(), channel, testNotification())
+	if err == nil || IsPermanent(err) {
+		t.Fatalf("err = %v, want a temporary error", err)
+	}
+	if strings.Contains(err.Error(), testBotToken) {
+		t.Errorf("error leaks the bot token: %v", err)
+	}
+}
@@ -0,0 +1,86 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/yourusername/diabetes-assistant/internal/models"
+)
+
+// Webhook request headers. The signature is the hex HMAC-SHA256 of "<timestamp>.<body>" keyed
+// with the channel's secret, receivers should reject old timestamps to prevent replays.
+const (
+	SignatureHeader = "X-Diabetes-Assistant-Signature"
+	TimestampHeader = "X-Diabetes-Assistant-Timestamp"
+)
+
+// WebhookNotifier posts notifications as JSON to the channel's URL
+type WebhookNotifier struct {
+	client *http.Client
+}
+
+// NewWebhookNotifier creates a new webhook notifier
+func NewWebhookNotifier() *WebhookNotifier {
+	return &WebhookNotifier{
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Send posts the notification and fails unless the receiver answers 2xx
+func (n *WebhookNotifier) Send(ctx context.Context, channel models.NotificationChannel, notification models.Notification) error {
+	body, err := json.Marshal(notification)
+	if err != nil {
+		return Permanent(fmt.Errorf("failed to encode notification: %w", err))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, channel.Target, bytes.NewReader(body))
+	if err != nil {
+		return Permanent(fmt.Errorf("invalid webhook URL: %w", err))
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(TimestampHeader, timestamp)
+	if channel.Secret != "" {
+		req.Header.Set(SignatureHeader, "sha256="+Sign(channel.Secret, timestamp, body))
+	}
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("webhook request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+
+	return statusError("webhook", resp.StatusCode)
+}
+
+// Sign returns the hex HMAC-SHA256 signature of a webhook body sent at the given Unix timestamp
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// statusError turns an unsuccessful HTTP status into an error. Client errors other than timeouts
+// and rate limits are permanent: the request won't succeed when repeated.
+func statusError(service string, status int) error {
+	if status >= 200 && status < 300 {
+		return nil
+	}
+	err := fmt.Errorf("%s answered %d", service, status)
+	if status >= 400 && status < 500 && status != http.StatusRequestTimeout && status != http.StatusTooManyRequests {
+		return Permanent(err)
+	}
+	return err
+}
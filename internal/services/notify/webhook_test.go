@@ -0,0 +1,136 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yourusername/diabetes-assistant/internal/models"
+)
+
+func testNotification() models.Notification {
+	return models.Notification{
+		ID:          "n1",
+		UserID:      "user1",
+		ChannelID:   "c1",
+		Kind:        models.NotificationAlert,
+		Subject:     "Urgent glucose alert",
+		Text:        "Glucose is 3.1 and falling",
+		Urgent:      true,
+		Status:      models.NotificationPending,
+		CreatedAt:   time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+		ChannelType: models.ChannelWebhook,
+	}
+}
+
+func TestWebhookNotifierSignsPayload(t *testing.T) {
+	var got struct {
+		contentType, timestamp, signature string
+		body                              []byte
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got.contentType = r.Header.Get("Content-Type")
+		got.timestamp = r.Header.Get(TimestampHeader)
+		got.signature = r.Header.Get(SignatureHeader)
+		got.body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	channel := models.NotificationChannel{ID: "c1", Type: models.ChannelWebhook, Enabled: true, Target: server.URL, Secret: "s3cret"}
+	if err := NewWebhookNotifier().Send(context.Background(), channel, testNotification()); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	if got.contentType != "application/json" {
+		t.Errorf("Content-Type = %q", got.contentType)
+	}
+	sent, err := strconv.ParseInt(got.timestamp, 10, 64)
+	if err != nil || time.Since(time.Unix(sent, 0)) > time.Minute {
+		t.Errorf("%s = %q, want the current Unix time", TimestampHeader, got.timestamp)
+	}
+	if want := "sha256=" + Sign("s3cret", got.timestamp, got.body); got.signature != want {
+		t.Errorf("%s = %q, want %q", SignatureHeader, got.signature, want)
+	}
+
+	var payload models.Notification
+	if err := json.Unmarshal(got.body, &payload); err != nil {
+		t.Fatalf("payload: %v", err)
+	}
+	if payload.ID != "n1" || payload.Text != "Glucose is 3.1 and falling" || !payload.Urgent {
+		t.Errorf("payload = %+v", payload)
+	}
+}
+
+func TestWebhookNotifierWithoutSecret(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(SignatureHeader) != "" {
+			t.Errorf("unexpected %s header without a secret", SignatureHeader)
+		}
+	}))
+	defer server.Close()
+
+	channel := models.NotificationChannel{ID: "c1", Type: models.ChannelWebhook, Enabled: true, Target: server.URL}
+	if err := NewWebhookNotifier().Send(context.Background(), channel, testNotification()); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+}
+
+func TestWebhookNotifierStatusErrors(t *testing.T) {
+	tests := []struct {
+		status    int
+		wantErr   bool
+		permanent bool
+	}{
+		{status: http.StatusOK},
+		{status: http.StatusAccepted},
+		{status: http.StatusBadRequest, wantErr: true, permanent: true},
+		{status: http.StatusGone, wantErr: true, permanent: true},
+		{status: http.StatusRequestTimeout, wantErr: true},
+		{status: http.StatusTooManyRequests, wantErr: true},
+		{status: http.StatusInternalServerError, wantErr: true},
+		{status: http.StatusBadGateway, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(strconv.Itoa(tt.status), func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+			}))
+			defer server.Close()
+
+			channel := models.NotificationChannel{ID: "c1", Type: models.ChannelWebhook, Enabled: true, Target: server.URL}
+			err := NewWebhookNotifier().Send(context.Background(), channel, testNotification())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want error %v", err, tt.wantErr)
+			}
+			if err != nil && IsPermanent(err) != tt.permanent {
+				t.Errorf("IsPermanent(%v) = %v, want %v", err, IsPermanent(err), tt.permanent)
+			}
+		})
+	}
+}
+
+func TestWebhookNotifierUnreachable(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	url := server.URL
+	server.Close()
+
+	channel := models.NotificationChannel{ID: "c1", Type: models.ChannelWebhook, Enabled: true, Target: url}
+	err := NewWebhookNotifier().Send(context.Background(), channel, testNotification())
+	if err == nil || IsPermanent(err) {
+		t.Errorf("err = %v, want a temporary error", err)
+	}
+
+	channel.Target = "://not a url"
+	err = NewWebhookNotifier().Send(context.Background(), channel, testNotification())
+	if err == nil || !IsPermanent(err) || !strings.Contains(err.Error(), "invalid webhook URL") {
+		t.Errorf("err = %v, want a permanent invalid URL error", err)
+	}
+}
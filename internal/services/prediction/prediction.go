@@ -0,0 +1,133 @@
+package prediction
+
+import (
+	"errors"
+	"math"
+	"time"
+
+	"github.com/yourusername/diabetes-assistant/internal/models"
+	"github.com/yourusername/diabetes-assistant/internal/services/cob"
+	"github.com/yourusername/diabetes-assistant/internal/services/glucose"
+	"github.com/yourusername/diabetes-assistant/internal/services/insulin"
+)
+
+// Forecast horizons
+const (
+	Step           = 5 * time.Minute
+	MinHorizon     = 30 * time.Minute
+	MaxHorizon     = 180 * time.Minute
+	DefaultHorizon = MaxHorizon
+)
+
+const (
+	momentumMinutes = 30.0 // The current trend fades out over this time
+	minGlucose      = 2.2  // mmol/L, the lowest value CGMs report
+	maxGlucose      = 22.2 // mmol/L, the highest value CGMs report
+)
+
+// ErrNoGlucose is returned when there is no recent reading to start the forecast from
+var ErrNoGlucose = errors.New("no glucose reading in the last 15 minutes")
+
+// Input is everything a forecast is computed from
+type Input struct {
+	Now      time.Time
+	Readings []models.BloodSugarReading
+	Doses    []models.InsulinDose
+	Meals    []models.MealRecord
+	Settings *models.Settings
+}
+
+// Predict forecasts glucose over the horizon in 5 minute steps. Like Loop and oref0 it adds up
+// three effects on the latest reading: the current trend fading out over 30 minutes, the activity
+// of insulin on board scaled by the sensitivity schedule, and carb absorption (see package cob)
+// scaled by the sensitivity to carb ratio schedule.
+func Predict(in Input, horizon time.Duration) (*models.Forecast, error) {
+	latest, ok := glucose.Latest(in.Readings, in.Now)
+	if !ok {
+		return nil, ErrNoGlucose
+	}
+	horizon = clampHorizon(horizon)
+
+	rate, _ := glucose.RateOfChange(in.Readings, in.Now)
+	settings := in.Settings
+	meals := cob.Eaten(in.Meals, in.Doses)
+
+	forecast := &models.Forecast{
+		GeneratedAt:  in.Now,
+		StartGlucose: latest.Value,
+		MinGlucose:   latest.Value,
+		MinGlucoseAt: in.Now,
+		MaxGlucose:   latest.Value,
+		IOB:          insulin.OnBoard(in.Doses, in.Now, settings.IOBDuration),
+		COB:          round(cob.OnBoard(meals, settings, in.Now), 1),
+		Points:       []models.ForecastPoint{{Time: in.Now, Minutes: 0, Glucose: round(latest.Value, 1)}},
+	}
+
+	bg := latest.Value
+	stepMinutes := Step.Minutes()
+	for elapsed := Step; elapsed <= horizon; elapsed += Step {
+		from := in.Now.Add(elapsed - Step)
+		to := in.Now.Add(elapsed)
+
+		midpoint := (elapsed - Step/2).Minutes()
+		momentum := rate * stepMinutes * math.Max(0, 1-midpoint/momentumMinutes)
+		insulinEffect := -settings.SensitivityAt(from) * insulinActivity(in.Doses, settings.IOBDuration, from, to)
+		carbEffect := settings.CarbSensitivityAt(from) * cob.Absorbed(meals, settings, from, to)
+
+		forecast.Effects.Momentum += momentum
+		forecast.Effects.Insulin += insulinEffect
+		forecast.Effects.Carbs += carbEffect
+
+		bg = math.Min(maxGlucose, math.Max(minGlucose, bg+momentum+insulinEffect+carbEffect))
+		point := models.ForecastPoint{Time: to, Minutes: int(elapsed.Minutes()), Glucose: round(bg, 1)}
+		forecast.Points = append(forecast.Points, point)
+
+		if point.Glucose < forecast.MinGlucose {
+			forecast.MinGlucose = point.Glucose
+			forecast.MinGlucoseAt = to
+		}
+		forecast.MaxGlucose = math.Max(forecast.MaxGlucose, point.Glucose)
+	}
+
+	forecast.EventualGlucose = forecast.Points[len(forecast.Points)-1].Glucose
+	forecast.MinGlucose = round(forecast.MinGlucose, 1)
+	forecast.MaxGlucose = round(forecast.MaxGlucose, 1)
+	forecast.Effects.Momentum = round(forecast.Effects.Momentum, 1)
+	forecast.Effects.Insulin = round(forecast.Effects.Insulin, 1)
+	forecast.Effects.Carbs = round(forecast.Effects.Carbs, 1)
+	return forecast, nil
+}
+
+// clampHorizon keeps a horizon within 30 to 180 minutes, rounded down to whole steps
+func clampHorizon(horizon time.Duration) time.Duration {
+	if horizon <= 0 {
+		return DefaultHorizon
+	}
+	horizon = horizon.Truncate(Step)
+	if horizon < MinHorizon {
+		return MinHorizon
+	}
+	if horizon > MaxHorizon {
+		return MaxHorizon
+	}
+	return horizon
+}
+
+// insulinActivity returns the units of insulin that act between from and to
+func insulinActivity(doses []models.InsulinDose, actionHours float64, from, to time.Time) float64 {
+	total := 0.0
+	for _, dose := range doses {
+		if !insulin.IsRapidActing(dose) {
+			continue
+		}
+		before := insulin.RemainingFraction(from.Sub(dose.Timestamp).Minutes(), actionHours)
+		after := insulin.RemainingFraction(to.Sub(dose.Timestamp).Minutes(), actionHours)
+		total += dose.Units * (before - after)
+	}
+	return total
+}
+
+func round(x float64, decimals int) float64 {
+	pow := math.Pow(10, float64(decimals))
+	return math.Round(x*pow) / pow
+}
@@ -0,0 +1,172 @@
+package prediction
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/yourusername/diabetes-assistant/internal/models"
+	"github.com/yourusername/diabetes-assistant/internal/services/insulin"
+	"github.com/yourusername/diabetes-assistant/internal/storage"
+)
+
+// HistoryWindow is how far back doses and meals can still act, it covers the longest
+// insulin action and carb absorption times
+const HistoryWindow = 9 * time.Hour
+
+const (
+	// hypoThreshold is the predicted glucose a dose must not go below, in mmol/L
+	hypoThreshold = 3.9
+)
+
+// Service forecasts glucose from the readings, doses and meals in storage
+type Service struct {
+	storage storage.Storage
+}
+
+// NewService creates a new prediction service
+func NewService(storage storage.Storage) *Service {
+	return &Service{storage: storage}
+}
+
+// Forecast predicts the user's glucose over the horizon from now
+func (s *Service) Forecast(ctx context.Context, userID string, horizon time.Duration) (*models.Forecast, error) {
+	user, err := s.storage.GetUser(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch user: %w", err)
+	}
+	if user == nil {
+		// Readings are only stored for existing users
+		return nil, ErrNoGlucose
+	}
+
+	in, err := s.input(ctx, userID, &user.Settings, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	forecast, err := Predict(*in, horizon)
+	if err != nil {
+		return nil, err
+	}
+	forecast.UserID = userID
+	return forecast, nil
+}
+
+// CheckDose predicts the outcome of eating a meal and injecting units of insulin now, on top of
+// the insulin and carbs already on board. The meal is taken as eaten now. Fat and protein covered
+// by an extended bolus are not part of the model, so units should be the immediate dose.
+// Returns ErrNoGlucose without a recent reading.
+func (s *Service) CheckDose(ctx context.Context, userID string, settings *models.Settings, meal models.MealRecord, units float64) (*models.DoseCheck, error) {
+	now := time.Now()
+	in, err := s.input(ctx, userID, settings, now)
+	if err != nil {
+		return nil, err
+	}
+
+	iob := insulin.OnBoard(in.Doses, now, settings.IOBDuration)
+	// The meal isn't saved yet, so it counts as eaten rather than through the dose's meal ID
+	meal.Timestamp = now
+	meal.EatenAt = &now
+	in.Meals = append(in.Meals, meal)
+	in.Doses = append(in.Doses, models.InsulinDose{Timestamp: now, Units: units, Type: models.DoseTypeBolus})
+
+	forecast, err := Predict(*in, MaxHorizon)
+	if err != nil {
+		return nil, err
+	}
+
+	check := &models.DoseCheck{
+		Status:          models.DoseCheckOK,
+		EventualGlucose: forecast.EventualGlucose,
+		MinGlucose:      forecast.MinGlucose,
+		MinutesToMin:    int(forecast.MinGlucoseAt.Sub(now).Minutes()),
+		IOB:             iob,
+	}
+
+	switch {
+	case forecast.MinGlucose < hypoThreshold:
+		check.Status = models.DoseCheckLow
+		check.SuggestedReduction = round(reductionToTarget(*in, settings.TargetMin), 1)
+	case forecast.EventualGlucose > settings.TargetMax:
+		check.Status = models.DoseCheckHigh
+	}
+	check.Message = doseCheckMessage(settings.Locale, check)
+	return check, nil
+}
+
+// reductionToTarget finds how much less insulin the last dose of in must have to keep the predicted
+// minimum at or above target. It searches rather than dividing by the sensitivity because only part
+// of the dose acts before the minimum, and predictions are clamped to the CGM range.
+func reductionToTarget(in Input, target float64) float64 {
+	doses := append([]models.InsulinDose(nil), in.Doses...)
+	in.Doses = doses
+	units := doses[len(doses)-1].Units
+
+	low, high := 0.0, units
+	for i := 0; i < 20; i++ {
+		mid := (low + high) / 2
+		doses[len(doses)-1].Units = units - mid
+		forecast, err := Predict(in, MaxHorizon)
+		if err != nil {
+			return units
+		}
+		if forecast.MinGlucose >= target {
+			high = mid
+		} else {
+			low = mid
+		}
+	}
+	return high
+}
+
+// input loads the recent readings, doses and meals a forecast at now is computed from
+func (s *Service) input(ctx context.Context, userID string, settings *models.Settings, now time.Time) (*Input, error) {
+	readings, err := s.storage.GetRecentBloodSugarReadings(userID, 0, now.Add(-time.Hour))
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch readings: %w", err)
+	}
+
+	doses, err := s.storage.GetInsulinDoses(ctx, userID, now.Add(-HistoryWindow))
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch doses: %w", err)
+	}
+
+	meals, err := s.storage.GetMealRecords(ctx, userID, now.Add(-HistoryWindow))
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch meals: %w", err)
+	}
+
+	return &Input{
+		Now:      now,
+		Readings: readings,
+		Doses:    doses,
+		Meals:    meals,
+		Settings: settings,
+	}, nil
+}
+
+// doseCheckMessage explains a dose check in the user's language
+func doseCheckMessage(locale string, check *models.DoseCheck) string {
+	if locale == "en" {
+		switch check.Status {
+		case models.DoseCheckLow:
+			return fmt.Sprintf("With this dose glucose is predicted to drop to %.1f in %d minutes. Consider %.1f U less insulin (%.1f U already on board).",
+				check.MinGlucose, check.MinutesToMin, check.SuggestedReduction, check.IOB)
+		case models.DoseCheckHigh:
+			return fmt.Sprintf("With this dose glucose is predicted to reach %.1f in 3 hours, above your target range.", check.EventualGlucose)
+		default:
+			return fmt.Sprintf("With this dose glucose is predicted to be %.1f in 3 hours.", check.EventualGlucose)
+		}
+	}
+
+	switch check.Status {
+	case models.DoseCheckLow:
+		return fmt.Sprintf("С этой дозой сахар, по прогнозу, опустится до %.1f через %d минут. Возможно, стоит уменьшить дозу на %.1f ЕД (активного инсулина: %.1f ЕД).",
+			check.MinGlucose, check.MinutesToMin, check.SuggestedReduction, check.IOB)
+	case models.DoseCheckHigh:
+		return fmt.Sprintf("С этой дозой сахар, по прогнозу, через 3 часа будет %.1f, выше целевого диапазона.", check.EventualGlucose)
+	default:
+		return fmt.Sprintf("С этой дозой сахар, по прогнозу, через 3 часа будет %.1f.", check.EventualGlucose)
+	}
+}
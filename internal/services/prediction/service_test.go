@@ -0,0 +1,61 @@
+package prediction
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/yourusername/diabetes-assistant/internal/models"
+	"github.com/yourusername/diabetes-assistant/internal/storage"
+)
+
+// flatGlucoseService returns a service for a user whose glucose has been flat at value for the last hour
+func flatGlucoseService(t *testing.T, settings *models.Settings, value float64) *Service {
+	t.Helper()
+	store := storage.NewInMemoryStorage()
+	if err := store.CreateUser(&models.User{UserID: settings.UserID, Settings: *settings}); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	var readings []models.BloodSugarReading
+	for minutes := 60; minutes >= 0; minutes -= 5 {
+		readings = append(readings, models.BloodSugarReading{Value: value, Timestamp: now.Add(-time.Duration(minutes) * time.Minute), Source: "test"})
+	}
+	if err := store.AddBloodSugarReadings(context.Background(), settings.UserID, readings); err != nil {
+		t.Fatal(err)
+	}
+	return NewService(store)
+}
+
+func TestCheckDoseCountsTheMealCarbs(t *testing.T) {
+	settings := models.CreateDefaultSettings("user1")
+	settings.CarbRatioPeriods = []models.CarbRatioPeriod{{StartTime: "00:00", Ratio: 10, Hours: 24}}
+	service := flatGlucoseService(t, settings, 7)
+	ctx := context.Background()
+
+	// The dose that exactly covers the meal: the carbs offset it and glucose stays up
+	meal := models.MealRecord{Carbs: 60}
+	units := meal.Carbs / settings.CarbRatioAt(time.Now())
+	check, err := service.CheckDose(ctx, "user1", settings, meal, units)
+	if err != nil {
+		t.Fatalf("CheckDose: %v", err)
+	}
+	if check.Status == models.DoseCheckLow || check.SuggestedReduction != 0 {
+		t.Errorf("check = %+v, want the meal's carbs to offset the dose", check)
+	}
+	if check.MinGlucose < hypoThreshold {
+		t.Errorf("MinGlucose = %.1f, want at least %.1f", check.MinGlucose, hypoThreshold)
+	}
+
+	// The same dose without carbs goes low
+	noCarbs, err := service.CheckDose(ctx, "user1", settings, models.MealRecord{}, units)
+	if err != nil {
+		t.Fatalf("CheckDose: %v", err)
+	}
+	if noCarbs.Status != models.DoseCheckLow || noCarbs.SuggestedReduction <= 0 {
+		t.Errorf("check without carbs = %+v, want low with a reduction", noCarbs)
+	}
+	if noCarbs.MinGlucose >= check.MinGlucose {
+		t.Errorf("MinGlucose without carbs = %.1f, with = %.1f", noCarbs.MinGlucose, check.MinGlucose)
+	}
+}
@@ -0,0 +1,120 @@
+package products
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yourusername/diabetes-assistant/internal/models"
+)
+
+// SourceOpenFoodFacts marks products imported from an Open Food Facts dump
+const SourceOpenFoodFacts = "openfoodfacts"
+
+// ImportStats summarizes an import run
+type ImportStats struct {
+	Rows     int `json:"rows"`     // Data rows read from the dump
+	Imported int `json:"imported"` // Products saved
+	Skipped  int `json:"skipped"`  // Rows without a barcode, name or carbohydrate value
+}
+
+// ImportOpenFoodFacts streams an Open Food Facts CSV export (tab-separated, with a header row)
+// and saves products in batches. Only rows with a barcode, a name and carbohydrates per 100g are kept.
+func ImportOpenFoodFacts(ctx context.Context, r io.Reader, batchSize int, save func(context.Context, []models.Product) error) (*ImportStats, error) {
+	if batchSize <= 0 {
+		batchSize = 1000
+	}
+
+	reader := csv.NewReader(r)
+	reader.Comma = '\t'
+	reader.LazyQuotes = true
+	reader.FieldsPerRecord = -1
+	reader.ReuseRecord = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read header: %w", err)
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.TrimSpace(name)] = i
+	}
+	for _, required := range []string{"code", "product_name", "carbohydrates_100g"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("missing column %q, expected an Open Food Facts CSV export", required)
+		}
+	}
+
+	field := func(record []string, name string) string {
+		i, ok := columns[name]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	stats := &ImportStats{}
+	batch := make([]models.Product, 0, batchSize)
+	now := time.Now()
+
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			// Dumps contain the occasional broken line, skip it instead of failing the whole import
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) {
+				stats.Rows++
+				stats.Skipped++
+				continue
+			}
+			return stats, fmt.Errorf("failed to read dump: %w", err)
+		}
+		stats.Rows++
+
+		barcode := NormalizeBarcode(field(record, "code"))
+		name := field(record, "product_name")
+		carbs, err := strconv.ParseFloat(field(record, "carbohydrates_100g"), 64)
+		if barcode == "" || name == "" || err != nil || carbs < 0 || carbs > 100 {
+			stats.Skipped++
+			continue
+		}
+
+		servingQuantity, _ := strconv.ParseFloat(field(record, "serving_quantity"), 64)
+		batch = append(batch, models.Product{
+			Barcode:         barcode,
+			Name:            name,
+			Brand:           field(record, "brands"),
+			CarbsPer100g:    carbs,
+			ServingSize:     field(record, "serving_size"),
+			ServingQuantity: servingQuantity,
+			Source:          SourceOpenFoodFacts,
+			UpdatedAt:       now,
+		})
+
+		if len(batch) == batchSize {
+			if err := save(ctx, batch); err != nil {
+				return stats, fmt.Errorf("failed to save products: %w", err)
+			}
+			stats.Imported += len(batch)
+			batch = batch[:0]
+		}
+	}
+
+	if len(batch) > 0 {
+		if err := save(ctx, batch); err != nil {
+			return stats, fmt.Errorf("failed to save products: %w", err)
+		}
+		stats.Imported += len(batch)
+	}
+
+	return stats, nil
+}
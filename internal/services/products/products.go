@@ -0,0 +1,76 @@
+package products
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/yourusername/diabetes-assistant/internal/models"
+	"github.com/yourusername/diabetes-assistant/internal/storage"
+)
+
+// ErrWeightRequired is returned when neither a weight nor the product's serving size is known
+var ErrWeightRequired = errors.New("food weight is required for this product")
+
+// NormalizeBarcode keeps only the digits of a barcode
+func NormalizeBarcode(barcode string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, barcode)
+}
+
+// Lookup finds a product by barcode in the local database. UPC-A codes are also tried as
+// EAN-13 with a leading zero (and the other way around), since dumps store them either way.
+func Lookup(ctx context.Context, store storage.Storage, barcode string) (*models.Product, error) {
+	barcode = NormalizeBarcode(barcode)
+	if barcode == "" {
+		return nil, errors.New("invalid barcode")
+	}
+
+	candidates := []string{barcode}
+	if len(barcode) == 12 {
+		candidates = append(candidates, "0"+barcode)
+	} else if len(barcode) == 13 && barcode[0] == '0' {
+		candidates = append(candidates, barcode[1:])
+	}
+
+	for _, candidate := range candidates {
+		product, err := store.GetProductByBarcode(ctx, candidate)
+		if err != nil {
+			return nil, err
+		}
+		if product != nil {
+			return product, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// CarbsForWeight returns the carbohydrates in weight grams of the product and the weight used.
+// One serving is assumed when no weight is given.
+func CarbsForWeight(product *models.Product, weight float64) (float64, float64, error) {
+	if weight <= 0 {
+		weight = product.ServingQuantity
+	}
+	if weight <= 0 {
+		return 0, 0, ErrWeightRequired
+	}
+
+	return product.CarbsPer100g * weight / 100, weight, nil
+}
+
+// Describe explains a barcode-based carbohydrate value in the user's language
+func Describe(product *models.Product, weight, carbs float64, locale string) string {
+	if locale == "en" {
+		return fmt.Sprintf("Product database (%s): %.1f g carbohydrates per 100 g × %.0f g = %.1f g.",
+			product.Source, product.CarbsPer100g, weight, carbs)
+	}
+
+	return fmt.Sprintf("По базе продуктов (%s): %.1f г углеводов на 100 г × %.0f г = %.1f г.",
+		product.Source, product.CarbsPer100g, weight, carbs)
+}
@@ -0,0 +1,363 @@
+package reminders
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/yourusername/diabetes-assistant/internal/models"
+	"github.com/yourusername/diabetes-assistant/internal/storage"
+)
+
+const (
+	// missedAfter is how late a reminder may still be delivered, e.g. after the server was down.
+	// Later ones are marked missed, repeating ones move on to their next due time.
+	missedAfter = time.Hour
+	// readingWindow is how close to a meal check a reading makes it unnecessary
+	readingWindow = 30 * time.Minute
+	// claimTimeout is how long a claimed reminder is hidden from other replicas. A reminder that
+	// failed to deliver, or whose replica stopped while delivering it, is tried again after this.
+	claimTimeout = 2 * time.Minute
+)
+
+// Errors returned when managing a user's reminders
+var (
+	ErrNotFound   = errors.New("reminder not found")
+	ErrNotPending = errors.New("reminder is no longer pending")
+)
+
+// DeliverFunc delivers a due reminder with its text in the user's language
+type DeliverFunc func(ctx context.Context, reminder models.Reminder, text string) error
+
+// Service schedules reminders, persists them and delivers them when they are due. Pending
+// reminders live in storage, so they survive restarts.
+type Service struct {
+	storage storage.Storage
+	deliver DeliverFunc
+}
+
+// NewService creates a new reminder service. Reminders are only logged until SetDeliverFunc sets a delivery.
+func NewService(storage storage.Storage) *Service {
+	return &Service{
+		storage: storage,
+		deliver: logReminder,
+	}
+}
+
+// SetDeliverFunc sets how reminders are delivered
+func (s *Service) SetDeliverFunc(deliver DeliverFunc) {
+	s.deliver = deliver
+}
+
+// Create schedules a new reminder. Repeating reminders start at their next due time from now.
+func (s *Service) Create(ctx context.Context, reminder *models.Reminder) error {
+	now := time.Now()
+	reminder.ID = uuid.New().String()
+	reminder.Status = models.ReminderPending
+	reminder.CreatedAt = now
+	reminder.LastSentAt = nil
+	if reminder.Repeats() && !reminder.DueAt.After(now) {
+		reminder.DueAt = reminder.NextDue(now)
+	}
+
+	if err := s.storage.SaveReminder(ctx, reminder); err != nil {
+		return fmt.Errorf("failed to save reminder: %w", err)
+	}
+	return nil
+}
+
+// Get returns one of the user's reminders
+func (s *Service) Get(ctx context.Context, userID, id string) (*models.Reminder, error) {
+	reminder, err := s.storage.GetReminder(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch reminder: %w", err)
+	}
+	if reminder == nil || reminder.UserID != userID {
+		return nil, ErrNotFound
+	}
+	return reminder, nil
+}
+
+// Update replaces the schedule and note of a pending reminder
+func (s *Service) Update(ctx context.Context, userID, id string, changes models.Reminder) (*models.Reminder, error) {
+	reminder, err := s.Get(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if reminder.Status != models.ReminderPending {
+		return nil, ErrNotPending
+	}
+
+	reminder.Note = changes.Note
+	reminder.TimeOfDay = changes.TimeOfDay
+	reminder.IntervalDays = changes.IntervalDays
+	reminder.DueAt = changes.DueAt
+	if now := time.Now(); reminder.Repeats() && !reminder.DueAt.After(now) {
+		reminder.DueAt = reminder.NextDue(now)
+	}
+
+	if err := s.storage.SaveReminder(ctx, reminder); err != nil {
+		return nil, fmt.Errorf("failed to save reminder: %w", err)
+	}
+	return reminder, nil
+}
+
+// Cancel stops a pending reminder
+func (s *Service) Cancel(ctx context.Context, userID, id string) (*models.Reminder, error) {
+	reminder, err := s.Get(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if reminder.Status != models.ReminderPending {
+		return nil, ErrNotPending
+	}
+
+	reminder.Status = models.ReminderCancelled
+	if err := s.storage.SaveReminder(ctx, reminder); err != nil {
+		return nil, fmt.Errorf("failed to save reminder: %w", err)
+	}
+	return reminder, nil
+}
+
+// MealLogged schedules a glucose check after a meal, unless the user turned meal checks off.
+// It can be used as the analysis service's MealFunc.
+func (s *Service) MealLogged(ctx context.Context, meal models.MealRecord) error {
+	settings, err := s.settings(meal.UserID)
+	if err != nil {
+		return err
+	}
+	config := settings.ReminderSettings()
+	if !config.MealCheck {
+		return nil
+	}
+	minutes := config.MealCheckMinutes
+	if minutes <= 0 {
+		minutes = models.DefaultMealCheckMinutes
+	}
+
+	return s.Create(ctx, &models.Reminder{
+		UserID:  meal.UserID,
+		Type:    models.ReminderMealCheck,
+		Message: mealCheckText(settings.Locale, meal, minutes),
+		DueAt:   meal.Timestamp.Add(time.Duration(minutes) * time.Minute),
+		Ref:     meal.ID,
+	})
+}
+
+// Recheck schedules the reminder to check glucose again after treating a low, it can be used as
+// the hypo service's RecheckFunc
+func (s *Service) Recheck(ctx context.Context, treatment models.HypoTreatment) error {
+	settings, err := s.settings(treatment.UserID)
+	if err != nil {
+		return err
+	}
+
+	return s.Create(ctx, &models.Reminder{
+		UserID:  treatment.UserID,
+		Type:    models.ReminderHypoRecheck,
+		Message: recheckText(settings.Locale, treatment),
+		DueAt:   treatment.RecheckAt,
+		Ref:     treatment.ID,
+	})
+}
+
+// ReadingReceived cancels the glucose checks a new reading makes unnecessary: all pending
+// rechecks after a low, and meal checks due within 30 minutes
+func (s *Service) ReadingReceived(ctx context.Context, userID string, at time.Time) error {
+	reminders, err := s.storage.GetReminders(ctx, userID, at)
+	if err != nil {
+		return fmt.Errorf("failed to fetch reminders: %w", err)
+	}
+
+	for _, reminder := range reminders {
+		if reminder.Status != models.ReminderPending || !reminder.CreatedAt.Before(at) {
+			continue
+		}
+		switch {
+		case reminder.Type == models.ReminderHypoRecheck:
+		case reminder.Type == models.ReminderMealCheck && !reminder.Repeats() && reminder.DueAt.Sub(at) <= readingWindow:
+		default:
+			continue
+		}
+
+		reminder.Status = models.ReminderCancelled
+		if err := s.storage.SaveReminder(ctx, &reminder); err != nil {
+			return fmt.Errorf("failed to save reminder: %w", err)
+		}
+	}
+	return nil
+}
+
+// Run delivers due reminders every interval until ctx is cancelled. The first run picks up the
+// reminders that came due while the server was down.
+func (s *Service) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.SendDue(ctx); err != nil {
+			log.Printf("Reminders: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// SendDue delivers the reminders that are due. Each one is claimed first, so replicas running at
+// the same time never deliver it twice. A reminder that fails to deliver is tried again once its
+// claim runs out, until it's more than an hour late.
+func (s *Service) SendDue(ctx context.Context) error {
+	now := time.Now()
+	for {
+		reminder, err := s.storage.ClaimDueReminder(ctx, now, now.Add(claimTimeout))
+		if err != nil {
+			return fmt.Errorf("failed to claim due reminder: %w", err)
+		}
+		if reminder == nil {
+			return nil
+		}
+
+		if now.Sub(reminder.DueAt) > missedAfter {
+			log.Printf("Reminders: %s reminder %s of user %s was missed", reminder.Type, reminder.ID, reminder.UserID)
+			if reminder.Repeats() {
+				reminder.DueAt = reminder.NextDue(now)
+			} else {
+				reminder.Status = models.ReminderMissed
+			}
+			reminder.ClaimedUntil = nil
+			if err := s.storage.SaveReminder(ctx, reminder); err != nil {
+				return fmt.Errorf("failed to save reminder: %w", err)
+			}
+			continue
+		}
+
+		settings, err := s.settings(reminder.UserID)
+		if err != nil {
+			return err
+		}
+		if err := s.deliver(ctx, *reminder, reminderText(settings.Locale, *reminder)); err != nil {
+			log.Printf("Reminders: failed to deliver reminder %s: %v", reminder.ID, err)
+			continue
+		}
+
+		reminder.LastSentAt = &now
+		if reminder.Repeats() {
+			reminder.DueAt = reminder.NextDue(now)
+		} else {
+			reminder.Status = models.ReminderSent
+		}
+		reminder.ClaimedUntil = nil
+		if err := s.storage.SaveReminder(ctx, reminder); err != nil {
+			return fmt.Errorf("failed to save reminder: %w", err)
+		}
+
+		if reminder.Type == models.ReminderHypoRecheck {
+			if err := s.recheckSent(ctx, reminder.Ref, now); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// recheckSent records on the hypo treatment that its recheck reminder went out
+func (s *Service) recheckSent(ctx context.Context, treatmentID string, at time.Time) error {
+	treatment, err := s.storage.GetHypoTreatment(ctx, treatmentID)
+	if err != nil {
+		return fmt.Errorf("failed to fetch hypo treatment: %w", err)
+	}
+	if treatment == nil || !treatment.RecheckPending() {
+		return nil
+	}
+
+	treatment.RecheckSentAt = &at
+	if err := s.storage.SaveHypoTreatment(ctx, treatment); err != nil {
+		return fmt.Errorf("failed to save hypo treatment: %w", err)
+	}
+	return nil
+}
+
+// settings returns the user's settings, the defaults if the user doesn't exist
+func (s *Service) settings(userID string) (*models.Settings, error) {
+	user, err := s.storage.GetUser(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch user: %w", err)
+	}
+	if user == nil {
+		return models.CreateDefaultSettings(userID), nil
+	}
+	return &user.Settings, nil
+}
+
+// logReminder is the default reminder delivery
+func logReminder(ctx context.Context, reminder models.Reminder, text string) error {
+	log.Printf("Reminders: user %s: %s", reminder.UserID, text)
+	return nil
+}
+
+// reminderText is the text delivered for a reminder in the user's language
+func reminderText(locale string, reminder models.Reminder) string {
+	if reminder.Message != "" {
+		return reminder.Message
+	}
+
+	var text string
+	if locale == "en" {
+		switch reminder.Type {
+		case models.ReminderBasal:
+			text = "Time for your basal insulin."
+		case models.ReminderSensorChange:
+			text = "Time to change your sensor."
+		case models.ReminderNeedleChange:
+			text = "Time to change your pen needle."
+		case models.ReminderMealCheck:
+			text = "Time to check your glucose after the meal."
+		default:
+			text = "Time to check your glucose."
+		}
+	} else {
+		switch reminder.Type {
+		case models.ReminderBasal:
+			text = "Пора ввести базальный инсулин."
+		case models.ReminderSensorChange:
+			text = "Пора сменить сенсор."
+		case models.ReminderNeedleChange:
+			text = "Пора сменить иглу на шприц-ручке."
+		case models.ReminderMealCheck:
+			text = "Пора проверить сахар после еды."
+		default:
+			text = "Пора проверить сахар."
+		}
+	}
+
+	if reminder.Note != "" {
+		text += " " + reminder.Note
+	}
+	return text
+}
+
+// mealCheckText reminds the user to check glucose after a meal
+func mealCheckText(locale string, meal models.MealRecord, minutes int) string {
+	if locale == "en" {
+		return fmt.Sprintf("Time to check your glucose: %d minutes have passed since %s (%.0f g of carbs).",
+			minutes, meal.Name, meal.Carbs)
+	}
+	return fmt.Sprintf("Пора проверить сахар: прошло %d минут после приёма пищи «%s» (%.0f г углеводов).",
+		minutes, meal.Name, meal.Carbs)
+}
+
+// recheckText reminds the user to check glucose again after treating a low
+func recheckText(locale string, treatment models.HypoTreatment) string {
+	if locale == "en" {
+		return fmt.Sprintf("Time to check your glucose again: it was %.1f mmol/L at %s and %.0f g of fast carbs were suggested.",
+			treatment.Glucose, treatment.CreatedAt.Format("15:04"), treatment.Grams)
+	}
+	return fmt.Sprintf("Пора снова проверить сахар: в %s он был %.1f ммоль/л, рекомендовано %.0f г быстрых углеводов.",
+		treatment.CreatedAt.Format("15:04"), treatment.Glucose, treatment.Grams)
+}
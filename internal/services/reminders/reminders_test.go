@@ -0,0 +1,96 @@
+package reminders
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/yourusername/diabetes-assistant/internal/models"
+	"github.com/yourusername/diabetes-assistant/internal/storage"
+)
+
+func TestSendDueDeliversOnceAcrossReplicas(t *testing.T) {
+	store := storage.NewInMemoryStorage()
+	ctx := context.Background()
+	for _, id := range []string{"r1", "r2", "r3"} {
+		reminder := &models.Reminder{
+			ID:     id,
+			UserID: "user1",
+			Type:   models.ReminderCheck,
+			Status: models.ReminderPending,
+			DueAt:  time.Now().Add(-time.Minute),
+		}
+		if err := store.SaveReminder(ctx, reminder); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Each replica has its own service over the shared storage
+	var mu sync.Mutex
+	delivered := make(map[string]int)
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		service := NewService(store)
+		service.SetDeliverFunc(func(ctx context.Context, reminder models.Reminder, text string) error {
+			mu.Lock()
+			delivered[reminder.ID]++
+			mu.Unlock()
+			return nil
+		})
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := service.SendDue(ctx); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	for _, id := range []string{"r1", "r2", "r3"} {
+		if delivered[id] != 1 {
+			t.Errorf("%s delivered %d times, want once", id, delivered[id])
+		}
+		reminder, _ := store.GetReminder(ctx, id)
+		if reminder.Status != models.ReminderSent || reminder.ClaimedUntil != nil {
+			t.Errorf("%s: status %s, claimed until %v", id, reminder.Status, reminder.ClaimedUntil)
+		}
+	}
+}
+
+func TestSendDueRetriesFailedDeliveryAfterClaim(t *testing.T) {
+	store := storage.NewInMemoryStorage()
+	ctx := context.Background()
+	reminder := &models.Reminder{ID: "r1", UserID: "user1", Type: models.ReminderCheck, Status: models.ReminderPending, DueAt: time.Now()}
+	if err := store.SaveReminder(ctx, reminder); err != nil {
+		t.Fatal(err)
+	}
+
+	attempts := 0
+	service := NewService(store)
+	service.SetDeliverFunc(func(ctx context.Context, reminder models.Reminder, text string) error {
+		attempts++
+		return errors.New("channel is down")
+	})
+	if err := service.SendDue(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := service.SendDue(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if attempts != 1 {
+		t.Errorf("attempted %d times while claimed, want once", attempts)
+	}
+
+	// Once the claim runs out the reminder is due again
+	claimed, _ := store.GetReminder(ctx, "r1")
+	if claimed.Status != models.ReminderPending || claimed.ClaimedUntil == nil {
+		t.Fatalf("reminder = %+v, want pending and claimed", claimed)
+	}
+	next, err := store.ClaimDueReminder(ctx, claimed.ClaimedUntil.Add(time.Second), time.Now().Add(time.Hour))
+	if err != nil || next == nil || next.ID != "r1" {
+		t.Errorf("ClaimDueReminder after the claim = %v, %v", next, err)
+	}
+}
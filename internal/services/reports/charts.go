@@ -0,0 +1,486 @@
+package reports
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/yourusername/diabetes-assistant/internal/models"
+)
+
+// Page layout in points
+const (
+	margin         = 40.0
+	contentWidth   = pageWidth - 2*margin
+	pageBottom     = pageHeight - 45 // Content ends above the footer
+	sectionGap     = 16.0
+	rowHeight      = 13.0
+	rangeBarHeight = 150.0
+	// chartMaxGlucose is the top of the glucose axis in mmol/L, higher readings are drawn at it
+	chartMaxGlucose = 22.0
+)
+
+// Daily profile grid
+const (
+	profileColumns = 7
+	profileHeight  = 52.0
+	profileGap     = 6.0
+)
+
+var (
+	bodyText   = rgb(33, 33, 33)
+	mutedText  = rgb(110, 110, 110)
+	gridLine   = rgb(200, 200, 200)
+	headerFill = rgb(235, 238, 242)
+	stripeFill = rgb(247, 248, 250)
+	targetFill = rgb(226, 244, 228)
+	targetLine = rgb(76, 160, 90)
+	outerBand  = rgb(198, 219, 239)
+	innerBand  = rgb(107, 174, 214)
+	medianLine = rgb(8, 69, 148)
+	mealMark   = rgb(230, 140, 30)
+	doseMark   = rgb(120, 80, 170)
+)
+
+// Colors of the time in range bar, very high on top as in the AGP report
+var rangeColors = []color{
+	rgb(240, 150, 40), // Very high
+	rgb(253, 208, 90), // High
+	rgb(76, 175, 80),  // In range
+	rgb(229, 57, 53),  // Low
+	rgb(150, 20, 20),  // Very low
+}
+
+// overlayColors tell the days of the overlay apart
+var overlayColors = []color{
+	rgb(31, 119, 180), rgb(255, 127, 14), rgb(44, 160, 44), rgb(214, 39, 40),
+	rgb(148, 103, 189), rgb(140, 86, 75), rgb(227, 119, 194), rgb(127, 127, 127),
+	rgb(188, 189, 34), rgb(23, 190, 207),
+}
+
+// renderer flows sections down the pages, starting a new page when one doesn't fit
+type renderer struct {
+	doc  *pdfDocument
+	page *pdfPage
+	top  float64 // Where the next content starts
+}
+
+// column is a table column, Width in points
+type column struct {
+	Title string
+	Width float64
+	Right bool
+}
+
+// glucoseChart maps the time of day and glucose to a chart area on a page
+type glucoseChart struct {
+	page                  *pdfPage
+	x, top, width, height float64
+}
+
+func (r *renderer) newPage() {
+	r.page = r.doc.addPage()
+	r.top = margin
+}
+
+// ensureSpace starts a new page unless height fits on the current one
+func (r *renderer) ensureSpace(height float64) {
+	if r.top+height > pageBottom {
+		r.newPage()
+	}
+}
+
+func (r *renderer) heading(title string) {
+	r.ensureSpace(60)
+	r.page.setFill(bodyText)
+	r.page.text(margin, r.top+12, fontBold, 12, title)
+	r.top += 20
+}
+
+// note prints a paragraph of small text, wrapped to the content width
+func (r *renderer) note(text string) {
+	r.page.setFill(mutedText)
+	for _, line := range wrapText(text, fontRegular, 8, contentWidth) {
+		r.ensureSpace(11)
+		r.page.text(margin, r.top+8, fontRegular, 8, line)
+		r.top += 11
+	}
+	r.top += 4
+}
+
+// table prints rows under a header, repeating the header on each page it continues on
+func (r *renderer) table(columns []column, rows [][]string) {
+	for len(rows) > 0 {
+		r.ensureSpace(3 * rowHeight)
+		fit := int((pageBottom - r.top - rowHeight) / rowHeight)
+		if fit > len(rows) {
+			fit = len(rows)
+		}
+		r.top = drawTable(r.page, margin, r.top, columns, rows[:fit]) + 6
+		rows = rows[fit:]
+		if len(rows) > 0 {
+			r.newPage()
+		}
+	}
+}
+
+// drawTable draws a table with its top left corner at (x, top) and returns where it ends
+func drawTable(page *pdfPage, x, top float64, columns []column, rows [][]string) float64 {
+	var width float64
+	for _, c := range columns {
+		width += c.Width
+	}
+
+	page.setFill(headerFill)
+	page.fillRect(x, top, width, rowHeight)
+	cells := func(top float64, font string, values []string) {
+		left := x
+		for i, c := range columns {
+			if i < len(values) {
+				if c.Right {
+					page.textRight(left+c.Width-4, top+9.5, font, 7.5, values[i])
+				} else {
+					page.text(left+4, top+9.5, font, 7.5, values[i])
+				}
+			}
+			left += c.Width
+		}
+	}
+	titles := make([]string, len(columns))
+	for i, c := range columns {
+		titles[i] = c.Title
+	}
+	page.setFill(bodyText)
+	cells(top, fontBold, titles)
+
+	rowTop := top + rowHeight
+	for i, row := range rows {
+		if i%2 == 1 {
+			page.setFill(stripeFill)
+			page.fillRect(x, rowTop, width, rowHeight)
+		}
+		page.setFill(bodyText)
+		cells(rowTop, fontRegular, row)
+		rowTop += rowHeight
+	}
+
+	page.setStroke(gridLine)
+	page.setLineWidth(0.5)
+	page.strokeRect(x, top, width, rowTop-top)
+	return rowTop
+}
+
+// timeInRangeBar draws the stacked time in range bar with the share and daily time of each range
+func (r *renderer) timeInRangeBar(x, top float64, summary glucoseSummary) {
+	shares := summary.Ranges
+	ranges := []struct {
+		label  string
+		limits string
+		share  float64
+	}{
+		{"Very high", "above 13.9", shares.VeryHigh},
+		{"High", "10.1-13.9", shares.High},
+		{"In range", "3.9-10.0", shares.InRange},
+		{"Low", "3.0-3.8", shares.Low},
+		{"Very low", "below 3.0", shares.VeryLow},
+	}
+
+	const barWidth = 28.0
+	page := r.page
+	page.setFill(bodyText)
+	page.text(x, top+10, fontBold, 9, "Time in Ranges (mmol/L)")
+	barTop := top + 18
+	height := rangeBarHeight - 18
+
+	if summary.Readings == 0 {
+		page.setStroke(gridLine)
+		page.strokeRect(x, barTop, barWidth, height)
+	}
+	segmentTop := barTop
+	for i, rng := range ranges {
+		page.setFill(rangeColors[i])
+		page.fillRect(x, segmentTop, barWidth, rng.share*height)
+		segmentTop += rng.share * height
+
+		labelTop := barTop + float64(i)*height/float64(len(ranges)) + height/float64(len(ranges))/2 + 3
+		page.fillRect(x+barWidth+10, labelTop-7, 7, 7)
+		page.setFill(bodyText)
+		page.text(x+barWidth+22, labelTop, fontRegular, 8, fmt.Sprintf("%s  %s", rng.label, rng.limits))
+		page.textRight(x+200, labelTop, fontBold, 8, formatPercent(rng.share))
+		page.setFill(mutedText)
+		page.textRight(x+200, labelTop+9, fontRegular, 6.5, formatDuration(time.Duration(rng.share*24*float64(time.Hour)))+" per day")
+	}
+}
+
+// ambulatoryProfile draws the percentile bands and median of the AGP
+func (r *renderer) ambulatoryProfile(profile []agpBin, hasReadings bool) {
+	r.ensureSpace(250)
+	chart := newGlucoseChart(r.page, margin+28, r.top+4, contentWidth-28, 220)
+	chart.drawAxes()
+
+	if !hasReadings {
+		chart.message("No readings in this period")
+	}
+	for _, segment := range profileSegments(profile) {
+		chart.band(profile, segment, 0, 4, outerBand)
+		chart.band(profile, segment, 1, 3, innerBand)
+	}
+	chart.targetLines()
+	chart.page.setStroke(medianLine)
+	chart.page.setLineWidth(1.6)
+	for _, segment := range profileSegments(profile) {
+		chart.page.polyline(chart.percentileLine(profile, segment, 2))
+	}
+
+	legendTop := r.top + 4 + 220 + 26
+	legend := []struct {
+		label string
+		c     color
+	}{{"5-95%", outerBand}, {"25-75%", innerBand}, {"Median", medianLine}, {"Target range", targetLine}}
+	left := chart.x
+	for _, item := range legend {
+		r.page.setFill(item.c)
+		r.page.fillRect(left, legendTop-7, 12, 7)
+		r.page.setFill(bodyText)
+		r.page.text(left+16, legendTop, fontRegular, 8, item.label)
+		left += 30 + textWidth(fontRegular, 8, item.label)
+	}
+	r.top = legendTop + sectionGap
+}
+
+// dailyOverlay draws every day of the period on one 24-hour chart, with the AGP median on top
+func (r *renderer) dailyOverlay(data *reportData, profile []agpBin) {
+	r.ensureSpace(240)
+	chart := newGlucoseChart(r.page, margin+28, r.top+4, contentWidth-28, 200)
+	chart.drawAxes()
+	if len(data.readings) == 0 {
+		chart.message("No readings in this period")
+	}
+
+	for i, day := range data.days {
+		chart.readings(dayLines(data.readings, day, day.AddDate(0, 0, 1)), data.opts.Location, overlayColors[i%len(overlayColors)], 0.6)
+	}
+
+	chart.targetLines()
+	chart.page.setStroke(bodyText)
+	chart.page.setLineWidth(1.6)
+	for _, segment := range profileSegments(profile) {
+		chart.page.polyline(chart.percentileLine(profile, segment, 2))
+	}
+	r.top += 4 + 200 + 22 + sectionGap
+}
+
+// dailyProfiles draws a small chart per day, a week per row, with meals and doses marked
+func (r *renderer) dailyProfiles(data *reportData) {
+	loc := data.opts.Location
+	width := (contentWidth - profileGap*(profileColumns-1)) / profileColumns
+	cellHeight := profileHeight + 16
+
+	for i, day := range data.days {
+		column := i % profileColumns
+		if column == 0 {
+			if i > 0 {
+				r.top += cellHeight
+			}
+			r.ensureSpace(cellHeight)
+		}
+		x := margin + float64(column)*(width+profileGap)
+		next := day.AddDate(0, 0, 1)
+
+		r.page.setFill(bodyText)
+		r.page.text(x, r.top+8, fontBold, 7, day.Format("Mon 02 Jan"))
+		chart := newGlucoseChart(r.page, x, r.top+12, width, profileHeight)
+		chart.page.setFill(targetFill)
+		chart.page.fillRect(x, chart.y(highAbove), width, chart.y(lowBelow)-chart.y(highAbove))
+		chart.page.setStroke(gridLine)
+		chart.page.setLineWidth(0.5)
+		chart.page.strokeRect(x, chart.top, width, profileHeight)
+
+		chart.readings(dayLines(data.readings, day, next), loc, medianLine, 0.7)
+
+		chart.page.setFill(mealMark)
+		for _, meal := range data.meals {
+			if meal.Timestamp.Before(day) || !meal.Timestamp.Before(next) || meal.Carbs <= 0 {
+				continue
+			}
+			mx := chart.point(float64(minuteOfDay(meal.Timestamp.In(loc))), 0).x
+			bottom := chart.top + profileHeight
+			chart.page.polygon([]point{{mx - 2.5, bottom}, {mx + 2.5, bottom}, {mx, bottom - 4}})
+		}
+		chart.page.setFill(doseMark)
+		for _, dose := range data.doses {
+			if dose.Timestamp.Before(day) || !dose.Timestamp.Before(next) {
+				continue
+			}
+			dx := chart.point(float64(minuteOfDay(dose.Timestamp.In(loc))), 0).x
+			chart.page.fillRect(dx-1.2, chart.top+1, 2.4, 2.4)
+		}
+	}
+	if len(data.days) > 0 {
+		r.top += cellHeight
+	}
+	r.top += sectionGap
+}
+
+// dayLines returns the readings in [from, to) split where data is missing
+func dayLines(readings []models.BloodSugarReading, from, to time.Time) [][]models.BloodSugarReading {
+	var lines [][]models.BloodSugarReading
+	var line []models.BloodSugarReading
+	for _, reading := range readings {
+		if reading.Timestamp.Before(from) || !reading.Timestamp.Before(to) {
+			continue
+		}
+		if len(line) > 0 && reading.Timestamp.Sub(line[len(line)-1].Timestamp) > readingGap {
+			lines = append(lines, line)
+			line = nil
+		}
+		line = append(line, reading)
+	}
+	if len(line) > 0 {
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// profileSegments returns the runs of consecutive bins that have enough readings, as bin indexes
+func profileSegments(profile []agpBin) [][]int {
+	var segments [][]int
+	var segment []int
+	for i, bin := range profile {
+		if !bin.ok {
+			if len(segment) > 0 {
+				segments = append(segments, segment)
+			}
+			segment = nil
+			continue
+		}
+		segment = append(segment, i)
+	}
+	if len(segment) > 0 {
+		segments = append(segments, segment)
+	}
+	return segments
+}
+
+func newGlucoseChart(page *pdfPage, x, top, width, height float64) *glucoseChart {
+	return &glucoseChart{page: page, x: x, top: top, width: width, height: height}
+}
+
+// y returns the top coordinate of a glucose value
+func (c *glucoseChart) y(value float64) float64 {
+	value = math.Max(0, math.Min(value, chartMaxGlucose))
+	return c.top + c.height*(1-value/chartMaxGlucose)
+}
+
+// point returns where a glucose value at a minute of the day is drawn
+func (c *glucoseChart) point(minute, value float64) point {
+	return point{x: c.x + c.width*minute/(24*60), y: c.y(value)}
+}
+
+// binPoint returns the point of a percentile at the middle of an AGP bin
+func (c *glucoseChart) binPoint(bin int, value float64) point {
+	return c.point((float64(bin)+0.5)*24*60/agpBins, value)
+}
+
+// drawAxes draws the target range, hour and glucose grid lines and their labels
+func (c *glucoseChart) drawAxes() {
+	page := c.page
+	page.setFill(targetFill)
+	page.fillRect(c.x, c.y(highAbove), c.width, c.y(lowBelow)-c.y(highAbove))
+
+	page.setLineWidth(0.4)
+	page.setStroke(gridLine)
+	for hour := 0; hour <= 24; hour += 3 {
+		x := c.point(float64(hour*60), 0).x
+		page.line(x, c.top, x, c.top+c.height)
+		page.setFill(mutedText)
+		page.textCenter(x, c.top+c.height+10, fontRegular, 7, fmt.Sprintf("%02d:00", hour%24))
+	}
+	for _, value := range []float64{0, 3.0, 3.9, 10.0, 13.9, chartMaxGlucose} {
+		top := c.y(value)
+		if value != lowBelow && value != highAbove {
+			page.setDash(2, 2)
+			page.line(c.x, top, c.x+c.width, top)
+			page.setDash(0, 0)
+		}
+		page.setFill(mutedText)
+		page.textRight(c.x-4, top+2.5, fontRegular, 7, fmt.Sprintf("%.1f", value))
+	}
+	page.setStroke(gridLine)
+	page.strokeRect(c.x, c.top, c.width, c.height)
+	page.text(c.x-28, c.top-4, fontRegular, 7, "mmol/L")
+}
+
+// targetLines draws the limits of the target range, over the data so they stay visible
+func (c *glucoseChart) targetLines() {
+	c.page.setStroke(targetLine)
+	c.page.setLineWidth(0.8)
+	for _, value := range []float64{lowBelow, highAbove} {
+		c.page.line(c.x, c.y(value), c.x+c.width, c.y(value))
+	}
+}
+
+// band fills the area between two percentiles over a run of bins
+func (c *glucoseChart) band(profile []agpBin, segment []int, lower, upper int, fill color) {
+	points := c.percentileLine(profile, segment, upper)
+	for i := len(segment) - 1; i >= 0; i-- {
+		points = append(points, c.binPoint(segment[i], profile[segment[i]].percentiles[lower]))
+	}
+	c.page.setFill(fill)
+	c.page.polygon(points)
+}
+
+// percentileLine returns the points of a percentile over a run of bins
+func (c *glucoseChart) percentileLine(profile []agpBin, segment []int, percentile int) []point {
+	points := make([]point, len(segment))
+	for i, bin := range segment {
+		points[i] = c.binPoint(bin, profile[bin].percentiles[percentile])
+	}
+	return points
+}
+
+// readings draws lines through readings, and a dot for a reading without neighbours such as
+// a fingerstick
+func (c *glucoseChart) readings(lines [][]models.BloodSugarReading, loc *time.Location, stroke color, width float64) {
+	c.page.setStroke(stroke)
+	c.page.setFill(stroke)
+	c.page.setLineWidth(width)
+	for _, line := range lines {
+		points := make([]point, len(line))
+		for i, reading := range line {
+			points[i] = c.point(float64(minuteOfDay(reading.Timestamp.In(loc))), reading.Value)
+		}
+		if len(points) == 1 {
+			c.page.fillRect(points[0].x-1, points[0].y-1, 2, 2)
+			continue
+		}
+		c.page.polyline(points)
+	}
+}
+
+func (c *glucoseChart) message(text string) {
+	c.page.setFill(mutedText)
+	c.page.textCenter(c.x+c.width/2, c.top+c.height/2, fontRegular, 10, text)
+}
+
+// wrapText splits text into lines no wider than width
+func wrapText(text, font string, size, width float64) []string {
+	var lines []string
+	line := ""
+	for _, word := range strings.Fields(text) {
+		candidate := word
+		if line != "" {
+			candidate = line + " " + word
+		}
+		if line != "" && textWidth(font, size, candidate) > width {
+			lines = append(lines, line)
+			candidate = word
+		}
+		line = candidate
+	}
+	if line != "" {
+		lines = append(lines, line)
+	}
+	return lines
+}
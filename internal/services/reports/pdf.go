@@ -0,0 +1,247 @@
+package reports
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A4 page size in points
+const (
+	pageWidth  = 595.28
+	pageHeight = 841.89
+)
+
+// Fonts are the built-in Helvetica faces every PDF viewer has, so nothing is embedded. They only
+// cover Latin text; other characters are printed as '?'.
+const (
+	fontRegular = "F1"
+	fontBold    = "F2"
+)
+
+// helveticaWidths are the glyph widths of the printable ASCII characters from ' ' to '~' in
+// thousandths of the font size, from the Adobe font metrics
+var helveticaWidths = [...]int{
+	278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278,
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 278, 278, 584, 584, 584, 556,
+	1015, 667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, 722, 778,
+	667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 278, 278, 278, 469, 556,
+	333, 556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, 556, 556,
+	556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, 334, 260, 334, 584,
+}
+
+var helveticaBoldWidths = [...]int{
+	278, 333, 474, 556, 556, 889, 722, 238, 333, 333, 389, 584, 278, 333, 278, 278,
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 333, 333, 584, 584, 584, 611,
+	975, 722, 722, 722, 722, 667, 611, 778, 722, 278, 556, 722, 611, 833, 722, 778,
+	667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 333, 278, 333, 584, 556,
+	333, 556, 611, 556, 611, 556, 333, 611, 611, 278, 278, 556, 278, 889, 611, 611,
+	611, 611, 389, 556, 333, 611, 556, 778, 556, 556, 500, 389, 280, 389, 584,
+}
+
+type color struct {
+	r, g, b float64
+}
+
+func rgb(r, g, b uint8) color {
+	return color{float64(r) / 255, float64(g) / 255, float64(b) / 255}
+}
+
+type point struct {
+	x, y float64
+}
+
+// pdfDocument is a minimal PDF 1.4 writer for vector drawings and text. Pages are drawn with
+// coordinates from the top left corner in points, and the document is assembled when written.
+type pdfDocument struct {
+	title   string
+	created time.Time
+	pages   []*pdfPage
+}
+
+// pdfPage holds the content stream of a page
+type pdfPage struct {
+	content bytes.Buffer
+}
+
+func newPDFDocument(title string, created time.Time) *pdfDocument {
+	return &pdfDocument{title: title, created: created}
+}
+
+func (d *pdfDocument) addPage() *pdfPage {
+	page := &pdfPage{}
+	d.pages = append(d.pages, page)
+	return page
+}
+
+// writeTo writes the document: the catalog, the page tree, the fonts and info, then a page
+// object and a compressed content stream per page, followed by the cross-reference table
+func (d *pdfDocument) writeTo(w io.Writer) error {
+	var buf bytes.Buffer
+	var offsets []int
+	object := func(body string) {
+		offsets = append(offsets, buf.Len())
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	buf.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+
+	const firstPage = 6
+	kids := make([]string, len(d.pages))
+	for i := range d.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", firstPage+2*i)
+	}
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(d.pages)))
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+	object(fmt.Sprintf("<< /Title (%s) /Producer (Diabetes Assistant) /CreationDate (D:%s) >>",
+		escapePDFString(d.title), d.created.UTC().Format("20060102150405")+"Z"))
+
+	for i, page := range d.pages {
+		var compressed bytes.Buffer
+		zw := zlib.NewWriter(&compressed)
+		if _, err := zw.Write(page.content.Bytes()); err != nil {
+			return err
+		}
+		if err := zw.Close(); err != nil {
+			return err
+		}
+
+		object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %s %s] "+
+			"/Resources << /Font << /%s 3 0 R /%s 4 0 R >> >> /Contents %d 0 R >>",
+			formatNumber(pageWidth), formatNumber(pageHeight), fontRegular, fontBold, firstPage+2*i+1))
+		object(fmt.Sprintf("<< /Length %d /Filter /FlateDecode >>\nstream\n%s\nendstream", compressed.Len(), compressed.Bytes()))
+	}
+
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R /Info 5 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// flipY converts a distance from the top of the page to PDF's coordinates from the bottom
+func flipY(top float64) float64 {
+	return pageHeight - top
+}
+
+func (p *pdfPage) op(format string, args ...float64) {
+	parts := make([]interface{}, len(args))
+	for i, arg := range args {
+		parts[i] = formatNumber(arg)
+	}
+	fmt.Fprintf(&p.content, format+"\n", parts...)
+}
+
+func (p *pdfPage) setFill(c color) {
+	p.op("%s %s %s rg", c.r, c.g, c.b)
+}
+
+func (p *pdfPage) setStroke(c color) {
+	p.op("%s %s %s RG", c.r, c.g, c.b)
+}
+
+func (p *pdfPage) setLineWidth(width float64) {
+	p.op("%s w", width)
+}
+
+// setDash strokes dashed lines, setDash(0, 0) goes back to solid ones
+func (p *pdfPage) setDash(on, off float64) {
+	if on <= 0 {
+		p.content.WriteString("[] 0 d\n")
+		return
+	}
+	p.op("[%s %s] 0 d", on, off)
+}
+
+// fillRect fills the rectangle whose top left corner is at (x, top)
+func (p *pdfPage) fillRect(x, top, width, height float64) {
+	p.op("%s %s %s %s re f", x, flipY(top+height), width, height)
+}
+
+func (p *pdfPage) strokeRect(x, top, width, height float64) {
+	p.op("%s %s %s %s re S", x, flipY(top+height), width, height)
+}
+
+func (p *pdfPage) line(x1, top1, x2, top2 float64) {
+	p.op("%s %s m %s %s l S", x1, flipY(top1), x2, flipY(top2))
+}
+
+// polyline strokes a line through the points
+func (p *pdfPage) polyline(points []point) {
+	p.path(points)
+	p.content.WriteString("S\n")
+}
+
+// polygon fills the shape outlined by the points
+func (p *pdfPage) polygon(points []point) {
+	p.path(points)
+	p.content.WriteString("h f\n")
+}
+
+func (p *pdfPage) path(points []point) {
+	for i, pt := range points {
+		if i == 0 {
+			p.op("%s %s m", pt.x, flipY(pt.y))
+		} else {
+			p.op("%s %s l", pt.x, flipY(pt.y))
+		}
+	}
+}
+
+// text prints s with its baseline at top
+func (p *pdfPage) text(x, top float64, font string, size float64, s string) {
+	fmt.Fprintf(&p.content, "BT /%s %s Tf %s %s Td (%s) Tj ET\n",
+		font, formatNumber(size), formatNumber(x), formatNumber(flipY(top)), escapePDFString(s))
+}
+
+// textRight prints s so that it ends at x
+func (p *pdfPage) textRight(x, top float64, font string, size float64, s string) {
+	p.text(x-textWidth(font, size, s), top, font, size, s)
+}
+
+// textCenter prints s centered on x
+func (p *pdfPage) textCenter(x, top float64, font string, size float64, s string) {
+	p.text(x-textWidth(font, size, s)/2, top, font, size, s)
+}
+
+// textWidth returns the width of s in points
+func textWidth(font string, size float64, s string) float64 {
+	widths := helveticaWidths[:]
+	if font == fontBold {
+		widths = helveticaBoldWidths[:]
+	}
+	total := 0
+	for _, c := range pdfText(s) {
+		total += widths[c-' ']
+	}
+	return float64(total) * size / 1000
+}
+
+// pdfText replaces the characters the built-in fonts can't print
+func pdfText(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r < ' ' || r > '~' {
+			return '?'
+		}
+		return r
+	}, s)
+}
+
+func escapePDFString(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "(", `\(`, ")", `\)`).Replace(pdfText(s))
+}
+
+// formatNumber writes coordinates with two decimals at most, which is finer than print resolution
+func formatNumber(x float64) string {
+	return strconv.FormatFloat(round(x, 2), 'f', -1, 64)
+}
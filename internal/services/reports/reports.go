@@ -0,0 +1,465 @@
+package reports
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/yourusername/diabetes-assistant/internal/models"
+	"github.com/yourusername/diabetes-assistant/internal/services/glucose"
+	"github.com/yourusername/diabetes-assistant/internal/storage"
+)
+
+// Report periods. Two weeks is the usual span of an AGP report; longer periods are capped to
+// keep the daily profiles readable.
+const (
+	DefaultDays = 14
+	MaxDays     = 90
+)
+
+// maxSchedules is how many of the settings schedules in effect during the period are printed,
+// the most recent ones
+const maxSchedules = 3
+
+// ErrUserNotFound is returned by WritePDF for unknown users
+var ErrUserNotFound = errors.New("user not found")
+
+// Options select the period of a report. Days and times are printed in Location.
+type Options struct {
+	From     time.Time
+	To       time.Time
+	Location *time.Location
+}
+
+// Service renders clinic reports for endocrinologist visits as PDF: glucose statistics with time
+// in range, the ambulatory glucose profile, daily overlays and profiles, meal statistics, the
+// settings schedules in effect and the lows of the period. The PDF is drawn with the built-in
+// fonts and vector graphics, so no external renderer is needed.
+type Service struct {
+	storage storage.Storage
+}
+
+// NewService creates a new report service
+func NewService(storage storage.Storage) *Service {
+	return &Service{storage: storage}
+}
+
+// reportData is everything a report shows, records oldest first
+type reportData struct {
+	userID     string
+	opts       Options
+	days       []time.Time // Midnights of the days in the period
+	readings   []models.BloodSugarReading
+	meals      []models.MealRecord
+	doses      []models.InsulinDose
+	treatments []models.HypoTreatment
+	schedules  []schedule
+	// earlierSchedules counts the schedules in effect during the period that aren't printed
+	earlierSchedules int
+}
+
+// schedule is a version of the user's dosing settings and when it was in effect
+type schedule struct {
+	From, To time.Time
+	Settings models.Settings
+}
+
+// WritePDF renders the report for the period in opts and writes it to w. The document is built
+// in memory before anything is written, so errors leave w untouched.
+func (s *Service) WritePDF(ctx context.Context, w io.Writer, userID string, opts Options) error {
+	data, err := s.load(ctx, userID, opts)
+	if err != nil {
+		return err
+	}
+	return render(data, time.Now()).writeTo(w)
+}
+
+func (s *Service) load(ctx context.Context, userID string, opts Options) (*reportData, error) {
+	user, err := s.storage.GetUser(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch user: %w", err)
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+
+	data := &reportData{userID: userID, opts: opts, days: periodDays(opts)}
+	err = s.storage.StreamBloodSugarReadings(ctx, userID, opts.From, opts.To, func(reading models.BloodSugarReading) error {
+		data.readings = append(data.readings, reading)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch readings: %w", err)
+	}
+	err = s.storage.StreamMealRecords(ctx, userID, opts.From, opts.To, func(meal models.MealRecord) error {
+		data.meals = append(data.meals, meal)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch meals: %w", err)
+	}
+	err = s.storage.StreamInsulinDoses(ctx, userID, opts.From, opts.To, func(dose models.InsulinDose) error {
+		data.doses = append(data.doses, dose)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch doses: %w", err)
+	}
+
+	treatments, err := s.storage.GetHypoTreatments(ctx, userID, opts.From)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch hypo treatments: %w", err)
+	}
+	for i := len(treatments) - 1; i >= 0; i-- {
+		if treatments[i].CreatedAt.Before(opts.To) {
+			data.treatments = append(data.treatments, treatments[i])
+		}
+	}
+
+	revisions, err := s.storage.GetSettingsRevisions(ctx, userID, time.Time{}, opts.To)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch settings revisions: %w", err)
+	}
+	data.schedules = schedules(revisions, user.Settings, opts.From, opts.To)
+	if len(data.schedules) > maxSchedules {
+		data.earlierSchedules = len(data.schedules) - maxSchedules
+		data.schedules = data.schedules[data.earlierSchedules:]
+	}
+	return data, nil
+}
+
+// DefaultFrom returns the start of a report of DefaultDays whole days ending with the day of to
+func DefaultFrom(to time.Time, loc *time.Location) time.Time {
+	last := to.Add(-time.Nanosecond).In(loc)
+	return time.Date(last.Year(), last.Month(), last.Day()-(DefaultDays-1), 0, 0, 0, 0, loc)
+}
+
+// periodDays returns the midnights of the days the period touches
+func periodDays(opts Options) []time.Time {
+	from := opts.From.In(opts.Location)
+	day := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, opts.Location)
+	var days []time.Time
+	for ; day.Before(opts.To); day = day.AddDate(0, 0, 1) {
+		days = append(days, day)
+	}
+	return days
+}
+
+// schedules returns the versions of the dosing settings in effect during [from, to), oldest
+// first. Revisions that only changed other settings don't start a new version. Without any
+// revision before the period, the oldest known settings are taken to be in effect from its
+// start; without revisions at all, the current settings are.
+func schedules(revisions []models.SettingsRevision, current models.Settings, from, to time.Time) []schedule {
+	active := current
+	i := 0
+	for ; i < len(revisions) && revisions[i].SavedAt.Before(from); i++ {
+		active = revisions[i].Settings
+	}
+	if i == 0 && len(revisions) > 0 {
+		active = revisions[0].Settings
+	}
+
+	result := []schedule{{From: from, To: to, Settings: active}}
+	for _, revision := range revisions[i:] {
+		last := &result[len(result)-1]
+		if scheduleKey(revision.Settings) == scheduleKey(last.Settings) {
+			continue
+		}
+		if !revision.SavedAt.After(last.From) {
+			last.Settings = revision.Settings
+			continue
+		}
+		last.To = revision.SavedAt
+		result = append(result, schedule{From: revision.SavedAt, To: to, Settings: revision.Settings})
+	}
+	return result
+}
+
+// scheduleKey identifies the settings the report prints, so revisions that didn't change them
+// can be skipped
+func scheduleKey(settings models.Settings) string {
+	return fmt.Sprint(settings.TargetMin, settings.TargetMax, settings.IOBDuration,
+		settings.InsulinPeriods, settings.SensitivityPeriods, settings.CarbRatioPeriods)
+}
+
+// render lays the report out on pages
+func render(data *reportData, now time.Time) *pdfDocument {
+	loc := data.opts.Location
+	doc := newPDFDocument("Glucose Report", now)
+	summary := summarize(data.readings, data.opts.From, data.opts.To, loc)
+	profile := ambulatoryProfile(data.readings, loc)
+
+	r := &renderer{doc: doc}
+	r.newPage()
+	r.header(data, now)
+
+	r.heading("Glucose Statistics and Targets")
+	top := r.top
+	r.statistics(data, summary)
+	r.timeInRangeBar(margin+contentWidth-200, top, summary)
+	r.top = math.Max(r.top, top+rangeBarHeight+10) + sectionGap
+
+	r.heading("Ambulatory Glucose Profile (AGP)")
+	r.note("Median, 25-75% and 5-95% of all readings by time of day, with the target range of 3.9-10.0 mmol/L.")
+	r.ambulatoryProfile(profile, len(data.readings) > 0)
+
+	r.newPage()
+	r.heading("Daily Glucose Overlay")
+	r.note("Every day of the period on one 24-hour axis, with the median of the AGP.")
+	r.dailyOverlay(data, profile)
+	r.heading("Daily Glucose Profiles")
+	r.note("One chart per day from midnight to midnight. Triangles mark meals, dots mark insulin doses.")
+	r.dailyProfiles(data)
+
+	r.newPage()
+	r.heading("Meal Statistics")
+	r.mealStatistics(data)
+	r.heading("Insulin Settings")
+	r.settingsSchedules(data)
+	r.heading("Hypoglycemia Events")
+	r.hypoEvents(data)
+
+	footer := fmt.Sprintf("Diabetes Assistant glucose report, %s", formatPeriod(data.opts))
+	for i, page := range doc.pages {
+		page.setFill(mutedText)
+		page.text(margin, pageHeight-25, fontRegular, 7, footer)
+		page.textRight(margin+contentWidth, pageHeight-25, fontRegular, 7, fmt.Sprintf("Page %d of %d", i+1, len(doc.pages)))
+	}
+	return doc
+}
+
+// header prints the title and the period of the report
+func (r *renderer) header(data *reportData, now time.Time) {
+	loc := data.opts.Location
+	r.page.setFill(bodyText)
+	r.page.text(margin, r.top+18, fontBold, 18, "Glucose Report")
+	r.page.text(margin, r.top+36, fontRegular, 9, fmt.Sprintf("Period: %s (%d days)", formatPeriod(data.opts), len(data.days)))
+	r.page.text(margin, r.top+49, fontRegular, 9, fmt.Sprintf("User: %s    Time zone: %s    Generated: %s",
+		data.userID, loc.String(), now.In(loc).Format("02 Jan 2006 15:04")))
+	r.page.setStroke(gridLine)
+	r.page.setLineWidth(0.5)
+	r.page.line(margin, r.top+58, margin+contentWidth, r.top+58)
+	r.top += 58 + sectionGap
+}
+
+// statistics prints the headline numbers next to the time in range bar
+func (r *renderer) statistics(data *reportData, summary glucoseSummary) {
+	rows := [][2]string{
+		{"Readings", fmt.Sprintf("%d on %d of %d days", summary.Readings, summary.Days, len(data.days))},
+		{"Sensor coverage", formatPercent(summary.Coverage)},
+	}
+	if summary.Readings > 0 {
+		rows = append(rows,
+			[2]string{"Mean glucose", fmt.Sprintf("%.1f mmol/L (%.0f mg/dL)", summary.Mean, glucose.ToMgdl(summary.Mean))},
+			[2]string{"Glucose management indicator", fmt.Sprintf("%.1f%%", summary.GMI)},
+			[2]string{"Standard deviation", fmt.Sprintf("%.1f mmol/L", summary.SD)},
+			[2]string{"Coefficient of variation", fmt.Sprintf("%.1f%% (target 36%% or less)", summary.CV)},
+		)
+	}
+	if len(data.schedules) > 0 {
+		settings := data.schedules[len(data.schedules)-1].Settings
+		rows = append(rows, [2]string{"Personal target range", fmt.Sprintf("%.1f-%.1f mmol/L", settings.TargetMin, settings.TargetMax)})
+	}
+
+	days := float64(len(data.days))
+	var carbs, bolus, basal float64
+	for _, meal := range data.meals {
+		carbs += meal.Carbs
+	}
+	for _, dose := range data.doses {
+		if dose.Type == models.DoseTypeBasal {
+			basal += dose.Units
+		} else {
+			bolus += dose.Units
+		}
+	}
+	if days > 0 {
+		rows = append(rows,
+			[2]string{"Carbohydrates per day", fmt.Sprintf("%.0f g", carbs/days)},
+			[2]string{"Insulin per day", fmt.Sprintf("%.1f U (bolus %.1f U, basal %.1f U)", (bolus+basal)/days, bolus/days, basal/days)},
+		)
+	}
+
+	for _, row := range rows {
+		r.page.setFill(mutedText)
+		r.page.text(margin, r.top+10, fontRegular, 8.5, row[0])
+		r.page.setFill(bodyText)
+		r.page.text(margin+140, r.top+10, fontBold, 8.5, row[1])
+		r.top += 15
+	}
+}
+
+// mealStatistics prints the meals grouped by time of day
+func (r *renderer) mealStatistics(data *reportData) {
+	loc := data.opts.Location
+	stats := mealStatistics(data.meals, data.doses, data.readings, loc)
+	days := float64(len(data.days))
+
+	columns := []column{
+		{Title: "Meal time", Width: 95},
+		{Title: "Meals", Width: 50, Right: true},
+		{Title: "Per day", Width: 50, Right: true},
+		{Title: "Avg carbs", Width: 60, Right: true},
+		{Title: "Avg bolus", Width: 60, Right: true},
+		{Title: "Before", Width: 65, Right: true},
+		{Title: "2 h after", Width: 65, Right: true},
+		{Title: "Rise", Width: 70, Right: true},
+	}
+	var rows [][]string
+	for i, stat := range stats {
+		period := mealPeriods[i]
+		row := []string{fmt.Sprintf("%s %02d-%02d", period.Name, period.From, period.To), fmt.Sprint(stat.Meals), "-", "-", "-", "-", "-", "-"}
+		if stat.Meals > 0 {
+			row[2] = fmt.Sprintf("%.1f", float64(stat.Meals)/days)
+			row[3] = fmt.Sprintf("%.0f g", stat.Carbs/float64(stat.Meals))
+		}
+		if stat.BolusMeals > 0 {
+			row[4] = fmt.Sprintf("%.1f U", stat.Bolus/float64(stat.BolusMeals))
+		}
+		if stat.Checked > 0 {
+			before, after := stat.Before/float64(stat.Checked), stat.After/float64(stat.Checked)
+			row[5] = fmt.Sprintf("%.1f", before)
+			row[6] = fmt.Sprintf("%.1f", after)
+			row[7] = fmt.Sprintf("%+.1f", after-before)
+		}
+		rows = append(rows, row)
+	}
+	r.table(columns, rows)
+	r.note("Glucose in mmol/L from the last reading up to 30 minutes before the meal and the reading closest to " +
+		"2 hours after it. Bolus averages cover meals with a logged dose; hypo treatments are not counted as meals.")
+	r.top += sectionGap
+}
+
+// settingsSchedules prints the target, insulin periods, sensitivity and carb ratio schedules in
+// effect during the period
+func (r *renderer) settingsSchedules(data *reportData) {
+	loc := data.opts.Location
+	if data.earlierSchedules > 0 {
+		r.note(fmt.Sprintf("The settings changed %d more times earlier in the period; the latest %d versions are shown.",
+			data.earlierSchedules, len(data.schedules)))
+	}
+
+	for i := len(data.schedules) - 1; i >= 0; i-- {
+		schedule := data.schedules[i]
+		settings := schedule.Settings
+
+		insulinRows := make([][]string, len(settings.InsulinPeriods))
+		for j, period := range settings.InsulinPeriods {
+			insulinRows[j] = []string{period.StartTime, formatHours(period.Hours), fmt.Sprintf("x%.2f", period.Coefficient)}
+		}
+		sensitivityRows := make([][]string, len(settings.SensitivityPeriods))
+		for j, period := range settings.SensitivityPeriods {
+			sensitivityRows[j] = []string{period.StartTime, formatHours(period.Hours), fmt.Sprintf("%.1f", period.Sensitivity)}
+		}
+		ratioRows := make([][]string, len(settings.CarbRatioPeriods))
+		for j, period := range settings.CarbRatioPeriods {
+			ratioRows[j] = []string{period.StartTime, formatHours(period.Hours), fmt.Sprintf("%.1f", period.Ratio)}
+		}
+		longest := max(len(insulinRows), len(sensitivityRows), len(ratioRows))
+		r.ensureSpace(40 + float64(longest+1)*rowHeight)
+
+		r.page.setFill(bodyText)
+		r.page.text(margin, r.top+10, fontBold, 9, fmt.Sprintf("In effect %s - %s",
+			formatDateTime(schedule.From.In(loc)), formatDateTime(schedule.To.In(loc))))
+		r.page.setFill(mutedText)
+		r.page.text(margin, r.top+23, fontRegular, 8, fmt.Sprintf("Target %.1f-%.1f mmol/L, insulin action %s",
+			settings.TargetMin, settings.TargetMax, formatHours(settings.IOBDuration)))
+		top := r.top + 30
+
+		width := (contentWidth - 20) / 3
+		periodColumns := func(title string) []column {
+			return []column{{Title: "Start", Width: width * 0.3}, {Title: "Hours", Width: width * 0.25, Right: true}, {Title: title, Width: width * 0.45, Right: true}}
+		}
+		bottom := drawTable(r.page, margin, top, periodColumns("Insulin coefficient"), insulinRows)
+		bottom = math.Max(bottom, drawTable(r.page, margin+width+10, top, periodColumns("mmol/L per U"), sensitivityRows))
+		bottom = math.Max(bottom, drawTable(r.page, margin+2*(width+10), top, periodColumns("g carbs per U"), ratioRows))
+		r.top = bottom + sectionGap
+	}
+}
+
+// hypoEvents prints the lows of the period with their nadir and treatment
+func (r *renderer) hypoEvents(data *reportData) {
+	loc := data.opts.Location
+	episodes := hypoEpisodes(data.readings, data.treatments)
+	if len(episodes) == 0 {
+		r.note("No lows below 3.9 mmol/L lasting 15 minutes or more in this period.")
+		return
+	}
+
+	var level2 int
+	var total time.Duration
+	for _, episode := range episodes {
+		if episode.Nadir < veryLowBelow {
+			level2++
+		}
+		total += episode.End.Sub(episode.Start)
+	}
+	weeks := math.Max(float64(len(data.days))/7, 1)
+	r.note(fmt.Sprintf("%d events (%.1f per week), %d below 3.0 mmol/L. Average duration %s.",
+		len(episodes), float64(len(episodes))/weeks, level2, formatDuration(total/time.Duration(len(episodes)))))
+
+	columns := []column{
+		{Title: "Start", Width: 120},
+		{Title: "Duration", Width: 80, Right: true},
+		{Title: "Lowest", Width: 90, Right: true},
+		{Title: "Level", Width: 90, Right: true},
+		{Title: "Treated with", Width: 135, Right: true},
+	}
+	rows := make([][]string, len(episodes))
+	for i, episode := range episodes {
+		level := "1 (below 3.9)"
+		if episode.Nadir < veryLowBelow {
+			level = "2 (below 3.0)"
+		}
+		treated := "-"
+		if episode.Grams > 0 {
+			treated = fmt.Sprintf("%.0f g fast carbs", episode.Grams)
+		}
+		rows[i] = []string{
+			episode.Start.In(loc).Format("Mon 02 Jan 15:04"),
+			formatDuration(episode.End.Sub(episode.Start)),
+			fmt.Sprintf("%.1f mmol/L", episode.Nadir),
+			level,
+			treated,
+		}
+	}
+	r.table(columns, rows)
+	r.note("An event lasts from the first reading below 3.9 mmol/L to the first reading back in range and counts " +
+		"when it lasts at least 15 minutes or is a single fingerstick. Treatments are those confirmed in the app.")
+}
+
+func formatPeriod(opts Options) string {
+	from := opts.From.In(opts.Location)
+	// The period ends before To, show the last day it covers
+	to := opts.To.Add(-time.Nanosecond).In(opts.Location)
+	return from.Format("02 Jan 2006") + " - " + to.Format("02 Jan 2006")
+}
+
+func formatDateTime(t time.Time) string {
+	return t.Format("02 Jan 2006 15:04")
+}
+
+func formatPercent(share float64) string {
+	percent := share * 100
+	if percent > 0 && percent < 1 {
+		return "<1%"
+	}
+	return fmt.Sprintf("%.0f%%", percent)
+}
+
+// formatDuration writes durations as "45 min" or "2 h 05 min"
+func formatDuration(d time.Duration) string {
+	minutes := int(d.Round(time.Minute) / time.Minute)
+	if minutes < 60 {
+		return fmt.Sprintf("%d min", minutes)
+	}
+	return fmt.Sprintf("%d h %02d min", minutes/60, minutes%60)
+}
+
+func formatHours(hours float64) string {
+	return strings.TrimSuffix(strings.TrimRight(fmt.Sprintf("%.2f", hours), "0"), ".") + " h"
+}
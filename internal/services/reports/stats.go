@@ -0,0 +1,323 @@
+package reports
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/yourusername/diabetes-assistant/internal/models"
+	"github.com/yourusername/diabetes-assistant/internal/services/cob"
+	"github.com/yourusername/diabetes-assistant/internal/services/glucose"
+	"github.com/yourusername/diabetes-assistant/internal/services/hypo"
+)
+
+// Glucose ranges of the international consensus on time in range, in mmol/L. The report uses
+// them rather than the user's own target so it reads like other AGP reports.
+const (
+	veryLowBelow   = 3.0
+	lowBelow       = hypo.Threshold
+	highAbove      = 10.0
+	veryHighAbove  = 13.9
+	sensorInterval = 5 * time.Minute
+)
+
+const (
+	// agpBins splits the day into 15-minute bins
+	agpBins = 96
+	// agpWindow is how many neighbouring bins on each side are pooled into a bin's percentiles,
+	// which smooths the profile like the published AGP
+	agpWindow = 2
+	// agpMinValues is the fewest pooled readings a bin needs to be drawn
+	agpMinValues = 5
+	// readingGap is the longest time between readings that are joined by a line or belong to
+	// the same low
+	readingGap = 30 * time.Minute
+)
+
+// Readings around a meal used for its glucose before and two hours after
+const (
+	preMealWindow   = 30 * time.Minute
+	postMealDelay   = 2 * time.Hour
+	postMealWindow  = 20 * time.Minute
+	hypoTreatWindow = 30 * time.Minute // Treatments logged this long before a low are counted for it
+	// minHypoDuration is how long sensor readings must stay low to count as an event, shorter dips
+	// are usually sensor noise
+	minHypoDuration = 15 * time.Minute
+)
+
+// rangeShares are the fractions of readings in each glucose range
+type rangeShares struct {
+	VeryLow, Low, InRange, High, VeryHigh float64
+}
+
+// glucoseSummary holds the headline statistics of the readings
+type glucoseSummary struct {
+	Readings int
+	Days     int     // Days with at least one reading
+	Mean     float64 // mmol/L
+	SD       float64
+	CV       float64 // Percent
+	GMI      float64 // Glucose management indicator, percent
+	Coverage float64 // Share of 5-minute slots in the period with a reading
+	Ranges   rangeShares
+}
+
+// agpBin holds the 5th, 25th, 50th, 75th and 95th percentiles of a bin, ok is false when it
+// has too few readings
+type agpBin struct {
+	ok          bool
+	percentiles [5]float64
+}
+
+// hypoEpisode is a run of readings below the low threshold
+type hypoEpisode struct {
+	Start, End time.Time
+	Nadir      float64
+	Grams      float64 // Fast carbs of the hypo treatments logged for it
+}
+
+// mealPeriod is a time of day meals are grouped by, from hour From up to To
+type mealPeriod struct {
+	Name     string
+	From, To int
+}
+
+var mealPeriods = []mealPeriod{
+	{Name: "Breakfast", From: 5, To: 11},
+	{Name: "Lunch", From: 11, To: 16},
+	{Name: "Dinner", From: 16, To: 22},
+	{Name: "Night", From: 22, To: 5},
+}
+
+// mealStats sums up the meals of a meal period
+type mealStats struct {
+	Meals      int
+	Carbs      float64
+	BolusMeals int // Meals with a bolus logged for them
+	Bolus      float64
+	Before     float64 // Sum of glucose before the meals that have readings before and after
+	After      float64
+	Checked    int // Meals with readings before and after
+}
+
+func summarize(readings []models.BloodSugarReading, from, to time.Time, loc *time.Location) glucoseSummary {
+	summary := glucoseSummary{Readings: len(readings)}
+	if len(readings) == 0 {
+		return summary
+	}
+
+	var sum float64
+	dates := make(map[string]bool)
+	slots := make(map[int64]bool)
+	for _, reading := range readings {
+		sum += reading.Value
+		dates[reading.Timestamp.In(loc).Format("2006-01-02")] = true
+		slots[reading.Timestamp.Unix()/int64(sensorInterval/time.Second)] = true
+	}
+	summary.Mean = sum / float64(len(readings))
+
+	var squares float64
+	for _, reading := range readings {
+		squares += (reading.Value - summary.Mean) * (reading.Value - summary.Mean)
+	}
+	summary.SD = math.Sqrt(squares / float64(len(readings)))
+	summary.CV = summary.SD / summary.Mean * 100
+	summary.GMI = 3.31 + 0.02392*glucose.ToMgdl(summary.Mean)
+	summary.Days = len(dates)
+	if total := to.Sub(from) / sensorInterval; total > 0 {
+		summary.Coverage = math.Min(float64(len(slots))/float64(total), 1)
+	}
+	summary.Ranges = timeInRange(readings)
+	return summary
+}
+
+func timeInRange(readings []models.BloodSugarReading) rangeShares {
+	var shares rangeShares
+	if len(readings) == 0 {
+		return shares
+	}
+	share := 1 / float64(len(readings))
+	for _, reading := range readings {
+		switch value := reading.Value; {
+		case value < veryLowBelow:
+			shares.VeryLow += share
+		case value < lowBelow:
+			shares.Low += share
+		case value <= highAbove:
+			shares.InRange += share
+		case value <= veryHighAbove:
+			shares.High += share
+		default:
+			shares.VeryHigh += share
+		}
+	}
+	return shares
+}
+
+// ambulatoryProfile returns the glucose percentiles of each 15-minute bin of the day
+func ambulatoryProfile(readings []models.BloodSugarReading, loc *time.Location) []agpBin {
+	values := make([][]float64, agpBins)
+	for _, reading := range readings {
+		bin := minuteOfDay(reading.Timestamp.In(loc)) * agpBins / (24 * 60)
+		values[bin] = append(values[bin], reading.Value)
+	}
+
+	bins := make([]agpBin, agpBins)
+	for i := range bins {
+		var pooled []float64
+		for j := i - agpWindow; j <= i+agpWindow; j++ {
+			pooled = append(pooled, values[(j+agpBins)%agpBins]...)
+		}
+		if len(pooled) < agpMinValues {
+			continue
+		}
+		sort.Float64s(pooled)
+		bins[i].ok = true
+		for k, p := range []float64{0.05, 0.25, 0.5, 0.75, 0.95} {
+			bins[i].percentiles[k] = percentile(pooled, p)
+		}
+	}
+	return bins
+}
+
+// percentile interpolates the p-th quantile of sorted values
+func percentile(sorted []float64, p float64) float64 {
+	position := p * float64(len(sorted)-1)
+	lower := int(math.Floor(position))
+	if lower+1 >= len(sorted) {
+		return sorted[len(sorted)-1]
+	}
+	fraction := position - float64(lower)
+	return sorted[lower] + (sorted[lower+1]-sorted[lower])*fraction
+}
+
+// hypoEpisodes finds runs of readings below the low threshold. An episode ends at the first
+// reading back in range, or at the last low reading when the data stops, and counts if it lasts
+// at least minHypoDuration or is a single reading such as a fingerstick. Treatments logged
+// during an episode or shortly before it are attributed to it.
+func hypoEpisodes(readings []models.BloodSugarReading, treatments []models.HypoTreatment) []hypoEpisode {
+	var episodes []hypoEpisode
+	var current *hypoEpisode
+	var last time.Time
+	end := func(at time.Time) {
+		current.End = at
+		if duration := at.Sub(current.Start); duration == 0 || duration >= minHypoDuration {
+			episodes = append(episodes, *current)
+		}
+		current = nil
+	}
+	for _, reading := range readings {
+		if current != nil && reading.Timestamp.Sub(last) > readingGap {
+			end(last)
+		}
+		switch {
+		case reading.Value < lowBelow && current == nil:
+			current = &hypoEpisode{Start: reading.Timestamp, Nadir: reading.Value}
+		case reading.Value < lowBelow:
+			current.Nadir = math.Min(current.Nadir, reading.Value)
+		case current != nil:
+			end(reading.Timestamp)
+		}
+		last = reading.Timestamp
+	}
+	if current != nil {
+		end(last)
+	}
+
+	for i := range episodes {
+		for _, treatment := range treatments {
+			if treatment.CreatedAt.Before(episodes[i].Start.Add(-hypoTreatWindow)) || treatment.CreatedAt.After(episodes[i].End) {
+				continue
+			}
+			if treatment.Status == models.HypoTreatmentConfirmed {
+				episodes[i].Grams += treatment.ConfirmedGrams
+			}
+		}
+	}
+	return episodes
+}
+
+// mealStatistics groups the eaten meals by meal period, as carbs on board counts them. Hypo
+// treatments aren't meals and are left out.
+func mealStatistics(meals []models.MealRecord, doses []models.InsulinDose, readings []models.BloodSugarReading, loc *time.Location) []mealStats {
+	bolus := make(map[string]float64)
+	for _, dose := range doses {
+		if dose.MealID != "" && dose.Type != models.DoseTypeBasal {
+			bolus[dose.MealID] += dose.Units
+		}
+	}
+
+	stats := make([]mealStats, len(mealPeriods))
+	for _, meal := range cob.Eaten(meals, doses) {
+		if meal.Source == models.MealSourceHypo {
+			continue
+		}
+		stat := &stats[mealPeriodIndex(meal.Timestamp.In(loc).Hour())]
+		stat.Meals++
+		stat.Carbs += meal.Carbs
+		if units, ok := bolus[meal.ID]; ok {
+			stat.BolusMeals++
+			stat.Bolus += units
+		}
+
+		before, okBefore := latestBefore(readings, meal.Timestamp, preMealWindow)
+		after, okAfter := closestTo(readings, meal.Timestamp.Add(postMealDelay), postMealWindow)
+		if okBefore && okAfter {
+			stat.Before += before
+			stat.After += after
+			stat.Checked++
+		}
+	}
+	return stats
+}
+
+func mealPeriodIndex(hour int) int {
+	for i, period := range mealPeriods {
+		if period.From < period.To && hour >= period.From && hour < period.To {
+			return i
+		}
+		if period.From > period.To && (hour >= period.From || hour < period.To) {
+			return i
+		}
+	}
+	return len(mealPeriods) - 1
+}
+
+// latestBefore returns the value of the latest reading at or before t, no older than window.
+// Readings are oldest first.
+func latestBefore(readings []models.BloodSugarReading, t time.Time, window time.Duration) (float64, bool) {
+	i := sort.Search(len(readings), func(i int) bool { return readings[i].Timestamp.After(t) })
+	if i == 0 || t.Sub(readings[i-1].Timestamp) > window {
+		return 0, false
+	}
+	return readings[i-1].Value, true
+}
+
+// closestTo returns the value of the reading closest to t, within window of it
+func closestTo(readings []models.BloodSugarReading, t time.Time, window time.Duration) (float64, bool) {
+	i := sort.Search(len(readings), func(i int) bool { return !readings[i].Timestamp.Before(t) })
+	best, found := time.Duration(math.MaxInt64), false
+	var value float64
+	for _, j := range []int{i - 1, i} {
+		if j < 0 || j >= len(readings) {
+			continue
+		}
+		distance := readings[j].Timestamp.Sub(t)
+		if distance < 0 {
+			distance = -distance
+		}
+		if distance <= window && distance < best {
+			best, value, found = distance, readings[j].Value, true
+		}
+	}
+	return value, found
+}
+
+func minuteOfDay(t time.Time) int {
+	return t.Hour()*60 + t.Minute()
+}
+
+func round(x float64, decimals int) float64 {
+	pow := math.Pow(10, float64(decimals))
+	return math.Round(x*pow) / pow
+}
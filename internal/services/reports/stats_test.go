@@ -0,0 +1,56 @@
+package reports
+
+import (
+	"testing"
+	"time"
+
+	"github.com/yourusername/diabetes-assistant/internal/models"
+)
+
+func TestMealStatisticsCountsEatenMeals(t *testing.T) {
+	day := time.Date(2024, 5, 14, 0, 0, 0, 0, time.UTC)
+	at := func(hour, minute int) time.Time {
+		return day.Add(time.Duration(hour)*time.Hour + time.Duration(minute)*time.Minute)
+	}
+	eatenAt := at(12, 0)
+
+	meals := []models.MealRecord{
+		// Analyzed but never eaten
+		{ID: "analysis", Timestamp: at(8, 0), Carbs: 80, Source: models.MealSourcePhoto},
+		// Eaten with a bolus, and a basal dose that doesn't count
+		{ID: "breakfast", Timestamp: at(8, 30), Carbs: 45, Source: models.MealSourcePhoto},
+		// Marked as eaten without a bolus
+		{ID: "lunch", Timestamp: eatenAt, Carbs: 30, Source: models.MealSourceText, EatenAt: &eatenAt},
+		// Hypo treatments aren't meals
+		{ID: "hypo", Timestamp: at(13, 0), Carbs: 15, Source: models.MealSourceHypo, EatenAt: &eatenAt},
+	}
+	doses := []models.InsulinDose{
+		{ID: "d1", Timestamp: at(8, 25), Type: models.DoseTypeBolus, Units: 4.5, MealID: "breakfast"},
+		{ID: "d2", Timestamp: at(8, 30), Type: models.DoseTypeBasal, Units: 10, MealID: "breakfast"},
+	}
+	readings := []models.BloodSugarReading{
+		{Value: 6, Timestamp: at(8, 20)},
+		{Value: 8.5, Timestamp: at(10, 35)},
+	}
+
+	stats := mealStatistics(meals, doses, readings, time.UTC)
+
+	breakfast := stats[mealPeriodIndex(8)]
+	want := mealStats{Meals: 1, Carbs: 45, BolusMeals: 1, Bolus: 4.5, Before: 6, After: 8.5, Checked: 1}
+	if breakfast != want {
+		t.Errorf("breakfast = %+v, want %+v", breakfast, want)
+	}
+	lunch := stats[mealPeriodIndex(12)]
+	if want := (mealStats{Meals: 1, Carbs: 30}); lunch != want {
+		t.Errorf("lunch = %+v, want %+v", lunch, want)
+	}
+
+	total := mealStats{}
+	for _, stat := range stats {
+		total.Meals += stat.Meals
+		total.Carbs += stat.Carbs
+	}
+	if total.Meals != 2 || total.Carbs != 75 {
+		t.Errorf("%d meals with %.0f g carbs, want 2 with 75 g", total.Meals, total.Carbs)
+	}
+}
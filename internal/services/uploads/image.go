@@ -0,0 +1,242 @@
+package uploads
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	_ "image/gif" // Register the GIF decoder
+	"image/jpeg"
+	"image/png"
+	"net/http"
+)
+
+// maxPixels guards against decompression bombs: a small file can declare a huge canvas
+const maxPixels = 50_000_000
+
+// jpegQuality is used when re-encoding JPEG photos
+const jpegQuality = 85
+
+// ErrUnsupportedImage is returned for uploads that are not JPEG, PNG or GIF images
+var ErrUnsupportedImage = errors.New("unsupported image format (expected JPEG, PNG or GIF)")
+
+// Image is a processed photo ready to be stored and sent to an AI provider
+type Image struct {
+	Data     []byte
+	MIMEType string
+	Width    int
+	Height   int
+	ID       string // Blob key, set once the image is saved by a Store
+}
+
+// ProcessImage validates an uploaded photo by its content, applies the EXIF orientation and
+// re-encodes it, which drops all metadata including the location. Photos larger than maxDimension
+// on their longest side are downscaled; maxDimension <= 0 keeps the original size.
+// JPEG stays JPEG, PNG and GIF become PNG.
+func ProcessImage(data []byte, maxDimension int) (*Image, error) {
+	mimeType := http.DetectContentType(data)
+	switch mimeType {
+	case "image/jpeg", "image/png", "image/gif":
+	default:
+		return nil, fmt.Errorf("%w: got %s", ErrUnsupportedImage, mimeType)
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnsupportedImage, err)
+	}
+	if config.Width*config.Height > maxPixels {
+		return nil, fmt.Errorf("image is too large (%dx%d)", config.Width, config.Height)
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnsupportedImage, err)
+	}
+
+	// Work on plain RGBA pixels, draw has fast paths for the decoders' formats
+	bounds := src.Bounds()
+	img := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(img, img.Bounds(), src, bounds.Min, draw.Src)
+
+	// Phone cameras store rotation in EXIF instead of rotating the pixels,
+	// so it has to be applied before the metadata is dropped
+	if mimeType == "image/jpeg" {
+		img = orient(img, jpegOrientation(data))
+	}
+
+	img = downscale(img, maxDimension)
+
+	var out bytes.Buffer
+	if mimeType == "image/jpeg" {
+		err = jpeg.Encode(&out, img, &jpeg.Options{Quality: jpegQuality})
+	} else {
+		mimeType = "image/png"
+		err = png.Encode(&out, img)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode image: %w", err)
+	}
+
+	return &Image{
+		Data:     out.Bytes(),
+		MIMEType: mimeType,
+		Width:    img.Bounds().Dx(),
+		Height:   img.Bounds().Dy(),
+	}, nil
+}
+
+// jpegOrientation returns the EXIF orientation (1-8) of a JPEG file, or 1 if there is none
+func jpegOrientation(data []byte) int {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return 1
+	}
+
+	// Walk the marker segments up to the start of the image data
+	pos := 2
+	for pos+4 <= len(data) {
+		if data[pos] != 0xFF {
+			return 1
+		}
+		marker := data[pos+1]
+		if marker == 0xDA || marker == 0xD9 { // Start of scan, end of image
+			return 1
+		}
+
+		length := int(binary.BigEndian.Uint16(data[pos+2:]))
+		end := pos + 2 + length
+		if length < 2 || end > len(data) {
+			return 1
+		}
+
+		segment := data[pos+4 : end]
+		if marker == 0xE1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			return exifOrientation(segment[6:])
+		}
+		pos = end
+	}
+	return 1
+}
+
+// exifOrientation reads the orientation tag from the first IFD of a TIFF header
+func exifOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd < 8 || ifd+2 > len(tiff) {
+		return 1
+	}
+
+	count := int(order.Uint16(tiff[ifd:]))
+	for i := 0; i < count; i++ {
+		entry := ifd + 2 + i*12
+		if entry+12 > len(tiff) {
+			return 1
+		}
+		if order.Uint16(tiff[entry:]) == 0x0112 {
+			orientation := int(order.Uint16(tiff[entry+8:]))
+			if orientation < 1 || orientation > 8 {
+				return 1
+			}
+			return orientation
+		}
+	}
+	return 1
+}
+
+// orient rotates and flips an image according to an EXIF orientation value
+func orient(src *image.RGBA, orientation int) *image.RGBA {
+	if orientation <= 1 || orientation > 8 {
+		return src
+	}
+
+	w, h := src.Bounds().Dx(), src.Bounds().Dy()
+	dw, dh := w, h
+	if orientation >= 5 { // 5-8 swap width and height
+		dw, dh = h, w
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+
+	for sy := 0; sy < h; sy++ {
+		for sx := 0; sx < w; sx++ {
+			var dx, dy int
+			switch orientation {
+			case 2: // Mirrored horizontally
+				dx, dy = w-1-sx, sy
+			case 3: // Rotated 180°
+				dx, dy = w-1-sx, h-1-sy
+			case 4: // Mirrored vertically
+				dx, dy = sx, h-1-sy
+			case 5: // Transposed
+				dx, dy = sy, sx
+			case 6: // Rotated 90° clockwise
+				dx, dy = h-1-sy, sx
+			case 7: // Transversed
+				dx, dy = h-1-sy, w-1-sx
+			case 8: // Rotated 90° counter-clockwise
+				dx, dy = sy, w-1-sx
+			}
+
+			si := sy*src.Stride + sx*4
+			di := dy*dst.Stride + dx*4
+			copy(dst.Pix[di:di+4], src.Pix[si:si+4])
+		}
+	}
+	return dst
+}
+
+// downscale shrinks an image so its longest side is at most maxDimension, averaging the
+// source pixels that fall into each destination pixel
+func downscale(src *image.RGBA, maxDimension int) *image.RGBA {
+	w, h := src.Bounds().Dx(), src.Bounds().Dy()
+	if maxDimension <= 0 || (w <= maxDimension && h <= maxDimension) {
+		return src
+	}
+
+	dw, dh := maxDimension, h*maxDimension/w
+	if h > w {
+		dw, dh = w*maxDimension/h, maxDimension
+	}
+	dw, dh = max(dw, 1), max(dh, 1)
+
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		y0, y1 := y*h/dh, max((y+1)*h/dh, y*h/dh+1)
+		for x := 0; x < dw; x++ {
+			x0, x1 := x*w/dw, max((x+1)*w/dw, x*w/dw+1)
+
+			var sum [4]int
+			for sy := y0; sy < y1; sy++ {
+				row := sy * src.Stride
+				for sx := x0; sx < x1; sx++ {
+					p := src.Pix[row+sx*4 : row+sx*4+4]
+					sum[0] += int(p[0])
+					sum[1] += int(p[1])
+					sum[2] += int(p[2])
+					sum[3] += int(p[3])
+				}
+			}
+
+			n := (y1 - y0) * (x1 - x0)
+			di := y*dst.Stride + x*4
+			for c := 0; c < 4; c++ {
+				dst.Pix[di+c] = uint8(sum[c] / n)
+			}
+		}
+	}
+	return dst
+}
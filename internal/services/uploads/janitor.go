@@ -0,0 +1,78 @@
+package uploads
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/yourusername/diabetes-assistant/internal/storage"
+)
+
+// Janitor deletes photos that were never attached to a meal record, such as uploads
+// whose analysis failed or was abandoned, once they are older than the retention period
+type Janitor struct {
+	store     *Store
+	storage   storage.Storage
+	retention time.Duration
+}
+
+// NewJanitor creates a janitor for the store's photos
+func NewJanitor(store *Store, storage storage.Storage, retention time.Duration) *Janitor {
+	return &Janitor{
+		store:     store,
+		storage:   storage,
+		retention: retention,
+	}
+}
+
+// Run sweeps the stored photos every interval until ctx is cancelled
+func (j *Janitor) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if deleted, err := j.Sweep(ctx); err != nil {
+			log.Printf("Uploads janitor: %v", err)
+		} else if deleted > 0 {
+			log.Printf("Uploads janitor: deleted %d unattached photos", deleted)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Sweep deletes unattached photos older than the retention period and returns how many were deleted
+func (j *Janitor) Sweep(ctx context.Context) (int, error) {
+	photos, err := j.store.blobs.List(ctx, photoPrefix)
+	if err != nil {
+		return 0, err
+	}
+
+	cutoff := time.Now().Add(-j.retention)
+	deleted := 0
+	for _, photo := range photos {
+		if photo.ModTime.After(cutoff) {
+			continue
+		}
+
+		attached, err := j.storage.IsPhotoAttached(ctx, photo.Key)
+		if err != nil {
+			return deleted, fmt.Errorf("failed to check photo %s: %w", photo.Key, err)
+		}
+		if attached {
+			continue
+		}
+
+		if err := j.store.blobs.Delete(ctx, photo.Key); err != nil {
+			return deleted, fmt.Errorf("failed to delete photo %s: %w", photo.Key, err)
+		}
+		deleted++
+	}
+
+	return deleted, nil
+}
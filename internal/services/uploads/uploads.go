@@ -0,0 +1,127 @@
+package uploads
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/yourusername/diabetes-assistant/internal/blobs"
+)
+
+const (
+	photoPrefix    = "food_"        // Key prefix of uploaded photos in the blob store
+	photoURLTTL    = 24 * time.Hour // How long a signed photo URL stays valid
+	thumbDimension = 256            // Longest side of thumbnails in pixels
+)
+
+// Store saves processed photos to a blob store and signs URLs to serve them back
+type Store struct {
+	blobs        blobs.Store
+	maxDimension int
+	urlSecret    []byte
+}
+
+// NewStore creates a photo store. Photos larger than maxDimension on their longest side are downscaled.
+// urlSecret signs photo URLs; when it is empty a random secret is used, so URLs stop working
+// after a restart and are not valid on other replicas.
+func NewStore(blobStore blobs.Store, maxDimension int, urlSecret string) (*Store, error) {
+	secret := []byte(urlSecret)
+	if len(secret) == 0 {
+		secret = make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, fmt.Errorf("failed to generate photo URL secret: %w", err)
+		}
+	}
+
+	return &Store{
+		blobs:        blobStore,
+		maxDimension: maxDimension,
+		urlSecret:    secret,
+	}, nil
+}
+
+// Save validates, cleans and downscales an uploaded photo and stores it.
+// ErrUnsupportedImage means the upload is not a usable image.
+func (s *Store) Save(ctx context.Context, r io.Reader) (*Image, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read upload: %w", err)
+	}
+
+	img, err := ProcessImage(data, s.maxDimension)
+	if err != nil {
+		return nil, err
+	}
+
+	ext := ".jpg"
+	if img.MIMEType == "image/png" {
+		ext = ".png"
+	}
+
+	img.ID = photoPrefix + uuid.New().String() + ext
+	if err := s.blobs.Put(ctx, img.ID, img.Data, img.MIMEType); err != nil {
+		return nil, fmt.Errorf("failed to save photo: %w", err)
+	}
+
+	return img, nil
+}
+
+// Open returns a stored photo and its content type. With thumbnail set the photo is
+// downscaled for list views. A missing photo returns blobs.ErrNotFound.
+func (s *Store) Open(ctx context.Context, id string, thumbnail bool) ([]byte, string, error) {
+	reader, info, err := s.blobs.Get(ctx, id)
+	if err != nil {
+		return nil, "", err
+	}
+	defer reader.Close()
+
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read photo: %w", err)
+	}
+
+	if !thumbnail {
+		return data, info.ContentType, nil
+	}
+
+	thumb, err := ProcessImage(data, thumbDimension)
+	if err != nil {
+		return nil, "", err
+	}
+	return thumb.Data, thumb.MIMEType, nil
+}
+
+// PhotoURL returns a signed URL for GET /api/photos/{id} that is valid for photoURLTTL
+func (s *Store) PhotoURL(id string) string {
+	expires := strconv.FormatInt(time.Now().Add(photoURLTTL).Unix(), 10)
+
+	query := url.Values{}
+	query.Set("expires", expires)
+	query.Set("sig", s.sign(id, expires))
+	return "/api/photos/" + url.PathEscape(id) + "?" + query.Encode()
+}
+
+// VerifyURL checks the expiry and signature of a photo URL
+func (s *Store) VerifyURL(id, expires, signature string) bool {
+	expiresAt, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || time.Now().Unix() > expiresAt {
+		return false
+	}
+
+	return hmac.Equal([]byte(signature), []byte(s.sign(id, expires)))
+}
+
+// sign computes the URL signature of a photo
+func (s *Store) sign(id, expires string) string {
+	mac := hmac.New(sha256.New, s.urlSecret)
+	mac.Write([]byte(id + "\n" + expires))
+	return hex.EncodeToString(mac.Sum(nil))
+}
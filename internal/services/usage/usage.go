@@ -0,0 +1,170 @@
+package usage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/yourusername/diabetes-assistant/internal/models"
+	"github.com/yourusername/diabetes-assistant/internal/storage"
+)
+
+// ErrQuotaExceeded is returned when a user has used up today's AI analyses
+var ErrQuotaExceeded = errors.New("daily AI analysis limit reached, try again tomorrow")
+
+// quotaOperations are the AI calls counted against the daily quota. Chat is accounted but not limited.
+var quotaOperations = []string{models.AIOperationFoodAnalysis, models.AIOperationNutritionLabel}
+
+// Service reports AI usage and enforces per-user daily quotas
+type Service struct {
+	storage      storage.Storage
+	defaultQuota int
+}
+
+// NewService creates a usage service. defaultQuota is the daily number of AI analyses
+// for users without an override, 0 means unlimited.
+func NewService(storage storage.Storage, defaultQuota int) *Service {
+	return &Service{
+		storage:      storage,
+		defaultQuota: defaultQuota,
+	}
+}
+
+// CheckQuota returns ErrQuotaExceeded if the user may not run another AI analysis today.
+// It only looks at the usage so far, ReserveQuota takes a slot for an analysis.
+func (s *Service) CheckQuota(ctx context.Context, userID string) error {
+	status, err := s.Quota(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if status.DailyLimit > 0 && status.UsedToday >= status.DailyLimit {
+		return ErrQuotaExceeded
+	}
+	return nil
+}
+
+// ReserveQuota takes one of the user's AI analyses of today before the call is made, so workers
+// running at the same time can't go over the limit together. The returned release gives the
+// slot back and must be called when the analysis failed or made no provider call; it is a no-op
+// for unlimited users. Slots are counted separately from the usage records, so until they are
+// released in-flight analyses count as well.
+func (s *Service) ReserveQuota(ctx context.Context, userID string) (release func(), err error) {
+	status, err := s.Quota(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if status.DailyLimit <= 0 {
+		return func() {}, nil
+	}
+
+	day := startOfDay(time.Now()).Format("2006-01-02")
+	reserved, err := s.storage.ReserveAIQuotaSlot(ctx, userID, day, status.DailyLimit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reserve quota: %w", err)
+	}
+	if !reserved {
+		return nil, ErrQuotaExceeded
+	}
+
+	return func() {
+		// The analysis context may be gone by now
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+		defer cancel()
+		if err := s.storage.ReleaseAIQuotaSlot(ctx, userID, day); err != nil {
+			log.Printf("Usage: failed to release quota slot of user %s: %v", userID, err)
+		}
+	}, nil
+}
+
+// Quota returns the user's daily limit and today's usage
+func (s *Service) Quota(ctx context.Context, userID string) (*models.AIQuotaStatus, error) {
+	status := &models.AIQuotaStatus{
+		UserID:     userID,
+		DailyLimit: s.defaultQuota,
+	}
+
+	override, err := s.storage.GetAIQuota(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch quota: %w", err)
+	}
+	if override != nil {
+		status.DailyLimit = override.DailyLimit
+		status.Custom = true
+	}
+
+	status.UsedToday, err = s.storage.CountAIUsage(ctx, userID, startOfDay(time.Now()), quotaOperations...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count AI usage: %w", err)
+	}
+	return status, nil
+}
+
+// SetQuota overrides the user's daily limit, nil restores the default
+func (s *Service) SetQuota(ctx context.Context, userID string, dailyLimit *int) error {
+	if dailyLimit == nil {
+		return s.storage.DeleteAIQuota(ctx, userID)
+	}
+	if *dailyLimit < 0 {
+		return errors.New("daily limit must not be negative")
+	}
+
+	return s.storage.SaveAIQuota(ctx, &models.AIQuota{
+		UserID:     userID,
+		DailyLimit: *dailyLimit,
+		UpdatedAt:  time.Now(),
+	})
+}
+
+// Summarize aggregates AI calls in [from, to) by user and day, newest day first.
+// An empty userID includes all users.
+func (s *Service) Summarize(ctx context.Context, userID string, from, to time.Time) ([]models.AIUsageSummary, error) {
+	calls, err := s.storage.GetAIUsage(ctx, userID, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch AI usage: %w", err)
+	}
+
+	type key struct{ userID, date string }
+	summaries := make(map[key]*models.AIUsageSummary)
+	latency := make(map[key]int64)
+	for _, call := range calls {
+		k := key{call.UserID, call.Timestamp.In(time.Local).Format("2006-01-02")}
+		summary, exists := summaries[k]
+		if !exists {
+			summary = &models.AIUsageSummary{UserID: k.userID, Date: k.date}
+			summaries[k] = summary
+		}
+
+		summary.Calls++
+		if call.Error != "" {
+			summary.Errors++
+		}
+		summary.InputTokens += call.InputTokens
+		summary.OutputTokens += call.OutputTokens
+		summary.CostUSD += call.CostUSD
+		latency[k] += call.LatencyMs
+	}
+
+	result := make([]models.AIUsageSummary, 0, len(summaries))
+	for k, summary := range summaries {
+		summary.AvgLatencyMs = latency[k] / int64(summary.Calls)
+		result = append(result, *summary)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Date != result[j].Date {
+			return result[i].Date > result[j].Date
+		}
+		return result[i].UserID < result[j].UserID
+	})
+	return result, nil
+}
+
+// startOfDay returns midnight of t's day in server time
+func startOfDay(t time.Time) time.Time {
+	year, month, day := t.In(time.Local).Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, time.Local)
+}
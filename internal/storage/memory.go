@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sort"
 	"sync"
 	"time"
 
@@ -13,13 +14,62 @@ import (
 // InMemoryStorage implements Storage interface with an in-memory map
 type InMemoryStorage struct {
 	users map[string]*models.User
-	mu    sync.RWMutex
+	meals map[string][]models.MealRecord
+	doses map[string][]models.InsulinDose
+	chats map[string][]models.ChatMessage
+	// Products by barcode
+	products map[string]models.Product
+	// AI analysis cache by key
+	analysisCache map[string]models.AnalysisCacheEntry
+	// Analysis jobs by ID
+	jobs map[string]models.AnalysisJob
+	// AI calls, oldest first
+	aiUsage []models.AIUsage
+	// AI quota overrides by user ID
+	aiQuotas map[string]models.AIQuota
+	// Reserved AI quota slots by user ID and day
+	aiSlots map[string]int
+	// Hypo treatments by ID
+	hypoTreatments map[string]models.HypoTreatment
+	// Alerts by ID
+	alerts map[string]models.Alert
+	// Reminders by ID
+	reminders map[string]models.Reminder
+	// Settings revisions by user ID, oldest first
+	settingsRevisions map[string][]models.SettingsRevision
+	// Notification deliveries by ID
+	notifications map[string]models.Notification
+	// Telegram links by chat ID, link codes by code
+	telegramLinks map[int64]models.TelegramLink
+	telegramCodes map[string]models.TelegramLinkCode
+	// Nightscout upload secrets by user ID
+	nightscoutSecrets map[string]models.NightscoutSecret
+	mu                sync.RWMutex
 }
 
 // NewInMemoryStorage creates a new in-memory storage
 func NewInMemoryStorage() *InMemoryStorage {
 	return &InMemoryStorage{
 		users: make(map[string]*models.User),
+		meals: make(map[string][]models.MealRecord),
+		doses: make(map[string][]models.InsulinDose),
+		chats: make(map[string][]models.ChatMessage),
+
+		products:      make(map[string]models.Product),
+		analysisCache: make(map[string]models.AnalysisCacheEntry),
+		jobs:          make(map[string]models.AnalysisJob),
+		aiQuotas:      make(map[string]models.AIQuota),
+		aiSlots:       make(map[string]int),
+
+		hypoTreatments: make(map[string]models.HypoTreatment),
+		alerts:         make(map[string]models.Alert),
+		reminders:      make(map[string]models.Reminder),
+
+		settingsRevisions: make(map[string][]models.SettingsRevision),
+		notifications:     make(map[string]models.Notification),
+		telegramLinks:     make(map[int64]models.TelegramLink),
+		telegramCodes:     make(map[string]models.TelegramLinkCode),
+		nightscoutSecrets: make(map[string]models.NightscoutSecret),
 	}
 }
 
@@ -35,6 +85,18 @@ func (s *InMemoryStorage) GetUser(userID string) (*models.User, error) {
 	return user, nil
 }
 
+// GetUserIDs returns the IDs of all users
+func (s *InMemoryStorage) GetUserIDs(ctx context.Context) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	ids := make([]string, 0, len(s.users))
+	for id := range s.users {
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
 // CreateUser creates a new user
 func (s *InMemoryStorage) CreateUser(user *models.User) error {
 	if user.UserID == "" {
@@ -95,6 +157,7 @@ func (s *InMemoryStorage) UpdateUserSettings(userID string, settings models.Sett
 	ensureValidSettingsMemory(&settingsCopy)
 
 	user.Settings = settingsCopy
+	s.addSettingsRevision(userID, settingsCopy)
 	return nil
 }
 
@@ -113,6 +176,23 @@ func (s *InMemoryStorage) AddBloodSugarReading(userID string, reading models.Blo
 	return nil
 }
 
+// AddBloodSugarReadings adds many readings to a user, keeping them newest first
+func (s *InMemoryStorage) AddBloodSugarReadings(ctx context.Context, userID string, readings []models.BloodSugarReading) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, exists := s.users[userID]
+	if !exists {
+		return errors.New("user not found")
+	}
+
+	user.BloodSugarReadings = append(user.BloodSugarReadings, readings...)
+	sort.SliceStable(user.BloodSugarReadings, func(i, j int) bool {
+		return user.BloodSugarReadings[i].Timestamp.After(user.BloodSugarReadings[j].Timestamp)
+	})
+	return nil
+}
+
 // GetRecentBloodSugarReadings gets recent blood sugar readings for a user
 func (s *InMemoryStorage) GetRecentBloodSugarReadings(userID string, limit int, startDate time.Time) ([]models.BloodSugarReading, error) {
 	s.mu.RLock()
@@ -158,11 +238,105 @@ func (s *InMemoryStorage) SaveUserSettings(ctx context.Context, settings *models
 			BloodSugarReadings: make([]models.BloodSugarReading, 0),
 		}
 		s.users[settings.UserID] = newUser
+		s.addSettingsRevision(settings.UserID, *settings)
 		return nil
 	}
 
 	// Update existing user's settings
 	user.Settings = *settings
+	s.addSettingsRevision(settings.UserID, *settings)
+	return nil
+}
+
+// addSettingsRevision records a copy of the settings, the caller holds the lock
+func (s *InMemoryStorage) addSettingsRevision(userID string, settings models.Settings) {
+	s.settingsRevisions[userID] = append(s.settingsRevisions[userID], models.SettingsRevision{
+		UserID:   userID,
+		SavedAt:  time.Now(),
+		Settings: settings,
+	})
+}
+
+// GetSettingsRevisions returns the settings saved in [from, to), oldest first
+func (s *InMemoryStorage) GetSettingsRevisions(ctx context.Context, userID string, from, to time.Time) ([]models.SettingsRevision, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	revisions := []models.SettingsRevision{}
+	for _, revision := range s.settingsRevisions[userID] {
+		if !revision.SavedAt.Before(from) && revision.SavedAt.Before(to) {
+			revisions = append(revisions, revision)
+		}
+	}
+	return revisions, nil
+}
+
+// StreamBloodSugarReadings calls fn for each reading in [from, to), oldest first. The readings
+// are already in memory, they are copied so fn runs without holding the lock.
+func (s *InMemoryStorage) StreamBloodSugarReadings(ctx context.Context, userID string, from, to time.Time, fn func(models.BloodSugarReading) error) error {
+	s.mu.RLock()
+	var readings []models.BloodSugarReading
+	if user, exists := s.users[userID]; exists {
+		for _, reading := range user.BloodSugarReadings {
+			if !reading.Timestamp.Before(from) && reading.Timestamp.Before(to) {
+				readings = append(readings, reading)
+			}
+		}
+	}
+	s.mu.RUnlock()
+
+	sort.Slice(readings, func(i, j int) bool {
+		return readings[i].Timestamp.Before(readings[j].Timestamp)
+	})
+	for _, reading := range readings {
+		if err := fn(reading); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// StreamMealRecords calls fn for each meal in [from, to), oldest first
+func (s *InMemoryStorage) StreamMealRecords(ctx context.Context, userID string, from, to time.Time, fn func(models.MealRecord) error) error {
+	s.mu.RLock()
+	var meals []models.MealRecord
+	for _, meal := range s.meals[userID] {
+		if !meal.Timestamp.Before(from) && meal.Timestamp.Before(to) {
+			meals = append(meals, meal)
+		}
+	}
+	s.mu.RUnlock()
+
+	sort.Slice(meals, func(i, j int) bool {
+		return meals[i].Timestamp.Before(meals[j].Timestamp)
+	})
+	for _, meal := range meals {
+		if err := fn(meal); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// StreamInsulinDoses calls fn for each dose in [from, to), oldest first
+func (s *InMemoryStorage) StreamInsulinDoses(ctx context.Context, userID string, from, to time.Time, fn func(models.InsulinDose) error) error {
+	s.mu.RLock()
+	var doses []models.InsulinDose
+	for _, dose := range s.doses[userID] {
+		if !dose.Timestamp.Before(from) && dose.Timestamp.Before(to) {
+			doses = append(doses, dose)
+		}
+	}
+	s.mu.RUnlock()
+
+	sort.Slice(doses, func(i, j int) bool {
+		return doses[i].Timestamp.Before(doses[j].Timestamp)
+	})
+	for _, dose := range doses {
+		if err := fn(dose); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -215,6 +389,11 @@ func ensureValidSettingsMemory(settings *models.Settings) {
 	if settings.IOBDuration == 0 {
 		settings.IOBDuration = 4.0
 	}
+
+	// Set default locale if not specified
+	if settings.Locale == "" {
+		settings.Locale = models.DefaultLocale
+	}
 }
 
 // DeleteBloodSugarReading deletes a blood sugar reading for a user by timestamp
@@ -295,3 +474,698 @@ func (s *InMemoryStorage) SaveBloodSugarReading(ctx context.Context, reading *mo
 	user.BloodSugarReadings = append([]models.BloodSugarReading{*reading}, user.BloodSugarReadings...)
 	return nil
 }
+
+// SaveMealRecord saves a meal record
+func (s *InMemoryStorage) SaveMealRecord(ctx context.Context, meal *models.MealRecord) error {
+	if meal.UserID == "" {
+		return errors.New("user ID is required")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	meals := s.meals[meal.UserID]
+	for i := range meals {
+		if meals[i].ID == meal.ID {
+			meals[i] = *meal
+			return nil
+		}
+	}
+
+	// Add meal at the beginning of the slice (newest first)
+	s.meals[meal.UserID] = append([]models.MealRecord{*meal}, meals...)
+	return nil
+}
+
+// GetMealRecords gets meal records for a user logged at or after startDate
+func (s *InMemoryStorage) GetMealRecords(ctx context.Context, userID string, startDate time.Time) ([]models.MealRecord, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var meals []models.MealRecord
+	for _, meal := range s.meals[userID] {
+		if !meal.Timestamp.Before(startDate) {
+			meals = append(meals, meal)
+		}
+	}
+
+	return meals, nil
+}
+
+// GetMealRecord returns a user's meal, or nil if it doesn't exist
+func (s *InMemoryStorage) GetMealRecord(ctx context.Context, userID, id string) (*models.MealRecord, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, meal := range s.meals[userID] {
+		if meal.ID == id {
+			return &meal, nil
+		}
+	}
+	return nil, nil
+}
+
+// IsPhotoAttached reports whether any meal record references the uploaded photo
+func (s *InMemoryStorage) IsPhotoAttached(ctx context.Context, photoID string) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, meals := range s.meals {
+		for _, meal := range meals {
+			if meal.PhotoID == photoID {
+				return true, nil
+			}
+		}
+	}
+
+	return false, nil
+}
+
+// GetCachedAnalysis returns a cached analysis, or nil if it is missing or expired
+func (s *InMemoryStorage) GetCachedAnalysis(ctx context.Context, key string) (*models.AnalysisCacheEntry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	entry, exists := s.analysisCache[key]
+	if !exists || time.Now().After(entry.ExpiresAt) {
+		return nil, nil
+	}
+	return &entry, nil
+}
+
+// SaveCachedAnalysis stores an analysis and drops expired entries
+func (s *InMemoryStorage) SaveCachedAnalysis(ctx context.Context, entry *models.AnalysisCacheEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for key, cached := range s.analysisCache {
+		if now.After(cached.ExpiresAt) {
+			delete(s.analysisCache, key)
+		}
+	}
+
+	s.analysisCache[entry.Key] = *entry
+	return nil
+}
+
+// SaveAnalysisJob saves an analysis job
+func (s *InMemoryStorage) SaveAnalysisJob(ctx context.Context, job *models.AnalysisJob) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.jobs[job.ID] = *job
+	return nil
+}
+
+// GetAnalysisJob returns an analysis job, or nil if it doesn't exist
+func (s *InMemoryStorage) GetAnalysisJob(ctx context.Context, id string) (*models.AnalysisJob, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	job, exists := s.jobs[id]
+	if !exists {
+		return nil, nil
+	}
+	return &job, nil
+}
+
+// GetUnfinishedAnalysisJobs returns queued and running jobs, oldest first
+func (s *InMemoryStorage) GetUnfinishedAnalysisJobs(ctx context.Context) ([]models.AnalysisJob, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var jobs []models.AnalysisJob
+	for _, job := range s.jobs {
+		if !job.Finished() {
+			jobs = append(jobs, job)
+		}
+	}
+
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].CreatedAt.Before(jobs[j].CreatedAt)
+	})
+	return jobs, nil
+}
+
+// ClaimAnalysisJob moves a queued job to running
+func (s *InMemoryStorage) ClaimAnalysisJob(ctx context.Context, id, owner string, leaseUntil time.Time) (*models.AnalysisJob, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job, exists := s.jobs[id]
+	if !exists || job.Status != models.JobStatusQueued {
+		return nil, nil
+	}
+
+	job.Status = models.JobStatusRunning
+	job.Owner = owner
+	job.LeaseUntil = &leaseUntil
+	job.Attempts++
+	job.UpdatedAt = time.Now()
+	s.jobs[id] = job
+	return &job, nil
+}
+
+// RenewAnalysisJobLease extends the lease of a job the owner is still running
+func (s *InMemoryStorage) RenewAnalysisJobLease(ctx context.Context, id, owner string, leaseUntil time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job, exists := s.jobs[id]
+	if !exists || job.Status != models.JobStatusRunning || job.Owner != owner {
+		return false, nil
+	}
+
+	job.LeaseUntil = &leaseUntil
+	s.jobs[id] = job
+	return true, nil
+}
+
+// FinishAnalysisJob stores the outcome of a job the owner is still running
+func (s *InMemoryStorage) FinishAnalysisJob(ctx context.Context, job *models.AnalysisJob, owner string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, exists := s.jobs[job.ID]
+	if !exists || stored.Status != models.JobStatusRunning || stored.Owner != owner {
+		return false, nil
+	}
+
+	s.jobs[job.ID] = *job
+	return true, nil
+}
+
+// RequeueExpiredAnalysisJobs requeues running jobs whose lease has expired
+func (s *InMemoryStorage) RequeueExpiredAnalysisJobs(ctx context.Context, now time.Time) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var expired []models.AnalysisJob
+	for _, job := range s.jobs {
+		if job.Status == models.JobStatusRunning && (job.LeaseUntil == nil || job.LeaseUntil.Before(now)) {
+			expired = append(expired, job)
+		}
+	}
+	sort.Slice(expired, func(i, j int) bool {
+		return expired[i].CreatedAt.Before(expired[j].CreatedAt)
+	})
+
+	ids := make([]string, 0, len(expired))
+	for _, job := range expired {
+		job.Status = models.JobStatusQueued
+		job.Owner = ""
+		job.LeaseUntil = nil
+		job.UpdatedAt = now
+		s.jobs[job.ID] = job
+		ids = append(ids, job.ID)
+	}
+	return ids, nil
+}
+
+// SaveAIUsage records an AI call
+func (s *InMemoryStorage) SaveAIUsage(ctx context.Context, usage *models.AIUsage) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.aiUsage = append(s.aiUsage, *usage)
+	return nil
+}
+
+// GetAIUsage returns AI calls in [from, to), oldest first
+func (s *InMemoryStorage) GetAIUsage(ctx context.Context, userID string, from, to time.Time) ([]models.AIUsage, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var usage []models.AIUsage
+	for _, call := range s.aiUsage {
+		if (userID == "" || call.UserID == userID) && !call.Timestamp.Before(from) && call.Timestamp.Before(to) {
+			usage = append(usage, call)
+		}
+	}
+	return usage, nil
+}
+
+// CountAIUsage counts a user's successful calls with one of the operations since the given time
+func (s *InMemoryStorage) CountAIUsage(ctx context.Context, userID string, since time.Time, operations ...string) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	count := 0
+	for _, call := range s.aiUsage {
+		if call.UserID != userID || call.Timestamp.Before(since) || call.Error != "" {
+			continue
+		}
+		for _, operation := range operations {
+			if call.Operation == operation {
+				count++
+				break
+			}
+		}
+	}
+	return count, nil
+}
+
+// GetAIQuota returns a user's quota override, or nil if there is none
+func (s *InMemoryStorage) GetAIQuota(ctx context.Context, userID string) (*models.AIQuota, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	quota, exists := s.aiQuotas[userID]
+	if !exists {
+		return nil, nil
+	}
+	return &quota, nil
+}
+
+// SaveAIQuota saves a user's quota override
+func (s *InMemoryStorage) SaveAIQuota(ctx context.Context, quota *models.AIQuota) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.aiQuotas[quota.UserID] = *quota
+	return nil
+}
+
+// DeleteAIQuota removes a user's quota override
+func (s *InMemoryStorage) DeleteAIQuota(ctx context.Context, userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.aiQuotas, userID)
+	return nil
+}
+
+// ReserveAIQuotaSlot takes one of the user's slots of the day
+func (s *InMemoryStorage) ReserveAIQuotaSlot(ctx context.Context, userID, day string, limit int) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := userID + "/" + day
+	if s.aiSlots[key] >= limit {
+		return false, nil
+	}
+	s.aiSlots[key]++
+	return true, nil
+}
+
+// ReleaseAIQuotaSlot gives back one of the user's slots of the day
+func (s *InMemoryStorage) ReleaseAIQuotaSlot(ctx context.Context, userID, day string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := userID + "/" + day
+	if s.aiSlots[key] > 0 {
+		s.aiSlots[key]--
+	}
+	return nil
+}
+
+// SaveInsulinDose saves an insulin dose
+func (s *InMemoryStorage) SaveInsulinDose(ctx context.Context, dose *models.InsulinDose) error {
+	if dose.UserID == "" {
+		return errors.New("user ID is required")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	doses := s.doses[dose.UserID]
+	for i := range doses {
+		if doses[i].ID == dose.ID {
+			doses[i] = *dose
+			return nil
+		}
+	}
+
+	// Add dose at the beginning of the slice (newest first)
+	s.doses[dose.UserID] = append([]models.InsulinDose{*dose}, doses...)
+	return nil
+}
+
+// GetInsulinDoses gets insulin doses for a user logged at or after startDate
+func (s *InMemoryStorage) GetInsulinDoses(ctx context.Context, userID string, startDate time.Time) ([]models.InsulinDose, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var doses []models.InsulinDose
+	for _, dose := range s.doses[userID] {
+		if !dose.Timestamp.Before(startDate) {
+			doses = append(doses, dose)
+		}
+	}
+
+	return doses, nil
+}
+
+// SaveNightscoutSecret saves a user's Nightscout upload secret, replacing their previous one
+func (s *InMemoryStorage) SaveNightscoutSecret(ctx context.Context, secret *models.NightscoutSecret) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.nightscoutSecrets[secret.UserID] = *secret
+	return nil
+}
+
+// GetNightscoutSecret returns the Nightscout upload secret with the given hash, or nil if no user has it
+func (s *InMemoryStorage) GetNightscoutSecret(ctx context.Context, hash string) (*models.NightscoutSecret, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, secret := range s.nightscoutSecrets {
+		if secret.Hash == hash {
+			return &secret, nil
+		}
+	}
+	return nil, nil
+}
+
+// SaveChatMessage appends a message to the user's chat history
+func (s *InMemoryStorage) SaveChatMessage(ctx context.Context, message *models.ChatMessage) error {
+	if message.UserID == "" {
+		return errors.New("user ID is required")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.chats[message.UserID] = append(s.chats[message.UserID], *message)
+	return nil
+}
+
+// GetChatHistory returns the last limit messages of the user's chat history, oldest first
+func (s *InMemoryStorage) GetChatHistory(ctx context.Context, userID string, limit int) ([]models.ChatMessage, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	history := s.chats[userID]
+	if limit > 0 && len(history) > limit {
+		history = history[len(history)-limit:]
+	}
+
+	// Return a copy so callers can't modify the stored history
+	return append([]models.ChatMessage(nil), history...), nil
+}
+
+// ClearChatHistory deletes the user's chat history
+func (s *InMemoryStorage) ClearChatHistory(ctx context.Context, userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.chats, userID)
+	return nil
+}
+
+// SaveProducts inserts or replaces products by barcode
+func (s *InMemoryStorage) SaveProducts(ctx context.Context, products []models.Product) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, product := range products {
+		s.products[product.Barcode] = product
+	}
+	return nil
+}
+
+// GetProductByBarcode returns the product with the given barcode, or nil if it isn't in the database
+func (s *InMemoryStorage) GetProductByBarcode(ctx context.Context, barcode string) (*models.Product, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	product, exists := s.products[barcode]
+	if !exists {
+		return nil, nil
+	}
+	return &product, nil
+}
+
+// SaveHypoTreatment saves a hypo treatment
+func (s *InMemoryStorage) SaveHypoTreatment(ctx context.Context, treatment *models.HypoTreatment) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.hypoTreatments[treatment.ID] = *treatment
+	return nil
+}
+
+// GetHypoTreatment returns a hypo treatment, or nil if it doesn't exist
+func (s *InMemoryStorage) GetHypoTreatment(ctx context.Context, id string) (*models.HypoTreatment, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	treatment, exists := s.hypoTreatments[id]
+	if !exists {
+		return nil, nil
+	}
+	return &treatment, nil
+}
+
+// GetHypoTreatments returns a user's hypo treatments since startDate, newest first
+func (s *InMemoryStorage) GetHypoTreatments(ctx context.Context, userID string, startDate time.Time) ([]models.HypoTreatment, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	treatments := []models.HypoTreatment{}
+	for _, treatment := range s.hypoTreatments {
+		if treatment.UserID == userID && !treatment.CreatedAt.Before(startDate) {
+			treatments = append(treatments, treatment)
+		}
+	}
+
+	sort.Slice(treatments, func(i, j int) bool {
+		return treatments[i].CreatedAt.After(treatments[j].CreatedAt)
+	})
+	return treatments, nil
+}
+
+// SaveAlert saves an alert
+func (s *InMemoryStorage) SaveAlert(ctx context.Context, alert *models.Alert) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.alerts[alert.ID] = *alert
+	return nil
+}
+
+// CreateAlert stores an alert unless one with its ID exists
+func (s *InMemoryStorage) CreateAlert(ctx context.Context, alert *models.Alert) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.alerts[alert.ID]; exists {
+		return false, nil
+	}
+	s.alerts[alert.ID] = *alert
+	return true, nil
+}
+
+// GetAlert returns an alert, or nil if it doesn't exist
+func (s *InMemoryStorage) GetAlert(ctx context.Context, id string) (*models.Alert, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	alert, exists := s.alerts[id]
+	if !exists {
+		return nil, nil
+	}
+	return &alert, nil
+}
+
+// GetAlerts returns a user's alerts since startDate, newest first
+func (s *InMemoryStorage) GetAlerts(ctx context.Context, userID string, startDate time.Time) ([]models.Alert, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	alerts := []models.Alert{}
+	for _, alert := range s.alerts {
+		if alert.UserID == userID && !alert.CreatedAt.Before(startDate) {
+			alerts = append(alerts, alert)
+		}
+	}
+
+	sort.Slice(alerts, func(i, j int) bool {
+		return alerts[i].CreatedAt.After(alerts[j].CreatedAt)
+	})
+	return alerts, nil
+}
+
+// SaveReminder saves a reminder
+func (s *InMemoryStorage) SaveReminder(ctx context.Context, reminder *models.Reminder) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.reminders[reminder.ID] = *reminder
+	return nil
+}
+
+// GetReminder returns a reminder, or nil if it doesn't exist
+func (s *InMemoryStorage) GetReminder(ctx context.Context, id string) (*models.Reminder, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	reminder, exists := s.reminders[id]
+	if !exists {
+		return nil, nil
+	}
+	return &reminder, nil
+}
+
+// GetReminders returns a user's pending reminders and the others due since startDate, by due time
+func (s *InMemoryStorage) GetReminders(ctx context.Context, userID string, startDate time.Time) ([]models.Reminder, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	reminders := []models.Reminder{}
+	for _, reminder := range s.reminders {
+		if reminder.UserID == userID && (reminder.Status == models.ReminderPending || !reminder.DueAt.Before(startDate)) {
+			reminders = append(reminders, reminder)
+		}
+	}
+	sort.Slice(reminders, func(i, j int) bool {
+		return reminders[i].DueAt.Before(reminders[j].DueAt)
+	})
+	return reminders, nil
+}
+
+// ClaimDueReminder takes the most overdue pending reminder that isn't claimed
+func (s *InMemoryStorage) ClaimDueReminder(ctx context.Context, before, claimUntil time.Time) (*models.Reminder, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var due *models.Reminder
+	for _, reminder := range s.reminders {
+		if reminder.Status != models.ReminderPending || reminder.DueAt.After(before) {
+			continue
+		}
+		if reminder.ClaimedUntil != nil && reminder.ClaimedUntil.After(before) {
+			continue
+		}
+		if due == nil || reminder.DueAt.Before(due.DueAt) {
+			due = &reminder
+		}
+	}
+	if due == nil {
+		return nil, nil
+	}
+
+	due.ClaimedUntil = &claimUntil
+	s.reminders[due.ID] = *due
+	return due, nil
+}
+
+// SaveNotification saves a notification delivery
+func (s *InMemoryStorage) SaveNotification(ctx context.Context, notification *models.Notification) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.notifications[notification.ID] = *notification
+	return nil
+}
+
+// GetNotification returns a notification delivery, or nil if it doesn't exist
+func (s *InMemoryStorage) GetNotification(ctx context.Context, id string) (*models.Notification, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	notification, exists := s.notifications[id]
+	if !exists {
+		return nil, nil
+	}
+	return &notification, nil
+}
+
+// GetNotifications returns a user's notification deliveries since startDate, newest first
+func (s *InMemoryStorage) GetNotifications(ctx context.Context, userID string, startDate time.Time) ([]models.Notification, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	notifications := []models.Notification{}
+	for _, notification := range s.notifications {
+		if notification.UserID == userID && !notification.CreatedAt.Before(startDate) {
+			notifications = append(notifications, notification)
+		}
+	}
+
+	sort.Slice(notifications, func(i, j int) bool {
+		return notifications[i].CreatedAt.After(notifications[j].CreatedAt)
+	})
+	return notifications, nil
+}
+
+// ClaimDueNotification takes the most overdue pending delivery
+func (s *InMemoryStorage) ClaimDueNotification(ctx context.Context, before, claimUntil time.Time) (*models.Notification, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var due *models.Notification
+	for _, notification := range s.notifications {
+		if notification.Status != models.NotificationPending || notification.NextAttemptAt.After(before) {
+			continue
+		}
+		if due == nil || notification.NextAttemptAt.Before(due.NextAttemptAt) {
+			due = &notification
+		}
+	}
+	if due == nil {
+		return nil, nil
+	}
+
+	due.NextAttemptAt = claimUntil
+	s.notifications[due.ID] = *due
+	return due, nil
+}
+
+// SaveTelegramLink links a Telegram chat to a user
+func (s *InMemoryStorage) SaveTelegramLink(ctx context.Context, link *models.TelegramLink) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.telegramLinks[link.ChatID] = *link
+	return nil
+}
+
+// GetTelegramLink returns the link of a Telegram chat, or nil if it isn't linked
+func (s *InMemoryStorage) GetTelegramLink(ctx context.Context, chatID int64) (*models.TelegramLink, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	link, exists := s.telegramLinks[chatID]
+	if !exists {
+		return nil, nil
+	}
+	return &link, nil
+}
+
+// DeleteTelegramLink unlinks a Telegram chat
+func (s *InMemoryStorage) DeleteTelegramLink(ctx context.Context, chatID int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.telegramLinks, chatID)
+	return nil
+}
+
+// SaveTelegramLinkCode saves a Telegram link code
+func (s *InMemoryStorage) SaveTelegramLinkCode(ctx context.Context, code *models.TelegramLinkCode) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.telegramCodes[code.Code] = *code
+	return nil
+}
+
+// TakeTelegramLinkCode returns a link code and deletes it, nil if it doesn't exist or has expired
+func (s *InMemoryStorage) TakeTelegramLinkCode(ctx context.Context, code string) (*models.TelegramLinkCode, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	linkCode, exists := s.telegramCodes[code]
+	if !exists {
+		return nil, nil
+	}
+	delete(s.telegramCodes, code)
+	if time.Now().After(linkCode.ExpiresAt) {
+		return nil, nil
+	}
+	return &linkCode, nil
+}
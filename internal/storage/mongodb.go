@@ -17,6 +17,28 @@ type MongoDBStorage struct {
 	client     *mongo.Client
 	database   *mongo.Database
 	collection *mongo.Collection
+	readings   *mongo.Collection
+	meals      *mongo.Collection
+	doses      *mongo.Collection
+	chats      *mongo.Collection
+	products   *mongo.Collection
+	analysis   *mongo.Collection // AI analysis cache
+	jobs       *mongo.Collection
+	aiUsage    *mongo.Collection
+	aiQuotas   *mongo.Collection
+	aiSlots    *mongo.Collection // Reserved daily AI quota slots per user
+	hypo       *mongo.Collection
+	alerts     *mongo.Collection
+	reminders  *mongo.Collection
+	// Copies of the settings, one per save
+	settingsRevisions *mongo.Collection
+	// Notification deliveries
+	notifications *mongo.Collection
+	// Telegram chats linked to users and their one-time link codes
+	telegramLinks *mongo.Collection
+	telegramCodes *mongo.Collection
+	// Nightscout upload secrets by user ID
+	nightscoutSecrets *mongo.Collection
 }
 
 // Check that MongoDBStorage implements the Storage interface
@@ -24,6 +46,11 @@ var _ Storage = (*MongoDBStorage)(nil)
 
 // NewMongoDBStorage creates a new MongoDB storage instance
 func NewMongoDBStorage(uri string) (*MongoDBStorage, error) {
+	return newMongoDBStorage(uri, "diabetes-assistant")
+}
+
+// newMongoDBStorage creates a MongoDB storage instance on the named database
+func newMongoDBStorage(uri, databaseName string) (*MongoDBStorage, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
@@ -37,14 +64,128 @@ func NewMongoDBStorage(uri string) (*MongoDBStorage, error) {
 		return nil, err
 	}
 
-	database := client.Database("diabetes-assistant")
+	database := client.Database(databaseName)
 	collection := database.Collection("users")
 
-	return &MongoDBStorage{
+	// Readings are read by user and time range, a user has one reading per instant
+	readings := database.Collection("readings")
+	_, err = readings.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "userId", Value: 1}, {Key: "timestamp", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create readings index: %w", err)
+	}
+
+	// MongoDB removes cache entries once expiresAt has passed
+	analysisCache := database.Collection("analysis_cache")
+	_, err = analysisCache.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.M{"expiresAt": 1},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create analysis cache index: %w", err)
+	}
+
+	// Quota checks count a user's calls of the current day
+	aiUsage := database.Collection("ai_usage")
+	_, err = aiUsage.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "userId", Value: 1}, {Key: "timestamp", Value: 1}},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create AI usage index: %w", err)
+	}
+
+	// MongoDB removes quota slot counters of past days
+	aiSlots := database.Collection("ai_quota_slots")
+	_, err = aiSlots.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.M{"expiresAt": 1},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create AI quota slot index: %w", err)
+	}
+
+	// MongoDB removes link codes once they have expired
+	telegramCodes := database.Collection("telegram_link_codes")
+	_, err = telegramCodes.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.M{"expiresAt": 1},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Telegram link code index: %w", err)
+	}
+
+	// Retries claim pending deliveries that are due
+	notifications := database.Collection("notifications")
+	_, err = notifications.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "status", Value: 1}, {Key: "nextAttemptAt", Value: 1}},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create notifications index: %w", err)
+	}
+
+	// The scheduler claims pending reminders that are due
+	reminders := database.Collection("reminders")
+	_, err = reminders.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "status", Value: 1}, {Key: "dueAt", Value: 1}},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create reminders index: %w", err)
+	}
+
+	// Exports fetch a user's revisions by time
+	settingsRevisions := database.Collection("settings_revisions")
+	_, err = settingsRevisions.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "userId", Value: 1}, {Key: "savedAt", Value: 1}},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create settings revisions index: %w", err)
+	}
+
+	// Uploads look the user up by the hash of their secret
+	nightscoutSecrets := database.Collection("nightscout_secrets")
+	_, err = nightscoutSecrets.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.M{"hash": 1},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Nightscout secrets index: %w", err)
+	}
+
+	s := &MongoDBStorage{
 		client:     client,
 		database:   database,
 		collection: collection,
-	}, nil
+		readings:   readings,
+		meals:      database.Collection("meals"),
+		doses:      database.Collection("doses"),
+		chats:      database.Collection("chat_messages"),
+		products:   database.Collection("products"),
+		analysis:   analysisCache,
+		jobs:       database.Collection("analysis_jobs"),
+		aiUsage:    aiUsage,
+		aiQuotas:   database.Collection("ai_quotas"),
+		aiSlots:    aiSlots,
+		hypo:       database.Collection("hypo_treatments"),
+		alerts:     database.Collection("alerts"),
+		reminders:  reminders,
+
+		settingsRevisions: settingsRevisions,
+
+		notifications: notifications,
+		telegramLinks: database.Collection("telegram_links"),
+		telegramCodes: telegramCodes,
+
+		nightscoutSecrets: nightscoutSecrets,
+	}
+
+	// Older versions kept readings in the user document, a large history there slows down every user
+	// lookup and can grow past MongoDB's document size limit
+	if err := s.moveEmbeddedReadings(context.Background()); err != nil {
+		return nil, fmt.Errorf("failed to move readings out of user documents: %w", err)
+	}
+	return s, nil
 }
 
 // Close closes the MongoDB connection
@@ -68,6 +209,22 @@ func (s *MongoDBStorage) GetUser(userID string) (*models.User, error) {
 	return &user, nil
 }
 
+// GetUserIDs returns the IDs of all users
+func (s *MongoDBStorage) GetUserIDs(ctx context.Context) ([]string, error) {
+	values, err := s.collection.Distinct(ctx, "userId", bson.M{})
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0, len(values))
+	for _, value := range values {
+		if id, ok := value.(string); ok {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
 // CreateUser creates a new user
 func (s *MongoDBStorage) CreateUser(user *models.User) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
@@ -101,52 +258,11 @@ func (s *MongoDBStorage) UpdateUserSettings(userID string, settings models.Setti
 		bson.M{"userId": userID},
 		bson.M{"$set": settings},
 	)
-	return err
-}
-
-// AddBloodSugarReading adds a new blood sugar reading
-func (s *MongoDBStorage) AddBloodSugarReading(userID string, reading models.BloodSugarReading) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-
-	_, err := s.collection.UpdateOne(
-		ctx,
-		bson.M{"userId": userID},
-		bson.M{"$push": bson.M{"bloodSugarReadings": reading}},
-		options.Update().SetUpsert(true),
-	)
-	return err
-}
-
-// GetRecentBloodSugarReadings gets recent blood sugar readings
-func (s *MongoDBStorage) GetRecentBloodSugarReadings(userID string, limit int, startDate time.Time) ([]models.BloodSugarReading, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-
-	var user struct {
-		BloodSugarReadings []models.BloodSugarReading `bson:"bloodSugarReadings"`
-	}
-	err := s.collection.FindOne(ctx, bson.M{"userId": userID}).Decode(&user)
 	if err != nil {
-		if errors.Is(err, mongo.ErrNoDocuments) {
-			return nil, nil
-		}
-		return nil, err
-	}
-
-	// Filter readings by date and limit
-	var filteredReadings []models.BloodSugarReading
-	for _, reading := range user.BloodSugarReadings {
-		if reading.Timestamp.After(startDate) {
-			filteredReadings = append(filteredReadings, reading)
-		}
-	}
-
-	if limit > 0 && len(filteredReadings) > limit {
-		filteredReadings = filteredReadings[:limit]
+		return err
 	}
-
-	return filteredReadings, nil
+	settings.UserID = userID
+	return s.addSettingsRevision(ctx, settings)
 }
 
 // GetUserSettings retrieves user settings from the database
@@ -173,6 +289,9 @@ func ensureValidSettingsMongo(settings *models.Settings) {
 	if settings.IOBDuration == 0 {
 		settings.IOBDuration = 4.0
 	}
+	if settings.Locale == "" {
+		settings.Locale = models.DefaultLocale
+	}
 	if len(settings.CarbRatioPeriods) == 0 {
 		settings.CarbRatioPeriods = []models.CarbRatioPeriod{
 			{
@@ -219,72 +338,827 @@ func (s *MongoDBStorage) SaveUserSettings(ctx context.Context, settings *models.
 		bson.M{"$set": settings},
 		options.Update().SetUpsert(true),
 	)
+	if err != nil {
+		return err
+	}
+	return s.addSettingsRevision(ctx, *settings)
+}
+
+// addSettingsRevision records a copy of the settings
+func (s *MongoDBStorage) addSettingsRevision(ctx context.Context, settings models.Settings) error {
+	_, err := s.settingsRevisions.InsertOne(ctx, models.SettingsRevision{
+		UserID:   settings.UserID,
+		SavedAt:  settings.UpdatedAt,
+		Settings: settings,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save settings revision: %w", err)
+	}
+	return nil
+}
+
+// GetSettingsRevisions returns the settings saved in [from, to), oldest first
+func (s *MongoDBStorage) GetSettingsRevisions(ctx context.Context, userID string, from, to time.Time) ([]models.SettingsRevision, error) {
+	cursor, err := s.settingsRevisions.Find(
+		ctx,
+		bson.M{"userId": userID, "savedAt": bson.M{"$gte": from, "$lt": to}},
+		options.Find().SetSort(bson.M{"savedAt": 1}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	revisions := []models.SettingsRevision{}
+	if err := cursor.All(ctx, &revisions); err != nil {
+		return nil, err
+	}
+	return revisions, nil
+}
+
+// StreamMealRecords calls fn for each meal in [from, to), oldest first
+func (s *MongoDBStorage) StreamMealRecords(ctx context.Context, userID string, from, to time.Time, fn func(models.MealRecord) error) error {
+	cursor, err := s.meals.Find(
+		ctx,
+		bson.M{"userId": userID, "timestamp": bson.M{"$gte": from, "$lt": to}},
+		options.Find().SetSort(bson.M{"timestamp": 1}),
+	)
+	if err != nil {
+		return err
+	}
+	return streamCursor(ctx, cursor, fn)
+}
+
+// StreamInsulinDoses calls fn for each dose in [from, to), oldest first
+func (s *MongoDBStorage) StreamInsulinDoses(ctx context.Context, userID string, from, to time.Time, fn func(models.InsulinDose) error) error {
+	cursor, err := s.doses.Find(
+		ctx,
+		bson.M{"userId": userID, "timestamp": bson.M{"$gte": from, "$lt": to}},
+		options.Find().SetSort(bson.M{"timestamp": 1}),
+	)
+	if err != nil {
+		return err
+	}
+	return streamCursor(ctx, cursor, fn)
+}
+
+// streamCursor decodes the cursor's documents one at a time and calls fn for each, then closes it
+func streamCursor[T any](ctx context.Context, cursor *mongo.Cursor, fn func(T) error) error {
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var item T
+		if err := cursor.Decode(&item); err != nil {
+			return err
+		}
+		if err := fn(item); err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
+
+// SaveMealRecord saves a meal record to the meals collection
+func (s *MongoDBStorage) SaveMealRecord(ctx context.Context, meal *models.MealRecord) error {
+	if meal.UserID == "" {
+		return errors.New("user ID is required")
+	}
+
+	_, err := s.meals.ReplaceOne(
+		ctx,
+		bson.M{"_id": meal.ID},
+		meal,
+		options.Replace().SetUpsert(true),
+	)
 	return err
 }
 
-// SaveBloodSugarReading saves a blood sugar reading to the database
-func (s *MongoDBStorage) SaveBloodSugarReading(ctx context.Context, reading *models.BloodSugarReading) error {
-	// Since reading doesn't have UserID, we need to get it from the context
-	userID, ok := ctx.Value("userID").(string)
-	if !ok {
-		return errors.New("userID not found in context")
+// GetMealRecords retrieves meal records for a user logged at or after startDate
+func (s *MongoDBStorage) GetMealRecords(ctx context.Context, userID string, startDate time.Time) ([]models.MealRecord, error) {
+	cursor, err := s.meals.Find(
+		ctx,
+		bson.M{"userId": userID, "timestamp": bson.M{"$gte": startDate}},
+		options.Find().SetSort(bson.M{"timestamp": -1}),
+	)
+	if err != nil {
+		return nil, err
 	}
+	defer cursor.Close(ctx)
 
-	_, err := s.collection.UpdateOne(
+	var meals []models.MealRecord
+	if err := cursor.All(ctx, &meals); err != nil {
+		return nil, err
+	}
+	return meals, nil
+}
+
+// GetMealRecord returns a user's meal, or nil if it doesn't exist
+func (s *MongoDBStorage) GetMealRecord(ctx context.Context, userID, id string) (*models.MealRecord, error) {
+	var meal models.MealRecord
+	err := s.meals.FindOne(ctx, bson.M{"_id": id, "userId": userID}).Decode(&meal)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &meal, nil
+}
+
+// IsPhotoAttached reports whether any meal record references the uploaded photo
+func (s *MongoDBStorage) IsPhotoAttached(ctx context.Context, photoID string) (bool, error) {
+	count, err := s.meals.CountDocuments(ctx, bson.M{"photoId": photoID}, options.Count().SetLimit(1))
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// GetCachedAnalysis returns a cached analysis, or nil if it is missing or expired.
+// Expired entries are filtered because the TTL monitor only runs once a minute.
+func (s *MongoDBStorage) GetCachedAnalysis(ctx context.Context, key string) (*models.AnalysisCacheEntry, error) {
+	var entry models.AnalysisCacheEntry
+	err := s.analysis.FindOne(ctx, bson.M{"_id": key, "expiresAt": bson.M{"$gt": time.Now()}}).Decode(&entry)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// SaveCachedAnalysis stores an analysis in the analysis_cache collection
+func (s *MongoDBStorage) SaveCachedAnalysis(ctx context.Context, entry *models.AnalysisCacheEntry) error {
+	_, err := s.analysis.ReplaceOne(
 		ctx,
-		bson.M{"userId": userID},
-		bson.M{"$push": bson.M{"bloodSugarReadings": reading}},
+		bson.M{"_id": entry.Key},
+		entry,
+		options.Replace().SetUpsert(true),
+	)
+	return err
+}
+
+// SaveAnalysisJob saves an analysis job to the analysis_jobs collection
+func (s *MongoDBStorage) SaveAnalysisJob(ctx context.Context, job *models.AnalysisJob) error {
+	_, err := s.jobs.ReplaceOne(
+		ctx,
+		bson.M{"_id": job.ID},
+		job,
+		options.Replace().SetUpsert(true),
+	)
+	return err
+}
+
+// GetAnalysisJob returns an analysis job, or nil if it doesn't exist
+func (s *MongoDBStorage) GetAnalysisJob(ctx context.Context, id string) (*models.AnalysisJob, error) {
+	var job models.AnalysisJob
+	err := s.jobs.FindOne(ctx, bson.M{"_id": id}).Decode(&job)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &job, nil
+}
+
+// GetUnfinishedAnalysisJobs returns queued and running jobs, oldest first
+func (s *MongoDBStorage) GetUnfinishedAnalysisJobs(ctx context.Context) ([]models.AnalysisJob, error) {
+	cursor, err := s.jobs.Find(
+		ctx,
+		bson.M{"status": bson.M{"$in": []string{models.JobStatusQueued, models.JobStatusRunning}}},
+		options.Find().SetSort(bson.M{"createdAt": 1}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var jobs []models.AnalysisJob
+	if err := cursor.All(ctx, &jobs); err != nil {
+		return nil, err
+	}
+	return jobs, nil
+}
+
+// ClaimAnalysisJob moves a queued job to running in a single update, so only one worker gets it
+func (s *MongoDBStorage) ClaimAnalysisJob(ctx context.Context, id, owner string, leaseUntil time.Time) (*models.AnalysisJob, error) {
+	var job models.AnalysisJob
+	err := s.jobs.FindOneAndUpdate(
+		ctx,
+		bson.M{"_id": id, "status": models.JobStatusQueued},
+		bson.M{
+			"$set": bson.M{
+				"status":     models.JobStatusRunning,
+				"owner":      owner,
+				"leaseUntil": leaseUntil,
+				"updatedAt":  time.Now(),
+			},
+			"$inc": bson.M{"attempts": 1},
+		},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&job)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &job, nil
+}
+
+// RenewAnalysisJobLease extends the lease of a job the owner is still running
+func (s *MongoDBStorage) RenewAnalysisJobLease(ctx context.Context, id, owner string, leaseUntil time.Time) (bool, error) {
+	result, err := s.jobs.UpdateOne(
+		ctx,
+		bson.M{"_id": id, "status": models.JobStatusRunning, "owner": owner},
+		bson.M{"$set": bson.M{"leaseUntil": leaseUntil}},
+	)
+	if err != nil {
+		return false, err
+	}
+	return result.MatchedCount > 0, nil
+}
+
+// FinishAnalysisJob stores the outcome of a job the owner is still running
+func (s *MongoDBStorage) FinishAnalysisJob(ctx context.Context, job *models.AnalysisJob, owner string) (bool, error) {
+	result, err := s.jobs.ReplaceOne(
+		ctx,
+		bson.M{"_id": job.ID, "status": models.JobStatusRunning, "owner": owner},
+		job,
+	)
+	if err != nil {
+		return false, err
+	}
+	return result.MatchedCount > 0, nil
+}
+
+// RequeueExpiredAnalysisJobs requeues running jobs whose lease has expired. Jobs from before
+// leases were recorded have none and count as expired. Each job is moved with a conditional
+// update, so when several replicas look at once only one of them gets it.
+func (s *MongoDBStorage) RequeueExpiredAnalysisJobs(ctx context.Context, now time.Time) ([]string, error) {
+	expired := bson.M{
+		"status": models.JobStatusRunning,
+		"$or": []bson.M{
+			{"leaseUntil": bson.M{"$lt": now}},
+			{"leaseUntil": nil},
+		},
+	}
+
+	cursor, err := s.jobs.Find(ctx, expired, options.Find().
+		SetSort(bson.M{"createdAt": 1}).
+		SetProjection(bson.M{"_id": 1}))
+	if err != nil {
+		return nil, err
+	}
+	var candidates []struct {
+		ID string `bson:"_id"`
+	}
+	if err := cursor.All(ctx, &candidates); err != nil {
+		return nil, err
+	}
+
+	var ids []string
+	for _, candidate := range candidates {
+		filter := bson.M{"_id": candidate.ID}
+		for key, value := range expired {
+			filter[key] = value
+		}
+		result, err := s.jobs.UpdateOne(ctx, filter, bson.M{
+			"$set":   bson.M{"status": models.JobStatusQueued, "updatedAt": now},
+			"$unset": bson.M{"owner": "", "leaseUntil": ""},
+		})
+		if err != nil {
+			return ids, err
+		}
+		if result.ModifiedCount > 0 {
+			ids = append(ids, candidate.ID)
+		}
+	}
+	return ids, nil
+}
+
+// SaveAIUsage records an AI call in the ai_usage collection
+func (s *MongoDBStorage) SaveAIUsage(ctx context.Context, usage *models.AIUsage) error {
+	_, err := s.aiUsage.InsertOne(ctx, usage)
+	return err
+}
+
+// GetAIUsage returns AI calls in [from, to), oldest first
+func (s *MongoDBStorage) GetAIUsage(ctx context.Context, userID string, from, to time.Time) ([]models.AIUsage, error) {
+	filter := bson.M{"timestamp": bson.M{"$gte": from, "$lt": to}}
+	if userID != "" {
+		filter["userId"] = userID
+	}
+
+	cursor, err := s.aiUsage.Find(ctx, filter, options.Find().SetSort(bson.M{"timestamp": 1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var usage []models.AIUsage
+	if err := cursor.All(ctx, &usage); err != nil {
+		return nil, err
+	}
+	return usage, nil
+}
+
+// CountAIUsage counts a user's calls with one of the operations since the given time. Failed
+// calls don't count, older records without an error have no error field at all.
+func (s *MongoDBStorage) CountAIUsage(ctx context.Context, userID string, since time.Time, operations ...string) (int, error) {
+	count, err := s.aiUsage.CountDocuments(ctx, bson.M{
+		"userId":    userID,
+		"timestamp": bson.M{"$gte": since},
+		"operation": bson.M{"$in": operations},
+		"error":     bson.M{"$in": []interface{}{nil, ""}},
+	})
+	return int(count), err
+}
+
+// GetAIQuota returns a user's quota override, or nil if there is none
+func (s *MongoDBStorage) GetAIQuota(ctx context.Context, userID string) (*models.AIQuota, error) {
+	var quota models.AIQuota
+	err := s.aiQuotas.FindOne(ctx, bson.M{"_id": userID}).Decode(&quota)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &quota, nil
+}
+
+// SaveAIQuota saves a user's quota override to the ai_quotas collection
+func (s *MongoDBStorage) SaveAIQuota(ctx context.Context, quota *models.AIQuota) error {
+	_, err := s.aiQuotas.ReplaceOne(
+		ctx,
+		bson.M{"_id": quota.UserID},
+		quota,
+		options.Replace().SetUpsert(true),
+	)
+	return err
+}
+
+// DeleteAIQuota removes a user's quota override
+func (s *MongoDBStorage) DeleteAIQuota(ctx context.Context, userID string) error {
+	_, err := s.aiQuotas.DeleteOne(ctx, bson.M{"_id": userID})
+	return err
+}
+
+// ReserveAIQuotaSlot increments the user's counter of the day unless it has reached limit. The
+// filter doesn't match a full counter, so the upsert tries to insert a second document with the
+// same ID, which fails with a duplicate key error instead of going over the limit.
+func (s *MongoDBStorage) ReserveAIQuotaSlot(ctx context.Context, userID, day string, limit int) (bool, error) {
+	_, err := s.aiSlots.UpdateOne(
+		ctx,
+		bson.M{"_id": userID + "/" + day, "used": bson.M{"$lt": limit}},
+		bson.M{
+			"$inc":         bson.M{"used": 1},
+			"$setOnInsert": bson.M{"userId": userID, "day": day, "expiresAt": time.Now().Add(48 * time.Hour)},
+		},
 		options.Update().SetUpsert(true),
 	)
+	if mongo.IsDuplicateKeyError(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// ReleaseAIQuotaSlot decrements the user's counter of the day
+func (s *MongoDBStorage) ReleaseAIQuotaSlot(ctx context.Context, userID, day string) error {
+	_, err := s.aiSlots.UpdateOne(
+		ctx,
+		bson.M{"_id": userID + "/" + day, "used": bson.M{"$gt": 0}},
+		bson.M{"$inc": bson.M{"used": -1}},
+	)
 	return err
 }
 
-// GetBloodSugarReadings retrieves all blood sugar readings for a user
-func (s *MongoDBStorage) GetBloodSugarReadings(ctx context.Context, userID string) ([]*models.BloodSugarReading, error) {
-	var user struct {
-		BloodSugarReadings []*models.BloodSugarReading `bson:"bloodSugarReadings"`
+// SaveInsulinDose saves an insulin dose to the doses collection
+func (s *MongoDBStorage) SaveInsulinDose(ctx context.Context, dose *models.InsulinDose) error {
+	if dose.UserID == "" {
+		return errors.New("user ID is required")
 	}
-	err := s.collection.FindOne(ctx, bson.M{"userId": userID}).Decode(&user)
+
+	_, err := s.doses.ReplaceOne(
+		ctx,
+		bson.M{"_id": dose.ID},
+		dose,
+		options.Replace().SetUpsert(true),
+	)
+	return err
+}
+
+// GetInsulinDoses retrieves insulin doses for a user logged at or after startDate
+func (s *MongoDBStorage) GetInsulinDoses(ctx context.Context, userID string, startDate time.Time) ([]models.InsulinDose, error) {
+	cursor, err := s.doses.Find(
+		ctx,
+		bson.M{"userId": userID, "timestamp": bson.M{"$gte": startDate}},
+		options.Find().SetSort(bson.M{"timestamp": -1}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var doses []models.InsulinDose
+	if err := cursor.All(ctx, &doses); err != nil {
+		return nil, err
+	}
+	return doses, nil
+}
+
+// SaveNightscoutSecret saves a user's Nightscout upload secret, replacing their previous one
+func (s *MongoDBStorage) SaveNightscoutSecret(ctx context.Context, secret *models.NightscoutSecret) error {
+	_, err := s.nightscoutSecrets.ReplaceOne(
+		ctx,
+		bson.M{"_id": secret.UserID},
+		secret,
+		options.Replace().SetUpsert(true),
+	)
+	return err
+}
+
+// GetNightscoutSecret returns the Nightscout upload secret with the given hash, or nil if no user has it
+func (s *MongoDBStorage) GetNightscoutSecret(ctx context.Context, hash string) (*models.NightscoutSecret, error) {
+	var secret models.NightscoutSecret
+	err := s.nightscoutSecrets.FindOne(ctx, bson.M{"hash": hash}).Decode(&secret)
 	if err != nil {
 		if errors.Is(err, mongo.ErrNoDocuments) {
 			return nil, nil
 		}
 		return nil, err
 	}
-	return user.BloodSugarReadings, nil
+	return &secret, nil
 }
 
-// DeleteBloodSugarReading deletes a specific blood sugar reading
-func (s *MongoDBStorage) DeleteBloodSugarReading(ctx context.Context, userID string, timestamp string) error {
-	// Convert string timestamp to time.Time
-	t, err := time.Parse(time.RFC3339, timestamp)
+// SaveChatMessage appends a message to the user's chat history
+func (s *MongoDBStorage) SaveChatMessage(ctx context.Context, message *models.ChatMessage) error {
+	if message.UserID == "" {
+		return errors.New("user ID is required")
+	}
+
+	_, err := s.chats.InsertOne(ctx, message)
+	return err
+}
+
+// GetChatHistory returns the last limit messages of the user's chat history, oldest first
+func (s *MongoDBStorage) GetChatHistory(ctx context.Context, userID string, limit int) ([]models.ChatMessage, error) {
+	findOptions := options.Find().SetSort(bson.M{"timestamp": -1})
+	if limit > 0 {
+		findOptions.SetLimit(int64(limit))
+	}
+
+	cursor, err := s.chats.Find(ctx, bson.M{"userId": userID}, findOptions)
 	if err != nil {
-		return fmt.Errorf("invalid timestamp format: %v", err)
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var history []models.ChatMessage
+	if err := cursor.All(ctx, &history); err != nil {
+		return nil, err
+	}
+
+	// Reverse to get oldest first
+	for i, j := 0, len(history)-1; i < j; i, j = i+1, j-1 {
+		history[i], history[j] = history[j], history[i]
+	}
+	return history, nil
+}
+
+// ClearChatHistory deletes the user's chat history
+func (s *MongoDBStorage) ClearChatHistory(ctx context.Context, userID string) error {
+	_, err := s.chats.DeleteMany(ctx, bson.M{"userId": userID})
+	return err
+}
+
+// SaveProducts inserts or replaces products by barcode in a single bulk write
+func (s *MongoDBStorage) SaveProducts(ctx context.Context, products []models.Product) error {
+	if len(products) == 0 {
+		return nil
 	}
 
-	// First check if user exists
-	user, err := s.GetUser(userID)
+	writes := make([]mongo.WriteModel, 0, len(products))
+	for _, product := range products {
+		writes = append(writes, mongo.NewReplaceOneModel().
+			SetFilter(bson.M{"_id": product.Barcode}).
+			SetReplacement(product).
+			SetUpsert(true))
+	}
+
+	_, err := s.products.BulkWrite(ctx, writes, options.BulkWrite().SetOrdered(false))
+	return err
+}
+
+// GetProductByBarcode returns the product with the given barcode, or nil if it isn't in the database
+func (s *MongoDBStorage) GetProductByBarcode(ctx context.Context, barcode string) (*models.Product, error) {
+	var product models.Product
+	err := s.products.FindOne(ctx, bson.M{"_id": barcode}).Decode(&product)
 	if err != nil {
-		return err
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+		return nil, err
 	}
-	if user == nil {
-		return errors.New("user not found")
+	return &product, nil
+}
+
+// SaveHypoTreatment inserts or replaces a hypo treatment
+func (s *MongoDBStorage) SaveHypoTreatment(ctx context.Context, treatment *models.HypoTreatment) error {
+	_, err := s.hypo.ReplaceOne(
+		ctx,
+		bson.M{"_id": treatment.ID},
+		treatment,
+		options.Replace().SetUpsert(true),
+	)
+	return err
+}
+
+// GetHypoTreatment returns a hypo treatment, or nil if it doesn't exist
+func (s *MongoDBStorage) GetHypoTreatment(ctx context.Context, id string) (*models.HypoTreatment, error) {
+	var treatment models.HypoTreatment
+	err := s.hypo.FindOne(ctx, bson.M{"_id": id}).Decode(&treatment)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+		return nil, err
 	}
+	return &treatment, nil
+}
 
-	// Update the user document to remove the reading with matching timestamp
-	result, err := s.collection.UpdateOne(
+// GetHypoTreatments returns a user's hypo treatments since startDate, newest first
+func (s *MongoDBStorage) GetHypoTreatments(ctx context.Context, userID string, startDate time.Time) ([]models.HypoTreatment, error) {
+	cursor, err := s.hypo.Find(
 		ctx,
-		bson.M{"userId": userID},
-		bson.M{"$pull": bson.M{"bloodSugarReadings": bson.M{"timestamp": t}}},
+		bson.M{"userId": userID, "createdAt": bson.M{"$gte": startDate}},
+		options.Find().SetSort(bson.M{"createdAt": -1}),
 	)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	defer cursor.Close(ctx)
 
-	// Check if any document was modified
-	if result.ModifiedCount == 0 {
-		return errors.New("no reading found with the specified timestamp")
+	treatments := []models.HypoTreatment{}
+	if err := cursor.All(ctx, &treatments); err != nil {
+		return nil, err
 	}
+	return treatments, nil
+}
 
-	return nil
+// SaveAlert inserts or replaces an alert
+func (s *MongoDBStorage) SaveAlert(ctx context.Context, alert *models.Alert) error {
+	_, err := s.alerts.ReplaceOne(
+		ctx,
+		bson.M{"_id": alert.ID},
+		alert,
+		options.Replace().SetUpsert(true),
+	)
+	return err
+}
+
+// CreateAlert inserts an alert, the unique _id makes sure only the first of several replicas
+// raising the same alert stores it
+func (s *MongoDBStorage) CreateAlert(ctx context.Context, alert *models.Alert) (bool, error) {
+	_, err := s.alerts.InsertOne(ctx, alert)
+	if mongo.IsDuplicateKeyError(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// GetAlert returns an alert, or nil if it doesn't exist
+func (s *MongoDBStorage) GetAlert(ctx context.Context, id string) (*models.Alert, error) {
+	var alert models.Alert
+	err := s.alerts.FindOne(ctx, bson.M{"_id": id}).Decode(&alert)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &alert, nil
+}
+
+// GetAlerts returns a user's alerts since startDate, newest first
+func (s *MongoDBStorage) GetAlerts(ctx context.Context, userID string, startDate time.Time) ([]models.Alert, error) {
+	cursor, err := s.alerts.Find(
+		ctx,
+		bson.M{"userId": userID, "createdAt": bson.M{"$gte": startDate}},
+		options.Find().SetSort(bson.M{"createdAt": -1}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	alerts := []models.Alert{}
+	if err := cursor.All(ctx, &alerts); err != nil {
+		return nil, err
+	}
+	return alerts, nil
+}
+
+// SaveReminder inserts or replaces a reminder
+func (s *MongoDBStorage) SaveReminder(ctx context.Context, reminder *models.Reminder) error {
+	_, err := s.reminders.ReplaceOne(
+		ctx,
+		bson.M{"_id": reminder.ID},
+		reminder,
+		options.Replace().SetUpsert(true),
+	)
+	return err
+}
+
+// GetReminder returns a reminder, or nil if it doesn't exist
+func (s *MongoDBStorage) GetReminder(ctx context.Context, id string) (*models.Reminder, error) {
+	var reminder models.Reminder
+	err := s.reminders.FindOne(ctx, bson.M{"_id": id}).Decode(&reminder)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &reminder, nil
+}
+
+// GetReminders returns a user's pending reminders and the others due since startDate, by due time
+func (s *MongoDBStorage) GetReminders(ctx context.Context, userID string, startDate time.Time) ([]models.Reminder, error) {
+	cursor, err := s.reminders.Find(
+		ctx,
+		bson.M{
+			"userId": userID,
+			"$or": bson.A{
+				bson.M{"status": models.ReminderPending},
+				bson.M{"dueAt": bson.M{"$gte": startDate}},
+			},
+		},
+		options.Find().SetSort(bson.M{"dueAt": 1}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	reminders := []models.Reminder{}
+	if err := cursor.All(ctx, &reminders); err != nil {
+		return nil, err
+	}
+	return reminders, nil
+}
+
+// ClaimDueReminder takes the most overdue pending reminder that isn't claimed in a single update
+func (s *MongoDBStorage) ClaimDueReminder(ctx context.Context, before, claimUntil time.Time) (*models.Reminder, error) {
+	var reminder models.Reminder
+	err := s.reminders.FindOneAndUpdate(
+		ctx,
+		bson.M{
+			"status": models.ReminderPending,
+			"dueAt":  bson.M{"$lte": before},
+			"$or": bson.A{
+				bson.M{"claimedUntil": nil},
+				bson.M{"claimedUntil": bson.M{"$lte": before}},
+			},
+		},
+		bson.M{"$set": bson.M{"claimedUntil": claimUntil}},
+		options.FindOneAndUpdate().
+			SetSort(bson.M{"dueAt": 1}).
+			SetReturnDocument(options.After),
+	).Decode(&reminder)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &reminder, nil
+}
+
+// SaveNotification inserts or replaces a notification delivery
+func (s *MongoDBStorage) SaveNotification(ctx context.Context, notification *models.Notification) error {
+	_, err := s.notifications.ReplaceOne(
+		ctx,
+		bson.M{"_id": notification.ID},
+		notification,
+		options.Replace().SetUpsert(true),
+	)
+	return err
+}
+
+// GetNotification returns a notification delivery, or nil if it doesn't exist
+func (s *MongoDBStorage) GetNotification(ctx context.Context, id string) (*models.Notification, error) {
+	var notification models.Notification
+	err := s.notifications.FindOne(ctx, bson.M{"_id": id}).Decode(&notification)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &notification, nil
+}
+
+// GetNotifications returns a user's notification deliveries since startDate, newest first
+func (s *MongoDBStorage) GetNotifications(ctx context.Context, userID string, startDate time.Time) ([]models.Notification, error) {
+	cursor, err := s.notifications.Find(
+		ctx,
+		bson.M{"userId": userID, "createdAt": bson.M{"$gte": startDate}},
+		options.Find().SetSort(bson.M{"createdAt": -1}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	notifications := []models.Notification{}
+	if err := cursor.All(ctx, &notifications); err != nil {
+		return nil, err
+	}
+	return notifications, nil
+}
+
+// ClaimDueNotification takes the most overdue pending delivery in a single update
+func (s *MongoDBStorage) ClaimDueNotification(ctx context.Context, before, claimUntil time.Time) (*models.Notification, error) {
+	var notification models.Notification
+	err := s.notifications.FindOneAndUpdate(
+		ctx,
+		bson.M{
+			"status":        models.NotificationPending,
+			"nextAttemptAt": bson.M{"$lte": before},
+		},
+		bson.M{"$set": bson.M{"nextAttemptAt": claimUntil}},
+		options.FindOneAndUpdate().
+			SetSort(bson.M{"nextAttemptAt": 1}).
+			SetReturnDocument(options.After),
+	).Decode(&notification)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &notification, nil
+}
+
+// SaveTelegramLink links a Telegram chat to a user, replacing an earlier link of the chat
+func (s *MongoDBStorage) SaveTelegramLink(ctx context.Context, link *models.TelegramLink) error {
+	_, err := s.telegramLinks.ReplaceOne(
+		ctx,
+		bson.M{"_id": link.ChatID},
+		link,
+		options.Replace().SetUpsert(true),
+	)
+	return err
+}
+
+// GetTelegramLink returns the link of a Telegram chat, or nil if it isn't linked
+func (s *MongoDBStorage) GetTelegramLink(ctx context.Context, chatID int64) (*models.TelegramLink, error) {
+	var link models.TelegramLink
+	err := s.telegramLinks.FindOne(ctx, bson.M{"_id": chatID}).Decode(&link)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &link, nil
+}
+
+// DeleteTelegramLink unlinks a Telegram chat
+func (s *MongoDBStorage) DeleteTelegramLink(ctx context.Context, chatID int64) error {
+	_, err := s.telegramLinks.DeleteOne(ctx, bson.M{"_id": chatID})
+	return err
+}
+
+// SaveTelegramLinkCode saves a Telegram link code
+func (s *MongoDBStorage) SaveTelegramLinkCode(ctx context.Context, code *models.TelegramLinkCode) error {
+	_, err := s.telegramCodes.InsertOne(ctx, code)
+	return err
+}
+
+// TakeTelegramLinkCode returns a link code and deletes it, nil if it doesn't exist or has expired.
+// The TTL index removes expired codes only about once a minute, so expiry is checked here too.
+func (s *MongoDBStorage) TakeTelegramLinkCode(ctx context.Context, code string) (*models.TelegramLinkCode, error) {
+	var linkCode models.TelegramLinkCode
+	err := s.telegramCodes.FindOneAndDelete(ctx, bson.M{"_id": code}).Decode(&linkCode)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if time.Now().After(linkCode.ExpiresAt) {
+		return nil, nil
+	}
+	return &linkCode, nil
 }
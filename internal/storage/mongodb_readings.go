@@ -0,0 +1,222 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/yourusername/diabetes-assistant/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// readingDocument is a reading in the readings collection
+type readingDocument struct {
+	UserID                   string `bson:"userId"`
+	models.BloodSugarReading `bson:",inline"`
+}
+
+// upsertReadings adds readings of a user to the readings collection. A reading is keyed by the user
+// and its time, one that is already stored is left as it is, so adding the same readings again is safe.
+func (s *MongoDBStorage) upsertReadings(ctx context.Context, userID string, readings []models.BloodSugarReading) error {
+	if len(readings) == 0 {
+		return nil
+	}
+
+	writes := make([]mongo.WriteModel, len(readings))
+	for i, reading := range readings {
+		writes[i] = mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"userId": userID, "timestamp": reading.Timestamp}).
+			SetUpdate(bson.M{"$setOnInsert": readingDocument{UserID: userID, BloodSugarReading: reading}}).
+			SetUpsert(true)
+	}
+	_, err := s.readings.BulkWrite(ctx, writes, options.BulkWrite().SetOrdered(false))
+	if onlyDuplicateKeys(err) {
+		// Another writer inserted the same reading at the same time
+		return nil
+	}
+	return err
+}
+
+// onlyDuplicateKeys reports whether all errors of a bulk write are duplicate keys
+func onlyDuplicateKeys(err error) bool {
+	var bulkErr mongo.BulkWriteException
+	if !errors.As(err, &bulkErr) || bulkErr.WriteConcernError != nil || len(bulkErr.WriteErrors) == 0 {
+		return false
+	}
+	for _, writeErr := range bulkErr.WriteErrors {
+		if !mongo.IsDuplicateKeyError(writeErr) {
+			return false
+		}
+	}
+	return true
+}
+
+// moveEmbeddedReadings moves readings stored in user documents by older versions to the readings
+// collection. Readings are copied before they are removed from the user document, and copying
+// them again is a no-op, so a start that fails halfway or replicas starting at the same time lose
+// or duplicate nothing.
+func (s *MongoDBStorage) moveEmbeddedReadings(ctx context.Context) error {
+	cursor, err := s.collection.Find(
+		ctx,
+		bson.M{"bloodSugarReadings.0": bson.M{"$exists": true}},
+		options.Find().SetProjection(bson.M{"userId": 1, "bloodSugarReadings": 1}),
+	)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var user struct {
+			ID       primitive.ObjectID         `bson:"_id"`
+			UserID   string                     `bson:"userId"`
+			Readings []models.BloodSugarReading `bson:"bloodSugarReadings"`
+		}
+		if err := cursor.Decode(&user); err != nil {
+			return err
+		}
+		if err := s.upsertReadings(ctx, user.UserID, user.Readings); err != nil {
+			return fmt.Errorf("failed to copy readings of user %s: %w", user.UserID, err)
+		}
+
+		// Only the copied readings are removed, ones added in the meantime stay for the next start
+		timestamps := make([]time.Time, len(user.Readings))
+		for i, reading := range user.Readings {
+			timestamps[i] = reading.Timestamp
+		}
+		_, err := s.collection.UpdateOne(
+			ctx,
+			bson.M{"_id": user.ID},
+			bson.M{"$pull": bson.M{"bloodSugarReadings": bson.M{"timestamp": bson.M{"$in": timestamps}}}},
+		)
+		if err != nil {
+			return fmt.Errorf("failed to remove copied readings of user %s: %w", user.UserID, err)
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return err
+	}
+
+	// Drop the emptied arrays
+	_, err = s.collection.UpdateMany(
+		ctx,
+		bson.M{"bloodSugarReadings": bson.M{"$exists": true}, "bloodSugarReadings.0": bson.M{"$exists": false}},
+		bson.M{"$unset": bson.M{"bloodSugarReadings": ""}},
+	)
+	return err
+}
+
+// AddBloodSugarReading adds a new blood sugar reading
+func (s *MongoDBStorage) AddBloodSugarReading(userID string, reading models.BloodSugarReading) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	return s.upsertReadings(ctx, userID, []models.BloodSugarReading{reading})
+}
+
+// AddBloodSugarReadings adds many readings in one bulk write
+func (s *MongoDBStorage) AddBloodSugarReadings(ctx context.Context, userID string, readings []models.BloodSugarReading) error {
+	count, err := s.collection.CountDocuments(ctx, bson.M{"userId": userID}, options.Count().SetLimit(1))
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return errors.New("user not found")
+	}
+	return s.upsertReadings(ctx, userID, readings)
+}
+
+// GetRecentBloodSugarReadings gets the readings since startDate, newest first
+func (s *MongoDBStorage) GetRecentBloodSugarReadings(userID string, limit int, startDate time.Time) ([]models.BloodSugarReading, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	opts := options.Find().SetSort(bson.M{"timestamp": -1})
+	if limit > 0 {
+		opts.SetLimit(int64(limit))
+	}
+	cursor, err := s.readings.Find(ctx, bson.M{"userId": userID, "timestamp": bson.M{"$gte": startDate}}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var readings []models.BloodSugarReading
+	if err := cursor.All(ctx, &readings); err != nil {
+		return nil, err
+	}
+	return readings, nil
+}
+
+// StreamBloodSugarReadings calls fn for each reading in [from, to), oldest first
+func (s *MongoDBStorage) StreamBloodSugarReadings(ctx context.Context, userID string, from, to time.Time, fn func(models.BloodSugarReading) error) error {
+	cursor, err := s.readings.Find(
+		ctx,
+		bson.M{"userId": userID, "timestamp": bson.M{"$gte": from, "$lt": to}},
+		options.Find().SetSort(bson.M{"timestamp": 1}),
+	)
+	if err != nil {
+		return err
+	}
+	return streamCursor(ctx, cursor, fn)
+}
+
+// SaveBloodSugarReading saves a blood sugar reading to the database
+func (s *MongoDBStorage) SaveBloodSugarReading(ctx context.Context, reading *models.BloodSugarReading) error {
+	// Since reading doesn't have UserID, we need to get it from the context
+	userID, ok := ctx.Value("userID").(string)
+	if !ok {
+		return errors.New("userID not found in context")
+	}
+
+	return s.upsertReadings(ctx, userID, []models.BloodSugarReading{*reading})
+}
+
+// GetBloodSugarReadings retrieves all blood sugar readings for a user, newest first
+func (s *MongoDBStorage) GetBloodSugarReadings(ctx context.Context, userID string) ([]*models.BloodSugarReading, error) {
+	cursor, err := s.readings.Find(ctx, bson.M{"userId": userID}, options.Find().SetSort(bson.M{"timestamp": -1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var readings []*models.BloodSugarReading
+	if err := cursor.All(ctx, &readings); err != nil {
+		return nil, err
+	}
+	return readings, nil
+}
+
+// DeleteBloodSugarReading deletes a specific blood sugar reading
+func (s *MongoDBStorage) DeleteBloodSugarReading(ctx context.Context, userID string, timestamp string) error {
+	// Convert string timestamp to time.Time
+	t, err := time.Parse(time.RFC3339, timestamp)
+	if err != nil {
+		return fmt.Errorf("invalid timestamp format: %v", err)
+	}
+
+	// First check if user exists
+	user, err := s.GetUser(userID)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return errors.New("user not found")
+	}
+
+	result, err := s.readings.DeleteOne(ctx, bson.M{"userId": userID, "timestamp": t})
+	if err != nil {
+		return err
+	}
+
+	// Check if any document was deleted
+	if result.DeletedCount == 0 {
+		return errors.New("no reading found with the specified timestamp")
+	}
+
+	return nil
+}
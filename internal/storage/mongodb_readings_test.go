@@ -0,0 +1,109 @@
+package storage
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/yourusername/diabetes-assistant/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// testMongoDBStorage connects to the server at MONGODB_TEST_URI with a database of its own, which
+// is dropped after the test. Tests are skipped without it.
+func testMongoDBStorage(t *testing.T) *MongoDBStorage {
+	t.Helper()
+	uri := os.Getenv("MONGODB_TEST_URI")
+	if uri == "" {
+		t.Skip("MONGODB_TEST_URI is not set")
+	}
+
+	s, err := newMongoDBStorage(uri, "diabetes-assistant-test-"+uuid.New().String()[:8])
+	if err != nil {
+		t.Fatalf("connecting to MongoDB: %v", err)
+	}
+	t.Cleanup(func() {
+		s.database.Drop(context.Background())
+		s.Close()
+	})
+	return s
+}
+
+func TestMoveEmbeddedReadings(t *testing.T) {
+	s := testMongoDBStorage(t)
+	ctx := context.Background()
+
+	at := time.Date(2024, 5, 14, 8, 0, 0, 0, time.UTC)
+	embedded := []models.BloodSugarReading{
+		{Value: 5.1, Timestamp: at, Source: "manual"},
+		{Value: 5.6, Timestamp: at.Add(5 * time.Minute), Source: "manual"},
+		{Value: 5.6, Timestamp: at.Add(5 * time.Minute), Source: "manual"}, // Duplicated by an old import
+		{Value: 6.2, Timestamp: at.Add(10 * time.Minute), Source: "manual"},
+	}
+	_, err := s.collection.InsertMany(ctx, []interface{}{
+		bson.M{"userId": "user1", "bloodSugarReadings": embedded},
+		bson.M{"userId": "user2", "bloodSugarReadings": []models.BloodSugarReading{}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A start that stopped after copying part of the readings
+	if err := s.upsertReadings(ctx, "user1", embedded[:1]); err != nil {
+		t.Fatal(err)
+	}
+
+	// Every start runs the move, later ones find nothing left to do
+	for i := 0; i < 2; i++ {
+		if err := s.moveEmbeddedReadings(ctx); err != nil {
+			t.Fatalf("moveEmbeddedReadings: %v", err)
+		}
+	}
+
+	readings, err := s.GetRecentBloodSugarReadings("user1", 0, at.Add(-time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(readings) != 3 {
+		t.Fatalf("got %d readings, want the 3 distinct ones: %+v", len(readings), readings)
+	}
+	if readings[0].Value != 6.2 || readings[2].Value != 5.1 {
+		t.Errorf("readings = %+v, want newest first", readings)
+	}
+
+	left, err := s.collection.CountDocuments(ctx, bson.M{"bloodSugarReadings": bson.M{"$exists": true}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if left != 0 {
+		t.Errorf("%d user documents still have embedded readings", left)
+	}
+}
+
+func TestUpsertReadingsIgnoresStoredReadings(t *testing.T) {
+	s := testMongoDBStorage(t)
+	ctx := context.Background()
+	if err := s.CreateUser(&models.User{UserID: "user1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	at := time.Date(2024, 5, 14, 8, 0, 0, 0, time.UTC)
+	readings := []models.BloodSugarReading{{Value: 5.1, Timestamp: at}, {Value: 5.6, Timestamp: at.Add(5 * time.Minute)}}
+	for i := 0; i < 2; i++ {
+		if err := s.AddBloodSugarReadings(ctx, "user1", readings); err != nil {
+			t.Fatalf("AddBloodSugarReadings: %v", err)
+		}
+	}
+
+	stored, err := s.GetBloodSugarReadings(ctx, "user1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stored) != 2 {
+		t.Errorf("got %d readings after adding the same 2 twice", len(stored))
+	}
+	if err := s.AddBloodSugarReadings(ctx, "nobody", readings); err == nil {
+		t.Error("expected an error for a user that doesn't exist")
+	}
+}
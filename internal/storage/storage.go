@@ -20,14 +20,135 @@ type Storage interface {
 
 	// User operations
 	GetUser(userID string) (*models.User, error)
+	// GetUserIDs returns the IDs of all users
+	GetUserIDs(ctx context.Context) ([]string, error)
 	CreateUser(user *models.User) error
 	UpdateUser(user *models.User) error
 	UpdateUserSettings(userID string, settings models.Settings) error
 
 	// Blood sugar readings operations
 	AddBloodSugarReading(userID string, reading models.BloodSugarReading) error
+	// AddBloodSugarReadings adds many readings at once, e.g. for imports; the user must exist
+	AddBloodSugarReadings(ctx context.Context, userID string, readings []models.BloodSugarReading) error
+	// GetRecentBloodSugarReadings returns the readings since startDate, newest first, at most limit of them if limit > 0
 	GetRecentBloodSugarReadings(userID string, limit int, startDate time.Time) ([]models.BloodSugarReading, error)
 
+	// Streams for exports: fn is called for each reading, meal or dose in [from, to), oldest first,
+	// without loading them all into memory. The first error fn returns stops the stream and is returned.
+	StreamBloodSugarReadings(ctx context.Context, userID string, from, to time.Time, fn func(models.BloodSugarReading) error) error
+	StreamMealRecords(ctx context.Context, userID string, from, to time.Time, fn func(models.MealRecord) error) error
+	StreamInsulinDoses(ctx context.Context, userID string, from, to time.Time, fn func(models.InsulinDose) error) error
+
+	// GetSettingsRevisions returns the settings saved in [from, to), oldest first. A revision is
+	// recorded by SaveUserSettings and UpdateUserSettings.
+	GetSettingsRevisions(ctx context.Context, userID string, from, to time.Time) ([]models.SettingsRevision, error)
+
+	// Meal records (newest first), saving a meal with the ID of a stored one replaces it
+	SaveMealRecord(ctx context.Context, meal *models.MealRecord) error
+	GetMealRecords(ctx context.Context, userID string, startDate time.Time) ([]models.MealRecord, error)
+	// GetMealRecord returns a user's meal, or nil if it doesn't exist
+	GetMealRecord(ctx context.Context, userID, id string) (*models.MealRecord, error)
+	// IsPhotoAttached reports whether any meal record references the uploaded photo
+	IsPhotoAttached(ctx context.Context, photoID string) (bool, error)
+
+	// AI analysis cache, expired entries are treated as missing (nil, nil)
+	GetCachedAnalysis(ctx context.Context, key string) (*models.AnalysisCacheEntry, error)
+	SaveCachedAnalysis(ctx context.Context, entry *models.AnalysisCacheEntry) error
+
+	// Food analysis jobs
+	SaveAnalysisJob(ctx context.Context, job *models.AnalysisJob) error
+	GetAnalysisJob(ctx context.Context, id string) (*models.AnalysisJob, error)
+	// GetUnfinishedAnalysisJobs returns queued and running jobs, oldest first
+	GetUnfinishedAnalysisJobs(ctx context.Context) ([]models.AnalysisJob, error)
+	// ClaimAnalysisJob atomically moves a queued job to running for owner with a lease until
+	// leaseUntil and counts the attempt. It returns nil if the job is not queued, e.g. because
+	// another worker took it.
+	ClaimAnalysisJob(ctx context.Context, id, owner string, leaseUntil time.Time) (*models.AnalysisJob, error)
+	// RenewAnalysisJobLease extends the lease of a running job. It returns false if owner no
+	// longer runs the job.
+	RenewAnalysisJobLease(ctx context.Context, id, owner string, leaseUntil time.Time) (bool, error)
+	// FinishAnalysisJob stores the outcome of a job owner is running. It returns false and stores
+	// nothing if owner no longer runs the job, e.g. because its lease expired and it was requeued.
+	FinishAnalysisJob(ctx context.Context, job *models.AnalysisJob, owner string) (bool, error)
+	// RequeueExpiredAnalysisJobs moves running jobs whose lease ended before now back to queued
+	// and returns their IDs. Each job is requeued by one caller only.
+	RequeueExpiredAnalysisJobs(ctx context.Context, now time.Time) ([]string, error)
+
+	// AI usage accounting
+	SaveAIUsage(ctx context.Context, usage *models.AIUsage) error
+	// GetAIUsage returns calls in [from, to), oldest first. An empty userID returns all users.
+	GetAIUsage(ctx context.Context, userID string, from, to time.Time) ([]models.AIUsage, error)
+	// CountAIUsage counts a user's successful calls with one of the operations since the given time
+	CountAIUsage(ctx context.Context, userID string, since time.Time, operations ...string) (int, error)
+	// Per-user AI quota overrides, GetAIQuota returns nil if the user has none
+	GetAIQuota(ctx context.Context, userID string) (*models.AIQuota, error)
+	SaveAIQuota(ctx context.Context, quota *models.AIQuota) error
+	DeleteAIQuota(ctx context.Context, userID string) error
+	// ReserveAIQuotaSlot atomically takes one of a user's limit slots of the day, so concurrent
+	// workers can't exceed the quota together. It returns false when all slots are taken.
+	ReserveAIQuotaSlot(ctx context.Context, userID, day string, limit int) (bool, error)
+	// ReleaseAIQuotaSlot gives back a slot that wasn't used, e.g. because the call failed
+	ReleaseAIQuotaSlot(ctx context.Context, userID, day string) error
+
+	// Insulin doses (newest first), saving a dose with the ID of a stored one replaces it
+	SaveInsulinDose(ctx context.Context, dose *models.InsulinDose) error
+	GetInsulinDoses(ctx context.Context, userID string, startDate time.Time) ([]models.InsulinDose, error)
+
+	// Hypo treatments (newest first), GetHypoTreatment returns nil if it doesn't exist
+	SaveHypoTreatment(ctx context.Context, treatment *models.HypoTreatment) error
+	GetHypoTreatment(ctx context.Context, id string) (*models.HypoTreatment, error)
+	GetHypoTreatments(ctx context.Context, userID string, startDate time.Time) ([]models.HypoTreatment, error)
+
+	// Alerts (newest first), GetAlert returns nil if it doesn't exist
+	SaveAlert(ctx context.Context, alert *models.Alert) error
+	// CreateAlert stores a new alert. It returns false and stores nothing if an alert with its ID
+	// already exists, e.g. because another replica raised it first.
+	CreateAlert(ctx context.Context, alert *models.Alert) (bool, error)
+	GetAlert(ctx context.Context, id string) (*models.Alert, error)
+	GetAlerts(ctx context.Context, userID string, startDate time.Time) ([]models.Alert, error)
+
+	// Reminders (by due time), GetReminder returns nil if it doesn't exist
+	SaveReminder(ctx context.Context, reminder *models.Reminder) error
+	GetReminder(ctx context.Context, id string) (*models.Reminder, error)
+	// GetReminders returns a user's pending reminders and the others due since startDate
+	GetReminders(ctx context.Context, userID string, startDate time.Time) ([]models.Reminder, error)
+	// ClaimDueReminder atomically takes the next pending reminder of any user due at or before the
+	// given time that no other replica is delivering, and marks it claimed until claimUntil. It
+	// returns nil when nothing is due.
+	ClaimDueReminder(ctx context.Context, before, claimUntil time.Time) (*models.Reminder, error)
+
+	// Notification deliveries (newest first), GetNotification returns nil if it doesn't exist
+	SaveNotification(ctx context.Context, notification *models.Notification) error
+	GetNotification(ctx context.Context, id string) (*models.Notification, error)
+	GetNotifications(ctx context.Context, userID string, startDate time.Time) ([]models.Notification, error)
+	// ClaimDueNotification atomically takes the next pending delivery of any user due at or before
+	// the given time by moving its next attempt to claimUntil, so no other replica retries it in
+	// the meantime. It returns nil when nothing is due.
+	ClaimDueNotification(ctx context.Context, before, claimUntil time.Time) (*models.Notification, error)
+
+	// Telegram chats linked to users, GetTelegramLink returns nil if the chat isn't linked
+	SaveTelegramLink(ctx context.Context, link *models.TelegramLink) error
+	GetTelegramLink(ctx context.Context, chatID int64) (*models.TelegramLink, error)
+	DeleteTelegramLink(ctx context.Context, chatID int64) error
+	SaveTelegramLinkCode(ctx context.Context, code *models.TelegramLinkCode) error
+	// TakeTelegramLinkCode returns a link code and deletes it so it can't be used twice.
+	// Expired codes are treated as missing (nil, nil).
+	TakeTelegramLinkCode(ctx context.Context, code string) (*models.TelegramLinkCode, error)
+
+	// Nightscout upload secrets, one per user. GetNightscoutSecret looks a secret up by its hash
+	// and returns nil if no user has it.
+	SaveNightscoutSecret(ctx context.Context, secret *models.NightscoutSecret) error
+	GetNightscoutSecret(ctx context.Context, hash string) (*models.NightscoutSecret, error)
+
+	// Chat history (oldest first)
+	SaveChatMessage(ctx context.Context, message *models.ChatMessage) error
+	GetChatHistory(ctx context.Context, userID string, limit int) ([]models.ChatMessage, error)
+	ClearChatHistory(ctx context.Context, userID string) error
+
+	// Product database
+	SaveProducts(ctx context.Context, products []models.Product) error
+	GetProductByBarcode(ctx context.Context, barcode string) (*models.Product, error)
+
 	// Close connection if needed
 	Close() error
 }
@@ -0,0 +1,358 @@
+package telegram
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/yourusername/diabetes-assistant/internal/models"
+	"github.com/yourusername/diabetes-assistant/internal/services/alerts"
+	"github.com/yourusername/diabetes-assistant/internal/services/analysis"
+	"github.com/yourusername/diabetes-assistant/internal/services/glucose"
+	"github.com/yourusername/diabetes-assistant/internal/services/hypo"
+	"github.com/yourusername/diabetes-assistant/internal/services/reminders"
+	"github.com/yourusername/diabetes-assistant/internal/services/uploads"
+	"github.com/yourusername/diabetes-assistant/internal/services/usage"
+	"github.com/yourusername/diabetes-assistant/internal/storage"
+)
+
+// ReadingSource is the source of readings logged with /bg
+const ReadingSource = "telegram"
+
+const (
+	// pollTimeout is how long a getUpdates call waits for new messages
+	pollTimeout = 30 * time.Second
+	// retryDelay is the wait after a failed poll
+	retryDelay = 5 * time.Second
+	// maxConcurrent is how many messages are handled at the same time; photo analyses are slow
+	maxConcurrent = 8
+)
+
+// captionWeight matches a leading weight in a photo caption, e.g. "250 г плов" or "180g"
+var captionWeight = regexp.MustCompile(`^\s*(\d+(?:[.,]\d+)?)\s*(?:g|gr|г|гр)?\.?(?:\s+|$)`)
+
+// Bot is the Telegram front-end: users log readings with /bg, send food photos for a carb
+// estimate and dose suggestion, and see their settings with /settings
+type Bot struct {
+	client    *Client
+	storage   storage.Storage
+	analysis  *analysis.Service
+	uploads   *uploads.Store
+	hypo      *hypo.Service
+	alerts    *alerts.Service
+	reminders *reminders.Service
+}
+
+// NewBot creates a new Telegram bot
+func NewBot(client *Client, storage storage.Storage, analysisService *analysis.Service, uploadStore *uploads.Store, hypoService *hypo.Service, alertService *alerts.Service, reminderService *reminders.Service) *Bot {
+	return &Bot{
+		client:    client,
+		storage:   storage,
+		analysis:  analysisService,
+		uploads:   uploadStore,
+		hypo:      hypoService,
+		alerts:    alertService,
+		reminders: reminderService,
+	}
+}
+
+// Run long-polls for messages and handles them until ctx is cancelled
+func (b *Bot) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	slots := make(chan struct{}, maxConcurrent)
+
+	var offset int64
+	for ctx.Err() == nil {
+		updates, err := b.client.GetUpdates(ctx, offset, pollTimeout)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			log.Printf("Telegram: failed to fetch updates: %v", err)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(retryDelay):
+			}
+			continue
+		}
+
+		for _, update := range updates {
+			offset = update.UpdateID + 1
+			if update.Message == nil {
+				continue
+			}
+
+			slots <- struct{}{}
+			wg.Add(1)
+			go func(message Message) {
+				defer func() { <-slots; wg.Done() }()
+				b.Handle(ctx, message)
+			}(*update.Message)
+		}
+	}
+}
+
+// Handle answers one message
+func (b *Bot) Handle(ctx context.Context, message Message) {
+	reply, err := b.answer(ctx, message)
+	if err != nil {
+		log.Printf("Telegram: chat %d: %v", message.Chat.ID, err)
+		reply = failureText(b.locale(ctx, message))
+	}
+	if reply == "" {
+		return
+	}
+	if err := b.client.SendMessage(ctx, message.Chat.ID, reply); err != nil {
+		log.Printf("Telegram: failed to reply to chat %d: %v", message.Chat.ID, err)
+	}
+}
+
+// answer returns the reply to a message
+func (b *Bot) answer(ctx context.Context, message Message) (string, error) {
+	command, args := parseCommand(message.Text)
+	switch command {
+	case "/start", "/link":
+		if args == "" {
+			return helpText(b.locale(ctx, message)), nil
+		}
+		return b.link(ctx, message, args)
+	case "/help":
+		return helpText(b.locale(ctx, message)), nil
+	}
+
+	link, err := b.storage.GetTelegramLink(ctx, message.Chat.ID)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch link: %w", err)
+	}
+	if link == nil {
+		return notLinkedText(b.locale(ctx, message)), nil
+	}
+
+	user, err := b.user(link.UserID)
+	if err != nil {
+		return "", err
+	}
+	locale := user.Settings.Locale
+
+	switch {
+	case command == "/bg":
+		return b.logReading(ctx, user, args)
+	case command == "/settings":
+		return settingsText(&user.Settings, time.Now()), nil
+	case command == "/unlink":
+		if err := b.storage.DeleteTelegramLink(ctx, message.Chat.ID); err != nil {
+			return "", fmt.Errorf("failed to unlink chat: %w", err)
+		}
+		return unlinkedText(locale), nil
+	case len(message.Photo) > 0:
+		return b.analyzePhoto(ctx, message, user)
+	}
+	return helpText(locale), nil
+}
+
+// link connects the chat to the user the code was issued for and adds the chat as a
+// notification channel, so alerts arrive here too
+func (b *Bot) link(ctx context.Context, message Message, code string) (string, error) {
+	linkCode, err := b.storage.TakeTelegramLinkCode(ctx, strings.TrimSpace(code))
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch link code: %w", err)
+	}
+	if linkCode == nil {
+		return invalidCodeText(b.locale(ctx, message)), nil
+	}
+
+	link := &models.TelegramLink{ChatID: message.Chat.ID, UserID: linkCode.UserID, LinkedAt: time.Now()}
+	if err := b.storage.SaveTelegramLink(ctx, link); err != nil {
+		return "", fmt.Errorf("failed to save link: %w", err)
+	}
+
+	user, err := b.user(linkCode.UserID)
+	if err != nil {
+		return "", err
+	}
+	chatID := strconv.FormatInt(message.Chat.ID, 10)
+	if !hasTelegramChannel(user.Settings.NotificationChannels, chatID) {
+		user.Settings.NotificationChannels = append(user.Settings.NotificationChannels, models.NotificationChannel{
+			ID:      uuid.New().String(),
+			Type:    models.ChannelTelegram,
+			Name:    "Telegram",
+			Enabled: true,
+			Target:  chatID,
+		})
+		if err := b.storage.UpdateUserSettings(user.UserID, user.Settings); err != nil {
+			return "", fmt.Errorf("failed to add notification channel: %w", err)
+		}
+	}
+	return linkedText(user.Settings.Locale), nil
+}
+
+// logReading saves a reading given as "/bg 7.2", suggests a treatment when it's low and
+// evaluates the alert rules; raised alerts are delivered through the notification channels
+func (b *Bot) logReading(ctx context.Context, user *models.User, args string) (string, error) {
+	locale := user.Settings.Locale
+	value, err := strconv.ParseFloat(strings.Replace(strings.TrimSpace(args), ",", ".", 1), 64)
+	if err != nil || value <= 0 || value > 40 {
+		return bgUsageText(locale), nil
+	}
+
+	reading := models.BloodSugarReading{
+		Value:     value,
+		Timestamp: time.Now(),
+		Source:    ReadingSource,
+	}
+	if err := b.storage.AddBloodSugarReading(user.UserID, reading); err != nil {
+		return "", fmt.Errorf("failed to save reading: %w", err)
+	}
+
+	// A new reading is the recheck after treating a low
+	if err := b.hypo.ReadingReceived(ctx, user.UserID, reading.Timestamp); err != nil {
+		log.Printf("Telegram: error updating hypo rechecks: %v", err)
+	}
+	if err := b.reminders.ReadingReceived(ctx, user.UserID, reading.Timestamp); err != nil {
+		log.Printf("Telegram: error updating reminders: %v", err)
+	}
+
+	recent, err := b.storage.GetRecentBloodSugarReadings(user.UserID, 0, reading.Timestamp.Add(-time.Hour))
+	if err != nil {
+		log.Printf("Telegram: error fetching readings: %v", err)
+	}
+	glucose.AnnotateReading(&reading, recent)
+	arrow := ""
+	if reading.Trend != glucose.ArrowNotComputable {
+		arrow = glucose.Symbol(reading.Trend)
+	}
+	reply := savedReadingText(locale, glucose.Describe(value, reading.Trend, locale), arrow)
+
+	if value < hypo.Threshold {
+		treatment, err := b.hypo.Advise(ctx, &user.Settings, user.UserID, reading)
+		if err != nil {
+			log.Printf("Telegram: error advising hypo treatment: %v", err)
+		} else {
+			reply += "\n\n" + treatment.Message
+		}
+	}
+
+	if _, err := b.alerts.Evaluate(ctx, user.UserID, &user.Settings, reading.Timestamp); err != nil {
+		log.Printf("Telegram: error evaluating alerts: %v", err)
+	}
+	return reply, nil
+}
+
+// analyzePhoto estimates the carbs of a food photo and suggests a dose. A caption is used as the
+// meal description, a leading weight such as "250 г" as the portion weight.
+func (b *Bot) analyzePhoto(ctx context.Context, message Message, user *models.User) (string, error) {
+	locale := user.Settings.Locale
+	if err := b.client.SendChatAction(ctx, message.Chat.ID, "typing"); err != nil {
+		log.Printf("Telegram: failed to send chat action: %v", err)
+	}
+
+	// The last size is the largest
+	photo := message.Photo[len(message.Photo)-1]
+	data, err := b.client.DownloadFile(ctx, photo.FileID)
+	if err != nil {
+		return "", fmt.Errorf("failed to download photo: %w", err)
+	}
+
+	img, err := b.uploads.Save(ctx, bytes.NewReader(data))
+	if errors.Is(err, uploads.ErrUnsupportedImage) {
+		return unsupportedPhotoText(locale), nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to save photo: %w", err)
+	}
+
+	weight, description := parseCaption(message.Caption)
+	result, err := b.analysis.Analyze(ctx, &models.AnalysisRequest{
+		UserID:      user.UserID,
+		PhotoID:     img.ID,
+		Description: description,
+		Weight:      weight,
+	})
+	if errors.Is(err, usage.ErrQuotaExceeded) {
+		return quotaText(locale), nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to analyze photo: %w", err)
+	}
+	return analysisText(locale, result), nil
+}
+
+// user returns a user, created with default settings if they don't exist yet
+func (b *Bot) user(userID string) (*models.User, error) {
+	user, err := b.storage.GetUser(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch user: %w", err)
+	}
+	if user != nil {
+		return user, nil
+	}
+
+	user = &models.User{
+		UserID:             userID,
+		Settings:           *models.CreateDefaultSettings(userID),
+		BloodSugarReadings: []models.BloodSugarReading{},
+	}
+	if err := b.storage.CreateUser(user); err != nil {
+		return nil, fmt.Errorf("failed to create user: %w", err)
+	}
+	return user, nil
+}
+
+// locale returns the language of the linked user, or of the sender's Telegram app for chats
+// that aren't linked yet
+func (b *Bot) locale(ctx context.Context, message Message) string {
+	if link, err := b.storage.GetTelegramLink(ctx, message.Chat.ID); err == nil && link != nil {
+		if user, err := b.storage.GetUser(link.UserID); err == nil && user != nil && user.Settings.Locale != "" {
+			return user.Settings.Locale
+		}
+	}
+	if message.From != nil && message.From.LanguageCode == "en" {
+		return "en"
+	}
+	return models.DefaultLocale
+}
+
+// parseCommand splits "/bg@my_bot 7.2" into "/bg" and "7.2", text that isn't a command gives ""
+func parseCommand(text string) (string, string) {
+	text = strings.TrimSpace(text)
+	if !strings.HasPrefix(text, "/") {
+		return "", ""
+	}
+	command, args, _ := strings.Cut(text, " ")
+	command, _, _ = strings.Cut(command, "@")
+	return strings.ToLower(command), strings.TrimSpace(args)
+}
+
+// parseCaption splits a photo caption into a leading portion weight in grams and the description
+func parseCaption(caption string) (float64, string) {
+	caption = strings.TrimSpace(caption)
+	match := captionWeight.FindStringSubmatch(caption)
+	if match == nil {
+		return 0, caption
+	}
+	weight, err := strconv.ParseFloat(strings.Replace(match[1], ",", ".", 1), 64)
+	if err != nil || weight <= 0 || weight > 5000 {
+		return 0, caption
+	}
+	return weight, strings.TrimSpace(caption[len(match[0]):])
+}
+
+// hasTelegramChannel reports whether the chat already is one of the notification channels
+func hasTelegramChannel(channels []models.NotificationChannel, chatID string) bool {
+	for _, channel := range channels {
+		if channel.Type == models.ChannelTelegram && channel.Target == chatID {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,213 @@
+package telegram
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const testToken = "123456:ABC-test-token"
+
+// fakeBotAPI is a stand-in for the Bot API. Methods answer with the result stored for them,
+// or with the error stored in errors, and files are served from files by path.
+type fakeBotAPI struct {
+	server  *httptest.Server
+	results map[string]interface{}
+	errors  map[string]int
+	files   map[string][]byte
+
+	mu    sync.Mutex
+	calls map[string][]map[string]interface{} // parameters received by method
+}
+
+func newFakeBotAPI(t *testing.T) *fakeBotAPI {
+	api := &fakeBotAPI{
+		results: make(map[string]interface{}),
+		errors:  make(map[string]int),
+		files:   make(map[string][]byte),
+		calls:   make(map[string][]map[string]interface{}),
+	}
+	api.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if path, ok := strings.CutPrefix(r.URL.Path, "/file/bot"+testToken+"/"); ok {
+			data, found := api.files[path]
+			if !found {
+				http.NotFound(w, r)
+				return
+			}
+			w.Write(data)
+			return
+		}
+
+		method, ok := strings.CutPrefix(r.URL.Path, "/bot"+testToken+"/")
+		if !ok || r.Method != http.MethodPost {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+			http.NotFound(w, r)
+			return
+		}
+		if r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("%s: Content-Type = %q", method, r.Header.Get("Content-Type"))
+		}
+		var params map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+			t.Errorf("%s: decoding parameters: %v", method, err)
+		}
+		api.mu.Lock()
+		api.calls[method] = append(api.calls[method], params)
+		api.mu.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		if code, failed := api.errors[method]; failed {
+			w.WriteHeader(code)
+			json.NewEncoder(w).Encode(map[string]interface{}{"ok": false, "error_code": code, "description": http.StatusText(code)})
+			return
+		}
+		result, found := api.results[method]
+		if !found {
+			result = true
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"ok": true, "result": result})
+	}))
+	t.Cleanup(api.server.Close)
+	return api
+}
+
+func (api *fakeBotAPI) client() *Client {
+	return NewClient(api.server.URL+"/", testToken)
+}
+
+func TestGetUpdates(t *testing.T) {
+	api := newFakeBotAPI(t)
+	api.results["getUpdates"] = []map[string]interface{}{
+		{
+			"update_id": 11,
+			"message": map[string]interface{}{
+				"message_id": 5,
+				"chat":       map[string]interface{}{"id": 42, "type": "private"},
+				"from":       map[string]interface{}{"id": 7, "username": "alice", "language_code": "en"},
+				"caption":    "lunch",
+				"photo": []map[string]interface{}{
+					{"file_id": "small", "width": 90, "height": 90},
+					{"file_id": "large", "width": 1280, "height": 1280, "file_size": 2048},
+				},
+			},
+		},
+		{"update_id": 12},
+	}
+
+	updates, err := api.client().GetUpdates(context.Background(), 10, 30*time.Second)
+	if err != nil {
+		t.Fatalf("GetUpdates: %v", err)
+	}
+
+	params := api.calls["getUpdates"][0]
+	if params["offset"] != float64(10) || params["timeout"] != float64(30) {
+		t.Errorf("getUpdates parameters = %v", params)
+	}
+	if allowed, _ := params["allowed_updates"].([]interface{}); len(allowed) != 1 || allowed[0] != "message" {
+		t.Errorf("allowed_updates = %v, want only messages", params["allowed_updates"])
+	}
+
+	if len(updates) != 2 || updates[0].UpdateID != 11 || updates[1].Message != nil {
+		t.Fatalf("updates = %+v", updates)
+	}
+	message := updates[0].Message
+	if message.Chat.ID != 42 || message.From.Username != "alice" || message.From.LanguageCode != "en" || message.Caption != "lunch" {
+		t.Errorf("message = %+v", message)
+	}
+	if len(message.Photo) != 2 || message.Photo[1].FileID != "large" || message.Photo[1].FileSize != 2048 {
+		t.Errorf("photo = %+v", message.Photo)
+	}
+}
+
+func TestSendMessageAndChatAction(t *testing.T) {
+	api := newFakeBotAPI(t)
+	client := api.client()
+
+	if err := client.SendMessage(context.Background(), 42, "Сахар 5,4 ммоль/л"); err != nil {
+		t.Fatalf("SendMessage: %v", err)
+	}
+	if err := client.SendChatAction(context.Background(), 42, "typing"); err != nil {
+		t.Fatalf("SendChatAction: %v", err)
+	}
+
+	if sent := api.calls["sendMessage"]; len(sent) != 1 || sent[0]["chat_id"] != float64(42) || sent[0]["text"] != "Сахар 5,4 ммоль/л" {
+		t.Errorf("sendMessage parameters = %v", sent)
+	}
+	if actions := api.calls["sendChatAction"]; len(actions) != 1 || actions[0]["action"] != "typing" {
+		t.Errorf("sendChatAction parameters = %v", actions)
+	}
+}
+
+func TestAPIError(t *testing.T) {
+	api := newFakeBotAPI(t)
+	api.errors["sendMessage"] = http.StatusForbidden
+
+	err := api.client().SendMessage(context.Background(), 42, "hello")
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("err = %v, want an APIError", err)
+	}
+	if apiErr.Code != http.StatusForbidden || apiErr.Description != "Forbidden" {
+		t.Errorf("APIError = %+v", apiErr)
+	}
+}
+
+func TestDownloadFile(t *testing.T) {
+	api := newFakeBotAPI(t)
+	api.results["getFile"] = map[string]interface{}{"file_id": "large", "file_size": 4, "file_path": "photos/file_1.jpg"}
+	api.files["photos/file_1.jpg"] = []byte("\xff\xd8\xff\xe0")
+
+	data, err := api.client().DownloadFile(context.Background(), "large")
+	if err != nil {
+		t.Fatalf("DownloadFile: %v", err)
+	}
+	if string(data) != "\xff\xd8\xff\xe0" {
+		t.Errorf("data = %q", data)
+	}
+	if params := api.calls["getFile"][0]; params["file_id"] != "large" {
+		t.Errorf("getFile parameters = %v", params)
+	}
+}
+
+func TestDownloadFileErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		file map[string]interface{}
+	}{
+		{name: "no path", file: map[string]interface{}{"file_id": "f"}},
+		{name: "too large", file: map[string]interface{}{"file_id": "f", "file_size": maxFileSize + 1, "file_path": "photos/big.jpg"}},
+		{name: "missing", file: map[string]interface{}{"file_id": "f", "file_path": "photos/missing.jpg"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := newFakeBotAPI(t)
+			api.results["getFile"] = tt.file
+			api.files["photos/big.jpg"] = []byte("big")
+
+			if _, err := api.client().DownloadFile(context.Background(), "f"); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestClientRedactsToken(t *testing.T) {
+	api := newFakeBotAPI(t)
+	client := api.client()
+	api.server.Close()
+
+	err := client.SendMessage(context.Background(), 42, "hello")
+	if err == nil {
+		t.Fatal("expected an error from a closed server")
+	}
+	if strings.Contains(err.Error(), testToken) {
+		t.Errorf("error leaks the bot token: %v", err)
+	}
+}
@@ -0,0 +1,178 @@
+package telegram
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/yourusername/diabetes-assistant/internal/models"
+)
+
+// helpText lists the bot's commands
+func helpText(locale string) string {
+	if locale == "en" {
+		return "Send a photo of your meal for a carb estimate and dose suggestion, optionally with the weight and a description in the caption, e.g. \"250 g chicken pilaf\".\n\n" +
+			"/bg 7.2 – log a glucose reading in mmol/L\n" +
+			"/settings – show your settings\n" +
+			"/link CODE – link this chat to your account (get the code in the app)\n" +
+			"/unlink – unlink this chat"
+	}
+	return "Пришлите фото еды, чтобы оценить углеводы и дозу инсулина. В подписи можно указать вес и описание, например «250 г плов с курицей».\n\n" +
+		"/bg 7.2 – записать сахар в ммоль/л\n" +
+		"/settings – показать настройки\n" +
+		"/link КОД – привязать чат к аккаунту (код есть в приложении)\n" +
+		"/unlink – отвязать чат"
+}
+
+func notLinkedText(locale string) string {
+	if locale == "en" {
+		return "This chat isn't linked to an account yet. Get a link code in the app and send /link CODE."
+	}
+	return "Этот чат ещё не привязан к аккаунту. Получите код в приложении и отправьте /link КОД."
+}
+
+func invalidCodeText(locale string) string {
+	if locale == "en" {
+		return "The link code is invalid or has expired. Get a new one in the app."
+	}
+	return "Код привязки неверный или устарел. Получите новый в приложении."
+}
+
+func linkedText(locale string) string {
+	if locale == "en" {
+		return "Chat linked. Alerts and reminders will arrive here too.\n\n" + helpText(locale)
+	}
+	return "Чат привязан. Оповещения и напоминания теперь будут приходить и сюда.\n\n" + helpText(locale)
+}
+
+func unlinkedText(locale string) string {
+	if locale == "en" {
+		return "Chat unlinked. Remove it from the notification channels in your settings to stop alerts."
+	}
+	return "Чат отвязан. Чтобы оповещения перестали приходить, удалите его из каналов уведомлений в настройках."
+}
+
+func bgUsageText(locale string) string {
+	if locale == "en" {
+		return "Send the reading in mmol/L, e.g. /bg 7.2"
+	}
+	return "Укажите сахар в ммоль/л, например /bg 7.2"
+}
+
+func savedReadingText(locale, status, arrow string) string {
+	if arrow != "" {
+		status += " " + arrow
+	}
+	if locale == "en" {
+		return "Saved: " + status
+	}
+	return "Записано: " + status
+}
+
+func unsupportedPhotoText(locale string) string {
+	if locale == "en" {
+		return "I can't read this image. Please send a JPEG or PNG photo."
+	}
+	return "Не удалось прочитать изображение. Пришлите фото в формате JPEG или PNG."
+}
+
+func quotaText(locale string) string {
+	if locale == "en" {
+		return "You've reached today's limit of photo analyses. Try again tomorrow."
+	}
+	return "Дневной лимит анализов фото исчерпан. Попробуйте завтра."
+}
+
+func failureText(locale string) string {
+	if locale == "en" {
+		return "Something went wrong, please try again later."
+	}
+	return "Что-то пошло не так, попробуйте позже."
+}
+
+// analysisText summarises a meal analysis: the carbs, the suggested dose and when to inject it
+func analysisText(locale string, result *models.MealAnalysis) string {
+	details := result.Analysis
+	var b strings.Builder
+
+	if locale == "en" {
+		fmt.Fprintf(&b, "%s\nCarbs: %.0f g", result.DetectedFood, result.Carbs)
+		if details.Weight > 0 {
+			fmt.Fprintf(&b, " in %.0f g", details.Weight)
+		}
+		fmt.Fprintf(&b, "\nDose: %.1f U (meal %.1f U, correction %.1f U)", result.InsulinDose, details.MealInsulin, details.CorrectionInsulin)
+		if bolus := details.ExtendedBolus; bolus != nil {
+			fmt.Fprintf(&b, "\nFat and protein: %.1f U now and %.1f U over %.0f h", bolus.ImmediateInsulin, bolus.ExtendedInsulin, bolus.DurationHours)
+		}
+	} else {
+		fmt.Fprintf(&b, "%s\nУглеводы: %.0f г", result.DetectedFood, result.Carbs)
+		if details.Weight > 0 {
+			fmt.Fprintf(&b, " на %.0f г", details.Weight)
+		}
+		fmt.Fprintf(&b, "\nДоза: %.1f ЕД (на еду %.1f ЕД, коррекция %.1f ЕД)", result.InsulinDose, details.MealInsulin, details.CorrectionInsulin)
+		if bolus := details.ExtendedBolus; bolus != nil {
+			fmt.Fprintf(&b, "\nЖиры и белки: %.1f ЕД сразу и %.1f ЕД в течение %.0f ч", bolus.ImmediateInsulin, bolus.ExtendedInsulin, bolus.DurationHours)
+		}
+	}
+
+	if details.PreBolus != nil && details.PreBolus.Message != "" {
+		b.WriteString("\n" + details.PreBolus.Message)
+	}
+	if details.DoseCheck != nil && details.DoseCheck.Message != "" {
+		b.WriteString("\n" + details.DoseCheck.Message)
+	}
+	if details.Reasoning != "" {
+		b.WriteString("\n\n" + details.Reasoning)
+	}
+	return b.String()
+}
+
+// settingsText summarises the settings in effect at the given time
+func settingsText(settings *models.Settings, at time.Time) string {
+	config := settings.AlertSettings()
+
+	channels := 0
+	for _, channel := range settings.NotificationChannels {
+		if channel.Enabled {
+			channels++
+		}
+	}
+
+	if settings.Locale == "en" {
+		text := fmt.Sprintf("Target: %.1f–%.1f mmol/L\n"+
+			"Insulin action: %.1f h\n"+
+			"Now: carb ratio %.1f g/U, sensitivity %.1f mmol/L per U, coefficient %.2f\n"+
+			"Fat-protein unit: %.0f g of carbs\n"+
+			"Alerts: low below %.1f, high above %.1f mmol/L\n"+
+			"Notification channels: %d",
+			settings.TargetMin, settings.TargetMax, settings.IOBDuration,
+			settings.CarbRatioAt(at), settings.SensitivityAt(at), settings.InsulinCoefficientAt(at),
+			fpuFactor(settings), config.Low.Threshold, config.High.Threshold, channels)
+		if config.QuietHours.Enabled {
+			text += fmt.Sprintf("\nQuiet hours: %s–%s", config.QuietHours.Start, config.QuietHours.End)
+		}
+		return text
+	}
+
+	text := fmt.Sprintf("Целевой сахар: %.1f–%.1f ммоль/л\n"+
+		"Время действия инсулина: %.1f ч\n"+
+		"Сейчас: углеводный коэффициент %.1f г/ЕД, чувствительность %.1f ммоль/л на ЕД, коэффициент %.2f\n"+
+		"Жиро-белковая единица: %.0f г углеводов\n"+
+		"Оповещения: низкий ниже %.1f, высокий выше %.1f ммоль/л\n"+
+		"Каналы уведомлений: %d",
+		settings.TargetMin, settings.TargetMax, settings.IOBDuration,
+		settings.CarbRatioAt(at), settings.SensitivityAt(at), settings.InsulinCoefficientAt(at),
+		fpuFactor(settings), config.Low.Threshold, config.High.Threshold, channels)
+	if config.QuietHours.Enabled {
+		text += fmt.Sprintf("\nТихие часы: %s–%s", config.QuietHours.Start, config.QuietHours.End)
+	}
+	return text
+}
+
+// fpuFactor returns the grams of carbohydrate dosed per fat-protein unit
+func fpuFactor(settings *models.Settings) float64 {
+	if settings.FPUFactor <= 0 {
+		return models.DefaultFPUFactor
+	}
+	return settings.FPUFactor
+}
@@ -618,6 +618,9 @@ function createDefaultSettings() {
         targetMin: 4.0,
         targetMax: 8.0,
         iobDuration: 4.0,
+        fpuFactor: 10,
+        absorptionTimes: { fast: 2, medium: 3, slow: 4 },
+        absorptionCurve: 'piecewise',
         insulinPeriods: [{
             startTime: '00:00',
             coefficient: 1.0,
@@ -636,6 +639,33 @@ function createDefaultSettings() {
     };
 }
 
+// Default alert rules, the same as on the server
+function createDefaultAlertSettings() {
+    return {
+        urgentLow: { enabled: true, threshold: 3.0, snoozeMinutes: 15 },
+        low: { enabled: true, threshold: 3.9, snoozeMinutes: 30 },
+        high: { enabled: true, threshold: 13.9, snoozeMinutes: 60 },
+        rapidRise: { enabled: true, threshold: 0.17, snoozeMinutes: 30 },
+        rapidFall: { enabled: true, threshold: 0.17, snoozeMinutes: 30 },
+        predictedLow: { enabled: true, threshold: 3.9, minutes: 20, snoozeMinutes: 30 },
+        staleData: { enabled: true, threshold: 0, minutes: 20, snoozeMinutes: 60 },
+        persistentHigh: { enabled: true, threshold: 10.0, minutes: 120, snoozeMinutes: 120 },
+        quietHours: { enabled: false, start: '22:00', end: '07:00' }
+    };
+}
+
+// Alert rules as loaded, the form only edits some of them
+let loadedAlertSettings = createDefaultAlertSettings();
+
+// Notification channels as loaded, they are set up through the API and kept on save
+let loadedNotificationChannels = [];
+
+// Automatic reminder settings as loaded, kept on save
+let loadedReminderSettings = null;
+
+// Language of AI responses and messages as loaded, kept on save
+let loadedLocale = '';
+
 function populateSettingsForm(settings) {
     if (!settings) {
         settings = createDefaultSettings();
@@ -645,6 +675,22 @@ function populateSettingsForm(settings) {
     document.getElementById('target-min').value = settings.targetMin || 4.0;
     document.getElementById('target-max').value = settings.targetMax || 8.0;
     document.getElementById('iob-duration').value = settings.iobDuration || 4.0;
+    document.getElementById('fpu-factor').value = settings.fpuFactor || 10;
+    const absorption = settings.absorptionTimes || {};
+    document.getElementById('absorption-fast').value = absorption.fast || 2;
+    document.getElementById('absorption-medium').value = absorption.medium || 3;
+    document.getElementById('absorption-slow').value = absorption.slow || 4;
+    document.getElementById('absorption-curve').value = settings.absorptionCurve || 'piecewise';
+
+    loadedAlertSettings = settings.alerts || createDefaultAlertSettings();
+    loadedNotificationChannels = settings.notificationChannels || [];
+    loadedReminderSettings = settings.reminders || null;
+    loadedLocale = settings.locale || '';
+    document.getElementById('alert-low').value = loadedAlertSettings.low.threshold;
+    document.getElementById('alert-high').value = loadedAlertSettings.high.threshold;
+    document.getElementById('quiet-hours-enabled').checked = loadedAlertSettings.quietHours.enabled;
+    document.getElementById('quiet-hours-start').value = loadedAlertSettings.quietHours.start || '22:00';
+    document.getElementById('quiet-hours-end').value = loadedAlertSettings.quietHours.end || '07:00';
     
     // Clear existing periods
     document.getElementById('insulin-coefficients-container').innerHTML = '';
@@ -708,6 +754,17 @@ function saveSettings(event) {
         targetMin: parseFloat(document.getElementById('target-min').value),
         targetMax: parseFloat(document.getElementById('target-max').value),
         iobDuration: parseFloat(document.getElementById('iob-duration').value),
+        fpuFactor: parseFloat(document.getElementById('fpu-factor').value),
+        absorptionTimes: {
+            fast: parseFloat(document.getElementById('absorption-fast').value) || 0,
+            medium: parseFloat(document.getElementById('absorption-medium').value) || 0,
+            slow: parseFloat(document.getElementById('absorption-slow').value) || 0
+        },
+        absorptionCurve: document.getElementById('absorption-curve').value,
+        alerts: collectAlertSettings(),
+        notificationChannels: loadedNotificationChannels,
+        reminders: loadedReminderSettings,
+        locale: loadedLocale,
         insulinPeriods: collectPeriods('insulin-coefficients-container', 'coefficient'),
         sensitivityPeriods: collectPeriods('insulin-sensitivity-container', 'sensitivity'),
         carbRatioPeriods: collectPeriods('carb-ratio-container', 'ratio')
@@ -740,6 +797,19 @@ function saveSettings(event) {
     });
 }
 
+// Applies the alert fields of the form to the loaded alert rules
+function collectAlertSettings() {
+    const alerts = JSON.parse(JSON.stringify(loadedAlertSettings));
+    alerts.low.threshold = parseFloat(document.getElementById('alert-low').value) || alerts.low.threshold;
+    alerts.high.threshold = parseFloat(document.getElementById('alert-high').value) || alerts.high.threshold;
+    alerts.quietHours = {
+        enabled: document.getElementById('quiet-hours-enabled').checked,
+        start: document.getElementById('quiet-hours-start').value || '22:00',
+        end: document.getElementById('quiet-hours-end').value || '07:00'
+    };
+    return alerts;
+}
+
 function collectPeriods(containerId, valueField) {
     const container = document.getElementById(containerId);
     if (!container) return [];
@@ -836,6 +906,19 @@ function saveBloodSugar(inputId) {
         
         // Показываем сообщение об успехе
         showAlert('Значение сахара крови успешно сохранено', 'success');
+
+        // При гипогликемии подсказываем, сколько быстрых углеводов съесть
+        if (data.treatment) {
+            showAlert(`${data.treatment.message}
+                <button type="button" class="btn btn-sm btn-warning ms-2" onclick="confirmHypoTreatment('${data.treatment.id}')">Съел(а)</button>`, 'warning');
+        }
+
+        // Оповещения, сработавшие на это значение (кроме тихих часов)
+        const delivered = (data.alerts || []).filter(alert => !alert.silenced);
+        if (delivered.length > 0 && !data.treatment) {
+            const urgent = delivered.some(alert => alert.severity === 'urgent');
+            showAlert(delivered.map(alert => alert.message).join('<br>'), urgent ? 'danger' : 'warning');
+        }
         
         // Обновляем список и график
         loadBloodSugarReadings();
@@ -846,6 +929,32 @@ function saveBloodSugar(inputId) {
     });
 }
 
+/**
+ * Confirms that the suggested hypo treatment was eaten, the server logs it as a meal
+ */
+function confirmHypoTreatment(treatmentId) {
+    fetch(`${API_BASE_URL}/hypo/${currentUserId}/${treatmentId}/confirm`, {
+        method: 'POST',
+        headers: {
+            'Content-Type': 'application/json'
+        },
+        body: JSON.stringify({})
+    })
+    .then(response => {
+        if (!response.ok) {
+            throw new Error('Ошибка сети или сервера');
+        }
+        return response.json();
+    })
+    .then(data => {
+        showAlert(`Записано: ${data.meal.carbs.toFixed(0)} г быстрых углеводов. Проверьте сахар через 15 минут.`, 'success');
+    })
+    .catch(error => {
+        console.error('Ошибка при подтверждении:', error);
+        showAlert('Ошибка при подтверждении: ' + error.message, 'danger');
+    });
+}
+
 /**
  * Attempts to restart the server connection
  */
@@ -972,7 +1081,7 @@ function displayBloodSugarReadings(readings) {
                 <span class="timestamp">${formattedDate}</span>
                 <span class="source badge bg-secondary">${reading.source || 'Ручной ввод'}</span>
             </div>
-            <span class="value ${valueColorClass}">${reading.value.toFixed(1)} ммоль/л</span>
+            <span class="value ${valueColorClass}">${reading.value.toFixed(1)} ммоль/л ${trendArrow(reading.trend)}</span>
         `;
         
         // Добавляем кнопку удаления
@@ -1168,9 +1277,10 @@ function analyzeFood(e) {
         }
         return response.json();
     })
-    .then(data => {
+    .then(data => waitForAnalysisJob(data.jobId))
+    .then(result => {
         // Handle successful response
-        handleAnalysisResponse(data, foodPhoto, "", foodWeight);
+        handleAnalysisResponse({ success: true, ...result }, foodPhoto, "", foodWeight);
     })
     .catch(error => {
         // Handle error
@@ -1178,6 +1288,47 @@ function analyzeFood(e) {
     });
 }
 
+// Poll an analysis job until it is done, the analysis runs on the server in the background
+function waitForAnalysisJob(jobId) {
+    return new Promise((resolve, reject) => {
+        const poll = () => {
+            fetch(`${API_BASE_URL}/jobs/${jobId}`)
+                .then(response => {
+                    if (!response.ok) {
+                        throw new Error(`HTTP error! status: ${response.status}`);
+                    }
+                    return response.json();
+                })
+                .then(job => {
+                    if (job.status === 'done') {
+                        resolve(job.result);
+                    } else if (job.status === 'failed') {
+                        reject(new Error(job.error));
+                    } else {
+                        setTimeout(poll, 1000);
+                    }
+                })
+                .catch(reject);
+        };
+        poll();
+    });
+}
+
+// Стрелки тренда сахара, как в Dexcom и Nightscout
+const TREND_ARROWS = {
+    DoubleUp: '⇈',
+    SingleUp: '↑',
+    FortyFiveUp: '↗',
+    Flat: '→',
+    FortyFiveDown: '↘',
+    SingleDown: '↓',
+    DoubleDown: '⇊'
+};
+
+function trendArrow(trend) {
+    return TREND_ARROWS[trend] || '';
+}
+
 function handleAnalysisResponse(response, foodPhoto, foodInput, foodWeight) {
     const analysisContent = document.getElementById('analysis-content');
     let html = '';
@@ -1217,7 +1368,7 @@ function handleAnalysisResponse(response, foodPhoto, foodInput, foodWeight) {
                     </div>
                     <div class="d-flex justify-content-between mb-2">
                         <span>Уверенность:</span>
-                        <strong>${analysis.confidence}</strong>
+                        <strong>${Math.round(analysis.confidence * 100)}%</strong>
                     </div>`;
         
         // Add weight if provided
@@ -1259,6 +1410,43 @@ function handleAnalysisResponse(response, foodPhoto, foodInput, foodWeight) {
                 </div>
             </div>`;
         
+        // When to inject relative to the meal
+        if (analysis.preBolus) {
+            const alertType = analysis.preBolus.action === 'after_meal' ? 'warning' : 'info';
+            html += `<div class="alert alert-${alertType} mt-3 mb-0">
+                        <strong>Когда колоть:</strong> ${analysis.preBolus.message}
+                    </div>`;
+        }
+
+        // Predicted outcome of the suggested dose
+        if (analysis.doseCheck) {
+            const alertType = { low: 'danger', high: 'warning' }[analysis.doseCheck.status] || 'success';
+            html += `<div class="alert alert-${alertType} mt-3 mb-0">
+                        <strong>Прогноз:</strong> ${analysis.doseCheck.message}
+                    </div>`;
+        }
+
+        // Dual-wave suggestion for meals with a lot of fat and protein
+        const extended = analysis.extendedBolus;
+        if (extended) {
+            html += `<div class="mt-3 pt-3 border-top">
+                        <h5>Растянутый болюс (${extended.fpu.toFixed(1)} ЖБЕ):</h5>
+                        <p class="text-muted small">Жиры ${analysis.fat.toFixed(0)} г и белки ${analysis.protein.toFixed(0)} г повысят сахар через несколько часов</p>
+                        <div class="d-flex justify-content-between mb-2">
+                            <span>Сейчас (${extended.immediatePercent}%):</span>
+                            <span>${extended.immediateInsulin.toFixed(1)} ед.</span>
+                        </div>
+                        <div class="d-flex justify-content-between mb-2">
+                            <span>Растянуть на ${extended.durationHours} ч:</span>
+                            <span>${extended.extendedInsulin.toFixed(1)} ед.</span>
+                        </div>
+                        <div class="d-flex justify-content-between fw-bold">
+                            <span>Всего:</span>
+                            <span>${extended.totalInsulin.toFixed(1)} ед.</span>
+                        </div>
+                    </div>`;
+        }
+        
     } else {
         html = `<div class="alert alert-danger">Ошибка анализа: ${response.error || 'Неизвестная ошибка'}</div>`;
     }